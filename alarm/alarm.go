@@ -0,0 +1,480 @@
+// Package alarm 实现闹钟：一次性和按 cron 规则重复的闹钟，保存到磁盘，重启后继续生效
+package alarm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 闹钟参数
+const (
+	DefaultSnooze      = 9 * time.Minute
+	DefaultRingTimeout = 10 * time.Minute // 无人响应时响铃多久后自动停止
+	checkInterval      = time.Second
+	missedGrace        = 10 * time.Minute // 启动时补响该时长内错过的闹钟
+)
+
+// ErrNotRinging 没有正在响铃或贪睡中的闹钟
+var ErrNotRinging = errors.New("no alarm is ringing")
+
+// Alarm 一个闹钟：At 和 Repeat 二选一
+type Alarm struct {
+	ID      int       `json:"id"`
+	Label   string    `json:"label,omitempty"`
+	At      time.Time `json:"at"`               // 一次性闹钟的响铃时间
+	Repeat  string    `json:"repeat,omitempty"` // 重复闹钟的 cron 表达式（分 时 日 月 周）
+	Song    string    `json:"song,omitempty"`   // 响铃时播放的歌曲，空表示使用默认铃声
+	Snooze  time.Time `json:"snooze"`           // 贪睡后再次响铃的时间
+	Created time.Time `json:"created"`
+}
+
+// Next 晚于 after 的下一次响铃时间，没有时返回零值
+// 贪睡中的闹钟只在贪睡结束时响，原定时间（已经响过）不再计入
+func (a Alarm) Next(after time.Time) time.Time {
+	if a.Snooze.After(after) {
+		return a.Snooze
+	}
+	if a.Repeat == "" {
+		if a.At.After(after) {
+			return a.At
+		}
+		return time.Time{}
+	}
+	if s, err := parseSchedule(a.Repeat); err == nil {
+		return s.next(after)
+	}
+	return time.Time{}
+}
+
+// Recurring 是否为重复闹钟
+func (a Alarm) Recurring() bool {
+	return a.Repeat != ""
+}
+
+// Manager 管理闹钟的保存和调度，到点时调用响铃回调
+type Manager struct {
+	path        string
+	ringTimeout time.Duration
+	logger      *slog.Logger
+
+	mu        sync.Mutex
+	alarms    []Alarm
+	nextID    int
+	checked   time.Time // 上次检查的时间，(checked, now] 内到点的闹钟响铃
+	ringing   int       // 正在响铃的闹钟 ID，0 表示没有
+	pending   []int     // 到点时另一个闹钟正在响铃而推迟的闹钟 ID，按到点顺序
+	ringTimer *time.Timer
+	onRing    func(Alarm)
+	onStop    func(Alarm)
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// DefaultPath 默认的闹钟文件位置：用户配置目录下的 xiaozhi/alarms.json
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "xiaozhi", "alarms.json")
+}
+
+// NewManager 创建闹钟管理器，path 为空时使用 DefaultPath
+func NewManager(path string, logger *slog.Logger) *Manager {
+	if path == "" {
+		path = DefaultPath()
+	}
+	return &Manager{
+		path:        path,
+		ringTimeout: DefaultRingTimeout,
+		logger:      logger,
+		nextID:      1,
+		done:        make(chan struct{}),
+	}
+}
+
+// SetRingTimeout 设置无人响应时的最长响铃时间，需在 Start 之前调用
+func (m *Manager) SetRingTimeout(d time.Duration) {
+	if d > 0 {
+		m.ringTimeout = d
+	}
+}
+
+// Start 读取保存的闹钟并开始调度；onRing 在闹钟响起时调用，onStop 在响铃停止（贪睡、关闭或超时）时调用
+// 启动前 missedGrace 内错过的闹钟会补响，更早错过的一次性闹钟被删除；闹钟文件损坏时从空列表开始
+func (m *Manager) Start(onRing, onStop func(Alarm)) {
+	m.mu.Lock()
+	if err := m.load(); err != nil {
+		m.logger.Warn("Failed to load alarms, starting empty", "path", m.path, "error", err)
+	}
+	m.onRing, m.onStop = onRing, onStop
+	m.checked = time.Now().Add(-missedGrace)
+	count := len(m.alarms)
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go m.loop()
+	m.logger.Info("Alarm scheduler started", "alarms", count, "path", m.path)
+}
+
+// Close 停止调度
+func (m *Manager) Close() {
+	select {
+	case <-m.done:
+		return
+	default:
+	}
+	close(m.done)
+	m.wg.Wait()
+
+	m.mu.Lock()
+	if m.ringTimer != nil {
+		m.ringTimer.Stop()
+		m.ringTimer = nil
+	}
+	m.mu.Unlock()
+}
+
+// Add 添加闹钟，返回分配了 ID 的闹钟
+func (m *Manager) Add(a Alarm) (Alarm, error) {
+	now := time.Now()
+	if a.Repeat != "" {
+		if _, err := parseSchedule(a.Repeat); err != nil {
+			return Alarm{}, err
+		}
+		a.At = time.Time{}
+	} else if !a.At.After(now) {
+		return Alarm{}, fmt.Errorf("alarm time %s is in the past", a.At.Format("2006-01-02 15:04"))
+	}
+	if a.Next(now).IsZero() {
+		return Alarm{}, fmt.Errorf("alarm %q never rings", a.Repeat)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a.ID = m.nextID
+	a.Snooze = time.Time{}
+	a.Created = now
+	m.nextID++
+	m.alarms = append(m.alarms, a)
+	m.saveLocked()
+	m.logger.Info("Alarm added", "id", a.ID, "at", a.At, "repeat", a.Repeat, "label", a.Label)
+	return a, nil
+}
+
+// List 所有闹钟，按下一次响铃时间排序
+func (m *Manager) List() []Alarm {
+	m.mu.Lock()
+	alarms := make([]Alarm, len(m.alarms))
+	copy(alarms, m.alarms)
+	m.mu.Unlock()
+
+	now := time.Now()
+	sort.SliceStable(alarms, func(i, j int) bool {
+		return alarms[i].Next(now).Before(alarms[j].Next(now))
+	})
+	return alarms
+}
+
+// Cancel 删除闹钟，正在响铃时同时停止响铃
+func (m *Manager) Cancel(id int) (Alarm, error) {
+	m.mu.Lock()
+	i := m.indexLocked(id)
+	if i < 0 {
+		m.mu.Unlock()
+		return Alarm{}, fmt.Errorf("alarm %d not found", id)
+	}
+	a := m.alarms[i]
+	m.alarms = append(m.alarms[:i], m.alarms[i+1:]...)
+	stopped := m.stopRingingLocked(id)
+	m.saveLocked()
+	m.mu.Unlock()
+
+	m.logger.Info("Alarm canceled", "id", id)
+	if stopped {
+		m.notifyStop(a)
+	}
+	return a, nil
+}
+
+// Ringing 正在响铃的闹钟
+func (m *Manager) Ringing() (Alarm, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := m.indexLocked(m.ringing); i >= 0 {
+		return m.alarms[i], true
+	}
+	return Alarm{}, false
+}
+
+// Snooze 停止响铃，d 之后再响
+func (m *Manager) Snooze(d time.Duration) (Alarm, error) {
+	if d <= 0 {
+		d = DefaultSnooze
+	}
+	m.mu.Lock()
+	i := m.indexLocked(m.ringing)
+	if i < 0 {
+		m.mu.Unlock()
+		return Alarm{}, ErrNotRinging
+	}
+	m.stopRingingLocked(m.ringing)
+	m.alarms[i].Snooze = time.Now().Add(d).Truncate(time.Second)
+	a := m.alarms[i]
+	m.saveLocked()
+	m.mu.Unlock()
+
+	m.logger.Info("Alarm snoozed", "id", a.ID, "until", a.Snooze)
+	m.notifyStop(a)
+	return a, nil
+}
+
+// Dismiss 关闭正在响铃的闹钟；没有响铃时取消贪睡中的闹钟
+// 一次性闹钟关闭后删除，重复闹钟等待下一次
+func (m *Manager) Dismiss() (Alarm, error) {
+	m.mu.Lock()
+	i := m.indexLocked(m.ringing)
+	if i < 0 {
+		now := time.Now()
+		for j, a := range m.alarms {
+			if a.Snooze.After(now) {
+				i = j
+				break
+			}
+		}
+	}
+	if i < 0 {
+		m.mu.Unlock()
+		return Alarm{}, ErrNotRinging
+	}
+	a := m.alarms[i]
+	stopped := m.stopRingingLocked(a.ID)
+	m.finishLocked(i)
+	m.saveLocked()
+	m.mu.Unlock()
+
+	m.logger.Info("Alarm dismissed", "id", a.ID)
+	if stopped {
+		m.notifyStop(a)
+	}
+	return a, nil
+}
+
+// finishLocked 结束一次响铃：清除贪睡，一次性闹钟被删除
+func (m *Manager) finishLocked(i int) {
+	if m.alarms[i].Recurring() {
+		m.alarms[i].Snooze = time.Time{}
+		return
+	}
+	m.alarms = append(m.alarms[:i], m.alarms[i+1:]...)
+}
+
+// stopRingingLocked 如果 id 正在响铃则清除响铃状态，返回是否清除
+func (m *Manager) stopRingingLocked(id int) bool {
+	if id == 0 || m.ringing != id {
+		return false
+	}
+	m.ringing = 0
+	if m.ringTimer != nil {
+		m.ringTimer.Stop()
+		m.ringTimer = nil
+	}
+	return true
+}
+
+func (m *Manager) notifyStop(a Alarm) {
+	if m.onStop != nil {
+		m.onStop(a)
+	}
+}
+
+func (m *Manager) indexLocked(id int) int {
+	if id == 0 {
+		return -1
+	}
+	for i, a := range m.alarms {
+		if a.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// loop 每秒检查一次到点的闹钟
+func (m *Manager) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	m.check()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check 响起 (checked, now] 内到点的闹钟，删除不会再响的一次性闹钟
+// 到点时另一个闹钟正在响铃则推迟，等它停止后再响
+func (m *Manager) check() {
+	now := time.Now()
+	m.mu.Lock()
+	from := m.checked
+	if gap := now.Sub(from); gap < 0 || gap > 2*missedGrace {
+		// 两次检查的间隔异常说明系统时间被调整（如开机后网络对时），跳过期间的闹钟
+		m.logger.Info("System clock changed, skipping missed alarms", "from", from, "to", now)
+		from = now
+	}
+	m.checked = now
+
+	var ring *Alarm
+	if m.ringing == 0 {
+		// 先响之前被推迟的闹钟，已删除的跳过
+		for ring == nil && len(m.pending) > 0 {
+			if i := m.indexLocked(m.pending[0]); i >= 0 {
+				ring = &m.alarms[i]
+			}
+			m.pending = m.pending[1:]
+		}
+	}
+	changed := false
+	for i := range m.alarms {
+		a := &m.alarms[i]
+		next := a.Next(from)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+		if !a.Snooze.IsZero() && !a.Snooze.After(now) {
+			a.Snooze = time.Time{}
+			changed = true
+		}
+		switch {
+		case ring == nil && m.ringing == 0:
+			ring = a
+		case a.ID == m.ringing || (ring != nil && a.ID == ring.ID) || slices.Contains(m.pending, a.ID):
+			// 已在响铃或等待中
+		default:
+			m.logger.Info("Another alarm is ringing, deferring", "id", a.ID)
+			m.pending = append(m.pending, a.ID)
+		}
+	}
+
+	if ring != nil {
+		m.ringing = ring.ID
+		id := ring.ID
+		m.ringTimer = time.AfterFunc(m.ringTimeout, func() { m.timeout(id) })
+	}
+
+	// 删除不会再响的一次性闹钟（错过或已响过且未贪睡），推迟的闹钟保留到响过为止
+	kept := m.alarms[:0]
+	for _, a := range m.alarms {
+		if a.ID != m.ringing && !slices.Contains(m.pending, a.ID) && a.Next(now).IsZero() {
+			m.logger.Info("Removing expired alarm", "id", a.ID, "at", a.At)
+			changed = true
+			continue
+		}
+		kept = append(kept, a)
+	}
+	m.alarms = kept
+
+	var ringing Alarm
+	if ring != nil {
+		ringing = m.alarms[m.indexLocked(m.ringing)]
+	}
+	if changed {
+		m.saveLocked()
+	}
+	onRing := m.onRing
+	m.mu.Unlock()
+
+	if ring != nil {
+		m.logger.Info("Alarm ringing", "id", ringing.ID, "label", ringing.Label)
+		if onRing != nil {
+			onRing(ringing)
+		}
+	}
+}
+
+// timeout 响铃超时无人响应，停止响铃
+func (m *Manager) timeout(id int) {
+	m.mu.Lock()
+	i := m.indexLocked(id)
+	if m.ringing != id || i < 0 {
+		m.mu.Unlock()
+		return
+	}
+	a := m.alarms[i]
+	m.stopRingingLocked(id)
+	m.finishLocked(i)
+	m.saveLocked()
+	m.mu.Unlock()
+
+	m.logger.Info("Alarm ring timed out", "id", id)
+	m.notifyStop(a)
+}
+
+// alarmFile 闹钟文件格式
+type alarmFile struct {
+	NextID int     `json:"next_id"`
+	Alarms []Alarm `json:"alarms"`
+}
+
+// load 读取闹钟文件，文件不存在时从空开始
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read alarms: %w", err)
+	}
+	var f alarmFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("invalid alarms file: %w", err)
+	}
+	m.alarms = f.Alarms
+	m.nextID = f.NextID
+	for _, a := range m.alarms {
+		if a.ID >= m.nextID {
+			m.nextID = a.ID + 1
+		}
+	}
+	if m.nextID < 1 {
+		m.nextID = 1
+	}
+	return nil
+}
+
+// saveLocked 原子地写入闹钟文件，失败时只记录日志
+func (m *Manager) saveLocked() {
+	if err := m.save(); err != nil {
+		m.logger.Warn("Failed to save alarms", "path", m.path, "error", err)
+	}
+}
+
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(alarmFile{NextID: m.nextID, Alarms: m.alarms}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
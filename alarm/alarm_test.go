@@ -0,0 +1,238 @@
+package alarm
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeAlarms 直接写入闹钟文件，用于构造过去的闹钟
+func writeAlarms(t *testing.T, path string, alarms ...Alarm) {
+	t.Helper()
+	data, err := json.Marshal(alarmFile{NextID: len(alarms) + 1, Alarms: alarms})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startManager 启动管理器，响铃和停止事件写入返回的 channel
+func startManager(t *testing.T, path string) (*Manager, chan Alarm, chan Alarm) {
+	t.Helper()
+	rings, stops := make(chan Alarm, 8), make(chan Alarm, 8)
+	m := NewManager(path, slog.New(slog.DiscardHandler))
+	m.Start(func(a Alarm) { rings <- a }, func(a Alarm) { stops <- a })
+	t.Cleanup(m.Close)
+	return m, rings, stops
+}
+
+func receive(t *testing.T, ch chan Alarm, what string) Alarm {
+	t.Helper()
+	select {
+	case a := <-ch:
+		return a
+	case <-time.After(3 * checkInterval):
+		t.Fatalf("timed out waiting for %s", what)
+		return Alarm{}
+	}
+}
+
+func expectNone(t *testing.T, ch chan Alarm, what string) {
+	t.Helper()
+	select {
+	case a := <-ch:
+		t.Fatalf("unexpected %s: %+v", what, a)
+	case <-time.After(2 * checkInterval):
+	}
+}
+
+func ids(alarms []Alarm) []int {
+	var out []int
+	for _, a := range alarms {
+		out = append(out, a.ID)
+	}
+	return out
+}
+
+func TestMissedAlarmGrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.json")
+	now := time.Now()
+	writeAlarms(t, path,
+		Alarm{ID: 1, Label: "错过太久", At: now.Add(-missedGrace - time.Minute)},
+		Alarm{ID: 2, Label: "刚错过", At: now.Add(-time.Minute)},
+		Alarm{ID: 3, Label: "稍后", At: now.Add(time.Hour)},
+	)
+
+	m, rings, _ := startManager(t, path)
+	if a := receive(t, rings, "missed alarm"); a.ID != 2 {
+		t.Errorf("rang alarm %d, want 2", a.ID)
+	}
+	if a, ok := m.Ringing(); !ok || a.ID != 2 {
+		t.Errorf("Ringing = %+v, %v", a, ok)
+	}
+	// 超出宽限期的一次性闹钟被删除，正在响铃的保留到关闭为止
+	if got := ids(m.List()); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("alarms = %v, want [2 3]", got)
+	}
+
+	if _, err := m.Dismiss(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(m.List()); len(got) != 1 || got[0] != 3 {
+		t.Errorf("alarms after dismiss = %v, want [3]", got)
+	}
+	if _, err := m.Dismiss(); err != ErrNotRinging {
+		t.Errorf("second Dismiss = %v, want ErrNotRinging", err)
+	}
+}
+
+func TestSnoozePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.json")
+	writeAlarms(t, path, Alarm{ID: 1, Label: "起床", At: time.Now().Add(-time.Minute)})
+
+	m, rings, stops := startManager(t, path)
+	receive(t, rings, "alarm")
+	a, err := m.Snooze(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receive(t, stops, "stop after snooze")
+	if _, ok := m.Ringing(); ok {
+		t.Error("alarm still ringing after snooze")
+	}
+	m.Close()
+
+	// 重启后贪睡仍然有效，一次性闹钟不会因为原时间已过而被删除
+	m2, rings2, _ := startManager(t, path)
+	expectNone(t, rings2, "ring after restart")
+	alarms := m2.List()
+	if len(alarms) != 1 || !alarms[0].Snooze.Equal(a.Snooze) {
+		t.Fatalf("alarms after restart = %+v, want snooze %v", alarms, a.Snooze)
+	}
+	if next := alarms[0].Next(time.Now()); !next.Equal(a.Snooze) {
+		t.Errorf("Next = %v, want %v", next, a.Snooze)
+	}
+
+	// 没有响铃时 Dismiss 取消贪睡
+	if _, err := m2.Dismiss(); err != nil {
+		t.Fatal(err)
+	}
+	if alarms := m2.List(); len(alarms) != 0 {
+		t.Errorf("alarms after dismissing snooze = %+v", alarms)
+	}
+}
+
+func TestAlarmDeferredWhileRinging(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.json")
+	now := time.Now()
+	writeAlarms(t, path,
+		Alarm{ID: 1, Label: "第一个", At: now.Add(-2 * time.Minute)},
+		Alarm{ID: 2, Label: "第二个", At: now.Add(-time.Minute)},
+	)
+
+	m, rings, stops := startManager(t, path)
+	if a := receive(t, rings, "first alarm"); a.ID != 1 {
+		t.Fatalf("rang alarm %d, want 1", a.ID)
+	}
+	// 第二个闹钟到点时第一个正在响铃：保留，不删除
+	expectNone(t, rings, "second alarm while first rings")
+	if got := ids(m.List()); len(got) != 2 {
+		t.Fatalf("alarms while first rings = %v, want both", got)
+	}
+
+	if _, err := m.Dismiss(); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, stops, "stop of first alarm")
+	if a := receive(t, rings, "deferred alarm"); a.ID != 2 {
+		t.Errorf("rang alarm %d, want 2", a.ID)
+	}
+	if _, err := m.Dismiss(); err != nil {
+		t.Fatal(err)
+	}
+	if alarms := m.List(); len(alarms) != 0 {
+		t.Errorf("alarms after dismissing both = %+v", alarms)
+	}
+}
+
+func TestDeferredAlarmCanceled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.json")
+	now := time.Now()
+	writeAlarms(t, path,
+		Alarm{ID: 1, At: now.Add(-2 * time.Minute)},
+		Alarm{ID: 2, At: now.Add(-time.Minute)},
+	)
+
+	m, rings, _ := startManager(t, path)
+	receive(t, rings, "first alarm")
+	expectNone(t, rings, "second alarm while first rings")
+	if _, err := m.Cancel(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Dismiss(); err != nil {
+		t.Fatal(err)
+	}
+	expectNone(t, rings, "canceled deferred alarm")
+}
+
+func TestRingTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alarms.json")
+	writeAlarms(t, path, Alarm{ID: 1, Repeat: "* * * * *"})
+
+	rings, stops := make(chan Alarm, 8), make(chan Alarm, 8)
+	m := NewManager(path, slog.New(slog.DiscardHandler))
+	m.SetRingTimeout(200 * time.Millisecond)
+	m.Start(func(a Alarm) { rings <- a }, func(a Alarm) { stops <- a })
+	defer m.Close()
+
+	// 每分钟重复的闹钟在启动时补响，超时后停止但不删除
+	receive(t, rings, "recurring alarm")
+	receive(t, stops, "timeout")
+	if _, ok := m.Ringing(); ok {
+		t.Error("alarm still ringing after timeout")
+	}
+	if alarms := m.List(); len(alarms) != 1 {
+		t.Errorf("recurring alarm removed after timeout: %+v", alarms)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	m := NewManager(filepath.Join(t.TempDir(), "alarms.json"), slog.New(slog.DiscardHandler))
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		alarm   Alarm
+		wantErr bool
+	}{
+		{"future one-shot", Alarm{At: now.Add(time.Hour)}, false},
+		{"recurring", Alarm{Repeat: "0 7 * * 1-5", At: now.Add(-time.Hour)}, false},
+		{"past", Alarm{At: now.Add(-time.Minute)}, true},
+		{"invalid repeat", Alarm{Repeat: "0 25 * * *"}, true},
+		{"never rings", Alarm{Repeat: "0 0 31 2 *"}, true},
+	}
+	for _, tt := range tests {
+		a, err := m.Add(tt.alarm)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (a.ID == 0 || a.Created.IsZero() || (a.Recurring() && !a.At.IsZero())) {
+			t.Errorf("%s: added %+v", tt.name, a)
+		}
+	}
+
+	// 分配的 ID 在重新加载后继续递增
+	m2 := NewManager(m.path, slog.New(slog.DiscardHandler))
+	if err := m2.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(m2.alarms) != 2 || m2.nextID != 3 {
+		t.Errorf("reloaded %d alarms, next id %d", len(m2.alarms), m2.nextID)
+	}
+}
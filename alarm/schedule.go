@@ -0,0 +1,207 @@
+package alarm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule 解析后的 cron 表达式（分 时 日 月 周），每个字段为允许值的位图
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool // 日和周为 * 时只按另一个字段匹配
+}
+
+// 各字段的取值范围
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7}, // 0 和 7 都表示周日
+}
+
+// parseSchedule 解析 5 字段的 cron 表达式，支持 *、数字、a-b 范围、逗号列表和 /n 步长
+func parseSchedule(expr string) (schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return schedule{}, fmt.Errorf("cron expression needs %d fields: %q", len(cronFields), expr)
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		f := cronFields[i]
+		b, err := parseField(field, f.min, f.max)
+		if err != nil {
+			return schedule{}, fmt.Errorf("invalid %s field %q: %w", f.name, field, err)
+		}
+		bits[i] = b
+	}
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+// parseField 解析单个字段为位图
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(first); err != nil {
+				return 0, fmt.Errorf("invalid value %q", first)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(last); err != nil {
+					return 0, fmt.Errorf("invalid value %q", last)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// matchDay 判断日期是否匹配：日和周都有限制时满足其一即可（与 cron 一致）
+func (s schedule) matchDay(t time.Time) bool {
+	if s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// next 晚于 after 的第一个匹配时间，一年内没有匹配时返回零值
+// 时间按 after 所在时区计算：夏令时跳过的时间在跳变后响铃，重复的时间只响第一次
+func (s schedule) next(after time.Time) time.Time {
+	start := after.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	for i := 0; i <= 366; i++ {
+		d := day.AddDate(0, 0, i)
+		if !s.matchDay(d) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if s.hour&(1<<uint(h)) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if s.minute&(1<<uint(m)) == 0 {
+					continue
+				}
+				t := time.Date(d.Year(), d.Month(), d.Day(), h, m, 0, 0, d.Location())
+				if t.Hour() != h || t.Minute() != m {
+					// 夏令时跳过的时间不存在，time.Date 会提前到跳变前，改为跳变后立即响铃
+					_, before := t.Zone()
+					_, after := t.Add(3 * time.Hour).Zone()
+					t = t.Add(time.Duration(after-before) * time.Second)
+				}
+				if !t.Before(start) {
+					return t
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// 重复规则关键字对应的星期字段
+var repeatDays = map[string]string{
+	"daily":    "*",
+	"everyday": "*",
+	"每天":       "*",
+	"weekdays": "1-5",
+	"工作日":      "1-5",
+	"weekends": "0,6",
+	"周末":       "0,6",
+}
+
+// 星期名称
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	"日": 0, "天": 0, "一": 1, "二": 2, "三": 3, "四": 4, "五": 5, "六": 6,
+}
+
+// ParseRepeat 把重复规则转换为 cron 表达式，hour/minute 为响铃时间
+// 支持 once（或空，返回空字符串表示一次性）、daily、weekdays、weekends、
+// 星期列表（如 "mon,wed,fri" 或 "周一,周三"）以及完整的 5 字段 cron 表达式（此时忽略 hour/minute）
+func ParseRepeat(repeat string, hour, minute int) (string, error) {
+	repeat = strings.ToLower(strings.TrimSpace(repeat))
+	switch repeat {
+	case "", "once", "一次":
+		return "", nil
+	}
+
+	if len(strings.Fields(repeat)) == len(cronFields) {
+		if _, err := parseSchedule(repeat); err != nil {
+			return "", err
+		}
+		return repeat, nil
+	}
+
+	dow, ok := repeatDays[repeat]
+	if !ok {
+		var days []string
+		for _, name := range strings.FieldsFunc(repeat, func(r rune) bool { return r == ',' || r == '，' || r == '、' || r == ' ' }) {
+			name = strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(name, "周"), "星期"), "礼拜")
+			if len(name) > 3 && name[0] < 0x80 {
+				name = name[:3] // monday -> mon
+			}
+			n, ok := dayNames[name]
+			if !ok {
+				return "", fmt.Errorf("unknown repeat rule %q", repeat)
+			}
+			days = append(days, strconv.Itoa(n))
+		}
+		if len(days) == 0 {
+			return "", fmt.Errorf("unknown repeat rule %q", repeat)
+		}
+		dow = strings.Join(days, ",")
+	}
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return "", fmt.Errorf("invalid time %02d:%02d", hour, minute)
+	}
+	return fmt.Sprintf("%d %d * * %s", minute, hour, dow), nil
+}
@@ -0,0 +1,166 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // 测试不依赖系统时区数据
+)
+
+// newYork 有夏令时的固定时区
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"0 7 * * *", false},
+		{"*/15 9-17 * * 1-5", false},
+		{"0 0 1,15 * *", false},
+		{"30 6 * 1-3,12 0,6", false},
+		{"0 8 * * 7", false},
+		{"5-59/10 * * * *", false},
+		{"0 7 * *", true},
+		{"0 7 * * * *", true},
+		{"60 7 * * *", true},
+		{"0 24 * * *", true},
+		{"0 0 0 * *", true},
+		{"0 0 * 13 *", true},
+		{"0 0 * * 8", true},
+		{"0 0 * * 5-1", true},
+		{"*/0 * * * *", true},
+		{"a * * * *", true},
+		{"1-x * * * *", true},
+	}
+	for _, tt := range tests {
+		_, err := parseSchedule(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSchedule(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+		}
+	}
+
+	// 周日可以写作 0 或 7
+	s, err := parseSchedule("0 8 * * 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.dow&1 == 0 {
+		t.Error("day of week 7 should match Sunday")
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	loc := newYork(t)
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, loc)
+	}
+	// 2026-10-16 是周五
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"later today", "0 7 * * *", at(2026, 10, 16, 6, 0), at(2026, 10, 16, 7, 0)},
+		{"exactly at time moves to tomorrow", "0 7 * * *", at(2026, 10, 16, 7, 0), at(2026, 10, 17, 7, 0)},
+		{"seconds are ignored", "0 7 * * *", at(2026, 10, 16, 6, 59).Add(59 * time.Second), at(2026, 10, 16, 7, 0)},
+		{"weekdays skip weekend", "0 7 * * 1-5", at(2026, 10, 16, 8, 0), at(2026, 10, 19, 7, 0)},
+		{"weekends", "30 9 * * 0,6", at(2026, 10, 16, 8, 0), at(2026, 10, 17, 9, 30)},
+		{"sunday as 7", "0 8 * * 7", at(2026, 10, 16, 8, 0), at(2026, 10, 18, 8, 0)},
+		{"step within hour", "*/20 * * * *", at(2026, 10, 16, 8, 41), at(2026, 10, 16, 9, 0)},
+		{"day of month", "0 0 1 * *", at(2026, 10, 16, 8, 0), at(2026, 11, 1, 0, 0)},
+		{"day of month or week", "0 9 13 * 5", at(2026, 10, 17, 0, 0), at(2026, 10, 23, 9, 0)},
+		{"month rolls over year", "0 0 1 1 *", at(2026, 10, 16, 8, 0), at(2027, 1, 1, 0, 0)},
+		{"leap day within a year", "0 0 29 2 *", at(2027, 6, 1, 0, 0), at(2028, 2, 29, 0, 0)},
+		{"never", "0 0 30 2 *", at(2026, 10, 16, 8, 0), time.Time{}},
+		// 夏令时开始：2026-03-08 02:00 跳到 03:00，02:30 不存在
+		{"spring forward gap", "30 2 * * *", at(2026, 3, 8, 0, 0), time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC)},
+		{"spring forward next day", "30 2 * * *", time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC).In(loc), at(2026, 3, 9, 2, 30)},
+		{"spring forward keeps wall clock", "0 7 * * *", at(2026, 3, 7, 8, 0), time.Date(2026, 3, 8, 11, 0, 0, 0, time.UTC)},
+		// 夏令时结束：2026-11-01 01:00-02:00 出现两次，只在第一次响
+		{"fall back first", "30 1 * * *", at(2026, 11, 1, 0, 0), time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC)},
+		{"fall back not twice", "30 1 * * *", time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC).In(loc), at(2026, 11, 2, 1, 30)},
+		{"fall back keeps wall clock", "0 7 * * *", at(2026, 10, 31, 8, 0), time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseSchedule(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.next(tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("next(%v) = %v, want %v", tt.after, got, tt.want.In(loc))
+			}
+		})
+	}
+}
+
+func TestAlarmNext(t *testing.T) {
+	loc := newYork(t)
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, loc)
+	tests := []struct {
+		name  string
+		alarm Alarm
+		want  time.Time
+	}{
+		{"one-shot", Alarm{At: now.Add(time.Hour)}, now.Add(time.Hour)},
+		{"one-shot passed", Alarm{At: now.Add(-time.Hour)}, time.Time{}},
+		{"one-shot snoozed", Alarm{At: now.Add(-time.Minute), Snooze: now.Add(9 * time.Minute)}, now.Add(9 * time.Minute)},
+		{"snooze passed", Alarm{At: now.Add(-time.Hour), Snooze: now.Add(-time.Minute)}, time.Time{}},
+		{"recurring", Alarm{Repeat: "0 9 * * *"}, now.Add(time.Hour)},
+		{"snooze before repeat", Alarm{Repeat: "0 9 * * *", Snooze: now.Add(5 * time.Minute)}, now.Add(5 * time.Minute)},
+		{"snooze hides earlier repeat", Alarm{Repeat: "5 8 * * *", Snooze: now.Add(9 * time.Minute)}, now.Add(9 * time.Minute)},
+		{"snooze hides one-shot time", Alarm{At: now.Add(time.Minute), Snooze: now.Add(9 * time.Minute)}, now.Add(9 * time.Minute)},
+		{"invalid repeat", Alarm{Repeat: "bad"}, time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := tt.alarm.Next(now); !got.Equal(tt.want) {
+			t.Errorf("%s: Next = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRepeat(t *testing.T) {
+	tests := []struct {
+		repeat       string
+		hour, minute int
+		want         string
+		wantErr      bool
+	}{
+		{"", 7, 30, "", false},
+		{"once", 7, 30, "", false},
+		{"一次", 7, 30, "", false},
+		{"daily", 7, 30, "30 7 * * *", false},
+		{" 每天 ", 6, 0, "0 6 * * *", false},
+		{"Weekdays", 7, 0, "0 7 * * 1-5", false},
+		{"工作日", 7, 0, "0 7 * * 1-5", false},
+		{"周末", 9, 15, "15 9 * * 0,6", false},
+		{"mon,wed,fri", 8, 0, "0 8 * * 1,3,5", false},
+		{"Monday, Friday", 8, 0, "0 8 * * 1,5", false},
+		{"周一、周三", 8, 0, "0 8 * * 1,3", false},
+		{"星期六，星期日", 10, 0, "0 10 * * 6,0", false},
+		{"礼拜天", 10, 0, "0 10 * * 0", false},
+		{"*/5 * * * *", 0, 0, "*/5 * * * *", false},
+		{"0 7 * * 1-5", 99, 99, "0 7 * * 1-5", false},
+		{"61 7 * * *", 0, 0, "", true},
+		{"sometimes", 7, 0, "", true},
+		{"mon,someday", 7, 0, "", true},
+		{",", 7, 0, "", true},
+		{"daily", 24, 0, "", true},
+		{"daily", 7, 60, "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRepeat(tt.repeat, tt.hour, tt.minute)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseRepeat(%q, %d, %d) = %q, %v; want %q, err %v", tt.repeat, tt.hour, tt.minute, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
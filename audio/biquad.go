@@ -0,0 +1,26 @@
+package audio
+
+// Biquad 二阶 IIR 滤波器（转置直接 II 型），系数已按 a0 归一化
+type Biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	z1, z2     float64
+}
+
+// NewBiquad 由未归一化的系数创建滤波器
+func NewBiquad(b0, b1, b2, a0, a1, a2 float64) Biquad {
+	return Biquad{b0: b0 / a0, b1: b1 / a0, b2: b2 / a0, a1: a1 / a0, a2: a2 / a0}
+}
+
+// Process 处理一个样本
+func (f *Biquad) Process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// Reset 清除滤波器状态
+func (f *Biquad) Reset() {
+	f.z1, f.z2 = 0, 0
+}
@@ -0,0 +1,77 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DecodeClip 将短音频（WAV 或 Ogg Opus）完整解码，并转换为指定的采样率和声道数
+func DecodeClip(r io.Reader, name string, sampleRate, channels int, logger *slog.Logger) ([]int16, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	var pcm []int16
+	var srcRate, srcChannels int
+
+	switch ext {
+	case ".wav":
+		wav, err := NewWAVReader(r)
+		if err != nil {
+			return nil, err
+		}
+		pcm, err = wav.ReadAllPCM()
+		if err != nil {
+			return nil, err
+		}
+		srcRate, srcChannels = wav.Format.SampleRate, wav.Format.Channels
+	case ".opus", ".ogg":
+		reader, err := NewOggOpusReader(r, sampleRate, logger)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		buf := make([]int16, 5760*reader.Channels)
+		for {
+			n, err := reader.Read(buf)
+			pcm = append(pcm, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		srcRate, srcChannels = reader.SampleRate, reader.Channels
+	default:
+		return nil, fmt.Errorf("unsupported clip format: %s", ext)
+	}
+
+	pcm = ConvertChannels(pcm, srcChannels, channels)
+	return Resample(pcm, srcRate, sampleRate, channels), nil
+}
+
+// PlayClip 将 PCM 按帧切分后送入音频管理器播放，返回音频时长
+// 播放器回调一次最多消费一个缓冲块，因此需要按帧大小提交
+func PlayClip(m Manager, pcm []int16, cfg Config) (time.Duration, error) {
+	frameSamples := cfg.SampleRate * cfg.FrameDuration / 1000 * cfg.Channels
+	if frameSamples <= 0 {
+		return 0, fmt.Errorf("invalid frame size: %d", frameSamples)
+	}
+
+	for start := 0; start < len(pcm); start += frameSamples {
+		end := start + frameSamples
+		if end > len(pcm) {
+			end = len(pcm)
+		}
+		if err := m.Play(pcm[start:end]); err != nil {
+			return 0, err
+		}
+	}
+
+	frames := len(pcm) / cfg.Channels
+	return time.Duration(frames) * time.Second / time.Duration(cfg.SampleRate), nil
+}
@@ -0,0 +1,69 @@
+package audio
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrDeviceStalled 设备在超时时间内没有回调
+	ErrDeviceStalled = errors.New("audio device stalled")
+	// ErrDeviceStopped 设备被意外停止（如 USB 声卡拔出）
+	ErrDeviceStopped = errors.New("audio device stopped unexpectedly")
+)
+
+// 默认设备停滞超时（毫秒）
+const defaultStallTimeout = 2000
+
+// DeviceKind 音频设备类型
+type DeviceKind string
+
+const (
+	DeviceCapture  DeviceKind = "capture"
+	DevicePlayback DeviceKind = "playback"
+)
+
+// DeviceEventType 音频设备事件类型
+type DeviceEventType string
+
+const (
+	DeviceEventLost     DeviceEventType = "lost"     // 设备出错或停滞，开始恢复
+	DeviceEventRetry    DeviceEventType = "retry"    // 重新打开设备失败，等待下一次重试
+	DeviceEventRestored DeviceEventType = "restored" // 设备已恢复
+)
+
+// DeviceEvent 音频设备事件
+type DeviceEvent struct {
+	Kind    DeviceKind
+	Type    DeviceEventType
+	Err     error
+	Attempt int
+	Time    time.Time
+}
+
+// DeviceHealth 音频设备健康状态
+type DeviceHealth string
+
+const (
+	DeviceHealthIdle       DeviceHealth = "idle"       // 未使用
+	DeviceHealthOK         DeviceHealth = "ok"         // 正常工作
+	DeviceHealthRecovering DeviceHealth = "recovering" // 故障，正在重试
+)
+
+// DeviceStatus 音频设备状态
+type DeviceStatus struct {
+	Capture         DeviceHealth
+	Playback        DeviceHealth
+	CaptureRetries  int
+	PlaybackRetries int
+	LastError       string
+	LastErrorTime   time.Time
+}
+
+// stallTimeout 返回配置的停滞超时
+func (c Config) stallTimeout() time.Duration {
+	if c.StallTimeout <= 0 {
+		return defaultStallTimeout * time.Millisecond
+	}
+	return time.Duration(c.StallTimeout) * time.Millisecond
+}
@@ -0,0 +1,64 @@
+package audio
+
+import (
+	"fmt"
+
+	"github.com/gen2brain/malgo"
+)
+
+// DeviceInfo 音频设备信息
+type DeviceInfo struct {
+	Kind      DeviceKind
+	Name      string
+	IsDefault bool
+}
+
+// ListDevices 枚举系统中的录音和播放设备
+func ListDevices() ([]DeviceInfo, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize audio context: %w", err)
+	}
+	defer func() {
+		_ = ctx.Uninit()
+		ctx.Free()
+	}()
+
+	var devices []DeviceInfo
+	for _, kind := range []DeviceKind{DeviceCapture, DevicePlayback} {
+		deviceType := malgo.Capture
+		if kind == DevicePlayback {
+			deviceType = malgo.Playback
+		}
+
+		infos, err := ctx.Devices(deviceType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s devices: %w", kind, err)
+		}
+		for _, info := range infos {
+			devices = append(devices, DeviceInfo{
+				Kind:      kind,
+				Name:      info.Name(),
+				IsDefault: info.IsDefault != 0,
+			})
+		}
+	}
+	return devices, nil
+}
+
+// DefaultDeviceName 返回指定类型的默认设备名称，未找到时返回空字符串
+func DefaultDeviceName(devices []DeviceInfo, kind DeviceKind) string {
+	first := ""
+	for _, d := range devices {
+		if d.Kind != kind {
+			continue
+		}
+		if d.IsDefault {
+			return d.Name
+		}
+		if first == "" {
+			first = d.Name
+		}
+	}
+	return first
+}
@@ -0,0 +1,249 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EQ 滤波器类型
+const (
+	EQPeaking   = "peaking"
+	EQLowShelf  = "lowshelf"
+	EQHighShelf = "highshelf"
+	EQLowPass   = "lowpass"
+	EQHighPass  = "highpass"
+)
+
+// EQBand 均衡器的一个频段
+type EQBand struct {
+	Type      string  `json:"type"`           // peaking/lowshelf/highshelf/lowpass/highpass
+	Frequency float64 `json:"frequency"`      // 中心/转折频率（Hz）
+	Gain      float64 `json:"gain,omitempty"` // 增益（dB），只用于 peaking 和搁架滤波器
+	Q         float64 `json:"q,omitempty"`    // 品质因数，为 0 时使用 0.707
+}
+
+// 均衡器参数
+const (
+	defaultEQQ    = 0.7071
+	maxEQGain     = 15.0 // 单个频段的最大增益（dB）
+	eqResponseLen = 256  // 计算预衰减时采样的频点数
+)
+
+// eqPresets 内置预设，针对小尺寸扬声器：去掉发不出的低频、压低箱体共振
+var eqPresets = map[string][]EQBand{
+	"flat": nil,
+	// 人声：切掉低频轰鸣，突出 2-4kHz 的清晰度
+	"voice": {
+		{Type: EQHighPass, Frequency: 150, Q: defaultEQQ},
+		{Type: EQPeaking, Frequency: 300, Gain: -3, Q: 1},
+		{Type: EQPeaking, Frequency: 3000, Gain: 3, Q: 1},
+		{Type: EQHighShelf, Frequency: 9000, Gain: -2, Q: defaultEQQ},
+	},
+	// 音乐：去掉共振和刺耳的高频，整体保持平衡
+	"music": {
+		{Type: EQHighPass, Frequency: 60, Q: defaultEQQ},
+		{Type: EQPeaking, Frequency: 220, Gain: -2.5, Q: 1.2},
+		{Type: EQPeaking, Frequency: 4000, Gain: -1.5, Q: 1.5},
+		{Type: EQHighShelf, Frequency: 10000, Gain: 1.5, Q: defaultEQQ},
+	},
+	// 夜间：减少低频和高频，避免打扰他人
+	"night": {
+		{Type: EQLowShelf, Frequency: 150, Gain: -8, Q: defaultEQQ},
+		{Type: EQPeaking, Frequency: 2500, Gain: 1, Q: 1},
+		{Type: EQHighShelf, Frequency: 6000, Gain: -4, Q: defaultEQQ},
+	},
+	// 低音增强：在扬声器还能发声的频段提升，更低的频率滤掉以免失真
+	"bass_boost": {
+		{Type: EQHighPass, Frequency: 50, Q: defaultEQQ},
+		{Type: EQLowShelf, Frequency: 120, Gain: 6, Q: defaultEQQ},
+		{Type: EQPeaking, Frequency: 350, Gain: -1.5, Q: 1},
+	},
+}
+
+// EQPresets 返回内置预设的名称
+func EQPresets() []string {
+	names := make([]string, 0, len(eqPresets))
+	for name := range eqPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EQPreset 返回预设的频段，兼容连字符写法（如 bass-boost）
+func EQPreset(name string) ([]EQBand, error) {
+	key := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+	bands, ok := eqPresets[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown EQ preset: %q", name)
+	}
+	return append([]EQBand(nil), bands...), nil
+}
+
+// validate 检查频段参数并填充默认值
+func (b *EQBand) validate() error {
+	b.Type = strings.ToLower(strings.TrimSpace(b.Type))
+	switch b.Type {
+	case EQPeaking, EQLowShelf, EQHighShelf, EQLowPass, EQHighPass:
+	case "":
+		b.Type = EQPeaking
+	default:
+		return fmt.Errorf("unknown EQ band type: %q", b.Type)
+	}
+	if b.Frequency < 20 || b.Frequency > 20000 {
+		return fmt.Errorf("EQ frequency must be between 20 and 20000 Hz: %v", b.Frequency)
+	}
+	if math.Abs(b.Gain) > maxEQGain {
+		return fmt.Errorf("EQ gain must be between -%v and %v dB: %v", maxEQGain, maxEQGain, b.Gain)
+	}
+	if b.Q == 0 {
+		b.Q = defaultEQQ
+	}
+	if b.Q < 0.1 || b.Q > 10 {
+		return fmt.Errorf("EQ Q must be between 0.1 and 10: %v", b.Q)
+	}
+	return nil
+}
+
+// design 按 RBJ Audio EQ Cookbook 计算滤波器系数，频率超过奈奎斯特频率时返回 false
+func (b EQBand) design(sampleRate int) (Biquad, bool) {
+	fs := float64(sampleRate)
+	if b.Frequency >= fs/2 {
+		return Biquad{}, false
+	}
+	w0 := 2 * math.Pi * b.Frequency / fs
+	cosw, alpha := math.Cos(w0), math.Sin(w0)/(2*b.Q)
+	a := math.Pow(10, b.Gain/40)
+	sa := 2 * math.Sqrt(a) * alpha
+
+	switch b.Type {
+	case EQLowShelf:
+		return NewBiquad(
+			a*((a+1)-(a-1)*cosw+sa), 2*a*((a-1)-(a+1)*cosw), a*((a+1)-(a-1)*cosw-sa),
+			(a+1)+(a-1)*cosw+sa, -2*((a-1)+(a+1)*cosw), (a+1)+(a-1)*cosw-sa), true
+	case EQHighShelf:
+		return NewBiquad(
+			a*((a+1)+(a-1)*cosw+sa), -2*a*((a-1)+(a+1)*cosw), a*((a+1)+(a-1)*cosw-sa),
+			(a+1)-(a-1)*cosw+sa, 2*((a-1)-(a+1)*cosw), (a+1)-(a-1)*cosw-sa), true
+	case EQLowPass:
+		return NewBiquad((1-cosw)/2, 1-cosw, (1-cosw)/2, 1+alpha, -2*cosw, 1-alpha), true
+	case EQHighPass:
+		return NewBiquad((1+cosw)/2, -(1 + cosw), (1+cosw)/2, 1+alpha, -2*cosw, 1-alpha), true
+	}
+	return NewBiquad(1+alpha*a, -2*cosw, 1-alpha*a, 1+alpha/a, -2*cosw, 1-alpha/a), true
+}
+
+// response 滤波器在频率 f 处的幅度响应
+func (f *Biquad) response(f0 float64, sampleRate int) float64 {
+	w := 2 * math.Pi * f0 / float64(sampleRate)
+	// H(z) = (b0 + b1 z^-1 + b2 z^-2) / (1 + a1 z^-1 + a2 z^-2)，z = e^{jw}
+	c1, s1, c2, s2 := math.Cos(w), math.Sin(w), math.Cos(2*w), math.Sin(2*w)
+	numRe, numIm := f.b0+f.b1*c1+f.b2*c2, -f.b1*s1-f.b2*s2
+	denRe, denIm := 1+f.a1*c1+f.a2*c2, -f.a1*s1-f.a2*s2
+	return math.Sqrt((numRe*numRe + numIm*numIm) / (denRe*denRe + denIm*denIm))
+}
+
+// Equalizer 多频段参数均衡器，可在播放过程中修改频段
+// 提升频段时自动预衰减，保证整体响应不超过 0dB，避免削波
+type Equalizer struct {
+	mu    sync.Mutex
+	bands []EQBand
+
+	// 按当前格式生成的滤波器，格式或频段变化时重建
+	sampleRate int
+	channels   int
+	filters    [][]Biquad // [声道][频段]
+	preamp     float64
+}
+
+// NewEqualizer 创建不做任何处理的均衡器
+func NewEqualizer() *Equalizer {
+	return &Equalizer{}
+}
+
+// SetBands 设置频段，为空时关闭均衡器
+func (e *Equalizer) SetBands(bands []EQBand) error {
+	valid := make([]EQBand, len(bands))
+	for i, b := range bands {
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("band %d: %w", i+1, err)
+		}
+		valid[i] = b
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bands = valid
+	e.filters = nil
+	return nil
+}
+
+// Bands 当前频段的副本
+func (e *Equalizer) Bands() []EQBand {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]EQBand(nil), e.bands...)
+}
+
+// Active 是否设置了频段
+func (e *Equalizer) Active() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.bands) > 0
+}
+
+// Process 原地处理交错 PCM
+func (e *Equalizer) Process(pcm []int16, sampleRate, channels int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.bands) == 0 || sampleRate <= 0 || channels <= 0 {
+		return
+	}
+	if e.filters == nil || e.sampleRate != sampleRate || e.channels != channels {
+		e.buildLocked(sampleRate, channels)
+	}
+
+	for i := 0; i+channels <= len(pcm); i += channels {
+		for ch := 0; ch < channels; ch++ {
+			x := float64(pcm[i+ch]) * e.preamp
+			for k := range e.filters[ch] {
+				x = e.filters[ch][k].Process(x)
+			}
+			pcm[i+ch] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, x)))
+		}
+	}
+}
+
+// buildLocked 为指定格式生成滤波器并计算预衰减
+func (e *Equalizer) buildLocked(sampleRate, channels int) {
+	var designed []Biquad
+	for _, b := range e.bands {
+		if f, ok := b.design(sampleRate); ok {
+			designed = append(designed, f)
+		}
+	}
+
+	// 在对数分布的频点上计算整体响应的最大值
+	peak := 1.0
+	nyquist := float64(sampleRate) / 2
+	for i := 0; i < eqResponseLen; i++ {
+		freq := 20 * math.Pow(nyquist/20, float64(i)/float64(eqResponseLen-1))
+		gain := 1.0
+		for k := range designed {
+			gain *= designed[k].response(math.Min(freq, nyquist*0.999), sampleRate)
+		}
+		peak = math.Max(peak, gain)
+	}
+
+	e.sampleRate = sampleRate
+	e.channels = channels
+	e.preamp = 1 / peak
+	e.filters = make([][]Biquad, channels)
+	for ch := range e.filters {
+		e.filters[ch] = append([]Biquad(nil), designed...)
+	}
+}
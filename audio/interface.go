@@ -39,6 +39,14 @@ type Manager interface {
 	Decode(opusData []byte) ([]int16, error)
 	Encode(pcm []int16) ([]byte, error)
 
+	// 设备状态
+	Events() <-chan DeviceEvent
+	DeviceStatus() DeviceStatus
+
+	// 电平监测
+	Levels() <-chan Level
+	LevelStats() LevelStats
+
 	// 生命周期管理
 	Close() error
 	Reinitialize() error
@@ -6,21 +6,42 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/utils"
 )
 
 // audioResourceManager 统一管理音频输入输出设备及编解码资源
 type audioResourceManager struct {
-	mu          sync.RWMutex
-	config      Config
-	logger      *slog.Logger
-	recorder    Recorder
-	player      AudioPlayer
-	decoder     *OpusDecoder
-	encoder     *OpusEncoder
-	isRecording bool
-	isPlaying   bool
-	closeChan   chan struct{}
-	closed      bool
+	mu           sync.RWMutex
+	config       Config
+	logger       *slog.Logger
+	recorder     Recorder
+	player       AudioPlayer
+	decoder      *OpusDecoder
+	encoder      *OpusEncoder
+	isRecording  bool
+	isPlaying    bool
+	recordCancel context.CancelFunc
+	closeChan    chan struct{}
+	closed       bool
+
+	// 设备故障检测与恢复
+	statusMu     sync.Mutex
+	status       DeviceStatus
+	events       chan DeviceEvent
+	eventsClosed bool
+
+	// 采集与播放电平
+	meter *levelMeter
+
+	// 语音播放的均衡器，由调用方持有，重新创建管理器后保留设置
+	eq *Equalizer
+}
+
+// activityReporter 由能够报告最后一次设备回调时间的播放器实现
+type activityReporter interface {
+	LastActivity() time.Time
 }
 
 // NewManager 创建新的音频管理器
@@ -33,6 +54,13 @@ func NewManager(cfg Config, logger *slog.Logger) (Manager, error) {
 		config:    cfg,
 		logger:    logger,
 		closeChan: make(chan struct{}),
+		events:    make(chan DeviceEvent, 16),
+		meter:     newLevelMeter(),
+		eq:        cfg.Equalizer,
+		status: DeviceStatus{
+			Capture:  DeviceHealthIdle,
+			Playback: DeviceHealthOK,
+		},
 	}
 
 	// 初始化 OPUS 编码器
@@ -59,12 +87,7 @@ func NewManager(cfg Config, logger *slog.Logger) (Manager, error) {
 	manager.decoder = decoder
 
 	// 初始化音频播放器
-	player, err := NewPCMPlayer(
-		cfg.SampleRate,
-		cfg.FrameDuration,
-		cfg.Channels,
-		logger,
-	)
+	player, err := manager.newPlayer()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create audio player: %w", err)
 	}
@@ -73,9 +96,31 @@ func NewManager(cfg Config, logger *slog.Logger) (Manager, error) {
 	// 初始化录音机（延迟初始化，需要时再创建）
 	// recorder 将在 StartRecording 时创建
 
+	// 监控播放设备，停滞时自动重新打开
+	go manager.playbackWatchdog()
+
 	return manager, nil
 }
 
+// newPlayer 按配置创建播放器
+func (m *audioResourceManager) newPlayer() (AudioPlayer, error) {
+	player, err := NewPCMPlayer(
+		m.config.SampleRate,
+		m.config.FrameDuration,
+		m.config.Channels,
+		m.logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+	player.writeTimeout = m.config.stallTimeout()
+	// 播放电平在数据送到设备时测量，而不是入队时，否则会比实际声音提前最多一个缓冲区
+	player.OnPlayed(func(pcm []int16) {
+		m.meter.observe(LevelPlayback, pcm)
+	})
+	return player, nil
+}
+
 // StartRecording 开始录音
 func (m *audioResourceManager) StartRecording(dataChan chan<- []byte) error {
 	m.mu.Lock()
@@ -89,8 +134,8 @@ func (m *audioResourceManager) StartRecording(dataChan chan<- []byte) error {
 		return fmt.Errorf("manager is closed")
 	}
 
-	// 创建录音机
-	recorder, err := NewRecorder(m.config, m.logger)
+	// 创建录音机（先确认编码器等资源可用）
+	recorder, err := newRecorder(m.config, m.logger, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create recorder: %w", err)
 	}
@@ -98,31 +143,91 @@ func (m *audioResourceManager) StartRecording(dataChan chan<- []byte) error {
 
 	m.isRecording = true
 
-	// 在后台启动录音
+	// 在后台启动录音，设备故障时自动重试
 	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		defer cancel()
-		if err := m.recorder.Record(ctx, dataChan); err != nil {
-			m.logger.Error("Recording failed", "error", err)
-		}
-	}()
+	m.recordCancel = cancel
+	go m.captureLoop(ctx, recorder, dataChan)
 
 	m.logger.Info("Recording started")
 	return nil
 }
 
+// captureLoop 运行录音，设备出错或停滞时按指数退避重新打开设备
+func (m *audioResourceManager) captureLoop(ctx context.Context, rec Recorder, dataChan chan<- []byte) {
+	backoff := utils.NewExponentialBackoff()
+	attempt := 0
+
+	// 设备启动成功后更新状态（在 Record 所在 goroutine 中同步调用）
+	onStart := func() {
+		if attempt > 0 {
+			m.markRestored(DeviceCapture, attempt)
+		} else {
+			m.setHealth(DeviceCapture, DeviceHealthOK)
+		}
+		attempt = 0
+		backoff.Reset()
+	}
+
+	onPCM := func(pcm []int16) {
+		m.meter.observe(LevelCapture, pcm)
+	}
+
+	for {
+		var err error
+		if rec == nil {
+			rec, err = newRecorder(m.config, m.logger, onStart)
+		}
+		if r, ok := rec.(*recorder); ok {
+			r.onStart = onStart
+			r.onPCM = onPCM
+		}
+		if err == nil {
+			err = rec.Record(ctx, dataChan)
+		}
+		rec = nil
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = ErrDeviceStopped
+		}
+
+		attempt++
+		m.markFault(DeviceCapture, err, attempt)
+
+		delay := backoff.NextDelay()
+		m.logger.Warn("Capture device failed, retrying", "error", err, "attempt", attempt, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
 // StopRecording 停止录音
 func (m *audioResourceManager) StopRecording() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.stopRecordingLocked()
+}
+
+// stopRecordingLocked 停止录音，调用方需持有 m.mu
+func (m *audioResourceManager) stopRecordingLocked() {
 	if !m.isRecording {
 		return
 	}
 
 	// recorder 会在 Record 方法结束时自动释放资源
+	if m.recordCancel != nil {
+		m.recordCancel()
+		m.recordCancel = nil
+	}
 	m.recorder = nil
 	m.isRecording = false
+	m.setHealth(DeviceCapture, DeviceHealthIdle)
 
 	m.logger.Info("Recording stopped")
 }
@@ -140,6 +245,12 @@ func (m *audioResourceManager) Play(data []int16) error {
 		return fmt.Errorf("player not initialized")
 	}
 
+	if m.eq != nil && m.eq.Active() {
+		// 调用方可能缓存了数据（如提示音），处理副本
+		data = append([]int16(nil), data...)
+		m.eq.Process(data, m.config.SampleRate, m.config.Channels)
+	}
+
 	return m.player.Play(data)
 }
 
@@ -204,9 +315,7 @@ func (m *audioResourceManager) Close() error {
 	var errs []error
 
 	// 停止录音
-	if m.isRecording {
-		m.StopRecording()
-	}
+	m.stopRecordingLocked()
 
 	// 关闭播放器
 	if m.player != nil {
@@ -228,6 +337,13 @@ func (m *audioResourceManager) Close() error {
 		m.encoder = nil
 	}
 
+	// 关闭事件通道
+	m.statusMu.Lock()
+	m.eventsClosed = true
+	close(m.events)
+	m.statusMu.Unlock()
+	m.meter.close()
+
 	m.logger.Info("Audio manager closed")
 
 	if len(errs) > 0 {
@@ -254,13 +370,9 @@ func (m *audioResourceManager) Reinitialize() error {
 		}
 	}
 
-	player, err := NewPCMPlayer(
-		m.config.SampleRate,
-		m.config.FrameDuration,
-		m.config.Channels,
-		m.logger,
-	)
+	player, err := m.newPlayer()
 	if err != nil {
+		m.player = nil
 		return fmt.Errorf("failed to recreate player: %w", err)
 	}
 	m.player = player
@@ -287,3 +399,189 @@ func (m *audioResourceManager) Reinitialize() error {
 	}
 	return nil
 }
+
+// Events 返回音频设备事件通道，管理器关闭时通道关闭
+func (m *audioResourceManager) Events() <-chan DeviceEvent {
+	return m.events
+}
+
+// DeviceStatus 返回当前音频设备状态
+func (m *audioResourceManager) DeviceStatus() DeviceStatus {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	return m.status
+}
+
+// Levels 返回采集与播放电平通道，管理器关闭时通道关闭
+func (m *audioResourceManager) Levels() <-chan Level {
+	return m.meter.ch
+}
+
+// LevelStats 返回最近的电平统计
+func (m *audioResourceManager) LevelStats() LevelStats {
+	return m.meter.snapshot()
+}
+
+// playbackWatchdog 监控播放设备回调，停滞时按指数退避重新打开
+func (m *audioResourceManager) playbackWatchdog() {
+	stallTimeout := m.config.stallTimeout()
+	ticker := time.NewTicker(stallTimeout / 2)
+	defer ticker.Stop()
+
+	backoff := utils.NewExponentialBackoff()
+	attempt := 0
+	var nextRetry time.Time
+
+	for {
+		select {
+		case <-m.closeChan:
+			return
+		case <-ticker.C:
+		}
+
+		if attempt == 0 {
+			if err := m.checkPlayback(stallTimeout); err != nil {
+				attempt = 1
+				m.markFault(DevicePlayback, err, attempt)
+				nextRetry = time.Now().Add(backoff.NextDelay())
+			}
+			continue
+		}
+
+		if time.Now().Before(nextRetry) {
+			continue
+		}
+
+		if err := m.reopenPlayer(); err != nil {
+			attempt++
+			m.markFault(DevicePlayback, err, attempt)
+			delay := backoff.NextDelay()
+			nextRetry = time.Now().Add(delay)
+			m.logger.Warn("Playback device still unavailable, retrying", "error", err, "attempt", attempt, "delay", delay)
+			continue
+		}
+
+		m.markRestored(DevicePlayback, attempt)
+		attempt = 0
+		backoff.Reset()
+	}
+}
+
+// checkPlayback 检查播放设备是否停滞，停滞时释放播放器
+func (m *audioResourceManager) checkPlayback(stallTimeout time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+
+	if m.player == nil {
+		return fmt.Errorf("playback device unavailable")
+	}
+
+	reporter, ok := m.player.(activityReporter)
+	if !ok {
+		return nil
+	}
+
+	idle := time.Since(reporter.LastActivity())
+	if idle <= stallTimeout {
+		return nil
+	}
+
+	if err := m.player.Close(); err != nil {
+		m.logger.Warn("Failed to close stalled player", "error", err)
+	}
+	m.player = nil
+	return fmt.Errorf("%w: no playback callback for %v", ErrDeviceStalled, idle.Round(time.Millisecond))
+}
+
+// reopenPlayer 重新打开播放设备
+func (m *audioResourceManager) reopenPlayer() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+
+	if m.player != nil {
+		if err := m.player.Close(); err != nil {
+			m.logger.Warn("Failed to close old player", "error", err)
+		}
+		m.player = nil
+	}
+
+	player, err := m.newPlayer()
+	if err != nil {
+		return err
+	}
+	m.player = player
+	return nil
+}
+
+// setHealth 更新设备健康状态
+func (m *audioResourceManager) setHealth(kind DeviceKind, health DeviceHealth) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+
+	switch kind {
+	case DeviceCapture:
+		m.status.Capture = health
+		if health != DeviceHealthRecovering {
+			m.status.CaptureRetries = 0
+		}
+	case DevicePlayback:
+		m.status.Playback = health
+		if health != DeviceHealthRecovering {
+			m.status.PlaybackRetries = 0
+		}
+	}
+}
+
+// markFault 记录设备故障并发送事件
+func (m *audioResourceManager) markFault(kind DeviceKind, err error, attempt int) {
+	m.statusMu.Lock()
+	switch kind {
+	case DeviceCapture:
+		m.status.Capture = DeviceHealthRecovering
+		m.status.CaptureRetries = attempt
+	case DevicePlayback:
+		m.status.Playback = DeviceHealthRecovering
+		m.status.PlaybackRetries = attempt
+	}
+	m.status.LastError = err.Error()
+	m.status.LastErrorTime = time.Now()
+	m.statusMu.Unlock()
+
+	eventType := DeviceEventRetry
+	if attempt == 1 {
+		eventType = DeviceEventLost
+		m.logger.Error("Audio device lost", "kind", kind, "error", err)
+	}
+	m.emit(DeviceEvent{Kind: kind, Type: eventType, Err: err, Attempt: attempt, Time: time.Now()})
+}
+
+// markRestored 记录设备恢复并发送事件
+func (m *audioResourceManager) markRestored(kind DeviceKind, attempts int) {
+	m.setHealth(kind, DeviceHealthOK)
+	m.logger.Info("Audio device restored", "kind", kind, "attempts", attempts)
+	m.emit(DeviceEvent{Kind: kind, Type: DeviceEventRestored, Attempt: attempts, Time: time.Now()})
+}
+
+// emit 非阻塞发送设备事件，通道满时丢弃
+func (m *audioResourceManager) emit(ev DeviceEvent) {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+
+	if m.eventsClosed {
+		return
+	}
+
+	select {
+	case m.events <- ev:
+	default:
+		m.logger.Warn("Audio device event dropped", "kind", ev.Kind, "type", ev.Type)
+	}
+}
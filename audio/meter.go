@@ -0,0 +1,135 @@
+package audio
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// LevelSource 电平来源
+type LevelSource string
+
+const (
+	LevelCapture  LevelSource = "capture"  // 麦克风采集
+	LevelPlayback LevelSource = "playback" // TTS 播放
+)
+
+// 样本绝对值达到该值视为削波
+const clipThreshold = math.MaxInt16 - 1
+
+// Level 一帧音频的电平，RMS 和 Peak 归一化到 0.0-1.0
+type Level struct {
+	Source  LevelSource
+	RMS     float64
+	Peak    float64
+	Clipped bool
+	Time    time.Time
+}
+
+// MeterStats 单个来源的电平统计
+type MeterStats struct {
+	RMS           float64
+	Peak          float64
+	ClippedFrames uint64
+	Frames        uint64
+	LastUpdate    time.Time
+}
+
+// LevelStats 采集与播放电平统计
+type LevelStats struct {
+	Capture  MeterStats
+	Playback MeterStats
+}
+
+// MeasureLevel 计算 PCM 的 RMS、峰值以及是否削波
+func MeasureLevel(pcm []int16) (rms, peak float64, clipped bool) {
+	if len(pcm) == 0 {
+		return 0, 0, false
+	}
+
+	var sum float64
+	var maxAbs int
+	for _, s := range pcm {
+		v := int(s)
+		if v < 0 {
+			v = -v
+		}
+		if v > maxAbs {
+			maxAbs = v
+		}
+		f := float64(s) / 32768.0
+		sum += f * f
+	}
+
+	rms = math.Sqrt(sum / float64(len(pcm)))
+	peak = float64(maxAbs) / 32768.0
+	return rms, peak, maxAbs >= clipThreshold
+}
+
+// LevelToDB 将归一化电平转换为 dBFS，静音返回 -96
+func LevelToDB(v float64) float64 {
+	if v <= 0 {
+		return -96
+	}
+	db := 20 * math.Log10(v)
+	if db < -96 {
+		return -96
+	}
+	return db
+}
+
+// levelMeter 记录各来源电平并通过通道发布
+type levelMeter struct {
+	mu     sync.Mutex
+	stats  LevelStats
+	ch     chan Level
+	closed bool
+}
+
+func newLevelMeter() *levelMeter {
+	return &levelMeter{ch: make(chan Level, 32)}
+}
+
+// observe 测量一帧音频并非阻塞地发布
+func (lm *levelMeter) observe(source LevelSource, pcm []int16) {
+	rms, peak, clipped := MeasureLevel(pcm)
+	now := time.Now()
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	stats := &lm.stats.Capture
+	if source == LevelPlayback {
+		stats = &lm.stats.Playback
+	}
+	stats.RMS = rms
+	stats.Peak = peak
+	stats.Frames++
+	if clipped {
+		stats.ClippedFrames++
+	}
+	stats.LastUpdate = now
+
+	if lm.closed {
+		return
+	}
+	select {
+	case lm.ch <- Level{Source: source, RMS: rms, Peak: peak, Clipped: clipped, Time: now}:
+	default:
+	}
+}
+
+func (lm *levelMeter) snapshot() LevelStats {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return lm.stats
+}
+
+func (lm *levelMeter) close() {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if !lm.closed {
+		lm.closed = true
+		close(lm.ch)
+	}
+}
@@ -0,0 +1,108 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// OggReader 从 Ogg 容器中按包读取第一个逻辑流的数据
+type OggReader struct {
+	r        *bufio.Reader
+	serial   uint32
+	started  bool
+	segments []byte // 当前页剩余的分段表
+	page     []byte // 当前页剩余的数据
+	packet   []byte // 跨页拼接中的包
+	granule  int64  // 最近一个完整页的 granule position
+}
+
+// NewOggReader 创建 Ogg 包读取器
+func NewOggReader(r io.Reader) *OggReader {
+	return &OggReader{r: bufio.NewReader(r), granule: -1}
+}
+
+// Granule 返回最近读取页的 granule position，未知时为 -1
+func (o *OggReader) Granule() int64 {
+	return o.granule
+}
+
+// ReadPacket 读取下一个完整的数据包
+func (o *OggReader) ReadPacket() ([]byte, error) {
+	for {
+		for len(o.segments) > 0 {
+			size := int(o.segments[0])
+			o.segments = o.segments[1:]
+			if size > len(o.page) {
+				return nil, errors.New("corrupt ogg page: segment exceeds page data")
+			}
+			o.packet = append(o.packet, o.page[:size]...)
+			o.page = o.page[size:]
+			// 长度小于 255 的分段表示包结束
+			if size < 255 {
+				packet := o.packet
+				o.packet = nil
+				return packet, nil
+			}
+		}
+
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readPage 读取下一页，跳过其他逻辑流的页
+func (o *OggReader) readPage() error {
+	for {
+		var header [27]byte
+		if _, err := io.ReadFull(o.r, header[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return io.EOF
+			}
+			return err
+		}
+		if string(header[0:4]) != "OggS" {
+			return errors.New("invalid ogg page signature")
+		}
+
+		headerType := header[5]
+		granule := int64(binary.LittleEndian.Uint64(header[6:14]))
+		serial := binary.LittleEndian.Uint32(header[14:18])
+		segCount := int(header[26])
+
+		segments := make([]byte, segCount)
+		if _, err := io.ReadFull(o.r, segments); err != nil {
+			return fmt.Errorf("failed to read ogg segment table: %w", err)
+		}
+		size := 0
+		for _, s := range segments {
+			size += int(s)
+		}
+		page := make([]byte, size)
+		if _, err := io.ReadFull(o.r, page); err != nil {
+			return fmt.Errorf("failed to read ogg page: %w", err)
+		}
+
+		if !o.started {
+			o.serial = serial
+			o.started = true
+		} else if serial != o.serial {
+			continue
+		}
+
+		// 非续包页开头时丢弃残留的半个包
+		if headerType&0x01 == 0 {
+			o.packet = nil
+		}
+
+		o.segments = segments
+		o.page = page
+		if granule != -1 {
+			o.granule = granule
+		}
+		return nil
+	}
+}
@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+)
+
+// OggOpusReader 解码 Ogg Opus 文件为 16 位交错 PCM
+type OggOpusReader struct {
+	ogg        *OggReader
+	decoder    *OpusDecoder
+	Channels   int
+	SampleRate int
+	gain       float64
+	preSkip    int // 待丢弃的起始帧数（按输出采样率）
+	pending    []int16
+}
+
+// NewOggOpusReader 解析 OpusHead/OpusTags 并创建解码器
+// sampleRate 为输出采样率，必须是 Opus 支持的采样率（8000/12000/16000/24000/48000）
+func NewOggOpusReader(r io.Reader, sampleRate int, logger *slog.Logger) (*OggOpusReader, error) {
+	ogg := NewOggReader(r)
+
+	head, err := ogg.ReadPacket()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpusHead: %w", err)
+	}
+	if len(head) < 19 || string(head[0:8]) != "OpusHead" {
+		return nil, errors.New("not an Ogg Opus stream")
+	}
+
+	channels := int(head[9])
+	if channels < 1 || channels > 2 {
+		return nil, fmt.Errorf("unsupported opus channel count: %d", channels)
+	}
+	preSkip := int(binary.LittleEndian.Uint16(head[10:12]))
+	outputGain := int16(binary.LittleEndian.Uint16(head[16:18]))
+
+	// OpusTags 包，暂不使用
+	if _, err := ogg.ReadPacket(); err != nil {
+		return nil, fmt.Errorf("failed to read OpusTags: %w", err)
+	}
+
+	decoder, err := NewOpusDecoder(sampleRate, channels, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OggOpusReader{
+		ogg:        ogg,
+		decoder:    decoder,
+		Channels:   channels,
+		SampleRate: sampleRate,
+		// output gain 为 Q7.8 格式的 dB 值
+		gain: math.Pow(10, float64(outputGain)/256/20),
+		// pre-skip 以 48kHz 为单位
+		preSkip: preSkip * sampleRate / 48000,
+	}, nil
+}
+
+// Read 读取交错的 16 位 PCM 样本，返回读取的样本数
+func (o *OggOpusReader) Read(pcm []int16) (int, error) {
+	for len(o.pending) == 0 {
+		packet, err := o.ogg.ReadPacket()
+		if err != nil {
+			return 0, err
+		}
+		if len(packet) == 0 {
+			continue
+		}
+
+		decoded, err := o.decoder.Decode(packet)
+		if err != nil {
+			return 0, err
+		}
+
+		if o.preSkip > 0 {
+			skip := o.preSkip * o.Channels
+			if skip > len(decoded) {
+				skip = len(decoded)
+			}
+			decoded = decoded[skip:]
+			o.preSkip -= skip / o.Channels
+		}
+		ApplyGain(decoded, o.gain)
+		o.pending = decoded
+	}
+
+	n := copy(pcm, o.pending)
+	o.pending = o.pending[n:]
+	return n, nil
+}
+
+// Close 释放解码器
+func (o *OggOpusReader) Close() {
+	o.decoder.Close()
+}
@@ -0,0 +1,93 @@
+package audio
+
+import "math"
+
+// Resample 使用线性插值将交错 PCM 从 fromRate 转换到 toRate
+func Resample(pcm []int16, fromRate, toRate, channels int) []int16 {
+	if fromRate == toRate || fromRate <= 0 || toRate <= 0 || channels <= 0 || len(pcm) == 0 {
+		return pcm
+	}
+
+	inFrames := len(pcm) / channels
+	outFrames := int(int64(inFrames) * int64(toRate) / int64(fromRate))
+	out := make([]int16, outFrames*channels)
+
+	step := float64(fromRate) / float64(toRate)
+	for i := 0; i < outFrames; i++ {
+		pos := float64(i) * step
+		idx := int(pos)
+		frac := pos - float64(idx)
+		next := idx + 1
+		if next >= inFrames {
+			next = inFrames - 1
+		}
+		for ch := 0; ch < channels; ch++ {
+			a := float64(pcm[idx*channels+ch])
+			b := float64(pcm[next*channels+ch])
+			out[i*channels+ch] = int16(a + (b-a)*frac)
+		}
+	}
+	return out
+}
+
+// ConvertChannels 转换交错 PCM 的声道数（多声道转单声道取平均，单声道转多声道复制）
+func ConvertChannels(pcm []int16, from, to int) []int16 {
+	if from == to || from <= 0 || to <= 0 {
+		return pcm
+	}
+
+	frames := len(pcm) / from
+	out := make([]int16, frames*to)
+	for i := 0; i < frames; i++ {
+		if to == 1 {
+			var sum int
+			for ch := 0; ch < from; ch++ {
+				sum += int(pcm[i*from+ch])
+			}
+			out[i] = int16(sum / from)
+			continue
+		}
+		for ch := 0; ch < to; ch++ {
+			src := ch
+			if src >= from {
+				src = from - 1
+			}
+			out[i*to+ch] = pcm[i*from+src]
+		}
+	}
+	return out
+}
+
+// ApplyGain 按线性增益缩放 PCM（原地修改），超出范围时削波
+func ApplyGain(pcm []int16, gain float64) {
+	if gain == 1 {
+		return
+	}
+	for i, s := range pcm {
+		pcm[i] = clampSample(float64(s) * gain)
+	}
+}
+
+// VolumeToGain 将 0-100 的音量转换为线性增益
+func VolumeToGain(volume int) float64 {
+	if volume <= 0 {
+		return 0
+	}
+	if volume >= 100 {
+		return 1
+	}
+	// 使用对数曲线，使音量调节更符合听感
+	db := (float64(volume) - 100) * 0.5
+	return math.Pow(10, db/20)
+}
+
+// clampSample 将浮点样本限制在 int16 范围内
+func clampSample(v float64) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/gordonklaus/portaudio"
 	"log/slog"
+	"sync/atomic"
+	"time"
 )
 
 // PCMPlayer PortAudio实现的PCM播放器
@@ -15,6 +17,11 @@ type PCMPlayer struct {
 	done       chan struct{}
 	logger     *slog.Logger
 	stream     *portaudio.Stream
+
+	lastCallback atomic.Int64  // 最后一次音频回调时间（UnixNano）
+	writeTimeout time.Duration // 缓冲区满时 Play 的最长等待时间
+
+	played atomic.Pointer[func([]int16)] // 数据送到设备时的回调，见 OnPlayed
 }
 
 // NewPCMPlayer 创建新的PortAudio PCM播放器
@@ -31,7 +38,10 @@ func NewPCMPlayer(sampleRate, frameDuration, channels int, logger *slog.Logger)
 		buffer:     make(chan []int16, 100),
 		done:       make(chan struct{}),
 		logger:     logger,
+		// 正常情况下回调每帧都会消费缓冲区，等待过久说明设备已停滞
+		writeTimeout: defaultStallTimeout * time.Millisecond,
 	}
+	player.lastCallback.Store(time.Now().UnixNano())
 
 	frameSize := sampleRate * frameDuration / 1000
 	// 打开音频流
@@ -61,6 +71,8 @@ func NewPCMPlayer(sampleRate, frameDuration, channels int, logger *slog.Logger)
 }
 
 func (p *PCMPlayer) audioCallback(out [][]float32) {
+	p.lastCallback.Store(time.Now().UnixNano())
+
 	// 计算总共需要处理的样本数（所有通道）
 	totalSamples := len(out) * len(out[0])
 	processed := 0
@@ -69,6 +81,9 @@ func (p *PCMPlayer) audioCallback(out [][]float32) {
 	for processed < totalSamples {
 		select {
 		case chunk := <-p.buffer:
+			if fn := p.played.Load(); fn != nil {
+				(*fn)(chunk)
+			}
 			// 将int16样本转换为float32并填充到输出缓冲区
 			for i := 0; i < len(chunk) && processed < totalSamples; i++ {
 				channel := processed % len(out)
@@ -95,9 +110,22 @@ func (p *PCMPlayer) Play(data []int16) error {
 		return nil
 	case <-p.done:
 		return errors.New("audio player closed")
+	case <-time.After(p.writeTimeout):
+		return fmt.Errorf("%w: playback buffer full", ErrDeviceStalled)
 	}
 }
 
+// OnPlayed 设置数据从缓冲区送到设备时的回调，用于与实际发声同步的电平测量
+// 回调在音频线程中执行，必须快速返回
+func (p *PCMPlayer) OnPlayed(fn func(pcm []int16)) {
+	p.played.Store(&fn)
+}
+
+// LastActivity 返回最后一次音频回调的时间
+func (p *PCMPlayer) LastActivity() time.Time {
+	return time.Unix(0, p.lastCallback.Load())
+}
+
 func (p *PCMPlayer) playbackLoop() {
 	// 这里可以添加额外的播放控制逻辑
 	<-p.done
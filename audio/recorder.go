@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gen2brain/malgo"
@@ -15,16 +16,25 @@ import (
 type recorder struct {
 	config      Config
 	logger      *slog.Logger
-	opusEncoder *OpusEncoder // 使用opus_codec.go中的编码器
+	opusEncoder *OpusEncoder  // 使用opus_codec.go中的编码器
+	onStart     func()        // 设备成功启动后回调
+	onPCM       func([]int16) // 每帧采集到的 PCM，用于电平监测
 }
 
 type Config struct {
 	SampleRate    int
 	Channels      int
-	FrameDuration int // 毫秒
+	FrameDuration int        // 毫秒
+	StallTimeout  int        // 毫秒，超过该时间无设备回调视为故障
+	Equalizer     *Equalizer // 播放前的均衡器（语音、提示音），为 nil 时不处理
 }
 
 func NewRecorder(cfg Config, logger *slog.Logger) (Recorder, error) {
+	return newRecorder(cfg, logger, nil)
+}
+
+// newRecorder 创建录音机，onStart 在设备启动成功后调用
+func newRecorder(cfg Config, logger *slog.Logger, onStart func()) (Recorder, error) {
 	// 使用现有OpusEncoder实现
 	encoder, err := NewOpusEncoder(
 		cfg.SampleRate,
@@ -40,6 +50,7 @@ func NewRecorder(cfg Config, logger *slog.Logger) (Recorder, error) {
 		config:      cfg,
 		logger:      logger,
 		opusEncoder: encoder,
+		onStart:     onStart,
 	}, nil
 }
 
@@ -91,7 +102,13 @@ func (r *recorder) Record(ctx context.Context, dataChan chan<- []byte) error {
 	var callbackMu sync.Mutex
 	stopped := false
 
+	// 记录最后一次回调时间，用于检测设备停滞
+	var lastCallback atomic.Int64
+	lastCallback.Store(time.Now().UnixNano())
+
 	captureCallback := func(_, pcmData []byte, _ uint32) {
+		lastCallback.Store(time.Now().UnixNano())
+
 		// 使用 mutex 保护，确保在停止后不再处理
 		callbackMu.Lock()
 		if stopped {
@@ -108,6 +125,9 @@ func (r *recorder) Record(ctx context.Context, dataChan chan<- []byte) error {
 
 		// PCM数据转换
 		pcm := bytesToInt16(pcmData)
+		if r.onPCM != nil {
+			r.onPCM(pcm)
+		}
 
 		// 使用opus_codec.go的Encode方法
 		opusData, err := r.opusEncoder.Encode(pcm)
@@ -131,9 +151,17 @@ func (r *recorder) Record(ctx context.Context, dataChan chan<- []byte) error {
 		}
 	}
 
+	// 设备被意外停止时（如 USB 声卡拔出）通知等待循环
+	deviceStopped := make(chan struct{})
+	var stopOnce sync.Once
+	stopCallback := func() {
+		stopOnce.Do(func() { close(deviceStopped) })
+	}
+
 	// 创建设备
 	device, err := malgo.InitDevice(ctxMalgo.Context, deviceConfig, malgo.DeviceCallbacks{
 		Data: captureCallback,
+		Stop: stopCallback,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize audio device: %w", err)
@@ -151,14 +179,43 @@ func (r *recorder) Record(ctx context.Context, dataChan chan<- []byte) error {
 		"channels", r.config.Channels,
 		"frame_size", frameSize)
 
-	// 等待上下文取消
-	<-ctx.Done()
+	if r.onStart != nil {
+		r.onStart()
+	}
+
+	// 等待上下文取消，同时监控设备故障
+	stallTimeout := r.config.stallTimeout()
+	watchdog := time.NewTicker(stallTimeout / 4)
+	defer watchdog.Stop()
+
+	var recordErr error
+waitLoop:
+	for {
+		select {
+		case <-ctx.Done():
+			break waitLoop
+		case <-deviceStopped:
+			recordErr = ErrDeviceStopped
+			break waitLoop
+		case <-watchdog.C:
+			idle := time.Since(time.Unix(0, lastCallback.Load()))
+			if idle > stallTimeout {
+				recordErr = fmt.Errorf("%w: no capture callback for %v", ErrDeviceStalled, idle.Round(time.Millisecond))
+				break waitLoop
+			}
+		}
+	}
 
 	// 标记回调停止，防止后续发送
 	callbackMu.Lock()
 	stopped = true
 	callbackMu.Unlock()
 
+	if recordErr != nil {
+		r.logger.Warn("Audio recording interrupted by device fault", "error", recordErr)
+		return recordErr
+	}
+
 	r.logger.Info("Audio recording stopped")
 	return nil
 }
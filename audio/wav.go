@@ -0,0 +1,248 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// WAV 编码格式
+const (
+	wavFormatPCM        = 1
+	wavFormatIEEEFloat  = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// WAVFormat WAV 文件格式信息
+type WAVFormat struct {
+	AudioFormat   uint16
+	Channels      int
+	SampleRate    int
+	BitsPerSample int
+	BlockAlign    int
+}
+
+// WAVReader 按 RIFF 块解析 WAV 文件并输出 16 位交错 PCM
+type WAVReader struct {
+	r         io.Reader
+	Format    WAVFormat
+	dataSize  int64 // data 块大小，-1 表示未知（流式写入的文件）
+	dataStart int64 // data 块数据在文件中的偏移
+	remaining int64
+	buf       []byte
+}
+
+// NewWAVReader 解析 WAV 头部，定位到 data 块起始位置
+func NewWAVReader(r io.Reader) (*WAVReader, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("failed to read RIFF header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	w := &WAVReader{r: r}
+	haveFormat := false
+	offset := int64(len(riff))
+
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, fmt.Errorf("failed to read chunk header: %w", err)
+		}
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		offset += int64(len(header))
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("invalid fmt chunk size: %d", size)
+			}
+			chunk := make([]byte, size+size&1)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+			}
+			if err := w.parseFormat(chunk[:size]); err != nil {
+				return nil, err
+			}
+			haveFormat = true
+			offset += int64(len(chunk))
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			w.dataSize = size
+			// 0 或 0xFFFFFFFF 通常表示流式写入时未回填的大小
+			if size == 0 || size == math.MaxUint32 {
+				w.dataSize = -1
+			}
+			w.remaining = w.dataSize
+			w.dataStart = offset
+			return w, nil
+		default:
+			// 跳过 LIST、fact 等未使用的块（块大小按 2 字节对齐）
+			if _, err := io.CopyN(io.Discard, r, size+size&1); err != nil {
+				return nil, fmt.Errorf("failed to skip %q chunk: %w", id, err)
+			}
+			offset += size + size&1
+		}
+	}
+}
+
+// parseFormat 解析 fmt 块
+func (w *WAVReader) parseFormat(chunk []byte) error {
+	f := WAVFormat{
+		AudioFormat:   binary.LittleEndian.Uint16(chunk[0:2]),
+		Channels:      int(binary.LittleEndian.Uint16(chunk[2:4])),
+		SampleRate:    int(binary.LittleEndian.Uint32(chunk[4:8])),
+		BlockAlign:    int(binary.LittleEndian.Uint16(chunk[12:14])),
+		BitsPerSample: int(binary.LittleEndian.Uint16(chunk[14:16])),
+	}
+
+	// WAVE_FORMAT_EXTENSIBLE 的实际格式在子格式 GUID 的前两个字节
+	if f.AudioFormat == wavFormatExtensible {
+		if len(chunk) < 26 {
+			return errors.New("truncated WAVE_FORMAT_EXTENSIBLE fmt chunk")
+		}
+		f.AudioFormat = binary.LittleEndian.Uint16(chunk[24:26])
+	}
+
+	if f.Channels <= 0 || f.SampleRate <= 0 {
+		return fmt.Errorf("invalid WAV format: channels=%d sample_rate=%d", f.Channels, f.SampleRate)
+	}
+
+	switch {
+	case f.AudioFormat == wavFormatPCM && (f.BitsPerSample == 8 || f.BitsPerSample == 16 || f.BitsPerSample == 24 || f.BitsPerSample == 32):
+	case f.AudioFormat == wavFormatIEEEFloat && (f.BitsPerSample == 32 || f.BitsPerSample == 64):
+	default:
+		return fmt.Errorf("unsupported WAV encoding: format=%d bits=%d", f.AudioFormat, f.BitsPerSample)
+	}
+
+	if expected := f.Channels * f.BitsPerSample / 8; f.BlockAlign != expected {
+		f.BlockAlign = expected
+	}
+
+	w.Format = f
+	return nil
+}
+
+// Duration 返回音频时长，大小未知时返回 0
+func (w *WAVReader) Duration() time.Duration {
+	if w.dataSize < 0 {
+		return 0
+	}
+	frames := w.dataSize / int64(w.Format.BlockAlign)
+	return time.Duration(frames) * time.Second / time.Duration(w.Format.SampleRate)
+}
+
+// SeekFrame 定位到指定帧，底层 Reader 必须实现 io.Seeker（且从文件起始处开始读取）
+func (w *WAVReader) SeekFrame(frame int64) error {
+	seeker, ok := w.r.(io.Seeker)
+	if !ok {
+		return errors.New("wav reader is not seekable")
+	}
+	if frame < 0 {
+		frame = 0
+	}
+
+	pos := frame * int64(w.Format.BlockAlign)
+	if w.dataSize >= 0 && pos > w.dataSize {
+		pos = w.dataSize
+	}
+	if _, err := seeker.Seek(w.dataStart+pos, io.SeekStart); err != nil {
+		return err
+	}
+	if w.dataSize >= 0 {
+		w.remaining = w.dataSize - pos
+	}
+	return nil
+}
+
+// Read 读取交错的 16 位 PCM 样本，返回读取的样本数
+func (w *WAVReader) Read(pcm []int16) (int, error) {
+	bytesPerSample := w.Format.BitsPerSample / 8
+	// 只读取完整的帧
+	frames := len(pcm) / w.Format.Channels
+	if frames == 0 {
+		return 0, nil
+	}
+	want := int64(frames * w.Format.BlockAlign)
+	if w.remaining >= 0 {
+		if w.remaining == 0 {
+			return 0, io.EOF
+		}
+		if want > w.remaining {
+			want = w.remaining
+		}
+	}
+
+	if int64(cap(w.buf)) < want {
+		w.buf = make([]byte, want)
+	}
+	buf := w.buf[:want]
+
+	n, err := io.ReadFull(w.r, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	if n == 0 && err == nil {
+		err = io.EOF
+	}
+	if w.remaining >= 0 {
+		w.remaining -= int64(n)
+	}
+
+	// 丢弃不完整的尾部帧
+	n -= n % w.Format.BlockAlign
+	count := n / bytesPerSample
+	for i := 0; i < count; i++ {
+		pcm[i] = w.sampleAt(buf[i*bytesPerSample:])
+	}
+	return count, err
+}
+
+// sampleAt 将单个样本转换为 int16
+func (w *WAVReader) sampleAt(b []byte) int16 {
+	switch w.Format.AudioFormat {
+	case wavFormatIEEEFloat:
+		var v float64
+		if w.Format.BitsPerSample == 32 {
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		} else {
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		return clampSample(v * 32768)
+	default:
+		switch w.Format.BitsPerSample {
+		case 8:
+			return int16(int(b[0])-128) << 8
+		case 16:
+			return int16(binary.LittleEndian.Uint16(b))
+		case 24:
+			return int16(uint16(b[1]) | uint16(b[2])<<8)
+		default:
+			return int16(binary.LittleEndian.Uint32(b) >> 16)
+		}
+	}
+}
+
+// ReadAllPCM 读取剩余全部样本
+func (w *WAVReader) ReadAllPCM() ([]int16, error) {
+	var all []int16
+	chunk := make([]int16, 4096*w.Format.Channels)
+	for {
+		n, err := w.Read(chunk)
+		all = append(all, chunk[:n]...)
+		if err == io.EOF {
+			return all, nil
+		}
+		if err != nil {
+			return all, err
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// chunk 写入一个 RIFF 块，奇数大小的块补一个填充字节
+func chunk(buf *bytes.Buffer, id string, data []byte) {
+	buf.WriteString(id)
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// fmtChunk 构造 fmt 块内容
+func fmtChunk(format uint16, channels, rate, bits int) []byte {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint16(b[0:], format)
+	binary.LittleEndian.PutUint16(b[2:], uint16(channels))
+	binary.LittleEndian.PutUint32(b[4:], uint32(rate))
+	binary.LittleEndian.PutUint32(b[8:], uint32(rate*channels*bits/8))
+	binary.LittleEndian.PutUint16(b[12:], uint16(channels*bits/8))
+	binary.LittleEndian.PutUint16(b[14:], uint16(bits))
+	return b
+}
+
+// extensibleChunk 构造 WAVE_FORMAT_EXTENSIBLE 的 fmt 块，子格式 GUID 的前两个字节为实际格式
+func extensibleChunk(subFormat uint16, channels, rate, bits int) []byte {
+	b := append(fmtChunk(wavFormatExtensible, channels, rate, bits), make([]byte, 24)...)
+	binary.LittleEndian.PutUint16(b[16:], 22)           // cbSize
+	binary.LittleEndian.PutUint16(b[18:], uint16(bits)) // 有效位数
+	binary.LittleEndian.PutUint16(b[24:], subFormat)
+	copy(b[26:], "\x00\x00\x00\x00\x10\x00\x80\x00\x00\xAA\x00\x38\x9B\x71")
+	return b
+}
+
+// riff 用给定的块构造 WAV 文件，RIFF 大小字段不参与解析，写 0 即可
+func riff(chunks func(buf *bytes.Buffer)) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	chunks(&buf)
+	return buf.Bytes()
+}
+
+func pcm16(samples ...int16) []byte {
+	b := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(s))
+	}
+	return b
+}
+
+func TestWAVReaderSkipsChunks(t *testing.T) {
+	data := riff(func(buf *bytes.Buffer) {
+		chunk(buf, "LIST", []byte("INFOx")) // 奇数大小，后面有填充字节
+		chunk(buf, "fmt ", fmtChunk(wavFormatPCM, 2, 8000, 16))
+		chunk(buf, "fact", []byte{4, 0, 0, 0})
+		chunk(buf, "data", pcm16(1, -1, 1000, -1000))
+	})
+
+	w, err := NewWAVReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Format.Channels != 2 || w.Format.SampleRate != 8000 || w.Format.BitsPerSample != 16 {
+		t.Errorf("format = %+v", w.Format)
+	}
+	got, err := w.ReadAllPCM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int16{1, -1, 1000, -1000}; !slices.Equal(got, want) {
+		t.Errorf("samples = %v, want %v", got, want)
+	}
+	if d := w.Duration(); d != 250*time.Microsecond {
+		t.Errorf("Duration = %v, want 250µs", d)
+	}
+}
+
+func TestWAVReaderSampleFormats(t *testing.T) {
+	f32 := func(vs ...float32) []byte {
+		b := make([]byte, len(vs)*4)
+		for i, v := range vs {
+			binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v))
+		}
+		return b
+	}
+	f64 := func(vs ...float64) []byte {
+		b := make([]byte, len(vs)*8)
+		for i, v := range vs {
+			binary.LittleEndian.PutUint64(b[i*8:], math.Float64bits(v))
+		}
+		return b
+	}
+
+	tests := []struct {
+		name string
+		fmt  []byte
+		data []byte
+		want []int16
+	}{
+		{"8-bit unsigned", fmtChunk(wavFormatPCM, 1, 8000, 8), []byte{0x80, 0xFF, 0x00, 0xC0}, []int16{0, 32512, -32768, 16384}},
+		{"24-bit", fmtChunk(wavFormatPCM, 1, 8000, 24), []byte{0xFF, 0x00, 0x40, 0x00, 0x00, 0x80}, []int16{16384, -32768}},
+		{"32-bit", fmtChunk(wavFormatPCM, 1, 8000, 32), []byte{0xFF, 0xFF, 0x00, 0x40, 0x00, 0x00, 0x00, 0xC0}, []int16{16384, -16384}},
+		{"float32", fmtChunk(wavFormatIEEEFloat, 1, 8000, 32), f32(0.5, -1, 1.5), []int16{16384, -32768, 32767}},
+		{"float64", fmtChunk(wavFormatIEEEFloat, 1, 8000, 64), f64(0.25, -0.25), []int16{8192, -8192}},
+		{"extensible pcm", extensibleChunk(wavFormatPCM, 1, 8000, 16), pcm16(123, -456), []int16{123, -456}},
+		{"extensible float", extensibleChunk(wavFormatIEEEFloat, 1, 8000, 32), f32(-0.5), []int16{-16384}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := riff(func(buf *bytes.Buffer) {
+				chunk(buf, "fmt ", tt.fmt)
+				chunk(buf, "data", tt.data)
+			})
+			w, err := NewWAVReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := w.ReadAllPCM()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("samples = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWAVReaderStreamingSize(t *testing.T) {
+	for _, size := range []uint32{0, math.MaxUint32} {
+		var buf bytes.Buffer
+		buf.WriteString("RIFF\xFF\xFF\xFF\xFFWAVE")
+		chunk(&buf, "fmt ", fmtChunk(wavFormatPCM, 1, 8000, 16))
+		buf.WriteString("data")
+		binary.Write(&buf, binary.LittleEndian, size)
+		buf.Write(pcm16(1, 2, 3))
+		buf.WriteByte(0x7F) // 不完整的尾部样本
+
+		w, err := NewWAVReader(&buf)
+		if err != nil {
+			t.Fatalf("size %#x: %v", size, err)
+		}
+		if d := w.Duration(); d != 0 {
+			t.Errorf("size %#x: Duration = %v, want 0 (unknown)", size, d)
+		}
+		got, err := w.ReadAllPCM()
+		if err != nil {
+			t.Fatalf("size %#x: %v", size, err)
+		}
+		if want := []int16{1, 2, 3}; !slices.Equal(got, want) {
+			t.Errorf("size %#x: samples = %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestWAVReaderSeekFrame(t *testing.T) {
+	samples := make([]int16, 2*8000) // 1 秒立体声
+	for i := range samples {
+		samples[i] = int16(i / 2)
+	}
+	data := riff(func(buf *bytes.Buffer) {
+		chunk(buf, "LIST", []byte("abc"))
+		chunk(buf, "fmt ", fmtChunk(wavFormatPCM, 2, 8000, 16))
+		chunk(buf, "data", pcm16(samples...))
+	})
+
+	w, err := NewWAVReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := w.Duration(); d != time.Second {
+		t.Errorf("Duration = %v, want 1s", d)
+	}
+
+	if err := w.SeekFrame(6000); err != nil {
+		t.Fatal(err)
+	}
+	rest, err := w.ReadAllPCM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 2*2000 || rest[0] != 6000 || rest[1] != 6000 {
+		t.Errorf("after SeekFrame(6000): %d samples starting %v", len(rest), rest[:min(2, len(rest))])
+	}
+
+	// 超出结尾时停在末尾
+	if err := w.SeekFrame(10000); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.Read(make([]int16, 16)); n != 0 || err != io.EOF {
+		t.Errorf("Read past end = %d, %v, want 0, EOF", n, err)
+	}
+
+	// 不支持定位的底层 Reader
+	w, err = NewWAVReader(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.SeekFrame(1); err == nil {
+		t.Error("SeekFrame on non-seekable reader succeeded")
+	}
+}
+
+func TestWAVReaderErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"not riff": []byte("RIFX\x00\x00\x00\x00WAVE"),
+		"data before fmt": riff(func(buf *bytes.Buffer) {
+			chunk(buf, "data", pcm16(1))
+		}),
+		"12-bit pcm": riff(func(buf *bytes.Buffer) {
+			chunk(buf, "fmt ", fmtChunk(wavFormatPCM, 1, 8000, 12))
+			chunk(buf, "data", pcm16(1))
+		}),
+		"truncated extensible": riff(func(buf *bytes.Buffer) {
+			chunk(buf, "fmt ", append(fmtChunk(wavFormatExtensible, 1, 8000, 16), 0, 0))
+			chunk(buf, "data", pcm16(1))
+		}),
+		"no data": riff(func(buf *bytes.Buffer) {
+			chunk(buf, "fmt ", fmtChunk(wavFormatPCM, 1, 8000, 16))
+		}),
+	}
+	for name, data := range tests {
+		if _, err := NewWAVReader(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: NewWAVReader succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/audio"
+	"github.com/lisuiheng/xiaozhi-go/core"
+)
+
+// audio-test 退出码
+const (
+	audioTestPass       = 0 // 全部检查通过
+	audioTestFail       = 1 // 检查未通过
+	audioTestSetupError = 2 // 配置或设备无法初始化
+)
+
+// 测试信号参数
+const (
+	testLeadIn        = 400 * time.Millisecond  // 播放前的静音，用于测量底噪
+	testChirpDuration = 500 * time.Millisecond  // 扫频信号时长，用于相关性测延迟
+	testChirpStartHz  = 300.0                   // 扫频起始频率
+	testChirpEndHz    = 3000.0                  // 扫频结束频率
+	testGap           = 200 * time.Millisecond  // 扫频与测试音之间的静音
+	testToneDuration  = time.Second             // 测试音时长
+	testToneHz        = 1000.0                  // 测试音频率
+	testTail          = 800 * time.Millisecond  // 播放结束后继续录音的时长
+	testFade          = 10 * time.Millisecond   // 淡入淡出，避免爆音
+	testSearchWindow  = 1000 * time.Millisecond // 延迟搜索范围
+)
+
+// audioTestReport audio-test 的检测结果
+type audioTestReport struct {
+	Pass           bool     `json:"pass"`
+	Failures       []string `json:"failures,omitempty"`
+	CaptureDevice  string   `json:"capture_device"`
+	PlaybackDevice string   `json:"playback_device"`
+	SampleRate     int      `json:"sample_rate"`
+	Channels       int      `json:"channels"`
+	Packets        int      `json:"opus_packets"`
+	DecodeErrors   int      `json:"opus_decode_errors"`
+	NoiseDB        float64  `json:"noise_floor_dbfs"`
+	ToneRMSDB      float64  `json:"tone_rms_dbfs"`
+	TonePeakDB     float64  `json:"tone_peak_dbfs"`
+	SNR            float64  `json:"snr_db"`
+	Clipped        bool     `json:"clipped"`
+	Correlation    float64  `json:"chirp_correlation"`
+	LatencyMs      float64  `json:"latency_ms"`
+}
+
+// runAudioTest 执行 `xiaozhi audio-test` 子命令：播放测试信号，
+// 经 Opus 编解码回环录制后检查电平、削波和延迟，返回进程退出码
+func runAudioTest(args []string) int {
+	fs := flag.NewFlagSet("audio-test", flag.ContinueOnError)
+	configPath := fs.String("c", "", "Path to config file")
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON")
+	volume := fs.Int("volume", 80, "Test signal level (1-100)")
+	minSNR := fs.Float64("min-snr", 10, "Minimum tone-to-noise ratio in dB")
+	minLevel := fs.Float64("min-level", -45, "Minimum recorded tone level in dBFS")
+	minCorrelation := fs.Float64("min-correlation", 0.3, "Minimum normalized chirp correlation")
+	maxLatency := fs.Duration("max-latency", 500*time.Millisecond, "Maximum round-trip latency")
+	verbose := fs.Bool("v", false, "Verbose logging")
+	if err := fs.Parse(args); err != nil {
+		return audioTestSetupError
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audio-test: %v\n", err)
+		return audioTestSetupError
+	}
+	audioCfg := core.NewAudioConfig(cfg)
+	if audioCfg.SampleRate <= 0 || audioCfg.Channels <= 0 || audioCfg.FrameDuration <= 0 {
+		fmt.Fprintf(os.Stderr, "audio-test: invalid audio config: %+v\n", audioCfg)
+		return audioTestSetupError
+	}
+
+	report := &audioTestReport{
+		SampleRate: audioCfg.SampleRate,
+		Channels:   audioCfg.Channels,
+	}
+
+	// 设备名称仅用于报告，枚举失败不影响测试
+	if devices, err := audio.ListDevices(); err != nil {
+		log.Warn("Failed to list audio devices", "error", err)
+	} else {
+		report.CaptureDevice = audio.DefaultDeviceName(devices, audio.DeviceCapture)
+		report.PlaybackDevice = audio.DefaultDeviceName(devices, audio.DevicePlayback)
+	}
+
+	manager, err := audio.NewManager(audioCfg, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audio-test: failed to create audio manager: %v\n", err)
+		return audioTestSetupError
+	}
+	defer manager.Close()
+
+	if err := runLoopback(manager, audioCfg, *volume, report); err != nil {
+		fmt.Fprintf(os.Stderr, "audio-test: %v\n", err)
+		return audioTestSetupError
+	}
+
+	// 判定
+	if report.Packets == 0 {
+		report.Failures = append(report.Failures, "no audio captured from microphone")
+	}
+	if report.DecodeErrors > 0 {
+		report.Failures = append(report.Failures, fmt.Sprintf("%d opus packets failed to decode", report.DecodeErrors))
+	}
+	if report.Packets > 0 {
+		if report.ToneRMSDB < *minLevel {
+			report.Failures = append(report.Failures, fmt.Sprintf("tone level %.1f dBFS below %.1f dBFS", report.ToneRMSDB, *minLevel))
+		}
+		if report.SNR < *minSNR {
+			report.Failures = append(report.Failures, fmt.Sprintf("SNR %.1f dB below %.1f dB", report.SNR, *minSNR))
+		}
+		if report.Clipped {
+			report.Failures = append(report.Failures, "captured audio is clipping")
+		}
+		if report.Correlation < *minCorrelation {
+			report.Failures = append(report.Failures, fmt.Sprintf("chirp not detected (correlation %.2f < %.2f)", report.Correlation, *minCorrelation))
+		} else if report.LatencyMs > float64(maxLatency.Milliseconds()) {
+			report.Failures = append(report.Failures, fmt.Sprintf("latency %.0f ms above %d ms", report.LatencyMs, maxLatency.Milliseconds()))
+		}
+	}
+	report.Pass = len(report.Failures) == 0
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printAudioTestReport(report)
+	}
+
+	if !report.Pass {
+		return audioTestFail
+	}
+	return audioTestPass
+}
+
+// runLoopback 边录音边播放测试信号，录音经 Opus 解码后进行分析
+func runLoopback(m audio.Manager, cfg audio.Config, volume int, report *audioTestReport) error {
+	rate := cfg.SampleRate
+	samplesOf := func(d time.Duration) int { return int(d.Seconds() * float64(rate)) }
+
+	amplitude := audio.VolumeToGain(volume)
+	chirp := generateChirp(rate, samplesOf(testChirpDuration), amplitude)
+	tone := generateTone(rate, samplesOf(testToneDuration), amplitude)
+
+	// 测试信号：扫频 + 静音 + 测试音（单声道）
+	signal := make([]int16, 0, len(chirp)+samplesOf(testGap)+len(tone))
+	signal = append(signal, chirp...)
+	signal = append(signal, make([]int16, samplesOf(testGap))...)
+	signal = append(signal, tone...)
+
+	var (
+		mu           sync.Mutex
+		captured     []int16
+		firstPacket  time.Time
+		packets      int
+		decodeErrors int
+	)
+
+	dataChan := make(chan []byte, 64)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var data []byte
+			select {
+			case data = <-dataChan:
+			case <-stop:
+				return
+			}
+			pcm, err := m.Decode(data)
+			mu.Lock()
+			if packets == 0 {
+				firstPacket = time.Now()
+			}
+			packets++
+			if err != nil {
+				decodeErrors++
+			} else {
+				captured = append(captured, audio.ConvertChannels(pcm, cfg.Channels, 1)...)
+			}
+			mu.Unlock()
+		}
+	}()
+
+	if err := m.StartRecording(dataChan); err != nil {
+		return fmt.Errorf("failed to start recording: %w", err)
+	}
+
+	time.Sleep(testLeadIn)
+
+	// 记录播放提交时刻对应的录音样本位置，作为延迟的零点
+	mu.Lock()
+	playStart := len(captured)
+	if packets > 0 {
+		// 首包时刻对应的是第一帧的结束
+		elapsed := time.Since(firstPacket) + time.Duration(cfg.FrameDuration)*time.Millisecond
+		playStart = samplesOf(elapsed)
+	}
+	mu.Unlock()
+
+	_, playErr := audio.PlayClip(m, audio.ConvertChannels(signal, 1, cfg.Channels), cfg)
+	time.Sleep(time.Duration(len(signal))*time.Second/time.Duration(rate) + testTail)
+
+	// 录音协程可能仍持有数据通道，因此不关闭通道，只停止读取
+	m.StopRecording()
+	close(stop)
+	<-done
+
+	if playErr != nil {
+		return fmt.Errorf("failed to play test signal: %w", playErr)
+	}
+
+	report.Packets = packets
+	report.DecodeErrors = decodeErrors
+	if len(captured) == 0 {
+		return nil
+	}
+
+	// 底噪：播放开始前的录音
+	noiseEnd := playStart
+	if noiseEnd > len(captured) {
+		noiseEnd = len(captured)
+	}
+	noiseRMS, _, _ := audio.MeasureLevel(captured[:noiseEnd])
+	report.NoiseDB = audio.LevelToDB(noiseRMS)
+
+	// 延迟：在播放起点之后的窗口内寻找扫频信号
+	lag, corr := correlate(captured, chirp, playStart, playStart+samplesOf(testSearchWindow))
+	report.Correlation = corr
+	report.LatencyMs = float64(lag-playStart) * 1000 / float64(rate)
+	if corr <= 0 {
+		lag = playStart
+	}
+
+	// 测试音：跳过起止各 100ms 的过渡段
+	toneStart := lag + len(chirp) + samplesOf(testGap) + samplesOf(100*time.Millisecond)
+	toneEnd := lag + len(chirp) + samplesOf(testGap) + len(tone) - samplesOf(100*time.Millisecond)
+	if toneEnd > len(captured) {
+		toneEnd = len(captured)
+	}
+	if toneStart < toneEnd {
+		rms, peak, _ := audio.MeasureLevel(captured[toneStart:toneEnd])
+		report.ToneRMSDB = audio.LevelToDB(rms)
+		report.TonePeakDB = audio.LevelToDB(peak)
+	} else {
+		report.ToneRMSDB = -96
+		report.TonePeakDB = -96
+	}
+	report.SNR = report.ToneRMSDB - report.NoiseDB
+
+	_, _, report.Clipped = audio.MeasureLevel(captured)
+	return nil
+}
+
+// generateChirp 生成线性扫频信号
+func generateChirp(rate, n int, amplitude float64) []int16 {
+	pcm := make([]int16, n)
+	duration := float64(n) / float64(rate)
+	k := (testChirpEndHz - testChirpStartHz) / duration
+	for i := range pcm {
+		t := float64(i) / float64(rate)
+		phase := 2 * math.Pi * (testChirpStartHz*t + k*t*t/2)
+		pcm[i] = int16(math.Sin(phase) * amplitude * fadeEnvelope(i, n, rate) * math.MaxInt16)
+	}
+	return pcm
+}
+
+// generateTone 生成单频正弦测试音
+func generateTone(rate, n int, amplitude float64) []int16 {
+	pcm := make([]int16, n)
+	for i := range pcm {
+		t := float64(i) / float64(rate)
+		pcm[i] = int16(math.Sin(2*math.Pi*testToneHz*t) * amplitude * fadeEnvelope(i, n, rate) * math.MaxInt16)
+	}
+	return pcm
+}
+
+// fadeEnvelope 首尾线性淡入淡出
+func fadeEnvelope(i, n, rate int) float64 {
+	fade := int(testFade.Seconds() * float64(rate))
+	switch {
+	case fade <= 0:
+		return 1
+	case i < fade:
+		return float64(i) / float64(fade)
+	case i >= n-fade:
+		return float64(n-1-i) / float64(fade)
+	}
+	return 1
+}
+
+// correlate 在 [from, to) 范围内搜索与参考信号归一化互相关最大的位置
+func correlate(signal, ref []int16, from, to int) (int, float64) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(signal)-len(ref)+1 {
+		to = len(signal) - len(ref) + 1
+	}
+	if len(ref) == 0 || from >= to {
+		return from, 0
+	}
+
+	var refEnergy float64
+	for _, s := range ref {
+		refEnergy += float64(s) * float64(s)
+	}
+
+	// 前缀平方和，快速计算各窗口能量
+	prefix := make([]float64, len(signal)+1)
+	for i, s := range signal {
+		prefix[i+1] = prefix[i] + float64(s)*float64(s)
+	}
+
+	bestLag, best := from, 0.0
+	for lag := from; lag < to; lag++ {
+		energy := prefix[lag+len(ref)] - prefix[lag]
+		if energy <= 0 {
+			continue
+		}
+		var dot float64
+		window := signal[lag : lag+len(ref)]
+		for i, r := range ref {
+			dot += float64(window[i]) * float64(r)
+		}
+		corr := dot / math.Sqrt(refEnergy*energy)
+		if corr > best {
+			best, bestLag = corr, lag
+		}
+	}
+	return bestLag, best
+}
+
+// printAudioTestReport 输出可读的检测报告
+func printAudioTestReport(r *audioTestReport) {
+	deviceName := func(name string) string {
+		if name == "" {
+			return "(unknown)"
+		}
+		return name
+	}
+
+	fmt.Println("xiaozhi audio loopback test")
+	fmt.Printf("  Capture device:    %s\n", deviceName(r.CaptureDevice))
+	fmt.Printf("  Playback device:   %s\n", deviceName(r.PlaybackDevice))
+	fmt.Printf("  Format:            %d Hz, %d ch\n", r.SampleRate, r.Channels)
+	fmt.Printf("  Opus packets:      %d (%d decode errors)\n", r.Packets, r.DecodeErrors)
+	fmt.Printf("  Noise floor:       %.1f dBFS\n", r.NoiseDB)
+	fmt.Printf("  Tone level:        %.1f dBFS RMS, %.1f dBFS peak\n", r.ToneRMSDB, r.TonePeakDB)
+	fmt.Printf("  SNR:               %.1f dB\n", r.SNR)
+	fmt.Printf("  Clipping:          %v\n", r.Clipped)
+	fmt.Printf("  Chirp correlation: %.2f\n", r.Correlation)
+	fmt.Printf("  Latency:           %.0f ms\n", r.LatencyMs)
+
+	if r.Pass {
+		fmt.Println("RESULT: PASS")
+		return
+	}
+	fmt.Println("RESULT: FAIL")
+	for _, f := range r.Failures {
+		fmt.Printf("  - %s\n", f)
+	}
+}
@@ -10,12 +10,18 @@ import (
 	"time"
 
 	"github.com/lisuiheng/xiaozhi-go/core"
+	"github.com/lisuiheng/xiaozhi-go/earcon"
 	"github.com/lisuiheng/xiaozhi-go/input"
 	"github.com/lisuiheng/xiaozhi-go/logger"
 	"github.com/spf13/viper"
 )
 
 func main() {
+	// 子命令：音频回环自检
+	if len(os.Args) > 1 && os.Args[1] == "audio-test" {
+		os.Exit(runAudioTest(os.Args[2:]))
+	}
+
 	// 加载配置
 	//cfg, err := loadConfig("D:\\GolandProjects\\xiaozhi-go\\config\\config.yaml")
 	//cfg, err := loadConfig("/media/lee/48624A91624A8422/GolandProjects/xiaozhi-go/config/config.yaml")
@@ -82,6 +88,8 @@ func main() {
 				// 等待一下确保连接建立
 				time.Sleep(500 * time.Millisecond)
 			}
+			// 等提示音播完再开麦，避免录入提示音
+			client.PlayEarconAndWait(earcon.EventWake)
 			if err := client.SendStartListening(core.ListenModeAuto); err != nil {
 				logger.Warn("Failed to start listening", "error", err)
 			} else {
@@ -120,11 +128,23 @@ func main() {
 				time.Sleep(500 * time.Millisecond)
 			}
 			// 启动自动监听
+			// 等提示音播完再开麦，避免录入提示音
+			client.PlayEarconAndWait(earcon.EventWake)
 			if err := client.SendStartListening(core.ListenModeAuto); err != nil {
 				logger.Warn("Failed to start listening", "error", err)
 			} else {
 				logger.Info("Started listening from clock mode")
 			}
+		case "alarm_snooze":
+			// 闹钟响铃时单击：贪睡
+			if err := client.SnoozeAlarm(); err != nil {
+				logger.Debug("Failed to snooze alarm", "error", err)
+			}
+		case "alarm_dismiss":
+			// 响铃时双击：关闭闹钟
+			if err := client.DismissAlarm(); err != nil {
+				logger.Debug("Failed to dismiss alarm", "error", err)
+			}
 		case "reset":
 			// 双击按键：重置为初始状态
 			logger.Info("Resetting to initial state...")
@@ -5,17 +5,26 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/lisuiheng/xiaozhi-go/alarm"
 	"github.com/lisuiheng/xiaozhi-go/audio"
 	"github.com/lisuiheng/xiaozhi-go/display"
+	"github.com/lisuiheng/xiaozhi-go/earcon"
+	"github.com/lisuiheng/xiaozhi-go/mpd"
 	"github.com/lisuiheng/xiaozhi-go/music"
 	"github.com/lisuiheng/xiaozhi-go/pkg/interfaces"
+	"github.com/lisuiheng/xiaozhi-go/prompt"
 	"github.com/lisuiheng/xiaozhi-go/protocols/websocket"
+	"github.com/lisuiheng/xiaozhi-go/upnp"
 	"log/slog"
 	"sync"
+	"sync/atomic"
 )
 
 // DisplayMode 显示模式
@@ -26,6 +35,7 @@ const (
 	DisplayModeClock   DisplayMode = "clock"   // 时钟模式，显示时间
 	DisplayModeDialog  DisplayMode = "dialog"  // 对话模式，显示对话
 	DisplayModeMusic   DisplayMode = "music"   // 音乐模式，显示音乐信息
+	DisplayModeAlarm   DisplayMode = "alarm"   // 闹钟响铃画面
 )
 
 type Client struct {
@@ -39,7 +49,11 @@ type Client struct {
 	audioSendChan chan []byte
 	wg            sync.WaitGroup
 	logger        *slog.Logger
-	audioManager  audio.Manager // 统一的音频管理器
+	audioManager  audio.Manager // 统一的音频管理器，重置和音乐播放后会被替换，通过 getAudioManager 读取
+	audioMu       sync.RWMutex
+	audioReleased bool             // 播放音乐期间音频设备已释放，音频管理器为 nil
+	pendingEarcon []earcon.Event   // 设备释放期间的提示音，音乐结束重建音频管理器后播放
+	voiceEQ       *audio.Equalizer // 语音播放（TTS、提示音）的均衡器
 	displayCtrl   *display.DisplayController
 
 	// 显示模式管理
@@ -52,6 +66,27 @@ type Client struct {
 
 	// 音乐播放器
 	musicPlayer *music.Player
+	mpdServer   *mpd.Server    // 局域网 MPD 客户端的控制接口
+	renderer    *upnp.Renderer // UPnP/DLNA 投屏
+	volume      atomic.Int32   // 扬声器音量，尚未读取或设置时为 -1
+	volumeProbe sync.Once      // 首次查询音量时从 amixer 读取
+
+	// 状态提示音
+	earcons *earcon.Player
+
+	// 闹钟
+	alarms       *alarm.Manager
+	ringMu       sync.Mutex
+	ringStop     chan struct{} // 关闭时停止当前响铃
+	ringSongPath string        // 响铃时播放的曲库歌曲，为空表示使用内置铃声
+	ringPrevMode DisplayMode   // 响铃前的显示模式，响铃结束后恢复
+
+	// 离线语音提示
+	prompts *prompt.Library
+
+	// 电平数据，供监听/说话动画使用
+	captureLevelChan  chan float64
+	playbackLevelChan chan float64
 }
 
 // Config 是客户端配置结构（已调整为匹配YAML文件的结构）
@@ -75,6 +110,12 @@ type Config struct {
 		Channels       int    `mapstructure:"channels"`
 		FrameDuration  int    `mapstructure:"frame_duration"`
 		SilenceTimeout string `mapstructure:"silence_timeout"`
+		StallTimeout   int    `mapstructure:"stall_timeout"` // 毫秒，设备无回调超时视为故障
+		// 扬声器均衡器，语音（TTS、提示音）和音乐分别设置
+		EQ struct {
+			Voice EQConfig `mapstructure:"voice"`
+			Music EQConfig `mapstructure:"music"`
+		} `mapstructure:"eq"`
 	} `mapstructure:"audio"`
 
 	Display struct {
@@ -89,16 +130,76 @@ type Config struct {
 		TimeFormat    string            `mapstructure:"time_format"`
 		DateFormat    string            `mapstructure:"date_format"`
 		EmotionDirs   map[string]string `mapstructure:"emotion_dirs"`
+		// 监听/说话时使用电平驱动的动画代替表情图片
+		ReactiveAnimations bool `mapstructure:"reactive_animations"`
 	} `mapstructure:"display"`
 
 	Music struct {
 		Enabled          bool     `mapstructure:"enabled"`
 		MusicPath        string   `mapstructure:"music_path"`
 		SupportedFormats []string `mapstructure:"supported_formats"`
-		AnimationPath    string   `mapstructure:"animation_path"`
+		AnimationPath    string   `mapstructure:"animation_path"` // 没有封面时在封面位置循环播放的动画目录
 		ShowSongName     bool     `mapstructure:"show_song_name"`
+		Layout           string   `mapstructure:"layout"`        // now_playing（默认，封面卡片）或 visualizer（只显示频谱）
+		ShowLyrics       bool     `mapstructure:"show_lyrics"`   // 显示同目录 .lrc 或内嵌的同步歌词
+		PlayMode         string   `mapstructure:"play_mode"`     // once/sequential/repeat_all/repeat_one/shuffle
+		Normalization    string   `mapstructure:"normalization"` // 音量标准化：off/track/album，没有 ReplayGain 标签时扫描估算响度
+		Crossfade        float64  `mapstructure:"crossfade"`     // 曲目之间交叉淡化的秒数（0-10），为 0 时无缝衔接
+		IndexPath        string   `mapstructure:"index_path"`    // 曲库索引文件，为空时使用用户缓存目录
+		Watch            bool     `mapstructure:"watch"`         // 监听目录变化并自动更新曲库
+		PlaylistPath     string   `mapstructure:"playlist_path"` // 用户播放列表文件，为空时使用用户配置目录
+		StatePath        string   `mapstructure:"state_path"`    // 播放位置和历史记录文件，为空时使用用户配置目录
+		// 没有内置解码器的格式（AAC、M4A 等）交给 ffmpeg 子进程解码，默认关闭
+		ExternalDecoder bool `mapstructure:"external_decoder"`
+		// 插入 U 盘等存储时自动加入曲库，拔出后移除；mount_roots 为空时监听 /media 和 /run/media
+		Removable struct {
+			Enabled    bool     `mapstructure:"enabled"`
+			MountRoots []string `mapstructure:"mount_roots"`
+		} `mapstructure:"removable"`
+		// 不短于该时长（秒）的曲目再次播放时从上次停止的位置继续，为 0 时使用默认的 10 分钟
+		ResumeMinDuration int `mapstructure:"resume_min_duration"`
+		// 频谱可视化：柱数（1-64，默认 16）和 "#RRGGBB" 颜色，为空时使用默认颜色
+		Visualizer struct {
+			Bars        int    `mapstructure:"bars"`
+			BottomColor string `mapstructure:"bottom_color"`
+			TopColor    string `mapstructure:"top_color"`
+			PeakColor   string `mapstructure:"peak_color"`
+		} `mapstructure:"visualizer"`
 	} `mapstructure:"music"`
 
+	Radio struct {
+		Stations []struct {
+			Name string `mapstructure:"name"`
+			URL  string `mapstructure:"url"` // HTTP(S) 音频流：MP3、Ogg Vorbis、Ogg Opus，AAC 需开启 music.external_decoder
+		} `mapstructure:"stations"`
+	} `mapstructure:"radio"`
+
+	// MPD 协议服务器，手机上的 MPD 客户端可以浏览曲库和控制播放
+	MPD struct {
+		Enabled bool   `mapstructure:"enabled"`
+		Listen  string `mapstructure:"listen"` // 监听地址，默认 ":6600"
+	} `mapstructure:"mpd"`
+
+	// UPnP/DLNA 媒体渲染器，手机上的音乐应用可以把音频投屏到设备上
+	UPnP struct {
+		Enabled bool   `mapstructure:"enabled"`
+		Name    string `mapstructure:"name"`   // 控制点中显示的设备名称，默认 "小智音箱"
+		Listen  string `mapstructure:"listen"` // HTTP 服务地址，默认 ":49494"
+	} `mapstructure:"upnp"`
+
+	// 闹钟：一次性或重复（工作日、cron 表达式）闹钟，保存到磁盘，重启后继续生效
+	Alarm struct {
+		Enabled       bool   `mapstructure:"enabled"`
+		Path          string `mapstructure:"path"`           // 闹钟文件，为空时使用用户配置目录
+		Song          string `mapstructure:"song"`           // 默认铃声：曲库中的歌名，为空或找不到时使用内置铃声
+		SnoozeMinutes int    `mapstructure:"snooze_minutes"` // 贪睡时长，默认 9 分钟
+		RingMinutes   int    `mapstructure:"ring_minutes"`   // 无人响应时响铃多久后自动停止，默认 10 分钟
+	} `mapstructure:"alarm"`
+
+	Earcons earcon.Config `mapstructure:"earcons"`
+
+	Prompts prompt.Config `mapstructure:"prompts"`
+
 	Logging struct {
 		Level   string   `mapstructure:"level"`
 		Outputs []string `mapstructure:"outputs"`
@@ -122,6 +223,36 @@ type TextAlignConfig struct {
 	Vertical   int `mapstructure:"vertical"`
 }
 
+// EQConfig 均衡器配置：预设（voice/music/night/bass_boost/flat）加自定义频段
+type EQConfig struct {
+	Preset string         `mapstructure:"preset"`
+	Bands  []audio.EQBand `mapstructure:"bands"` // 在预设之后叠加
+}
+
+// bands 合并预设和自定义频段
+func (cfg EQConfig) bands() ([]audio.EQBand, error) {
+	var bands []audio.EQBand
+	if cfg.Preset != "" {
+		preset, err := audio.EQPreset(cfg.Preset)
+		if err != nil {
+			return nil, err
+		}
+		bands = preset
+	}
+	return append(bands, cfg.Bands...), nil
+}
+
+// applyEQ 按配置设置均衡器，配置无效时保持关闭
+func applyEQ(eq *audio.Equalizer, cfg EQConfig, name string, log *slog.Logger) {
+	bands, err := cfg.bands()
+	if err == nil {
+		err = eq.SetBands(bands)
+	}
+	if err != nil {
+		log.Warn("Invalid EQ config, disabling", "target", name, "error", err)
+	}
+}
+
 // DeviceState 表示设备状态
 type DeviceState string
 
@@ -148,6 +279,7 @@ type Status struct {
 	State            DeviceState
 	SessionID        string
 	ConnectionStatus string
+	AudioDevice      audio.DeviceStatus
 }
 
 // NewClient 创建一个新的 xiaozhi 客户端
@@ -157,14 +289,13 @@ func NewClient(cfg Config, log *slog.Logger) (*Client, error) {
 	}
 
 	// 创建统一的音频管理器
-	audioManager, err := audio.NewManager(
-		audio.Config{
-			SampleRate:    cfg.Audio.SampleRate,
-			Channels:      cfg.Audio.Channels,
-			FrameDuration: cfg.Audio.FrameDuration,
-		},
-		log,
-	)
+	// 语音播放的均衡器由客户端持有，音乐模式重建音频管理器后保留设置
+	voiceEQ := audio.NewEqualizer()
+	applyEQ(voiceEQ, cfg.Audio.EQ.Voice, "voice", log)
+	audioCfg := NewAudioConfig(cfg)
+	audioCfg.Equalizer = voiceEQ
+
+	audioManager, err := audio.NewManager(audioCfg, log)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create audio manager: %w", err)
 	}
@@ -175,13 +306,66 @@ func NewClient(cfg Config, log *slog.Logger) (*Client, error) {
 	// 初始化音乐播放器
 	var musicPlayer *music.Player
 	if cfg.Music.Enabled {
+		if cfg.Music.ExternalDecoder {
+			if err := music.SetExternalDecoder(true); err != nil {
+				log.Warn("External music decoder unavailable, using built-in decoders only", "error", err)
+			}
+		}
 		musicPlayer = music.NewPlayer(cfg.Music.MusicPath, cfg.Music.SupportedFormats, log)
+		if cfg.Music.IndexPath != "" {
+			musicPlayer.SetIndexPath(cfg.Music.IndexPath)
+		}
+		if cfg.Music.PlaylistPath != "" {
+			musicPlayer.SetPlaylistPath(cfg.Music.PlaylistPath)
+		}
+		if cfg.Music.StatePath != "" {
+			musicPlayer.SetStatePath(cfg.Music.StatePath)
+		}
+		if cfg.Music.ResumeMinDuration > 0 {
+			musicPlayer.SetResumeMinDuration(time.Duration(cfg.Music.ResumeMinDuration) * time.Second)
+		}
+		if cfg.Music.Visualizer.Bars > 0 {
+			musicPlayer.SetSpectrumBands(cfg.Music.Visualizer.Bars)
+		}
+		applyEQ(musicPlayer.Equalizer(), cfg.Audio.EQ.Music, "music", log)
+		if mode, err := music.ParseNormalization(cfg.Music.Normalization); err != nil {
+			log.Warn("Invalid music normalization mode, disabling", "error", err)
+		} else {
+			_ = musicPlayer.SetNormalization(mode)
+		}
+		if cfg.Music.Crossfade > 0 {
+			if err := musicPlayer.SetCrossfade(time.Duration(cfg.Music.Crossfade * float64(time.Second))); err != nil {
+				log.Warn("Invalid music crossfade, disabling", "error", err)
+			}
+		}
 		if err := musicPlayer.LoadSongs(); err != nil {
 			log.Warn("Failed to load music", "error", err)
+		} else if cfg.Music.Watch {
+			if err := musicPlayer.WatchLibrary(); err != nil {
+				log.Warn("Failed to watch music library", "error", err)
+			}
+		}
+		if len(cfg.Radio.Stations) > 0 {
+			stations := make([]music.SongInfo, 0, len(cfg.Radio.Stations))
+			for _, station := range cfg.Radio.Stations {
+				if err := music.CheckStation(station.URL); err != nil {
+					log.Warn("Skipping radio station", "name", station.Name, "url", station.URL, "error", err)
+					continue
+				}
+				stations = append(stations, music.SongInfo{Name: station.Name, Path: station.URL})
+			}
+			musicPlayer.SetStations(stations)
+		}
+		if cfg.Music.PlayMode != "" {
+			if mode, err := music.ParseMode(cfg.Music.PlayMode); err != nil {
+				log.Warn("Invalid music play mode, using default", "error", err)
+			} else {
+				_ = musicPlayer.SetMode(mode)
+			}
 		}
 	}
 
-	return &Client{
+	client := &Client{
 		config:        cfg,
 		state:         DeviceStateUnknown,
 		closeChan:     make(chan struct{}),
@@ -189,10 +373,106 @@ func NewClient(cfg Config, log *slog.Logger) (*Client, error) {
 		audioSendChan: make(chan []byte, 100),
 		logger:        log,
 		audioManager:  audioManager,
+		voiceEQ:       voiceEQ,
 		displayCtrl:   displayCtrl,
 		displayMode:   DisplayModeEmotion,
 		musicPlayer:   musicPlayer,
-	}, nil
+		earcons:       earcon.NewPlayer(cfg.Earcons, NewAudioConfig(cfg), log),
+		prompts:       prompt.NewLibrary(cfg.Prompts, NewAudioConfig(cfg), log),
+
+		captureLevelChan:  make(chan float64, 8),
+		playbackLevelChan: make(chan float64, 8),
+	}
+
+	client.volume.Store(-1)
+	client.watchAudioManager(audioManager)
+	if musicPlayer != nil && cfg.Music.Removable.Enabled {
+		if err := musicPlayer.WatchMedia(cfg.Music.Removable.MountRoots, client.onMediaChanged); err != nil {
+			log.Warn("Failed to watch removable media", "error", err)
+		}
+	}
+	if musicPlayer != nil && cfg.MPD.Enabled {
+		server := mpd.NewServer(cfg.MPD.Listen, cfg.Music.MusicPath, musicPlayer, client, log)
+		if err := server.Start(); err != nil {
+			log.Warn("Failed to start MPD server", "error", err)
+		} else {
+			client.mpdServer = server
+		}
+	}
+	if musicPlayer != nil && cfg.UPnP.Enabled {
+		renderer := upnp.NewRenderer(cfg.UPnP.Name, cfg.UPnP.Listen, musicPlayer, client, log)
+		if err := renderer.Start(); err != nil {
+			log.Warn("Failed to start UPnP renderer", "error", err)
+		} else {
+			client.renderer = renderer
+		}
+	}
+	if cfg.Alarm.Enabled {
+		client.alarms = alarm.NewManager(cfg.Alarm.Path, log)
+		if cfg.Alarm.RingMinutes > 0 {
+			client.alarms.SetRingTimeout(time.Duration(cfg.Alarm.RingMinutes) * time.Minute)
+		}
+		client.alarms.Start(client.onAlarmRing, client.onAlarmStop)
+	}
+
+	return client, nil
+}
+
+// NewAudioConfig 根据客户端配置生成音频配置
+func NewAudioConfig(cfg Config) audio.Config {
+	return audio.Config{
+		SampleRate:    cfg.Audio.SampleRate,
+		Channels:      cfg.Audio.Channels,
+		FrameDuration: cfg.Audio.FrameDuration,
+		StallTimeout:  cfg.Audio.StallTimeout,
+	}
+}
+
+// audioConfig 创建音频管理器使用的配置，带上语音均衡器
+func (c *Client) audioConfig() audio.Config {
+	cfg := NewAudioConfig(c.config)
+	cfg.Equalizer = c.voiceEQ
+	return cfg
+}
+
+// watchAudioManager 订阅音频管理器的设备事件与电平数据
+func (c *Client) watchAudioManager(m audio.Manager) {
+	go c.watchAudioEvents(m)
+	go c.watchAudioLevels(m)
+}
+
+// watchAudioLevels 将电平数据分发给显示动画，管理器关闭后退出
+func (c *Client) watchAudioLevels(m audio.Manager) {
+	for lv := range m.Levels() {
+		target := c.captureLevelChan
+		if lv.Source == audio.LevelPlayback {
+			target = c.playbackLevelChan
+		}
+		select {
+		case target <- lv.RMS:
+		default:
+		}
+	}
+}
+
+// watchAudioEvents 处理音频设备故障与恢复事件，管理器关闭后退出
+func (c *Client) watchAudioEvents(m audio.Manager) {
+	for ev := range m.Events() {
+		switch ev.Type {
+		case audio.DeviceEventLost:
+			c.logger.Error("Audio device lost, recovering in background",
+				"kind", ev.Kind, "error", ev.Err)
+			// 播放设备仍可用时提示麦克风故障
+			if ev.Kind == audio.DeviceCapture {
+				c.PlayPrompt(prompt.KeyMicUnavailable)
+			}
+		case audio.DeviceEventRetry:
+			c.logger.Warn("Audio device recovery attempt failed",
+				"kind", ev.Kind, "attempt", ev.Attempt, "error", ev.Err)
+		case audio.DeviceEventRestored:
+			c.logger.Info("Audio device restored", "kind", ev.Kind, "attempts", ev.Attempt)
+		}
+	}
 }
 
 func (c *Client) Connect(ctx context.Context) error {
@@ -211,7 +491,12 @@ func (c *Client) Connect(ctx context.Context) error {
 	if err := transport.Connect(ctx); err != nil {
 		c.setState(DeviceStateUnknown)
 		c.logger.Error("Failed to connect to server", "error", err)
-		return fmt.Errorf("failed to connect to server: %v", err)
+		if errors.Is(err, interfaces.ErrAuthFailed) {
+			c.PlayPrompt(prompt.KeyAuthFailed)
+			return fmt.Errorf("%w: %v", ErrAuthFailed, err)
+		}
+		c.PlayPrompt(prompt.KeyConnectFailed)
+		return fmt.Errorf("%w: %v", ErrConnectionFailed, err)
 	}
 
 	c.transport = transport
@@ -327,7 +612,7 @@ func (c *Client) StopListening() error {
 
 // 修改后的 SendAudio（不再管理状态）
 func (c *Client) SendAudio(data []byte) error {
-	if c.audioManager == nil || !c.audioManager.IsRecording() {
+	if m := c.getAudioManager(); m == nil || !m.IsRecording() {
 		return errors.New("audio stream not started")
 	}
 
@@ -361,10 +646,16 @@ func (c *Client) GetStatus() Status {
 		connStatus = "connected"
 	}
 
+	var audioStatus audio.DeviceStatus
+	if m := c.getAudioManager(); m != nil {
+		audioStatus = m.DeviceStatus()
+	}
+
 	return Status{
 		State:            c.state,
 		SessionID:        c.sessionID,
 		ConnectionStatus: connStatus,
+		AudioDevice:      audioStatus,
 	}
 }
 
@@ -373,18 +664,37 @@ func (c *Client) Close() error {
 	c.logger.Info("Closing client connection")
 	close(c.closeChan)
 
-	// 停止音乐播放
-	if c.musicPlayer != nil && c.musicPlayer.IsPlaying() {
-		c.logger.Info("Stopping music before exit")
-		c.musicPlayer.Stop()
+	if c.mpdServer != nil {
+		if err := c.mpdServer.Close(); err != nil {
+			c.logger.Warn("Failed to close MPD server", "error", err)
+		}
+	}
+	if c.renderer != nil {
+		if err := c.renderer.Close(); err != nil {
+			c.logger.Warn("Failed to close UPnP renderer", "error", err)
+		}
+	}
+	if c.alarms != nil {
+		c.alarms.Close()
+		c.stopRinging()
+	}
+
+	// 停止音乐播放和曲库监听
+	if c.musicPlayer != nil {
+		if c.musicPlayer.IsPlaying() {
+			c.logger.Info("Stopping music before exit")
+		}
+		if err := c.musicPlayer.Close(); err != nil {
+			c.logger.Warn("Failed to close music player", "error", err)
+		}
 	}
 
 	// 停止音频采集
 	c.StopAudioCapture()
 
 	// 关闭音频管理器，释放所有音频资源
-	if c.audioManager != nil {
-		if err := c.audioManager.Close(); err != nil {
+	if m := c.getAudioManager(); m != nil {
+		if err := m.Close(); err != nil {
 			c.logger.Warn("Failed to close audio manager", "error", err)
 		}
 	}
@@ -439,33 +749,136 @@ func (c *Client) StopMusic() {
 	}
 }
 
+// PlayEarcon 异步播放事件提示音
+func (c *Client) PlayEarcon(ev earcon.Event) {
+	go c.playEarcon(ev)
+}
+
+// playEarcon 播放事件提示音，返回音效时长
+func (c *Client) playEarcon(ev earcon.Event) time.Duration {
+	if c.earcons == nil {
+		return 0
+	}
+	d, err := c.earcons.Play(c.getAudioManager(), ev)
+	if err != nil {
+		c.logger.Debug("Failed to play earcon", "event", ev, "error", err)
+	}
+	return d
+}
+
+// playEarconDeferred 播放提示音，播放音乐期间音频设备已释放时推迟到音乐结束后播放
+func (c *Client) playEarconDeferred(ev earcon.Event) {
+	c.audioMu.Lock()
+	if c.audioReleased {
+		if !slices.Contains(c.pendingEarcon, ev) {
+			c.pendingEarcon = append(c.pendingEarcon, ev)
+		}
+		c.audioMu.Unlock()
+		c.logger.Debug("Audio device released for music, deferring earcon", "event", ev)
+		return
+	}
+	c.audioMu.Unlock()
+	c.PlayEarcon(ev)
+}
+
+// PlayEarconAndWait 播放提示音并等待其播放完成，随后要开麦时使用，避免提示音被录入
+func (c *Client) PlayEarconAndWait(ev earcon.Event) {
+	if d := c.playEarcon(ev); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// PlayPrompt 异步播放离线语音提示，未录制对应语音时改用提示音
+func (c *Client) PlayPrompt(key prompt.Key) {
+	go c.playPrompt(key)
+}
+
+// playPrompt 播放离线语音提示
+func (c *Client) playPrompt(key prompt.Key) {
+	if c.prompts != nil && c.prompts.Enabled() {
+		_, err := c.prompts.Play(c.getAudioManager(), key)
+		if err == nil {
+			return
+		}
+		c.logger.Debug("Voice prompt unavailable, falling back to earcon", "key", key, "error", err)
+	}
+
+	switch key {
+	case prompt.KeyReconnected:
+		c.playEarcon(earcon.EventReconnected)
+	default:
+		c.playEarcon(earcon.EventError)
+	}
+}
+
+// classifyServerError 根据服务器错误信息选择语音提示
+func classifyServerError(message string) prompt.Key {
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "activat") || strings.Contains(message, "激活"):
+		return prompt.KeyActivationRequired
+	case strings.Contains(lower, "auth") || strings.Contains(lower, "token") ||
+		strings.Contains(lower, "unauthorized") || strings.Contains(message, "鉴权") ||
+		strings.Contains(message, "认证"):
+		return prompt.KeyAuthFailed
+	default:
+		return prompt.KeyServerError
+	}
+}
+
+// getAudioManager 当前的音频管理器
+func (c *Client) getAudioManager() audio.Manager {
+	c.audioMu.RLock()
+	defer c.audioMu.RUnlock()
+	return c.audioManager
+}
+
+// setAudioManager 替换音频管理器
+func (c *Client) setAudioManager(m audio.Manager) {
+	c.audioMu.Lock()
+	c.audioManager = m
+	c.audioReleased = false
+	c.audioMu.Unlock()
+}
+
+// playPendingEarcons 重建音频管理器后播放设备释放期间推迟的提示音
+func (c *Client) playPendingEarcons() {
+	c.audioMu.Lock()
+	pending := c.pendingEarcon
+	c.pendingEarcon = nil
+	c.audioMu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	go func() {
+		for _, ev := range pending {
+			c.PlayEarconAndWait(ev)
+		}
+	}()
+}
+
 // ResetAudioManager 重置音频管理器（关闭并重新创建）
 func (c *Client) ResetAudioManager() error {
 	c.logger.Info("Resetting audio manager...")
 
 	// 停止录音
-	if c.audioManager != nil {
-		c.audioManager.StopRecording()
+	if old := c.getAudioManager(); old != nil {
+		old.StopRecording()
 
 		// 关闭旧的音频管理器
-		if err := c.audioManager.Close(); err != nil {
+		if err := old.Close(); err != nil {
 			c.logger.Warn("Failed to close old audio manager", "error", err)
 		}
 	}
 
 	// 重新创建音频管理器
-	var err error
-	c.audioManager, err = audio.NewManager(
-		audio.Config{
-			SampleRate:    c.config.Audio.SampleRate,
-			Channels:      c.config.Audio.Channels,
-			FrameDuration: c.config.Audio.FrameDuration,
-		},
-		c.logger,
-	)
+	m, err := audio.NewManager(c.audioConfig(), c.logger)
+	c.setAudioManager(m)
 	if err != nil {
 		return fmt.Errorf("failed to recreate audio manager: %w", err)
 	}
+	c.watchAudioManager(m)
+	c.playPendingEarcons()
 
 	c.logger.Info("Audio manager has been reset successfully")
 	return nil
@@ -498,8 +911,18 @@ func (c *Client) setState(newState DeviceState) {
 			"from", oldState,
 			"to", newState)
 
+		// 状态提示音
+		switch {
+		case newState == DeviceStateDisconnected:
+			c.PlayEarcon(earcon.EventDisconnected)
+		case oldState == DeviceStateListening:
+			c.PlayEarcon(earcon.EventListenEnd)
+		}
+
 		// 只在表情模式下才根据状态显示表情
-		if c.GetDisplayModeEnum() == DisplayModeEmotion {
+		if c.GetDisplayModeEnum() == DisplayModeEmotion && c.showReactiveAnimation(newState) {
+			c.logger.Debug("Showing level-driven animation", "state", newState)
+		} else if c.GetDisplayModeEnum() == DisplayModeEmotion {
 			switch newState {
 			case DeviceStateSpeaking:
 				c.logger.Info("Attempting to show speaking emotion")
@@ -523,6 +946,29 @@ func (c *Client) setState(newState DeviceState) {
 	}
 }
 
+// showReactiveAnimation 在启用电平动画时为监听/说话状态显示动画，返回是否已处理
+func (c *Client) showReactiveAnimation(state DeviceState) bool {
+	if !c.config.Display.ReactiveAnimations || c.config.Display.SkipExecution {
+		return false
+	}
+
+	color := struct{ R, G, B uint8 }{R: 0, G: 200, B: 255}
+	var err error
+	switch state {
+	case DeviceStateListening:
+		err = c.displayCtrl.ShowListeningAnimation(c.captureLevelChan, color)
+	case DeviceStateSpeaking:
+		err = c.displayCtrl.ShowSpeakingAnimation(c.playbackLevelChan, color)
+	default:
+		return false
+	}
+	if err != nil {
+		c.logger.Warn("Failed to show level animation", "state", state, "error", err)
+		return false
+	}
+	return true
+}
+
 // 发送 JSON 消息
 func (c *Client) sendJSON(data interface{}) error {
 	if c.transport == nil {
@@ -601,18 +1047,19 @@ func (c *Client) messageHandler() {
 
 // 示例：处理接收到的 OPUS音频流
 func (c *Client) handleReceivedAudio(data []byte) error {
-	if c.audioManager == nil {
+	m := c.getAudioManager()
+	if m == nil {
 		return errors.New("audio manager not initialized")
 	}
 
 	// 1. 解码音频
-	pcmData, err := c.audioManager.Decode(data)
+	pcmData, err := m.Decode(data)
 	if err != nil {
 		return fmt.Errorf("audio decode failed: %w", err)
 	}
 
 	// 2. 播放音频
-	if err := c.audioManager.Play(pcmData); err != nil {
+	if err := m.Play(pcmData); err != nil {
 		return fmt.Errorf("audio play failed: %w", err)
 	}
 
@@ -666,7 +1113,7 @@ func (c *Client) audioSender() {
 				continue
 			}
 
-			if c.audioManager != nil && c.audioManager.IsRecording() {
+			if m := c.getAudioManager(); m != nil && m.IsRecording() {
 				if err := transport.Send(data, interfaces.MsgBinary); err != nil {
 					c.logger.Error("Failed to send audio", "error", err)
 					return
@@ -760,6 +1207,7 @@ func (c *Client) handleListenMessage(msg map[string]interface{}) error {
 		if text, ok := msg["text"].(string); ok {
 			c.logger.Info("Wake word detected", "text", text)
 		}
+		c.PlayEarcon(earcon.EventWake)
 	default:
 		c.logger.Debug("Received listen message", "state", state)
 	}
@@ -912,6 +1360,7 @@ func (c *Client) handleErrorMessage(message map[string]interface{}) error {
 		"session_id", sessionID,
 		"error", errorMsg,
 	)
+	c.PlayPrompt(classifyServerError(errorMsg))
 
 	// 可以根据错误类型进行不同的处理，例如重试、通知用户等
 	return fmt.Errorf("session %s error: %s", sessionID, errorMsg)
@@ -969,13 +1418,14 @@ func (c *Client) startAudioCapture() {
 	c.logger.Info("Starting audio capture")
 
 	// 使用 audioManager 统一管理音频采集
-	if c.audioManager == nil {
+	m := c.getAudioManager()
+	if m == nil {
 		c.logger.Error("Audio manager not initialized")
 		return
 	}
 
 	// 启动录音
-	if err := c.audioManager.StartRecording(c.audioSendChan); err != nil {
+	if err := m.StartRecording(c.audioSendChan); err != nil {
 		c.logger.Error("Failed to start recording", "error", err)
 		return
 	}
@@ -985,8 +1435,8 @@ func (c *Client) startAudioCapture() {
 
 // 添加 StopAudioCapture 方法
 func (c *Client) StopAudioCapture() {
-	if c.audioManager != nil {
-		c.audioManager.StopRecording()
+	if m := c.getAudioManager(); m != nil {
+		m.StopRecording()
 		c.logger.Info("Audio capture stopped")
 	}
 }
@@ -998,6 +1448,7 @@ func (c *Client) SendStartListening(mode ListenMode) error {
 	if currentState != DeviceStateIdle && currentState != DeviceStateConnecting {
 		// 如果处于 disconnected 状态，提示用户等待重连
 		if currentState == DeviceStateDisconnected {
+			c.PlayPrompt(prompt.KeyNotConnected)
 			return fmt.Errorf("device is disconnected, waiting for reconnection")
 		}
 		return fmt.Errorf("cannot start listening from state: %s", currentState)
@@ -1005,6 +1456,7 @@ func (c *Client) SendStartListening(mode ListenMode) error {
 
 	// 检查连接是否存在
 	if !c.IsConnected() {
+		c.PlayPrompt(prompt.KeyNotConnected)
 		return fmt.Errorf("not connected to server")
 	}
 
@@ -1377,6 +1829,69 @@ func init() {
 		},
 	)
 
+	// 注册均衡器工具
+	RegisterMCPTool(
+		"self.audio_speaker.set_eq",
+		"设置扬声器均衡器（音色）。语音和音乐分开设置：target=voice 只影响语音回复和提示音，music 只影响音乐，all 同时设置。可以选择预设或传自定义频段；preset=flat 关闭均衡器。不传 preset 和 bands 时只返回当前设置",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"target": map[string]interface{}{
+					"type":        "string",
+					"description": "voice（语音）、music（音乐）或 all（全部），默认 all",
+					"enum":        []string{"voice", "music", "all"},
+				},
+				"preset": map[string]interface{}{
+					"type":        "string",
+					"description": "预设：voice（人声清晰）、music（音乐）、night（夜间，减弱低音和高音）、bass_boost（低音增强）、flat（关闭）",
+					"enum":        audio.EQPresets(),
+				},
+				"bands": map[string]interface{}{
+					"type":        "array",
+					"description": "自定义频段，叠加在预设之后",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"type": map[string]interface{}{
+								"type": "string",
+								"enum": []string{audio.EQPeaking, audio.EQLowShelf, audio.EQHighShelf, audio.EQLowPass, audio.EQHighPass},
+							},
+							"frequency": map[string]interface{}{
+								"type":        "number",
+								"description": "频率（Hz）",
+							},
+							"gain": map[string]interface{}{
+								"type":        "number",
+								"description": "增益（dB，-15 到 15）",
+							},
+							"q": map[string]interface{}{
+								"type":        "number",
+								"description": "品质因数，默认 0.707",
+							},
+						},
+						"required": []string{"frequency"},
+					},
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册电平查询工具
+	RegisterMCPTool(
+		"self.audio.get_levels",
+		"获取麦克风采集与扬声器播放的实时电平（RMS/峰值 dBFS、削波帧数），用于诊断麦克风无声等问题",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	)
+
 	// 注册显示表情工具
 	RegisterMCPTool(
 		"self.display.show_emotion",
@@ -1485,7 +2000,7 @@ func init() {
 	// 注册音乐暂停工具
 	RegisterMCPTool(
 		"self.music.pause",
-		"暂停当前播放的音乐",
+		"暂停当前播放的音乐，已暂停时继续播放，返回暂停位置（秒）",
 		map[string]interface{}{
 			"type":       "object",
 			"properties": map[string]interface{}{},
@@ -1565,713 +2080,2166 @@ func init() {
 			return true, nil
 		},
 	)
-}
-
-// handleMCPMessage 处理 MCP 消息
-func (c *Client) handleMCPMessage(msg map[string]interface{}) error {
-	payload, ok := msg["payload"].(map[string]interface{})
-	if !ok {
-		return errors.New("MCP message missing payload field")
-	}
-
-	// 解析 MCP 请求
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	var mcpReq MCPRequest
-	if err := json.Unmarshal(payloadBytes, &mcpReq); err != nil {
-		return c.sendMCPError(mcpReq.ID, MCPErrorParseError, "Parse error", nil)
-	}
-
-	// 验证 JSONRPC 版本
-	if mcpReq.JSONRPC != MCPJSONRPCVersion {
-		return c.sendMCPError(mcpReq.ID, MCPErrorInvalidRequest, "Invalid JSON-RPC version", nil)
-	}
-
-	c.logger.Info("Received MCP request", "method", mcpReq.Method, "id", mcpReq.ID)
 
-	// 根据方法分发处理
-	switch mcpReq.Method {
-	case "initialize":
-		return c.handleMCPInitialize(mcpReq)
-	case "tools/list":
-		return c.handleMCPToolsList(mcpReq)
-	case "tools/call":
-		return c.handleMCPToolsCall(mcpReq)
-	case "notifications/initialized":
-		// 这是一个通知，不需要响应
-		c.logger.Info("MCP session initialized")
-		return nil
-	default:
-		return c.sendMCPError(mcpReq.ID, MCPErrorMethodNotFound, fmt.Sprintf("Method not found: %s", mcpReq.Method), nil)
-	}
-}
-
-// handleMCPInitialize 处理 initialize 请求
-func (c *Client) handleMCPInitialize(req MCPRequest) error {
-	// 解析参数（可选）
-	var params InitializeParams
-	if len(req.Params) > 0 {
-		if err := json.Unmarshal(req.Params, &params); err != nil {
-			return c.sendMCPError(req.ID, MCPErrorInvalidParams, "Invalid params", err.Error())
-		}
-	}
-
-	// 如果有视觉能力配置，可以保存
-	if params.Capabilities.Vision != nil {
-		c.logger.Info("Received vision capability",
-			"url", params.Capabilities.Vision.URL)
-	}
-
-	// 构建响应
-	result := InitializeResult{
-		ProtocolVersion: MCPProtocolVersion,
-		Capabilities: MCPCapabilities{
-			Tools: &ToolsCapability{
-				ListChanged: false,
+	// 注册歌曲搜索工具
+	RegisterMCPTool(
+		"self.music.search",
+		"按歌名、歌手或专辑模糊搜索本地音乐，支持拼音、首字母和同音字，返回按匹配度排序的歌曲（含索引）",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "搜索内容，可以是歌名、歌手、专辑或它们的组合，如“周杰伦 晴天”",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "最多返回的结果数，默认5",
+				},
 			},
+			"required": []string{"query"},
 		},
-		ServerInfo: MCPServerInfo{
-			Name:    "xiaozhi-go",
-			Version: "1.0.0",
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
 		},
-	}
-
-	return c.sendMCPResponse(req.ID, result)
-}
+	)
 
-// handleMCPToolsList 处理 tools/list 请求
-func (c *Client) handleMCPToolsList(req MCPRequest) error {
-	// 解析参数获取 cursor（用于分页，暂不支持）
-	cursor := ""
-	if len(req.Params) > 0 {
-		var params struct {
-			Cursor string `json:"cursor"`
+	// 注册按名称播放工具
+	RegisterMCPTool(
+		"self.music.play_by_name",
+		"按歌名播放本地音乐，自动选择最匹配的歌曲。用户说“播放周杰伦的晴天”时传 name=晴天, artist=周杰伦",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "歌名（也可以是专辑名，或只传歌手名播放该歌手的歌曲）",
+				},
+				"artist": map[string]interface{}{
+					"type":        "string",
+					"description": "歌手（可选）",
+				},
+			},
+			"required": []string{"name"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册睡眠定时工具
+	RegisterMCPTool(
+		"self.music.sleep_timer",
+		"音乐睡眠定时：N 分钟后或播完当前这首后自动停止播放，停止前逐渐降低音量。可以在开始播放前设置。action=set 设置（传 minutes 或 end_of_track=true），cancel 取消，query 查询剩余时间",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"action": map[string]interface{}{
+					"type":        "string",
+					"description": "set（设置）、cancel（取消）或 query（查询），默认 set",
+					"enum":        []string{"set", "cancel", "query"},
+				},
+				"minutes": map[string]interface{}{
+					"type":        "number",
+					"description": "多少分钟后停止",
+				},
+				"end_of_track": map[string]interface{}{
+					"type":        "boolean",
+					"description": "播完当前这首后停止",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册歌词工具
+	RegisterMCPTool(
+		"self.music.get_lyrics",
+		"获取正在播放的歌曲的歌词：当前唱到的这一句、上一句和下一句。用户问“这句唱的是什么”时使用；传 full=true 返回完整歌词",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"full": map[string]interface{}{
+					"type":        "boolean",
+					"description": "是否返回完整歌词，默认 false",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册播放记录工具
+	RegisterMCPTool(
+		"self.music.recent",
+		"获取最近播放的歌曲（含播放次数和上次停止的位置），传 sort=most_played 时按播放次数排序",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "最多返回的歌曲数，默认10",
+				},
+				"sort": map[string]interface{}{
+					"type":        "string",
+					"description": "排序方式：recent（最近播放，默认）或 most_played（播放最多）",
+					"enum":        []string{"recent", "most_played"},
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+	RegisterMCPTool(
+		"self.music.resume",
+		"从上次停止的位置继续播放最近听的歌曲、有声书或播客（“接着听”“继续上次的”）。暂停中则直接继续播放",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册播放列表工具
+	RegisterMCPTool(
+		"self.music.playlist_list",
+		"列出所有播放列表（用户创建的列表和音乐目录中的 M3U/PLS 文件）；传入 name 时返回该列表中的歌曲",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "播放列表名称（可选）",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+	RegisterMCPTool(
+		"self.music.playlist_create",
+		"创建播放列表",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "播放列表名称",
+				},
+			},
+			"required": []string{"name"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+	RegisterMCPTool(
+		"self.music.playlist_add",
+		"将歌曲加入播放列表，列表不存在时自动创建。可以传歌曲索引，也可以传歌名搜索",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "播放列表名称",
+				},
+				"index": map[string]interface{}{
+					"type":        "integer",
+					"description": "歌曲索引（从0开始）",
+				},
+				"song": map[string]interface{}{
+					"type":        "string",
+					"description": "歌名（和歌手），未传 index 时按名称搜索",
+				},
+			},
+			"required": []string{"name"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+	RegisterMCPTool(
+		"self.music.playlist_remove",
+		"从播放列表移除歌曲",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "播放列表名称",
+				},
+				"position": map[string]interface{}{
+					"type":        "integer",
+					"description": "歌曲在列表中的位置（从0开始，见 playlist_list）",
+				},
+			},
+			"required": []string{"name", "position"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+	RegisterMCPTool(
+		"self.music.playlist_delete",
+		"删除播放列表（音乐目录中的列表文件不能删除）",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "播放列表名称",
+				},
+			},
+			"required": []string{"name"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+	RegisterMCPTool(
+		"self.music.playlist_play",
+		"播放播放列表，之后切歌和播放模式只在该列表内生效",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "播放列表名称",
+				},
+			},
+			"required": []string{"name"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册网络电台工具
+	RegisterMCPTool(
+		"self.radio.play",
+		"播放网络电台。按名称播放配置的电台，或直接播放 HTTP(S) 音频流地址；都不传时播放第一个电台。播放中切换上一首/下一首会切换电台",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "电台名称，支持模糊匹配",
+				},
+				"url": map[string]interface{}{
+					"type":        "string",
+					"description": "音频流地址（可选，MP3 或 Ogg）",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	RegisterMCPTool(
+		"self.radio.list",
+		"获取已配置的网络电台列表",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册播放模式工具
+	modes := make([]string, 0, len(music.Modes()))
+	for _, m := range music.Modes() {
+		modes = append(modes, string(m))
+	}
+	RegisterMCPTool(
+		"self.music.set_mode",
+		"设置音乐播放模式：once 播放一首后停止，sequential 顺序播放到列表末尾，repeat_all 列表循环，repeat_one 单曲循环，shuffle 随机播放（一轮内不重复）",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"mode": map[string]interface{}{
+					"type":        "string",
+					"enum":        modes,
+					"description": "播放模式",
+				},
+			},
+			"required": []string{"mode"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册播放队列工具
+	RegisterMCPTool(
+		"self.music.queue_add",
+		"将歌曲加入播放队列，队列中的歌曲优先于播放模式依次播放。歌曲索引可通过 self.music.list 获取",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"index": map[string]interface{}{
+					"type":        "integer",
+					"description": "歌曲索引（从0开始）",
+				},
+				"play_next": map[string]interface{}{
+					"type":        "boolean",
+					"description": "为 true 时插入队首，作为下一首播放",
+				},
+			},
+			"required": []string{"index"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	RegisterMCPTool(
+		"self.music.queue_list",
+		"获取播放队列中的歌曲及当前播放模式",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	)
+
+	RegisterMCPTool(
+		"self.music.queue_remove",
+		"从播放队列中移除歌曲",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"position": map[string]interface{}{
+					"type":        "integer",
+					"description": "队列中的位置（从0开始），可通过 self.music.queue_list 获取",
+				},
+			},
+			"required": []string{"position"},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	RegisterMCPTool(
+		"self.music.queue_clear",
+		"清空播放队列",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册音乐状态查询工具
+	RegisterMCPTool(
+		"self.music.get_status",
+		"获取当前音乐播放状态：歌曲、播放状态（playing/paused/stopped）、已播放时长和总时长（秒）",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	)
+
+	// 注册音乐定位工具
+	RegisterMCPTool(
+		"self.music.seek",
+		"跳转到当前歌曲的指定位置。position 为绝对位置（秒）；offset 为相对当前位置的偏移（秒，负数表示后退），两者二选一",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"position": map[string]interface{}{
+					"type":        "number",
+					"description": "目标位置（秒）",
+				},
+				"offset": map[string]interface{}{
+					"type":        "number",
+					"description": "相对当前位置的偏移（秒），如 30 表示快进 30 秒，-10 表示后退 10 秒",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	// 注册闹钟工具
+	RegisterMCPTool(
+		"self.alarm.set",
+		"设置闹钟。例如「明天早上七点叫我」传 time=07:00、date=tomorrow；「工作日七点半」传 time=07:30、repeat=weekdays；「二十分钟后提醒我」传 in_minutes=20。只有 time 时设置在下一次到达该时间",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"time": map[string]interface{}{
+					"type":        "string",
+					"description": "响铃时间，24 小时制 HH:MM，如 07:00、19:30",
+				},
+				"date": map[string]interface{}{
+					"type":        "string",
+					"description": "日期：YYYY-MM-DD，或 today、tomorrow、day_after_tomorrow；只用于一次性闹钟",
+				},
+				"in_minutes": map[string]interface{}{
+					"type":        "number",
+					"description": "多少分钟后响铃，与 time 二选一",
+				},
+				"repeat": map[string]interface{}{
+					"type":        "string",
+					"description": "重复规则：once（默认）、daily、weekdays、weekends、星期列表如 mon,wed,fri，或 5 字段 cron 表达式（分 时 日 月 周）",
+				},
+				"label": map[string]interface{}{
+					"type":        "string",
+					"description": "闹钟名称，响铃时显示，如「起床」「开会」",
+				},
+				"song": map[string]interface{}{
+					"type":        "string",
+					"description": "响铃时播放的歌曲名，不传使用默认铃声",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	RegisterMCPTool(
+		"self.alarm.list",
+		"列出所有闹钟及下一次响铃时间",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	RegisterMCPTool(
+		"self.alarm.cancel",
+		"取消闹钟。传 id 删除指定闹钟（id 可通过 self.alarm.list 获取）；不传 id 时关闭正在响铃或贪睡中的闹钟，重复闹钟下次仍会响",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "integer",
+					"description": "闹钟 ID",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+
+	RegisterMCPTool(
+		"self.alarm.snooze",
+		"让正在响铃的闹钟过几分钟再响（贪睡）",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"minutes": map[string]interface{}{
+					"type":        "number",
+					"description": "贪睡分钟数，不传使用配置的默认值",
+				},
+			},
+		},
+		func(args map[string]interface{}) (interface{}, error) {
+			return true, nil
+		},
+	)
+}
+
+// handleMCPMessage 处理 MCP 消息
+func (c *Client) handleMCPMessage(msg map[string]interface{}) error {
+	payload, ok := msg["payload"].(map[string]interface{})
+	if !ok {
+		return errors.New("MCP message missing payload field")
+	}
+
+	// 解析 MCP 请求
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	var mcpReq MCPRequest
+	if err := json.Unmarshal(payloadBytes, &mcpReq); err != nil {
+		return c.sendMCPError(mcpReq.ID, MCPErrorParseError, "Parse error", nil)
+	}
+
+	// 验证 JSONRPC 版本
+	if mcpReq.JSONRPC != MCPJSONRPCVersion {
+		return c.sendMCPError(mcpReq.ID, MCPErrorInvalidRequest, "Invalid JSON-RPC version", nil)
+	}
+
+	c.logger.Info("Received MCP request", "method", mcpReq.Method, "id", mcpReq.ID)
+
+	// 根据方法分发处理
+	switch mcpReq.Method {
+	case "initialize":
+		return c.handleMCPInitialize(mcpReq)
+	case "tools/list":
+		return c.handleMCPToolsList(mcpReq)
+	case "tools/call":
+		return c.handleMCPToolsCall(mcpReq)
+	case "notifications/initialized":
+		// 这是一个通知，不需要响应
+		c.logger.Info("MCP session initialized")
+		return nil
+	default:
+		return c.sendMCPError(mcpReq.ID, MCPErrorMethodNotFound, fmt.Sprintf("Method not found: %s", mcpReq.Method), nil)
+	}
+}
+
+// handleMCPInitialize 处理 initialize 请求
+func (c *Client) handleMCPInitialize(req MCPRequest) error {
+	// 解析参数（可选）
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return c.sendMCPError(req.ID, MCPErrorInvalidParams, "Invalid params", err.Error())
+		}
+	}
+
+	// 如果有视觉能力配置，可以保存
+	if params.Capabilities.Vision != nil {
+		c.logger.Info("Received vision capability",
+			"url", params.Capabilities.Vision.URL)
+	}
+
+	// 构建响应
+	result := InitializeResult{
+		ProtocolVersion: MCPProtocolVersion,
+		Capabilities: MCPCapabilities{
+			Tools: &ToolsCapability{
+				ListChanged: false,
+			},
+		},
+		ServerInfo: MCPServerInfo{
+			Name:    "xiaozhi-go",
+			Version: "1.0.0",
+		},
+	}
+
+	return c.sendMCPResponse(req.ID, result)
+}
+
+// handleMCPToolsList 处理 tools/list 请求
+func (c *Client) handleMCPToolsList(req MCPRequest) error {
+	// 解析参数获取 cursor（用于分页，暂不支持）
+	cursor := ""
+	if len(req.Params) > 0 {
+		var params struct {
+			Cursor string `json:"cursor"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err == nil {
+			cursor = params.Cursor
+		}
+	}
+
+	// 如果有 cursor，说明需要分页（暂不支持）
+	if cursor != "" {
+		return c.sendMCPResponse(req.ID, ToolsListResult{
+			Tools:      []MCPTool{},
+			NextCursor: "",
+		})
+	}
+
+	// 返回所有工具
+	tools := GetMCPTools()
+
+	c.logger.Info("Returning tools list", "count", len(tools))
+
+	return c.sendMCPResponse(req.ID, ToolsListResult{
+		Tools:      tools,
+		NextCursor: "",
+	})
+}
+
+// handleMCPToolsCall 处理 tools/call 请求
+func (c *Client) handleMCPToolsCall(req MCPRequest) error {
+	// 解析参数
+	var params ToolsCallParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return c.sendMCPError(req.ID, MCPErrorInvalidParams, "Invalid params", err.Error())
+	}
+
+	c.logger.Info("Calling tool", "name", params.Name, "arguments", params.Arguments)
+
+	// 执行工具
+	var result interface{}
+	var err error
+
+	switch params.Name {
+	case "self.get_device_status":
+		result = c.getDeviceStatus()
+	case "self.audio_speaker.set_volume":
+		result, err = c.setVolume(params.Arguments)
+	case "self.audio_speaker.set_eq":
+		result, err = c.setEQTool(params.Arguments)
+	case "self.audio.get_levels":
+		result = c.getAudioLevels()
+	case "self.display.show_emotion":
+		result, err = c.showEmotionTool(params.Arguments)
+	case "self.display.show_text":
+		result, err = c.showTextTool(params.Arguments)
+	case "self.display.show_time":
+		result, err = c.showTimeTool(params.Arguments)
+	case "self.display.set_mode":
+		result, err = c.setDisplayModeTool(params.Arguments)
+	case "self.display.get_status":
+		result = c.getDisplayStatus()
+	// 音乐相关工具
+	case "self.music.play":
+		result, err = c.musicPlayTool(params.Arguments, req.ID)
+	case "self.music.pause":
+		result, err = c.musicPauseTool(params.Arguments)
+	case "self.music.stop":
+		result, err = c.musicStopTool(params.Arguments)
+	case "self.music.next":
+		result, err = c.musicNextTool(params.Arguments)
+	case "self.music.previous":
+		result, err = c.musicPreviousTool(params.Arguments)
+	case "self.music.list":
+		result = c.musicListTool()
+	case "self.music.play_song":
+		result, err = c.musicPlaySongTool(params.Arguments, req.ID)
+	case "self.music.search":
+		result, err = c.musicSearchTool(params.Arguments)
+	case "self.music.play_by_name":
+		result, err = c.musicPlayByNameTool(params.Arguments, req.ID)
+	case "self.music.sleep_timer":
+		result, err = c.musicSleepTimerTool(params.Arguments)
+	case "self.music.get_lyrics":
+		result, err = c.musicGetLyricsTool(params.Arguments)
+	case "self.music.recent":
+		result, err = c.musicRecentTool(params.Arguments)
+	case "self.music.resume":
+		result, err = c.musicResumeTool(params.Arguments, req.ID)
+	case "self.music.playlist_list":
+		result, err = c.musicPlaylistListTool(params.Arguments)
+	case "self.music.playlist_create":
+		result, err = c.musicPlaylistCreateTool(params.Arguments)
+	case "self.music.playlist_add":
+		result, err = c.musicPlaylistAddTool(params.Arguments)
+	case "self.music.playlist_remove":
+		result, err = c.musicPlaylistRemoveTool(params.Arguments)
+	case "self.music.playlist_delete":
+		result, err = c.musicPlaylistDeleteTool(params.Arguments)
+	case "self.music.playlist_play":
+		result, err = c.musicPlaylistPlayTool(params.Arguments, req.ID)
+	case "self.radio.play":
+		result, err = c.radioPlayTool(params.Arguments, req.ID)
+	case "self.radio.list":
+		result, err = c.radioListTool()
+	case "self.music.get_status":
+		result = c.musicStatusTool()
+	case "self.music.seek":
+		result, err = c.musicSeekTool(params.Arguments)
+	case "self.music.set_mode":
+		result, err = c.musicSetModeTool(params.Arguments)
+	case "self.music.queue_add":
+		result, err = c.musicQueueAddTool(params.Arguments)
+	case "self.music.queue_list":
+		result = c.musicQueueListTool()
+	case "self.music.queue_remove":
+		result, err = c.musicQueueRemoveTool(params.Arguments)
+	case "self.music.queue_clear":
+		result, err = c.musicQueueClearTool()
+	// 闹钟工具
+	case "self.alarm.set":
+		result, err = c.alarmSetTool(params.Arguments)
+	case "self.alarm.list":
+		result, err = c.alarmListTool()
+	case "self.alarm.cancel":
+		result, err = c.alarmCancelTool(params.Arguments)
+	case "self.alarm.snooze":
+		result, err = c.alarmSnoozeTool(params.Arguments)
+	default:
+		// 尝试从注册表调用
+		result, err = CallMCPTool(params.Name, params.Arguments)
+	}
+
+	// 构建响应
+	// 注意：如果 result 为 nil，说明工具已经自己发送了响应（如音乐播放），不需要再次发送
+	if result == nil && err == nil {
+		c.logger.Debug("Tool has already sent response, skipping MCP response")
+		return nil
+	}
+
+	callResult := ToolsCallResult{
+		Content: []MCPContent{},
+	}
+
+	if err != nil {
+		callResult.IsError = true
+		callResult.Content = append(callResult.Content, MCPContent{
+			Type: "text",
+			Text: err.Error(),
+		})
+	} else {
+		// 将结果转换为 JSON 文本
+		resultBytes, jsonErr := json.Marshal(result)
+		if jsonErr != nil {
+			callResult.Content = append(callResult.Content, MCPContent{
+				Type: "text",
+				Text: fmt.Sprintf("%v", result),
+			})
+		} else {
+			callResult.Content = append(callResult.Content, MCPContent{
+				Type: "text",
+				Text: string(resultBytes),
+			})
+		}
+	}
+
+	return c.sendMCPResponse(req.ID, callResult)
+}
+
+// getDeviceStatus 获取设备状态
+func (c *Client) getDeviceStatus() map[string]interface{} {
+	status := c.GetStatus()
+	return map[string]interface{}{
+		"state":             string(status.State),
+		"session_id":        status.SessionID,
+		"connection_status": status.ConnectionStatus,
+		"audio_device": map[string]interface{}{
+			"capture":          string(status.AudioDevice.Capture),
+			"playback":         string(status.AudioDevice.Playback),
+			"capture_retries":  status.AudioDevice.CaptureRetries,
+			"playback_retries": status.AudioDevice.PlaybackRetries,
+			"last_error":       status.AudioDevice.LastError,
+		},
+	}
+}
+
+// setVolume 设置音量
+func (c *Client) setVolume(args map[string]interface{}) (interface{}, error) {
+	volume, ok := args["volume"].(float64)
+	if !ok {
+		return nil, errors.New("volume must be a number")
+	}
+
+	// 转换为整数
+	volumeInt := int(volume)
+	if volumeInt < 0 || volumeInt > 100 {
+		return nil, errors.New("volume must be between 0 and 100")
+	}
+
+	if err := c.SetVolume(volumeInt); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+// SetVolume 使用 amixer 设置扬声器音量（0-100）
+func (c *Client) SetVolume(volume int) error {
+	cmd := exec.Command("amixer", "set", "Power Amplifier", fmt.Sprintf("%d%%", volume))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		c.logger.Error("Failed to set volume", "error", err, "output", string(output))
+		return fmt.Errorf("failed to set volume: %w", err)
+	}
+
+	c.volume.Store(int32(volume))
+	c.logger.Info("Volume set successfully", "volume", volume)
+	return nil
+}
+
+// Volume 当前音量：尚未设置时通过 amixer 读取一次，无法得知时返回 -1
+func (c *Client) Volume() int {
+	c.volumeProbe.Do(func() {
+		if volume, ok := readVolume(); ok {
+			c.volume.CompareAndSwap(-1, int32(volume))
+		}
+	})
+	return int(c.volume.Load())
+}
+
+// readVolume 从 amixer 输出中读取音量百分比，形如 "Mono: Playback 28 [44%] [-12.00dB]"
+func readVolume() (int, bool) {
+	output, err := exec.Command("amixer", "get", "Power Amplifier").Output()
+	if err != nil {
+		return 0, false
+	}
+	text := string(output)
+	end := strings.Index(text, "%]")
+	if end < 0 {
+		return 0, false
+	}
+	start := strings.LastIndex(text[:end], "[")
+	if start < 0 {
+		return 0, false
+	}
+	volume, err := strconv.Atoi(text[start+1 : end])
+	return volume, err == nil
+}
+
+// setEQTool 设置语音和/或音乐的均衡器，返回当前设置
+func (c *Client) setEQTool(args map[string]interface{}) (interface{}, error) {
+	targets := map[string]*audio.Equalizer{"voice": c.voiceEQ}
+	if c.musicPlayer != nil {
+		targets["music"] = c.musicPlayer.Equalizer()
+	}
+
+	target, _ := args["target"].(string)
+	switch target {
+	case "", "all":
+	case "voice", "music":
+		if targets[target] == nil {
+			return nil, fmt.Errorf("%s output is not available", target)
+		}
+		targets = map[string]*audio.Equalizer{target: targets[target]}
+	default:
+		return nil, fmt.Errorf("unknown target: %s", target)
+	}
+
+	cfg := EQConfig{}
+	cfg.Preset, _ = args["preset"].(string)
+	if raw, ok := args["bands"].([]interface{}); ok {
+		for i, item := range raw {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("band %d must be an object", i+1)
+			}
+			band := audio.EQBand{}
+			band.Type, _ = m["type"].(string)
+			band.Frequency, _ = m["frequency"].(float64)
+			band.Gain, _ = m["gain"].(float64)
+			band.Q, _ = m["q"].(float64)
+			cfg.Bands = append(cfg.Bands, band)
+		}
+	}
+
+	if cfg.Preset != "" || len(cfg.Bands) > 0 {
+		bands, err := cfg.bands()
+		if err != nil {
+			return nil, err
+		}
+		for name, eq := range targets {
+			if err := eq.SetBands(bands); err != nil {
+				return nil, err
+			}
+			c.logger.Info("EQ changed", "target", name, "preset", cfg.Preset, "bands", len(bands))
+		}
+	}
+
+	result := map[string]interface{}{"success": true}
+	for name, eq := range targets {
+		result[name] = eq.Bands()
+	}
+	return result, nil
+}
+
+// getAudioLevels 获取采集与播放电平
+func (c *Client) getAudioLevels() map[string]interface{} {
+	m := c.getAudioManager()
+	if m == nil {
+		return map[string]interface{}{
+			"available": false,
+		}
+	}
+
+	stats := m.LevelStats()
+	status := m.DeviceStatus()
+	return map[string]interface{}{
+		"available": true,
+		"capture":   meterStatsToMap(stats.Capture, m.IsRecording(), status.Capture),
+		"playback":  meterStatsToMap(stats.Playback, true, status.Playback),
+	}
+}
+
+// meterStatsToMap 将电平统计转换为工具返回结构
+func meterStatsToMap(m audio.MeterStats, active bool, health audio.DeviceHealth) map[string]interface{} {
+	result := map[string]interface{}{
+		"active":         active,
+		"device":         string(health),
+		"rms_dbfs":       math.Round(audio.LevelToDB(m.RMS)*10) / 10,
+		"peak_dbfs":      math.Round(audio.LevelToDB(m.Peak)*10) / 10,
+		"frames":         m.Frames,
+		"clipped_frames": m.ClippedFrames,
+	}
+	if !m.LastUpdate.IsZero() {
+		result["last_update_ms_ago"] = time.Since(m.LastUpdate).Milliseconds()
+	}
+	return result
+}
+
+// showEmotionTool 显示表情工具
+func (c *Client) showEmotionTool(args map[string]interface{}) (interface{}, error) {
+	emotion, ok := args["emotion"].(string)
+	if !ok {
+		return nil, errors.New("emotion must be a string")
+	}
+
+	// 切换到表情模式
+	c.SetDisplayMode(DisplayModeEmotion)
+
+	if err := c.ShowEmotion(emotion); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
+// showTextTool 显示文本工具
+func (c *Client) showTextTool(args map[string]interface{}) (interface{}, error) {
+	text, ok := args["text"].(string)
+	if !ok {
+		return nil, errors.New("text must be a string")
+	}
+
+	fontSize := c.config.Display.FontSize
+	if fs, ok := args["font_size"].(float64); ok {
+		fontSize = fs
+	}
+
+	if err := c.ShowText(text, fontSize, c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
+		return nil, err
+	}
+
+	return true, nil
+}
+
+// showTimeTool 显示时间工具
+func (c *Client) showTimeTool(args map[string]interface{}) (interface{}, error) {
+	if err := c.ShowDateTime(); err != nil {
+		return nil, err
+	}
+	return true, nil
+}
+
+// setDisplayModeTool 设置显示模式工具
+func (c *Client) setDisplayModeTool(args map[string]interface{}) (interface{}, error) {
+	mode, ok := args["mode"].(string)
+	if !ok {
+		return nil, errors.New("mode must be a string")
+	}
+
+	var displayMode DisplayMode
+	switch mode {
+	case "emotion":
+		displayMode = DisplayModeEmotion
+	case "clock":
+		displayMode = DisplayModeClock
+	case "dialog":
+		displayMode = DisplayModeDialog
+	case "music":
+		displayMode = DisplayModeMusic
+	default:
+		return nil, fmt.Errorf("invalid display mode: %s, valid modes are: emotion, clock, dialog, music", mode)
+	}
+
+	c.SetDisplayMode(displayMode)
+
+	// 根据模式执行相应操作
+	switch displayMode {
+	case DisplayModeClock:
+		if err := c.ShowDateTime(); err != nil {
+			return nil, err
+		}
+	case DisplayModeDialog:
+		// 显示对话模式提示
+		c.clearDialog()
+		if err := c.ShowText("对话模式已开启", c.config.Display.FontSize,
+			c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
+			c.logger.Warn("Failed to show dialog mode hint", "error", err)
+		}
+	case DisplayModeMusic:
+		// 显示音乐模式提示
+		if err := c.ShowText("音乐模式", c.config.Display.FontSize,
+			c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
+			c.logger.Warn("Failed to show music mode hint", "error", err)
+		}
+	case DisplayModeEmotion:
+		// 显示默认表情
+		if err := c.ShowEmotion("neutral"); err != nil {
+			c.logger.Warn("Failed to show neutral emotion", "error", err)
+		}
+	}
+
+	return map[string]interface{}{
+		"mode":    string(displayMode),
+		"success": true,
+	}, nil
+}
+
+// getDisplayStatus 获取显示状态
+func (c *Client) getDisplayStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":         string(c.GetDisplayModeEnum()),
+		"device_state": string(c.GetState()),
+	}
+}
+
+// ============================================================================
+// 音乐相关工具
+// ============================================================================
+
+// disconnectForMusic 断开连接以释放音频设备用于播放音乐
+func (c *Client) disconnectForMusic() {
+	c.logger.Info("Disconnecting for music playback")
+
+	// 先切换到音乐模式，防止状态变化触发表情显示
+	c.SetDisplayMode(DisplayModeMusic)
+
+	// 发送 abort 消息通知服务器中断会话
+	if c.transport != nil && c.sessionID != "" {
+		abortMsg := map[string]interface{}{
+			"type":       "abort",
+			"session_id": c.sessionID,
+			"reason":     "music_playback",
+		}
+		if err := c.sendJSON(abortMsg); err != nil {
+			c.logger.Warn("Failed to send abort message", "error", err)
+		}
+	}
+
+	// 停止录音
+	if m := c.getAudioManager(); m != nil {
+		m.StopRecording()
+	}
+
+	// 关闭 WebSocket 连接
+	if c.transport != nil {
+		if err := c.transport.Close(); err != nil {
+			c.logger.Warn("Failed to close transport for music", "error", err)
+		}
+		c.transport = nil
+	}
+
+	// 设置状态但不触发表情（已在音乐模式）
+	c.stateMutex.Lock()
+	c.state = DeviceStateIdle
+	c.sessionID = ""
+	c.stateMutex.Unlock()
+
+	// 等待音频设备完全释放
+	time.Sleep(500 * time.Millisecond)
+
+	// 关闭音频管理器，释放所有音频设备资源；期间的提示音推迟到重连后播放
+	c.audioMu.Lock()
+	m := c.audioManager
+	c.audioManager, c.audioReleased = nil, true
+	c.audioMu.Unlock()
+	if m != nil {
+		if err := m.Close(); err != nil {
+			c.logger.Warn("Failed to close audio manager", "error", err)
+		}
+	}
+
+	// 再次等待确保设备释放
+	time.Sleep(1500 * time.Millisecond)
+
+	c.logger.Info("Disconnected for music playback, audio device released")
+}
+
+// reconnectAfterMusic 音乐结束后重新连接
+func (c *Client) reconnectAfterMusic() {
+	c.logger.Info("Reconnecting after music playback")
+
+	// 切换回表情模式
+	c.SetDisplayMode(DisplayModeEmotion)
+
+	// 显示默认表情
+	if err := c.ShowEmotion("neutral"); err != nil {
+		c.logger.Warn("Failed to show neutral emotion after music", "error", err)
+	}
+
+	// 重新创建音频管理器（因为之前的已经被完全关闭）
+	m, err := audio.NewManager(c.audioConfig(), c.logger)
+	c.setAudioManager(m)
+	if err != nil {
+		c.logger.Error("Failed to recreate audio manager", "error", err)
+		return
+	}
+	c.watchAudioManager(m)
+	c.logger.Info("Audio manager recreated successfully")
+	c.playPendingEarcons()
+
+	// 重新建立 WebSocket 连接
+	ctx := context.Background()
+	if err := c.Connect(ctx); err != nil {
+		c.logger.Error("Failed to reconnect after music", "error", err)
+		return
+	}
+
+	// 重连成功后设置为空闲状态
+	c.setState(DeviceStateIdle)
+	c.logger.Info("Reconnected after music playback")
+}
+
+// reconnect 自动重连
+// 当连接断开时自动尝试重新连接，支持指数退避重试
+func (c *Client) reconnect() bool {
+	c.logger.Info("Attempting to reconnect...")
+
+	// 设置为断开状态
+	c.setState(DeviceStateDisconnected)
+
+	// 清理旧的 transport
+	c.stateMutex.Lock()
+	if c.transport != nil {
+		c.transport.Close()
+		c.transport = nil
+	}
+	c.stateMutex.Unlock()
+
+	// 重连参数
+	maxRetries := 5
+	baseDelay := time.Second
+	maxDelay := 30 * time.Second
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		// 检查是否已关闭
+		select {
+		case <-c.closeChan:
+			c.logger.Info("Client closed, stopping reconnection")
+			return false
+		default:
 		}
-		if err := json.Unmarshal(req.Params, &params); err == nil {
-			cursor = params.Cursor
+
+		c.logger.Info("Reconnection attempt", "attempt", attempt, "maxRetries", maxRetries)
+
+		// 创建新的 transport
+		transport, err := NewProtocol(c.config)
+		if err != nil {
+			c.logger.Error("Failed to create transport", "error", err)
+			continue
+		}
+
+		// 尝试连接
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = transport.Connect(ctx)
+		cancel()
+
+		if err != nil {
+			c.logger.Error("Failed to connect", "error", err, "attempt", attempt)
+			transport.Close()
+
+			// 计算退避时间
+			delay := baseDelay * time.Duration(1<<(attempt-1))
+			if delay > maxDelay {
+				delay = maxDelay
+			}
+
+			c.logger.Info("Waiting before retry", "delay", delay)
+
+			select {
+			case <-c.closeChan:
+				c.logger.Info("Client closed during reconnection wait")
+				return false
+			case <-time.After(delay):
+				continue
+			}
+		}
+
+		// 连接成功
+		c.stateMutex.Lock()
+		c.transport = transport
+		c.stateMutex.Unlock()
+
+		// 发送 hello 消息
+		helloMsg := map[string]interface{}{
+			"type":    "hello",
+			"version": 1,
+			"features": map[string]interface{}{
+				"mcp": true,
+			},
+			"transport": c.config.System.Network.Transport,
+			"audio_params": map[string]interface{}{
+				"format":         "opus",
+				"sample_rate":    c.config.Audio.SampleRate,
+				"channels":       c.config.Audio.Channels,
+				"frame_duration": c.config.Audio.FrameDuration,
+			},
+		}
+
+		if err := c.sendJSON(helloMsg); err != nil {
+			c.logger.Error("Failed to send hello message", "error", err)
+			continue
 		}
+
+		c.setState(DeviceStateIdle)
+		c.PlayPrompt(prompt.KeyReconnected)
+		c.logger.Info("Reconnection successful", "attempt", attempt)
+		return true
 	}
 
-	// 如果有 cursor，说明需要分页（暂不支持）
-	if cursor != "" {
-		return c.sendMCPResponse(req.ID, ToolsListResult{
-			Tools:      []MCPTool{},
-			NextCursor: "",
-		})
+	c.logger.Error("Reconnection failed after max retries", "maxRetries", maxRetries)
+	return false
+}
+
+// ShowMusicAnimation 显示音乐可视化效果
+func (c *Client) ShowMusicAnimation(songName string) error {
+	c.logger.Info("ShowMusicAnimation called", "songName", songName)
+
+	if c.config.Display.SkipExecution {
+		c.logger.Info("Display execution skipped")
+		return nil
 	}
 
-	// 返回所有工具
-	tools := GetMCPTools()
+	// 使用程序生成的可视化效果
+	if c.musicPlayer != nil {
+		frameChan := c.musicPlayer.GetVisualizeChannel()
+		style := c.musicVisualizerStyle()
+		progress := func() (time.Duration, time.Duration) {
+			status := c.musicPlayer.Status()
+			return status.Position, status.Duration
+		}
+		// 顶部显示播放列表或电台名称，music.show_song_name 开启时附带歌名或电台节目
+		label := func() string {
+			// 铃声歌曲响铃时显示闹钟
+			if a, ok := c.ringingAlarm(); ok {
+				return alarmTitle(a)
+			}
+			status := c.musicPlayer.Status()
+			var parts []string
+			if status.Radio {
+				parts = append(parts, status.Song.Name)
+				if c.config.Music.ShowSongName && status.StreamTitle != "" {
+					parts = append(parts, status.StreamTitle)
+				}
+				return strings.Join(parts, " · ")
+			}
 
-	c.logger.Info("Returning tools list", "count", len(tools))
+			if playlist := c.musicPlayer.CurrentPlaylist(); playlist != "" {
+				parts = append(parts, playlist)
+			}
+			if c.config.Music.ShowSongName {
+				name := songName
+				if status.Song != nil {
+					name = status.Song.Name
+				}
+				parts = append(parts, name)
+			}
+			return strings.Join(parts, " · ")
+		}
+		// 同步歌词：当前行和下一行
+		var lyrics display.MusicLyricsFunc
+		if c.config.Music.ShowLyrics {
+			lyrics = func() (string, string, float64) {
+				l, err := c.musicPlayer.CurrentLyrics()
+				if err != nil {
+					return "", "", 0
+				}
+				return l.Window(c.musicPlayer.Position())
+			}
+		}
+		// 睡眠定时倒计时
+		sleep := func() (time.Duration, bool) {
+			timer := c.musicPlayer.SleepTimer()
+			return timer.Remaining, timer.Active && timer.Remaining > 0
+		}
+		screen := display.MusicScreen{
+			Frames:   frameChan,
+			Style:    style,
+			Progress: progress,
+			Label:    label,
+			Lyrics:   lyrics,
+			Sleep:    sleep,
+		}
+		// 正在播放卡片：内嵌或目录中的封面，没有封面时使用配置的动画
+		if c.config.Music.Layout != "visualizer" {
+			screen.NowPlaying = func() display.MusicNowPlaying {
+				status := c.musicPlayer.Status()
+				np := display.MusicNowPlaying{Title: songName, Paused: status.State == music.StatePaused}
+				switch {
+				case status.Song == nil:
+				case status.Radio:
+					np.Title, np.Artist = status.Song.Name, status.StreamTitle
+				default:
+					np.Key, np.Title, np.Artist = status.Song.Path, status.Song.Name, status.Song.Artist
+				}
+				return np
+			}
+			screen.Cover = func(path string) ([]byte, error) {
+				return music.FindCover(path)
+			}
+			screen.AnimationPath = c.config.Music.AnimationPath
+		}
+		c.logger.Info("Starting music visualizer", "layout", c.config.Music.Layout)
+		return c.displayCtrl.ShowMusicVisualizer(screen)
+	}
 
-	return c.sendMCPResponse(req.ID, ToolsListResult{
-		Tools:      tools,
-		NextCursor: "",
-	})
+	c.logger.Warn("Music player is nil")
+	return nil
 }
 
-// handleMCPToolsCall 处理 tools/call 请求
-func (c *Client) handleMCPToolsCall(req MCPRequest) error {
-	// 解析参数
-	var params ToolsCallParams
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		return c.sendMCPError(req.ID, MCPErrorInvalidParams, "Invalid params", err.Error())
+// musicVisualizerStyle 根据配置生成频谱颜色，无效的颜色使用默认值
+func (c *Client) musicVisualizerStyle() display.MusicVisualizerStyle {
+	style := display.DefaultMusicVisualizerStyle()
+	cfg := c.config.Music.Visualizer
+	for _, item := range []struct {
+		value string
+		dst   *struct{ R, G, B uint8 }
+	}{
+		{cfg.BottomColor, &style.Bottom},
+		{cfg.TopColor, &style.Top},
+		{cfg.PeakColor, &style.Peak},
+	} {
+		if item.value == "" {
+			continue
+		}
+		col, err := parseHexColor(item.value)
+		if err != nil {
+			c.logger.Warn("Invalid visualizer color, using default", "color", item.value, "error", err)
+			continue
+		}
+		*item.dst = col
 	}
+	return style
+}
 
-	c.logger.Info("Calling tool", "name", params.Name, "arguments", params.Arguments)
+// parseHexColor 解析 "#RRGGBB" 或 "RRGGBB" 格式的颜色
+func parseHexColor(s string) (struct{ R, G, B uint8 }, error) {
+	var col struct{ R, G, B uint8 }
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) != 6 {
+		return col, fmt.Errorf("expected #RRGGBB, got %q", s)
+	}
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x", &col.R, &col.G, &col.B); err != nil {
+		return col, fmt.Errorf("expected #RRGGBB, got %q", s)
+	}
+	return col, nil
+}
 
-	// 执行工具
-	var result interface{}
-	var err error
+// musicPlayTool 播放音乐
+// mcpID 用于在断开连接前先发送 MCP 响应
+func (c *Client) musicPlayTool(args map[string]interface{}, mcpID interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-	switch params.Name {
-	case "self.get_device_status":
-		result = c.getDeviceStatus()
-	case "self.audio_speaker.set_volume":
-		result, err = c.setVolume(params.Arguments)
-	case "self.display.show_emotion":
-		result, err = c.showEmotionTool(params.Arguments)
-	case "self.display.show_text":
-		result, err = c.showTextTool(params.Arguments)
-	case "self.display.show_time":
-		result, err = c.showTimeTool(params.Arguments)
-	case "self.display.set_mode":
-		result, err = c.setDisplayModeTool(params.Arguments)
-	case "self.display.get_status":
-		result = c.getDisplayStatus()
-	// 音乐相关工具
-	case "self.music.play":
-		result, err = c.musicPlayTool(params.Arguments, req.ID)
-	case "self.music.pause":
-		result, err = c.musicPauseTool(params.Arguments)
-	case "self.music.stop":
-		result, err = c.musicStopTool(params.Arguments)
-	case "self.music.next":
-		result, err = c.musicNextTool(params.Arguments)
-	case "self.music.previous":
-		result, err = c.musicPreviousTool(params.Arguments)
-	case "self.music.list":
-		result = c.musicListTool()
-	case "self.music.play_song":
-		result, err = c.musicPlaySongTool(params.Arguments, req.ID)
-	default:
-		// 尝试从注册表调用
-		result, err = CallMCPTool(params.Name, params.Arguments)
+	// 暂停中直接从暂停位置继续，设备和连接状态保持不变
+	if c.musicPlayer.IsPaused() {
+		c.musicPlayer.Resume()
+		return map[string]interface{}{
+			"playing":  true,
+			"position": c.musicPlayer.Position().Seconds(),
+			"success":  true,
+		}, nil
 	}
 
-	// 构建响应
-	// 注意：如果 result 为 nil，说明工具已经自己发送了响应（如音乐播放），不需要再次发送
-	if result == nil && err == nil {
-		c.logger.Debug("Tool has already sent response, skipping MCP response")
-		return nil
+	c.logger.Info("musicPlayTool: preparing to play music")
+
+	// 播放开始提示音，等待播完再占用音频设备
+	c.PlayEarconAndWait(earcon.EventMusicStart)
+
+	// 先尝试播放音乐，如果成功再断开连接
+	if err := c.musicPlayer.Play(); err != nil {
+		c.logger.Error("Failed to start music playback", "error", err)
+		return nil, err
 	}
 
-	callResult := ToolsCallResult{
-		Content: []MCPContent{},
+	return c.enterMusicMode(mcpID, map[string]interface{}{
+		"playing": true,
+		"success": true,
+	})
+}
+
+// musicPauseTool 暂停音乐
+func (c *Client) musicPauseTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
 	}
 
-	if err != nil {
-		callResult.IsError = true
-		callResult.Content = append(callResult.Content, MCPContent{
-			Type: "text",
-			Text: err.Error(),
-		})
-	} else {
-		// 将结果转换为 JSON 文本
-		resultBytes, jsonErr := json.Marshal(result)
-		if jsonErr != nil {
-			callResult.Content = append(callResult.Content, MCPContent{
-				Type: "text",
-				Text: fmt.Sprintf("%v", result),
-			})
-		} else {
-			callResult.Content = append(callResult.Content, MCPContent{
-				Type: "text",
-				Text: string(resultBytes),
-			})
-		}
+	paused, position := c.musicPlayer.TogglePause()
+
+	return map[string]interface{}{
+		"paused":   paused,
+		"position": position.Seconds(),
+		"success":  true,
+	}, nil
+}
+
+// musicStopTool 停止音乐
+func (c *Client) musicStopTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
 	}
 
-	return c.sendMCPResponse(req.ID, callResult)
-}
+	c.musicPlayer.Stop()
+
+	// 停止音乐后恢复 WebSocket 连接
+	go c.reconnectAfterMusic()
 
-// getDeviceStatus 获取设备状态
-func (c *Client) getDeviceStatus() map[string]interface{} {
-	status := c.GetStatus()
 	return map[string]interface{}{
-		"state":             string(status.State),
-		"session_id":        status.SessionID,
-		"connection_status": status.ConnectionStatus,
-	}
+		"stopped": true,
+		"success": true,
+	}, nil
 }
 
-// setVolume 设置音量
-func (c *Client) setVolume(args map[string]interface{}) (interface{}, error) {
-	volume, ok := args["volume"].(float64)
-	if !ok {
-		return nil, errors.New("volume must be a number")
+// musicNextTool 下一首
+func (c *Client) musicNextTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
 	}
 
-	// 转换为整数
-	volumeInt := int(volume)
-	if volumeInt < 0 || volumeInt > 100 {
-		return nil, errors.New("volume must be between 0 and 100")
+	if err := c.musicPlayer.Next(); err != nil {
+		return nil, err
 	}
 
-	// 使用 amixer 设置音量
-	cmd := exec.Command("amixer", "set", "Power Amplifier", fmt.Sprintf("%d%%", volumeInt))
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		c.logger.Error("Failed to set volume", "error", err, "output", string(output))
-		return nil, fmt.Errorf("failed to set volume: %w", err)
+	// 显示音乐动画
+	if song := c.musicPlayer.GetCurrentSong(); song != nil {
+		go c.ShowMusicAnimation(song.Name)
 	}
 
-	c.logger.Info("Volume set successfully", "volume", volumeInt)
+	// 如果当前没有连接（音乐播放中断开的），不需要重连
+	// 如果已经重连了，这里也不需要额外操作
 
-	return true, nil
+	return map[string]interface{}{
+		"success": true,
+	}, nil
 }
 
-// showEmotionTool 显示表情工具
-func (c *Client) showEmotionTool(args map[string]interface{}) (interface{}, error) {
-	emotion, ok := args["emotion"].(string)
-	if !ok {
-		return nil, errors.New("emotion must be a string")
+// musicPreviousTool 上一首
+func (c *Client) musicPreviousTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
 	}
 
-	// 切换到表情模式
-	c.SetDisplayMode(DisplayModeEmotion)
-
-	if err := c.ShowEmotion(emotion); err != nil {
+	if err := c.musicPlayer.Previous(); err != nil {
 		return nil, err
 	}
 
-	return true, nil
+	// 显示音乐动画
+	if song := c.musicPlayer.GetCurrentSong(); song != nil {
+		go c.ShowMusicAnimation(song.Name)
+	}
+
+	// 如果当前没有连接（音乐播放中断开的），不需要重连
+	// 如果已经重连了，这里也不需要额外操作
+
+	return map[string]interface{}{
+		"success": true,
+	}, nil
 }
 
-// showTextTool 显示文本工具
-func (c *Client) showTextTool(args map[string]interface{}) (interface{}, error) {
-	text, ok := args["text"].(string)
-	if !ok {
-		return nil, errors.New("text must be a string")
-	}
+// noticeDuration 屏幕提示的显示时长
+const noticeDuration = 3 * time.Second
 
-	fontSize := c.config.Display.FontSize
-	if fs, ok := args["font_size"].(float64); ok {
-		fontSize = fs
+// onMediaChanged 插入或拔出 U 盘等存储时播放提示音并在屏幕上短暂提示
+// 没有歌曲的存储（如相机存储卡）不提示；播放音乐时提示音在音乐结束后播放
+func (c *Client) onMediaChanged(ev music.MediaEvent) {
+	if ev.Source.Songs == 0 {
+		return
+	}
+	if ev.Removed {
+		c.playEarconDeferred(earcon.EventMediaRemoved)
+		c.showNotice(fmt.Sprintf("%s 已移除", ev.Source.Label))
+		return
 	}
+	c.playEarconDeferred(earcon.EventMediaAdded)
+	c.showNotice(fmt.Sprintf("发现 %d 首歌曲", ev.Source.Songs))
+}
 
-	if err := c.ShowText(text, fontSize, c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
-		return nil, err
+// showNotice 短暂显示提示文字，之后恢复音乐、时钟或表情画面
+func (c *Client) showNotice(text string) {
+	if c.config.Display.SkipExecution {
+		return
+	}
+	if err := c.ShowText(text, c.config.Display.FontSize, c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
+		c.logger.Warn("Failed to show notice", "error", err)
+		return
 	}
 
-	return true, nil
+	time.AfterFunc(noticeDuration, func() {
+		switch c.GetDisplayModeEnum() {
+		case DisplayModeMusic:
+			if c.musicPlayer != nil && c.musicPlayer.IsPlaying() {
+				if song := c.musicPlayer.GetCurrentSong(); song != nil {
+					c.ShowMusicAnimation(song.Name)
+				}
+			}
+		case DisplayModeClock:
+			if c.GetState() == DeviceStateIdle {
+				c.ShowDateTime()
+			}
+		case DisplayModeEmotion:
+			if c.GetState() == DeviceStateIdle {
+				c.ShowEmotion("neutral")
+			}
+		}
+	})
 }
 
-// showTimeTool 显示时间工具
-func (c *Client) showTimeTool(args map[string]interface{}) (interface{}, error) {
-	if err := c.ShowDateTime(); err != nil {
-		return nil, err
+// musicListTool 获取音乐列表
+func (c *Client) musicListTool() interface{} {
+	if c.musicPlayer == nil {
+		return []interface{}{}
 	}
-	return true, nil
-}
 
-// setDisplayModeTool 设置显示模式工具
-func (c *Client) setDisplayModeTool(args map[string]interface{}) (interface{}, error) {
-	mode, ok := args["mode"].(string)
-	if !ok {
-		return nil, errors.New("mode must be a string")
+	songs := c.musicPlayer.GetSongs()
+	result := make([]map[string]interface{}, len(songs))
+	for i, song := range songs {
+		item := map[string]interface{}{
+			"index": i,
+			"name":  song.Name,
+			"path":  song.Path,
+		}
+		if song.Artist != "" {
+			item["artist"] = song.Artist
+		}
+		if song.Album != "" {
+			item["album"] = song.Album
+		}
+		if song.Duration > 0 {
+			item["duration"] = math.Round(song.Duration.Seconds())
+		}
+		if song.Source != "" {
+			item["source"] = song.Source
+		}
+		result[i] = item
 	}
 
-	var displayMode DisplayMode
-	switch mode {
-	case "emotion":
-		displayMode = DisplayModeEmotion
-	case "clock":
-		displayMode = DisplayModeClock
-	case "dialog":
-		displayMode = DisplayModeDialog
-	case "music":
-		displayMode = DisplayModeMusic
-	default:
-		return nil, fmt.Errorf("invalid display mode: %s, valid modes are: emotion, clock, dialog, music", mode)
+	list := map[string]interface{}{
+		"songs": result,
+		"count": len(result),
+	}
+	if sources := c.musicPlayer.MediaSources(); len(sources) > 0 {
+		items := make([]map[string]interface{}, len(sources))
+		for i, src := range sources {
+			items[i] = map[string]interface{}{
+				"label": src.Label,
+				"path":  src.Path,
+				"count": src.Songs,
+			}
+		}
+		list["removable"] = items
 	}
+	return list
+}
 
-	c.SetDisplayMode(displayMode)
+// musicSearchTool 模糊搜索歌曲
+func (c *Client) musicSearchTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-	// 根据模式执行相应操作
-	switch displayMode {
-	case DisplayModeClock:
-		if err := c.ShowDateTime(); err != nil {
-			return nil, err
-		}
-	case DisplayModeDialog:
-		// 显示对话模式提示
-		c.clearDialog()
-		if err := c.ShowText("对话模式已开启", c.config.Display.FontSize,
-			c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
-			c.logger.Warn("Failed to show dialog mode hint", "error", err)
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("query is required")
+	}
+	limit := 0
+	if v, ok := args["limit"].(float64); ok {
+		limit = int(v)
+	}
+
+	results := c.musicPlayer.Search(query, limit)
+	items := make([]map[string]interface{}, len(results))
+	for i, r := range results {
+		item := map[string]interface{}{
+			"index": r.Index,
+			"name":  r.Song.Name,
+			"score": math.Round(r.Score*100) / 100,
 		}
-	case DisplayModeMusic:
-		// 显示音乐模式提示
-		if err := c.ShowText("音乐模式", c.config.Display.FontSize,
-			c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
-			c.logger.Warn("Failed to show music mode hint", "error", err)
+		if r.Song.Artist != "" {
+			item["artist"] = r.Song.Artist
 		}
-	case DisplayModeEmotion:
-		// 显示默认表情
-		if err := c.ShowEmotion("neutral"); err != nil {
-			c.logger.Warn("Failed to show neutral emotion", "error", err)
+		if r.Song.Album != "" {
+			item["album"] = r.Song.Album
 		}
+		items[i] = item
 	}
 
 	return map[string]interface{}{
-		"mode":    string(displayMode),
-		"success": true,
+		"results": items,
+		"count":   len(items),
 	}, nil
 }
 
-// getDisplayStatus 获取显示状态
-func (c *Client) getDisplayStatus() map[string]interface{} {
-	return map[string]interface{}{
-		"mode":         string(c.GetDisplayModeEnum()),
-		"device_state": string(c.GetState()),
+// musicPlayByNameTool 播放最匹配的歌曲
+func (c *Client) musicPlayByNameTool(args map[string]interface{}, mcpID interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
 	}
-}
 
-// ============================================================================
-// 音乐相关工具
-// ============================================================================
+	name, _ := args["name"].(string)
+	artist, _ := args["artist"].(string)
+	query := strings.TrimSpace(artist + " " + name)
+	if query == "" {
+		return nil, errors.New("name is required")
+	}
 
-// disconnectForMusic 断开连接以释放音频设备用于播放音乐
-func (c *Client) disconnectForMusic() {
-	c.logger.Info("Disconnecting for music playback")
+	results := c.musicPlayer.Search(query, 1)
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no song matching %q", query)
+	}
 
-	// 先切换到音乐模式，防止状态变化触发表情显示
-	c.SetDisplayMode(DisplayModeMusic)
+	best := results[0]
+	c.logger.Info("Playing song by name", "query", query, "song", best.Song.Name, "artist", best.Song.Artist, "score", best.Score)
+	return c.musicPlaySongTool(map[string]interface{}{"index": float64(best.Index)}, mcpID)
+}
 
-	// 发送 abort 消息通知服务器中断会话
-	if c.transport != nil && c.sessionID != "" {
-		abortMsg := map[string]interface{}{
-			"type":       "abort",
-			"session_id": c.sessionID,
-			"reason":     "music_playback",
+// musicSleepTimerTool 设置、取消或查询睡眠定时
+// 定时到达时停止播放，由 enterMusicMode 中的等待协程通过 reconnectAfterMusic 恢复连接
+func (c *Client) musicSleepTimerTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "", "set":
+		if endOfTrack, _ := args["end_of_track"].(bool); endOfTrack {
+			if err := c.musicPlayer.SetSleepAtEndOfTrack(); err != nil {
+				return nil, err
+			}
+			break
 		}
-		if err := c.sendJSON(abortMsg); err != nil {
-			c.logger.Warn("Failed to send abort message", "error", err)
+		minutes, ok := args["minutes"].(float64)
+		if !ok || minutes <= 0 {
+			return nil, errors.New("minutes must be a positive number, or set end_of_track")
 		}
+		if err := c.musicPlayer.SetSleepTimer(time.Duration(minutes * float64(time.Minute))); err != nil {
+			return nil, err
+		}
+	case "cancel":
+		return map[string]interface{}{
+			"success":  true,
+			"canceled": c.musicPlayer.CancelSleepTimer(),
+		}, nil
+	case "query":
+	default:
+		return nil, fmt.Errorf("unknown action: %s", action)
 	}
 
-	// 停止录音
-	if c.audioManager != nil {
-		c.audioManager.StopRecording()
+	timer := c.musicPlayer.SleepTimer()
+	result := map[string]interface{}{
+		"success": true,
+		"active":  timer.Active,
 	}
-
-	// 关闭 WebSocket 连接
-	if c.transport != nil {
-		if err := c.transport.Close(); err != nil {
-			c.logger.Warn("Failed to close transport for music", "error", err)
+	if timer.Active {
+		result["end_of_track"] = timer.EndOfTrack
+		if timer.Remaining > 0 {
+			result["remaining"] = math.Round(timer.Remaining.Seconds())
 		}
-		c.transport = nil
 	}
+	return result, nil
+}
 
-	// 设置状态但不触发表情（已在音乐模式）
-	c.stateMutex.Lock()
-	c.state = DeviceStateIdle
-	c.sessionID = ""
-	c.stateMutex.Unlock()
-
-	// 等待音频设备完全释放
-	time.Sleep(500 * time.Millisecond)
+// musicGetLyricsTool 获取当前播放位置的歌词
+func (c *Client) musicGetLyricsTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-	// 关闭音频管理器，释放所有音频设备资源
-	if c.audioManager != nil {
-		if err := c.audioManager.Close(); err != nil {
-			c.logger.Warn("Failed to close audio manager", "error", err)
-		}
+	song := c.musicPlayer.GetCurrentSong()
+	if song == nil {
+		return nil, errors.New("no song is playing")
+	}
+	lyrics, err := c.musicPlayer.CurrentLyrics()
+	if err != nil {
+		return nil, err
 	}
 
-	// 再次等待确保设备释放
-	time.Sleep(1500 * time.Millisecond)
+	position := c.musicPlayer.Position()
+	result := map[string]interface{}{
+		"song":     song.Name,
+		"synced":   lyrics.Synced,
+		"position": math.Round(position.Seconds()*10) / 10,
+	}
 
-	c.logger.Info("Disconnected for music playback, audio device released")
+	// 没有时间信息时无法定位当前行，返回完整歌词
+	full, _ := args["full"].(bool)
+	if full || !lyrics.Synced {
+		result["lyrics"] = lyrics.Text()
+	}
+	if lyrics.Synced {
+		i := lyrics.Index(position)
+		if i >= 0 {
+			result["current"] = lyrics.Lines[i].Text
+		}
+		if i > 0 {
+			result["previous"] = lyrics.Lines[i-1].Text
+		}
+		if i+1 < len(lyrics.Lines) {
+			result["next"] = lyrics.Lines[i+1].Text
+		}
+	}
+	return result, nil
 }
 
-// reconnectAfterMusic 音乐结束后重新连接
-func (c *Client) reconnectAfterMusic() {
-	c.logger.Info("Reconnecting after music playback")
-
-	// 切换回表情模式
-	c.SetDisplayMode(DisplayModeEmotion)
+// musicRecentTool 最近播放或播放最多的歌曲
+func (c *Client) musicRecentTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-	// 显示默认表情
-	if err := c.ShowEmotion("neutral"); err != nil {
-		c.logger.Warn("Failed to show neutral emotion after music", "error", err)
+	limit := 10
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
 	}
 
-	// 重新创建音频管理器（因为之前的已经被完全关闭）
-	var err error
-	c.audioManager, err = audio.NewManager(
-		audio.Config{
-			SampleRate:    c.config.Audio.SampleRate,
-			Channels:      c.config.Audio.Channels,
-			FrameDuration: c.config.Audio.FrameDuration,
-		},
-		c.logger,
-	)
-	if err != nil {
-		c.logger.Error("Failed to recreate audio manager", "error", err)
-		return
+	var tracks []music.RecentTrack
+	switch sortBy, _ := args["sort"].(string); sortBy {
+	case "", "recent":
+		tracks = c.musicPlayer.Recent(limit)
+	case "most_played":
+		tracks = c.musicPlayer.MostPlayed(limit)
+	default:
+		return nil, fmt.Errorf("unknown sort: %s", sortBy)
 	}
-	c.logger.Info("Audio manager recreated successfully")
 
-	// 重新建立 WebSocket 连接
-	ctx := context.Background()
-	if err := c.Connect(ctx); err != nil {
-		c.logger.Error("Failed to reconnect after music", "error", err)
-		return
+	items := make([]map[string]interface{}, len(tracks))
+	for i, t := range tracks {
+		item := map[string]interface{}{
+			"index":       t.Index,
+			"name":        t.Name,
+			"play_count":  t.PlayCount,
+			"last_played": t.LastPlayed.Format(time.RFC3339),
+		}
+		if t.Position > 0 {
+			item["position"] = math.Round(t.Position.Seconds())
+		}
+		items[i] = item
 	}
 
-	// 重连成功后设置为空闲状态
-	c.setState(DeviceStateIdle)
-	c.logger.Info("Reconnected after music playback")
+	return map[string]interface{}{
+		"songs": items,
+		"count": len(items),
+	}, nil
 }
 
-// reconnect 自动重连
-// 当连接断开时自动尝试重新连接，支持指数退避重试
-func (c *Client) reconnect() bool {
-	c.logger.Info("Attempting to reconnect...")
-
-	// 设置为断开状态
-	c.setState(DeviceStateDisconnected)
+// musicResumeTool 从上次停止的位置继续播放最近播放的歌曲
+func (c *Client) musicResumeTool(args map[string]interface{}, mcpID interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-	// 清理旧的 transport
-	c.stateMutex.Lock()
-	if c.transport != nil {
-		c.transport.Close()
-		c.transport = nil
+	// 暂停中直接继续
+	if c.musicPlayer.IsPaused() {
+		c.musicPlayer.Resume()
+		return map[string]interface{}{
+			"playing":  true,
+			"position": math.Round(c.musicPlayer.Position().Seconds()),
+			"success":  true,
+		}, nil
 	}
-	c.stateMutex.Unlock()
 
-	// 重连参数
-	maxRetries := 5
-	baseDelay := time.Second
-	maxDelay := 30 * time.Second
+	// 播放开始提示音，等待播完再占用音频设备
+	c.PlayEarconAndWait(earcon.EventMusicStart)
 
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		// 检查是否已关闭
-		select {
-		case <-c.closeChan:
-			c.logger.Info("Client closed, stopping reconnection")
-			return false
-		default:
-		}
+	song, position, err := c.musicPlayer.ResumeLast()
+	if err != nil {
+		c.logger.Error("Failed to resume music", "error", err)
+		return nil, err
+	}
+	c.logger.Info("Resuming last played song", "song", song.Name, "position", position)
 
-		c.logger.Info("Reconnection attempt", "attempt", attempt, "maxRetries", maxRetries)
+	return c.enterMusicMode(mcpID, map[string]interface{}{
+		"success":  true,
+		"position": math.Round(position.Seconds()),
+	})
+}
 
-		// 创建新的 transport
-		transport, err := NewProtocol(c.config)
+// musicPlaylistListTool 列出播放列表，或列出指定列表中的歌曲
+func (c *Client) musicPlaylistListTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
+
+	if name, _ := args["name"].(string); strings.TrimSpace(name) != "" {
+		name, songs, err := c.musicPlayer.PlaylistSongs(name)
 		if err != nil {
-			c.logger.Error("Failed to create transport", "error", err)
-			continue
+			return nil, err
+		}
+		items := make([]map[string]interface{}, len(songs))
+		for i, song := range songs {
+			items[i] = map[string]interface{}{
+				"position": i,
+				"name":     song.Name,
+				"artist":   song.Artist,
+			}
 		}
+		return map[string]interface{}{
+			"name":  name,
+			"songs": items,
+			"count": len(items),
+		}, nil
+	}
 
-		// 尝试连接
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		err = transport.Connect(ctx)
-		cancel()
+	playlists, err := c.musicPlayer.Playlists()
+	if err != nil {
+		return nil, err
+	}
+	items := make([]map[string]interface{}, len(playlists))
+	for i, pl := range playlists {
+		items[i] = map[string]interface{}{
+			"name":   pl.Name,
+			"source": pl.Source,
+			"count":  pl.Count,
+		}
+	}
+	return map[string]interface{}{
+		"playlists": items,
+		"count":     len(items),
+		"playing":   c.musicPlayer.CurrentPlaylist(),
+	}, nil
+}
 
-		if err != nil {
-			c.logger.Error("Failed to connect", "error", err, "attempt", attempt)
-			transport.Close()
+// musicPlaylistCreateTool 创建播放列表
+func (c *Client) musicPlaylistCreateTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-			// 计算退避时间
-			delay := baseDelay * time.Duration(1<<(attempt-1))
-			if delay > maxDelay {
-				delay = maxDelay
-			}
+	name, _ := args["name"].(string)
+	if err := c.musicPlayer.CreatePlaylist(name); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"name":    strings.TrimSpace(name),
+		"success": true,
+	}, nil
+}
 
-			c.logger.Info("Waiting before retry", "delay", delay)
+// musicPlaylistAddTool 将歌曲加入播放列表，按索引或歌名
+func (c *Client) musicPlaylistAddTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-			select {
-			case <-c.closeChan:
-				c.logger.Info("Client closed during reconnection wait")
-				return false
-			case <-time.After(delay):
-				continue
-			}
+	name, _ := args["name"].(string)
+	index := -1
+	if v, ok := args["index"].(float64); ok {
+		index = int(v)
+	} else if query, _ := args["song"].(string); strings.TrimSpace(query) != "" {
+		results := c.musicPlayer.Search(query, 1)
+		if len(results) == 0 {
+			return nil, fmt.Errorf("no song matching %q", query)
 		}
+		index = results[0].Index
+	} else {
+		return nil, errors.New("index or song is required")
+	}
 
-		// 连接成功
-		c.stateMutex.Lock()
-		c.transport = transport
-		c.stateMutex.Unlock()
-
-		// 发送 hello 消息
-		helloMsg := map[string]interface{}{
-			"type":    "hello",
-			"version": 1,
-			"features": map[string]interface{}{
-				"mcp": true,
-			},
-			"transport": c.config.System.Network.Transport,
-			"audio_params": map[string]interface{}{
-				"format":         "opus",
-				"sample_rate":    c.config.Audio.SampleRate,
-				"channels":       c.config.Audio.Channels,
-				"frame_duration": c.config.Audio.FrameDuration,
-			},
-		}
+	song, err := c.musicPlayer.AddToPlaylist(name, index)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"playlist": strings.TrimSpace(name),
+		"song":     song.Name,
+		"success":  true,
+	}, nil
+}
 
-		if err := c.sendJSON(helloMsg); err != nil {
-			c.logger.Error("Failed to send hello message", "error", err)
-			continue
-		}
+// musicPlaylistRemoveTool 从播放列表移除歌曲
+func (c *Client) musicPlaylistRemoveTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-		c.setState(DeviceStateIdle)
-		c.logger.Info("Reconnection successful", "attempt", attempt)
-		return true
+	name, _ := args["name"].(string)
+	position, ok := args["position"].(float64)
+	if !ok {
+		return nil, errors.New("position must be a number")
 	}
 
-	c.logger.Error("Reconnection failed after max retries", "maxRetries", maxRetries)
-	return false
+	song, err := c.musicPlayer.RemoveFromPlaylist(name, int(position))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"removed": song.Name,
+		"success": true,
+	}, nil
 }
 
-// ShowMusicAnimation 显示音乐可视化效果
-func (c *Client) ShowMusicAnimation(songName string) error {
-	c.logger.Info("ShowMusicAnimation called", "songName", songName)
+// musicPlaylistDeleteTool 删除播放列表
+func (c *Client) musicPlaylistDeleteTool(args map[string]interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
-	if c.config.Display.SkipExecution {
-		c.logger.Info("Display execution skipped")
-		return nil
+	name, _ := args["name"].(string)
+	if err := c.musicPlayer.DeletePlaylist(name); err != nil {
+		return nil, err
 	}
+	return map[string]interface{}{"success": true}, nil
+}
 
-	// 使用程序生成的可视化效果
-	if c.musicPlayer != nil {
-		levelChan := c.musicPlayer.GetVisualizeChannel()
-		color := struct{ R, G, B uint8 }{R: 0, G: 180, B: 255} // 青色
-		c.logger.Info("Starting music visualizer")
-		return c.displayCtrl.ShowMusicVisualizer(levelChan, songName, color)
+// musicPlaylistPlayTool 播放播放列表
+func (c *Client) musicPlaylistPlayTool(args map[string]interface{}, mcpID interface{}) (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
 	}
 
-	c.logger.Warn("Music player is nil")
-	return nil
+	name, _ := args["name"].(string)
+
+	// 播放开始提示音，等待播完再占用音频设备
+	c.PlayEarconAndWait(earcon.EventMusicStart)
+
+	playlist, err := c.musicPlayer.PlayPlaylist(name)
+	if err != nil {
+		c.logger.Error("Failed to start playlist", "error", err)
+		return nil, err
+	}
+
+	return c.enterMusicMode(mcpID, map[string]interface{}{
+		"success":  true,
+		"playlist": playlist,
+	})
 }
 
-// musicPlayTool 播放音乐
-// mcpID 用于在断开连接前先发送 MCP 响应
-func (c *Client) musicPlayTool(args map[string]interface{}, mcpID interface{}) (interface{}, error) {
+// radioPlayTool 播放网络电台或音频流地址
+func (c *Client) radioPlayTool(args map[string]interface{}, mcpID interface{}) (interface{}, error) {
 	if c.musicPlayer == nil {
 		return nil, errors.New("music player is not initialized")
 	}
 
-	c.logger.Info("musicPlayTool: preparing to play music")
+	name, _ := args["name"].(string)
+	url, _ := args["url"].(string)
+	name, url = strings.TrimSpace(name), strings.TrimSpace(url)
 
-	// 切换到音乐模式
-	c.SetDisplayMode(DisplayModeMusic)
+	var station music.SongInfo
+	switch {
+	case url != "":
+		if err := music.CheckStation(url); err != nil {
+			return nil, err
+		}
+		station = music.SongInfo{Name: name, Path: url}
+	default:
+		stations := c.musicPlayer.Stations()
+		if len(stations) == 0 {
+			return nil, music.ErrNoStations
+		}
+		index := 0
+		if name != "" {
+			var err error
+			if index, err = c.musicPlayer.FindStation(name); err != nil {
+				return nil, err
+			}
+		}
+		station = stations[index]
+	}
 
-	// 先尝试播放音乐
-	if err := c.musicPlayer.Play(); err != nil {
-		c.logger.Error("Failed to start music playback", "error", err)
+	if station.Name == "" {
+		station.Name = station.Path
+	}
+
+	// 播放开始提示音，等待播完再占用音频设备
+	c.PlayEarconAndWait(earcon.EventMusicStart)
+
+	if err := c.musicPlayer.PlayStream(station); err != nil {
+		c.logger.Error("Failed to start radio", "error", err)
 		return nil, err
 	}
 
-	// 先发送 MCP 响应，再断开连接
-	c.logger.Info("Sending MCP response before disconnecting")
-	response := map[string]interface{}{
-		"playing": true,
+	return c.enterMusicMode(mcpID, map[string]interface{}{
 		"success": true,
-	}
-	if err := c.sendMCPResponse(mcpID, response); err != nil {
-		c.logger.Warn("Failed to send MCP response before disconnect", "error", err)
+		"station": station.Name,
+	})
+}
+
+// radioListTool 获取电台列表
+func (c *Client) radioListTool() (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
 	}
 
-	// 显示音乐动画
-	if song := c.musicPlayer.GetCurrentSong(); song != nil {
-		go c.ShowMusicAnimation(song.Name)
+	stations := c.musicPlayer.Stations()
+	items := make([]map[string]interface{}, len(stations))
+	for i, station := range stations {
+		items[i] = map[string]interface{}{
+			"index": i,
+			"name":  station.Name,
+		}
 	}
+	return map[string]interface{}{
+		"stations": items,
+		"count":    len(items),
+	}, nil
+}
 
-	// 断开 WebSocket 连接，释放音频设备
-	c.disconnectForMusic()
+// musicStatusTool 获取音乐播放状态
+func (c *Client) musicStatusTool() interface{} {
+	if c.musicPlayer == nil {
+		return map[string]interface{}{
+			"state": music.StateStopped,
+		}
+	}
 
-	// 注册一个回调，在音乐播放结束后自动重连
-	go func() {
-		// 等待音乐播放完成
-		for c.musicPlayer.IsPlaying() {
-			time.Sleep(500 * time.Millisecond)
+	status := c.musicPlayer.Status()
+	result := map[string]interface{}{
+		"state":    status.State,
+		"position": math.Round(status.Position.Seconds()*10) / 10,
+		"duration": math.Round(status.Duration.Seconds()*10) / 10,
+	}
+	if status.Duration > 0 {
+		result["remaining"] = math.Round((status.Duration-status.Position).Seconds()*10) / 10
+	}
+	if status.Song != nil {
+		result["song"] = status.Song.Name
+		result["index"] = status.Index
+		if status.Song.Artist != "" {
+			result["artist"] = status.Song.Artist
 		}
-		// 音乐停止后重连
-		time.Sleep(1 * time.Second)
-		if !c.musicPlayer.IsPlaying() && !c.musicPlayer.IsPaused() {
-			c.logger.Info("Music playback completed, reconnecting")
-			c.reconnectAfterMusic()
+		if status.Song.Album != "" {
+			result["album"] = status.Song.Album
 		}
-	}()
-
-	// 返回空结果，因为响应已经通过 sendMCPResponse 发送了
-	return nil, nil
+	}
+	if playlist := c.musicPlayer.CurrentPlaylist(); playlist != "" {
+		result["playlist"] = playlist
+	}
+	if status.Radio {
+		result["radio"] = true
+		if status.StreamTitle != "" {
+			result["stream_title"] = status.StreamTitle
+		}
+	}
+	result["mode"] = string(c.musicPlayer.Mode())
+	result["queue_length"] = len(c.musicPlayer.Queue())
+	return result
 }
 
-// musicPauseTool 暂停音乐
-func (c *Client) musicPauseTool(args map[string]interface{}) (interface{}, error) {
+// musicSetModeTool 设置播放模式
+func (c *Client) musicSetModeTool(args map[string]interface{}) (interface{}, error) {
 	if c.musicPlayer == nil {
 		return nil, errors.New("music player is not initialized")
 	}
 
-	c.musicPlayer.Pause()
+	modeStr, ok := args["mode"].(string)
+	if !ok {
+		return nil, errors.New("mode must be a string")
+	}
+	mode, err := music.ParseMode(modeStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.musicPlayer.SetMode(mode); err != nil {
+		return nil, err
+	}
 
 	return map[string]interface{}{
-		"paused":  true,
+		"mode":    string(mode),
 		"success": true,
 	}, nil
 }
 
-// musicStopTool 停止音乐
-func (c *Client) musicStopTool(args map[string]interface{}) (interface{}, error) {
+// musicQueueAddTool 将歌曲加入播放队列
+func (c *Client) musicQueueAddTool(args map[string]interface{}) (interface{}, error) {
 	if c.musicPlayer == nil {
 		return nil, errors.New("music player is not initialized")
 	}
 
-	c.musicPlayer.Stop()
+	index, ok := args["index"].(float64)
+	if !ok {
+		return nil, errors.New("index must be a number")
+	}
+	playNext, _ := args["play_next"].(bool)
 
-	// 停止音乐后恢复 WebSocket 连接
-	go c.reconnectAfterMusic()
+	song, err := c.musicPlayer.QueueAdd(int(index), playNext)
+	if err != nil {
+		return nil, err
+	}
 
 	return map[string]interface{}{
-		"stopped": true,
-		"success": true,
+		"song":         song.Name,
+		"queue_length": len(c.musicPlayer.Queue()),
+		"success":      true,
 	}, nil
 }
 
-// musicNextTool 下一首
-func (c *Client) musicNextTool(args map[string]interface{}) (interface{}, error) {
+// musicQueueListTool 获取播放队列
+func (c *Client) musicQueueListTool() interface{} {
 	if c.musicPlayer == nil {
-		return nil, errors.New("music player is not initialized")
-	}
-
-	if err := c.musicPlayer.Next(); err != nil {
-		return nil, err
+		return map[string]interface{}{"queue": []interface{}{}, "count": 0}
 	}
 
-	// 显示音乐动画
-	if song := c.musicPlayer.GetCurrentSong(); song != nil {
-		go c.ShowMusicAnimation(song.Name)
+	queue := c.musicPlayer.Queue()
+	items := make([]map[string]interface{}, len(queue))
+	for i, song := range queue {
+		items[i] = map[string]interface{}{
+			"position": i,
+			"name":     song.Name,
+		}
 	}
 
-	// 如果当前没有连接（音乐播放中断开的），不需要重连
-	// 如果已经重连了，这里也不需要额外操作
-
 	return map[string]interface{}{
-		"success": true,
-	}, nil
+		"queue": items,
+		"count": len(items),
+		"mode":  string(c.musicPlayer.Mode()),
+	}
 }
 
-// musicPreviousTool 上一首
-func (c *Client) musicPreviousTool(args map[string]interface{}) (interface{}, error) {
+// musicQueueRemoveTool 从播放队列移除歌曲
+func (c *Client) musicQueueRemoveTool(args map[string]interface{}) (interface{}, error) {
 	if c.musicPlayer == nil {
 		return nil, errors.New("music player is not initialized")
 	}
 
-	if err := c.musicPlayer.Previous(); err != nil {
-		return nil, err
+	position, ok := args["position"].(float64)
+	if !ok {
+		return nil, errors.New("position must be a number")
 	}
 
-	// 显示音乐动画
-	if song := c.musicPlayer.GetCurrentSong(); song != nil {
-		go c.ShowMusicAnimation(song.Name)
+	song, err := c.musicPlayer.QueueRemove(int(position))
+	if err != nil {
+		return nil, err
 	}
 
-	// 如果当前没有连接（音乐播放中断开的），不需要重连
-	// 如果已经重连了，这里也不需要额外操作
+	return map[string]interface{}{
+		"removed":      song.Name,
+		"queue_length": len(c.musicPlayer.Queue()),
+		"success":      true,
+	}, nil
+}
+
+// musicQueueClearTool 清空播放队列
+func (c *Client) musicQueueClearTool() (interface{}, error) {
+	if c.musicPlayer == nil {
+		return nil, errors.New("music player is not initialized")
+	}
 
+	c.musicPlayer.QueueClear()
 	return map[string]interface{}{
 		"success": true,
 	}, nil
 }
 
-// musicListTool 获取音乐列表
-func (c *Client) musicListTool() interface{} {
+// musicSeekTool 跳转到指定位置
+func (c *Client) musicSeekTool(args map[string]interface{}) (interface{}, error) {
 	if c.musicPlayer == nil {
-		return []interface{}{}
+		return nil, errors.New("music player is not initialized")
 	}
 
-	songs := c.musicPlayer.GetSongs()
-	result := make([]map[string]interface{}, len(songs))
-	for i, song := range songs {
-		result[i] = map[string]interface{}{
-			"index": i,
-			"name":  song.Name,
-			"path":  song.Path,
-		}
+	var target time.Duration
+	if position, ok := args["position"].(float64); ok {
+		target = time.Duration(position * float64(time.Second))
+	} else if offset, ok := args["offset"].(float64); ok {
+		target = c.musicPlayer.Position() + time.Duration(offset*float64(time.Second))
+	} else {
+		return nil, errors.New("position or offset must be a number")
 	}
 
-	return map[string]interface{}{
-		"songs": result,
-		"count": len(result),
+	actual, err := c.musicPlayer.Seek(target)
+	if err != nil {
+		return nil, err
 	}
+
+	return map[string]interface{}{
+		"position": math.Round(actual.Seconds()*10) / 10,
+		"duration": math.Round(c.musicPlayer.Duration().Seconds()*10) / 10,
+		"success":  true,
+	}, nil
 }
 
 // musicPlaySongTool 播放指定歌曲
@@ -2290,23 +4258,72 @@ func (c *Client) musicPlaySongTool(args map[string]interface{}, mcpID interface{
 
 	indexInt := int(index)
 
+	// 播放开始提示音，等待播完再占用音频设备
+	c.PlayEarconAndWait(earcon.EventMusicStart)
+
 	// 先尝试播放音乐，如果成功再断开连接
 	if err := c.musicPlayer.PlaySong(indexInt); err != nil {
 		c.logger.Error("Failed to start music playback", "error", err)
 		return nil, err
 	}
 
-	// 先发送 MCP 响应，再断开连接
-	// 这样可以确保响应能够成功发送
-	c.logger.Info("Sending MCP response before disconnecting")
 	response := map[string]interface{}{
 		"success": true,
 		"index":   indexInt,
 	}
+	return c.enterMusicMode(mcpID, response)
+}
+
+// enterMusicMode 音乐开始播放后：先发送 MCP 响应，再切换显示并断开连接释放音频设备，
+// 播放结束后自动重连。返回 nil 结果表示响应已发送
+func (c *Client) enterMusicMode(mcpID interface{}, response map[string]interface{}) (interface{}, error) {
+	// 先发送 MCP 响应，再断开连接
+	// 这样可以确保响应能够成功发送
+	c.logger.Info("Sending MCP response before disconnecting")
+	if song := c.musicPlayer.GetCurrentSong(); song != nil {
+		response["song"] = song.Name
+		if song.Artist != "" {
+			response["artist"] = song.Artist
+		}
+	}
 	if err := c.sendMCPResponse(mcpID, response); err != nil {
 		c.logger.Warn("Failed to send MCP response before disconnect", "error", err)
 	}
 
+	c.takeOverForMusic()
+
+	// 返回空结果，因为响应已经通过 sendMCPResponse 发送了
+	return nil, nil
+}
+
+// StartMusic 供 MPD、UPnP 等远程控制开始播放：已在播放音乐时直接切歌；
+// 否则先播放提示音，开始播放后在后台切换到音乐模式并释放语音对话占用的音频设备
+func (c *Client) StartMusic(start func() error) error {
+	if c.musicPlayer == nil {
+		return errors.New("music player is not initialized")
+	}
+
+	if c.musicPlayer.IsPlaying() {
+		if err := start(); err != nil {
+			return err
+		}
+		if song := c.musicPlayer.GetCurrentSong(); song != nil {
+			go c.ShowMusicAnimation(song.Name)
+		}
+		return nil
+	}
+
+	c.PlayEarconAndWait(earcon.EventMusicStart)
+	if err := start(); err != nil {
+		c.logger.Error("Failed to start music playback", "error", err)
+		return err
+	}
+	go c.takeOverForMusic()
+	return nil
+}
+
+// takeOverForMusic 音乐开始播放后切换显示并断开连接释放音频设备，播放结束后自动重连
+func (c *Client) takeOverForMusic() {
 	// 切换到音乐模式
 	c.SetDisplayMode(DisplayModeMusic)
 
@@ -2331,9 +4348,6 @@ func (c *Client) musicPlaySongTool(args map[string]interface{}, mcpID interface{
 			c.reconnectAfterMusic()
 		}
 	}()
-
-	// 返回空结果，因为响应已经通过 sendMCPResponse 发送了
-	return nil, nil
 }
 
 // sendMCPResponse 发送 MCP 响应
@@ -2396,3 +4410,407 @@ func (c *Client) SendMCPNotification(method string, params interface{}) error {
 
 	return c.sendJSON(msg)
 }
+
+// ============================================================================
+// 闹钟
+// ============================================================================
+
+// alarmPause 内置铃声两次循环之间的停顿
+const alarmPause = 500 * time.Millisecond
+
+// weekdayNames 星期的中文名称
+var weekdayNames = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// AlarmRinging 是否有闹钟正在响铃
+func (c *Client) AlarmRinging() bool {
+	if c.alarms == nil {
+		return false
+	}
+	_, ok := c.alarms.Ringing()
+	return ok
+}
+
+// SnoozeAlarm 贪睡：停止响铃，按配置的时长之后再响
+func (c *Client) SnoozeAlarm() error {
+	_, err := c.snoozeAlarm(0)
+	return err
+}
+
+// DismissAlarm 关闭正在响铃或贪睡中的闹钟
+func (c *Client) DismissAlarm() error {
+	if c.alarms == nil {
+		return errors.New("alarms are not enabled")
+	}
+	if _, err := c.alarms.Dismiss(); err != nil {
+		return err
+	}
+	c.showNotice("闹钟已关闭")
+	return nil
+}
+
+// snoozeAlarm 贪睡 d，d 为 0 时使用配置的贪睡时长
+func (c *Client) snoozeAlarm(d time.Duration) (alarm.Alarm, error) {
+	if c.alarms == nil {
+		return alarm.Alarm{}, errors.New("alarms are not enabled")
+	}
+	if d <= 0 {
+		d = alarm.DefaultSnooze
+		if c.config.Alarm.SnoozeMinutes > 0 {
+			d = time.Duration(c.config.Alarm.SnoozeMinutes) * time.Minute
+		}
+	}
+	a, err := c.alarms.Snooze(d)
+	if err != nil {
+		return a, err
+	}
+	c.showNotice(fmt.Sprintf("%d 分钟后再响", int(d.Round(time.Minute)/time.Minute)))
+	return a, nil
+}
+
+// onAlarmRing 闹钟响起，在调度协程中调用，不能阻塞
+func (c *Client) onAlarmRing(a alarm.Alarm) {
+	stop := make(chan struct{})
+	c.ringMu.Lock()
+	if c.ringStop != nil {
+		close(c.ringStop)
+	}
+	c.ringStop = stop
+	c.ringSongPath = ""
+	c.ringPrevMode = c.GetDisplayModeEnum()
+	c.ringMu.Unlock()
+
+	go c.ring(a, stop)
+}
+
+// onAlarmStop 响铃停止（贪睡、关闭或超时）：停止铃声并恢复响铃前的画面
+func (c *Client) onAlarmStop(a alarm.Alarm) {
+	c.ringMu.Lock()
+	if c.ringStop != nil {
+		close(c.ringStop)
+		c.ringStop = nil
+	}
+	songPath := c.ringSongPath
+	c.ringSongPath = ""
+	prev := c.ringPrevMode
+	c.ringMu.Unlock()
+
+	// 铃声歌曲停止后由 takeOverForMusic 中的等待协程重连并恢复表情画面
+	if songPath != "" {
+		if song := c.musicPlayer.GetCurrentSong(); song != nil && song.Path == songPath {
+			c.musicPlayer.Stop()
+			return
+		}
+	}
+
+	if c.GetDisplayModeEnum() != DisplayModeAlarm {
+		return
+	}
+	if prev == DisplayModeClock {
+		c.ShowDateTime()
+		return
+	}
+	c.SetDisplayMode(DisplayModeEmotion)
+	if err := c.ShowEmotion("neutral"); err != nil {
+		c.logger.Warn("Failed to show neutral emotion after alarm", "error", err)
+	}
+}
+
+// stopRinging 退出时结束响铃循环
+func (c *Client) stopRinging() {
+	c.ringMu.Lock()
+	defer c.ringMu.Unlock()
+	if c.ringStop != nil {
+		close(c.ringStop)
+		c.ringStop = nil
+	}
+}
+
+// ring 响铃：优先播放铃声歌曲，找不到、播放失败或播完仍无人响应时循环播放内置铃声，直到 stop 关闭
+func (c *Client) ring(a alarm.Alarm, stop chan struct{}) {
+	if index, ok := c.alarmSong(a); ok {
+		if err := c.StartMusic(func() error { return c.musicPlayer.PlaySong(index) }); err != nil {
+			c.logger.Warn("Failed to play alarm song, using built-in tone", "error", err)
+		} else if !c.waitAlarmSong(stop) {
+			return
+		}
+	}
+
+	// 暂停中的音乐同样处于播放状态，停止后由 takeOverForMusic 中的等待协程重连，恢复音频设备
+	if c.musicPlayer != nil && c.musicPlayer.IsPlaying() {
+		c.musicPlayer.Stop()
+	}
+
+	for {
+		c.showRinging(a, stop)
+		d := c.playEarcon(earcon.EventAlarm)
+		if d <= 0 {
+			d = time.Second // 音频设备尚未恢复时稍后重试
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(d + alarmPause):
+		}
+	}
+}
+
+// waitAlarmSong 等待铃声歌曲播完，返回是否仍需继续响铃
+func (c *Client) waitAlarmSong(stop chan struct{}) bool {
+	song := c.musicPlayer.GetCurrentSong()
+	c.ringMu.Lock()
+	stopped := c.ringStop != stop
+	if !stopped && song != nil {
+		c.ringSongPath = song.Path
+	}
+	c.ringMu.Unlock()
+	if stopped {
+		// 开始播放前响铃已被停止
+		c.musicPlayer.Stop()
+		return false
+	}
+	if song == nil {
+		return true
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+		if current := c.musicPlayer.GetCurrentSong(); !c.musicPlayer.IsPlaying() || current == nil || current.Path != song.Path {
+			break
+		}
+	}
+	c.ringMu.Lock()
+	stopped = c.ringStop != stop
+	c.ringSongPath = ""
+	c.ringMu.Unlock()
+	return !stopped
+}
+
+// alarmSong 闹钟的铃声歌曲在曲库中的索引
+func (c *Client) alarmSong(a alarm.Alarm) (int, bool) {
+	name := a.Song
+	if name == "" {
+		name = c.config.Alarm.Song
+	}
+	if name == "" || c.musicPlayer == nil {
+		return 0, false
+	}
+	results := c.musicPlayer.Search(name, 1)
+	if len(results) == 0 {
+		c.logger.Warn("Alarm song not found, using built-in tone", "song", name)
+		return 0, false
+	}
+	return results[0].Index, true
+}
+
+// ringingAlarm 正在用铃声歌曲响铃的闹钟，供音乐画面显示
+func (c *Client) ringingAlarm() (alarm.Alarm, bool) {
+	if c.alarms == nil {
+		return alarm.Alarm{}, false
+	}
+	c.ringMu.Lock()
+	path := c.ringSongPath
+	c.ringMu.Unlock()
+	if path == "" {
+		return alarm.Alarm{}, false
+	}
+	return c.alarms.Ringing()
+}
+
+// showRinging 显示响铃画面，响铃已停止时不再绘制
+func (c *Client) showRinging(a alarm.Alarm, stop chan struct{}) {
+	if c.config.Display.SkipExecution {
+		return
+	}
+	c.ringMu.Lock()
+	defer c.ringMu.Unlock()
+	if c.ringStop != stop {
+		return
+	}
+	if c.GetDisplayModeEnum() != DisplayModeAlarm {
+		c.SetDisplayMode(DisplayModeAlarm)
+	}
+	text := alarmTitle(a) + "\n按键贪睡，双击关闭"
+	if err := c.ShowText(text, c.config.Display.FontSize, c.config.Display.TextAlign.Horizontal, c.config.Display.TextAlign.Vertical); err != nil {
+		c.logger.Debug("Failed to show alarm", "error", err)
+	}
+}
+
+// alarmTitle 响铃时显示的标题
+func alarmTitle(a alarm.Alarm) string {
+	title := "闹钟 " + time.Now().Format("15:04")
+	if a.Label != "" {
+		title += " " + a.Label
+	}
+	return title
+}
+
+// alarmInfo 闹钟的工具返回信息
+func alarmInfo(a alarm.Alarm, now time.Time) map[string]interface{} {
+	info := map[string]interface{}{
+		"id":        a.ID,
+		"recurring": a.Recurring(),
+	}
+	if next := a.Next(now); !next.IsZero() {
+		info["next"] = next.Format("2006-01-02 15:04") + " " + weekdayNames[next.Weekday()]
+	}
+	if a.Repeat != "" {
+		info["repeat"] = a.Repeat
+	}
+	if a.Label != "" {
+		info["label"] = a.Label
+	}
+	if a.Song != "" {
+		info["song"] = a.Song
+	}
+	if a.Snooze.After(now) {
+		info["snoozed_until"] = a.Snooze.Format("15:04")
+	}
+	return info
+}
+
+// parseAlarmDate 解析一次性闹钟的日期，支持 YYYY-MM-DD 和今天、明天、后天
+func parseAlarmDate(s string, now time.Time) (time.Time, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "today", "今天":
+		return now, nil
+	case "tomorrow", "明天":
+		return now.AddDate(0, 0, 1), nil
+	case "day_after_tomorrow", "后天":
+		return now.AddDate(0, 0, 2), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(s), now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", s)
+	}
+	return t, nil
+}
+
+// alarmSetTool 设置一次性或重复闹钟
+func (c *Client) alarmSetTool(args map[string]interface{}) (interface{}, error) {
+	if c.alarms == nil {
+		return nil, errors.New("alarms are not enabled")
+	}
+
+	now := time.Now()
+	label, _ := args["label"].(string)
+	song, _ := args["song"].(string)
+	a := alarm.Alarm{Label: strings.TrimSpace(label), Song: strings.TrimSpace(song)}
+
+	if minutes, ok := args["in_minutes"].(float64); ok {
+		if minutes <= 0 {
+			return nil, errors.New("in_minutes must be a positive number")
+		}
+		a.At = now.Add(time.Duration(minutes * float64(time.Minute))).Truncate(time.Second)
+	} else {
+		timeStr, _ := args["time"].(string)
+		repeat, _ := args["repeat"].(string)
+		var hour, minute int
+		if strings.TrimSpace(timeStr) != "" {
+			t, err := time.Parse("15:04", strings.TrimSpace(timeStr))
+			if err != nil {
+				return nil, fmt.Errorf("invalid time %q, expected HH:MM", timeStr)
+			}
+			hour, minute = t.Hour(), t.Minute()
+		} else if len(strings.Fields(repeat)) != 5 {
+			// 只有 cron 表达式自带时间
+			return nil, errors.New("time or in_minutes is required")
+		}
+
+		cron, err := alarm.ParseRepeat(repeat, hour, minute)
+		if err != nil {
+			return nil, err
+		}
+		if cron != "" {
+			a.Repeat = cron
+		} else {
+			dateStr, _ := args["date"].(string)
+			day, err := parseAlarmDate(dateStr, now)
+			if err != nil {
+				return nil, err
+			}
+			a.At = time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+			// 没有指定日期且今天的时间已过，设在明天
+			if strings.TrimSpace(dateStr) == "" && !a.At.After(now) {
+				a.At = a.At.AddDate(0, 0, 1)
+			}
+		}
+	}
+
+	a, err := c.alarms.Add(a)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"success": true,
+		"alarm":   alarmInfo(a, now),
+	}, nil
+}
+
+// alarmListTool 列出所有闹钟
+func (c *Client) alarmListTool() (interface{}, error) {
+	if c.alarms == nil {
+		return nil, errors.New("alarms are not enabled")
+	}
+
+	now := time.Now()
+	ringing, _ := c.alarms.Ringing()
+	alarms := c.alarms.List()
+	items := make([]map[string]interface{}, len(alarms))
+	for i, a := range alarms {
+		items[i] = alarmInfo(a, now)
+		if a.ID == ringing.ID {
+			items[i]["ringing"] = true
+		}
+	}
+	return map[string]interface{}{
+		"alarms": items,
+		"now":    now.Format("2006-01-02 15:04") + " " + weekdayNames[now.Weekday()],
+	}, nil
+}
+
+// alarmCancelTool 删除指定闹钟，不传 id 时关闭正在响铃或贪睡中的闹钟
+func (c *Client) alarmCancelTool(args map[string]interface{}) (interface{}, error) {
+	if c.alarms == nil {
+		return nil, errors.New("alarms are not enabled")
+	}
+
+	var (
+		a   alarm.Alarm
+		err error
+	)
+	if id, ok := args["id"].(float64); ok {
+		a, err = c.alarms.Cancel(int(id))
+	} else {
+		a, err = c.alarms.Dismiss()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"success": true,
+		"id":      a.ID,
+	}, nil
+}
+
+// alarmSnoozeTool 贪睡正在响铃的闹钟
+func (c *Client) alarmSnoozeTool(args map[string]interface{}) (interface{}, error) {
+	var d time.Duration
+	if minutes, ok := args["minutes"].(float64); ok && minutes > 0 {
+		d = time.Duration(minutes * float64(time.Minute))
+	}
+	a, err := c.snoozeAlarm(d)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"success": true,
+		"id":      a.ID,
+		"until":   a.Snooze.Format("15:04"),
+	}, nil
+}
@@ -15,7 +15,6 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"log/slog"
-	"math"
 	"os"
 	"path/filepath"
 	"strings"
@@ -1064,8 +1063,24 @@ func getRotatedPixel(x, y, width, height int, rotation Rotation) (outX, outY int
 // 音乐可视化显示
 // ============================================================================
 
-// ShowMusicVisualizer 显示音乐可视化效果
-func (dc *DisplayController) ShowMusicVisualizer(levelChan <-chan float64, songName string, color interface{}) error {
+// MusicScreen 音乐模式的显示内容，函数均可为 nil
+type MusicScreen struct {
+	Frames   <-chan []float64 // 频谱帧，每帧为各频带能量（0.0-1.0），柱数随频带数
+	Style    MusicVisualizerStyle
+	Progress MusicProgressFunc // 当前播放位置和总时长
+	Label    MusicLabelFunc    // 顶部文字
+	Lyrics   MusicLyricsFunc   // 同步歌词
+	Sleep    MusicSleepFunc    // 睡眠定时
+
+	// 正在播放卡片：有封面时显示封面、标题/歌手、播放状态和进度；
+	// 没有封面时在封面位置播放 AnimationPath 中的动画，两者都没有时显示频谱
+	NowPlaying    MusicNowPlayingFunc
+	Cover         MusicCoverFunc
+	AnimationPath string
+}
+
+// ShowMusicVisualizer 显示音乐模式：正在播放卡片或频谱
+func (dc *DisplayController) ShowMusicVisualizer(screen MusicScreen) error {
 	dc.taskMutex.Lock()
 	defer dc.taskMutex.Unlock()
 
@@ -1094,14 +1109,16 @@ func (dc *DisplayController) ShowMusicVisualizer(levelChan <-chan float64, songN
 
 	go func() {
 		defer close(dc.currentTask.done)
-		dc.runMusicVisualizer(ctx, levelChan, songName, color)
+		dc.runMusicVisualizer(ctx, screen)
 	}()
 
 	return nil
 }
 
 // runMusicVisualizer 音乐可视化显示实现
-func (dc *DisplayController) runMusicVisualizer(ctx context.Context, levelChan <-chan float64, songName string, colorValue interface{}) {
+func (dc *DisplayController) runMusicVisualizer(ctx context.Context, screen MusicScreen) {
+	frameChan, style := screen.Frames, screen.Style
+	progress, label, lyrics, sleep := screen.Progress, screen.Label, screen.Lyrics, screen.Sleep
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Error("音乐可视化 panic 恢复", "错误", r)
@@ -1119,53 +1136,118 @@ func (dc *DisplayController) runMusicVisualizer(ctx context.Context, levelChan <
 	}
 	defer dc.closeFramebuffer()
 
-	// 波形条数量
-	barCount := 16
-	barWidth := fbWidth / barCount
-	barMaxHeight := fbHeight * 2 / 3
-
-	// 波形条高度历史（用于平滑过渡）
-	bars := make([]float64, barCount)
-	targetBars := make([]float64, barCount)
-
-	// 颜色
-	var col struct{ R, G, B uint8 }
-	switch v := colorValue.(type) {
-	case struct{ R, G, B uint8 }:
-		col = v
-	default:
-		col = struct{ R, G, B uint8 }{R: 0, G: 200, B: 255} // 默认青色
+	bars := newSpectrumBars(defaultBarCount)
+
+	// 正在播放卡片的封面区域
+	var art *nowPlayingArt
+	if screen.NowPlaying != nil {
+		art = &nowPlayingArt{cover: screen.Cover, animPath: screen.AnimationPath}
+	}
+	var lastCard time.Time
+
+	// 进度条时间、顶部文字、歌词和睡眠倒计时使用较小的字号
+	showClock := progress != nil
+	showLabel := label != nil
+	showLyrics := lyrics != nil
+	showSleep := sleep != nil
+	if showClock || showLabel || showLyrics || showSleep || art != nil {
+		if err := dc.loadFont(dc.fontPath, progressFontSize()); err != nil {
+			slog.Warn("加载字体失败，仅显示进度条", "错误", err)
+			showClock = false
+			showLabel = false
+			showLyrics = false
+			showSleep = false
+		}
 	}
 
 	// 动画帧率
 	ticker := time.NewTicker(33 * time.Millisecond) // ~30 FPS
 	defer ticker.Stop()
 
-	phase := 0.0
-
 	for {
 		select {
 		case <-ctx.Done():
 			slog.Info("音乐可视化被中断")
 			return
-		case level, ok := <-levelChan:
+		case frame, ok := <-frameChan:
 			if !ok {
 				// 通道关闭，退出
 				return
 			}
-			// 更新目标波形高度
-			dc.updateTargetBars(targetBars, level, &phase)
+			if len(frame) > 0 && len(frame) != len(bars.heights) {
+				bars = newSpectrumBars(len(frame))
+			}
+			bars.setTargets(frame)
 		case <-ticker.C:
+			// 有歌词时压低频谱，歌词显示在屏幕中部
+			var current, next string
+			var scroll float64
+			if showLyrics {
+				current, next, scroll = lyrics()
+			}
+
+			// 有封面或动画时显示正在播放卡片，卡片以较低帧率刷新
+			if art != nil {
+				np := screen.NowPlaying()
+				size := newCardLayout(dc.lineHeight()).artSize
+				if img := art.frame(dc, np.Key, size); img != nil {
+					bars.step()
+					if time.Since(lastCard) < cardFrameInterval {
+						continue
+					}
+					lastCard = time.Now()
+					for i := range dbuffer.backBuffer {
+						dbuffer.backBuffer[i] = 0
+					}
+					var position, duration time.Duration
+					if progress != nil {
+						position, duration = progress()
+					}
+					var sleepText string
+					if showSleep {
+						if remaining, active := sleep(); active {
+							sleepText = "睡眠 " + formatClock(remaining)
+						}
+					}
+					dc.drawNowPlaying(img, np, current, position, duration, sleepText, style)
+					dc.waitForVSync()
+					copy(dbuffer.frontBuffer, dbuffer.backBuffer)
+					copy(fbData, dbuffer.frontBuffer)
+					continue
+				}
+			}
+
 			// 清空后台缓冲
 			for i := range dbuffer.backBuffer {
 				dbuffer.backBuffer[i] = 0
 			}
+			barMaxHeight := fbHeight * 2 / 3
+			if current != "" || next != "" {
+				barMaxHeight = lyricsBarHeight()
+			}
 
-			// 平滑过渡
-			dc.smoothBars(bars, targetBars, 0.3)
+			// 平滑过渡并绘制频谱
+			bars.step()
+			drawSpectrumBars(bars, barMaxHeight, style)
+			dc.drawMusicLyrics(current, next, scroll)
 
-			// 绘制波形
-			dc.drawWaveformBars(bars, barWidth, barMaxHeight, col)
+			// 绘制进度条和时间
+			if progress != nil {
+				position, duration := progress()
+				dc.drawMusicProgress(position, duration, style.Bottom, showClock)
+			}
+
+			// 绘制睡眠定时倒计时
+			if showSleep {
+				if remaining, active := sleep(); active {
+					dc.drawMusicSleep(remaining, style.Top)
+				}
+			}
+
+			// 绘制顶部文字（播放列表和歌名）
+			if showLabel {
+				dc.drawMusicLabel(label())
+			}
 
 			// 等待垂直同步并交换缓冲
 			dc.waitForVSync()
@@ -1174,86 +1256,3 @@ func (dc *DisplayController) runMusicVisualizer(ctx context.Context, levelChan <
 		}
 	}
 }
-
-// updateTargetBars 更新目标波形高度
-func (dc *DisplayController) updateTargetBars(bars []float64, level float64, phase *float64) {
-	barCount := len(bars)
-
-	// 使用音频级别和相位生成波形效果
-	*phase += 0.15
-
-	for i := 0; i < barCount; i++ {
-		// 生成波形效果：中心高，两边低，随音量变化
-		center := float64(barCount) / 2
-		distance := math.Abs(float64(i) - center)
-		decay := 1.0 - (distance/center)*0.6
-
-		// 添加波动效果
-		wave := math.Sin(*phase+float64(i)*0.5)*0.3 + 0.7
-
-		// 目标高度
-		targetHeight := level * decay * wave
-		if targetHeight > 1.0 {
-			targetHeight = 1.0
-		}
-		bars[i] = targetHeight
-	}
-}
-
-// smoothBars 平滑波形过渡
-func (dc *DisplayController) smoothBars(bars, targetBars []float64, smoothFactor float64) {
-	for i := range bars {
-		// 线性插值平滑
-		bars[i] = bars[i] + (targetBars[i]-bars[i])*smoothFactor
-	}
-}
-
-// drawWaveformBars 绘制波形条
-func (dc *DisplayController) drawWaveformBars(bars []float64, barWidth, maxHeight int, col struct{ R, G, B uint8 }) {
-	barCount := len(bars)
-	spacing := 2 // 条间距
-
-	for i := 0; i < barCount; i++ {
-		height := int(bars[i] * float64(maxHeight))
-		if height < 2 {
-			height = 2
-		}
-
-		x := i*barWidth + spacing/2
-		barW := barWidth - spacing
-		if barW < 1 {
-			barW = 1
-		}
-
-		// 从底部开始绘制
-		yStart := fbHeight - height
-
-		// 绘制渐变色波形条
-		for y := yStart; y < fbHeight; y++ {
-			// 渐变效果：顶部亮，底部暗
-			progress := float64(y-yStart) / float64(height)
-			r := uint8(float64(col.R) * (0.5 + 0.5*progress))
-			g := uint8(float64(col.G) * (0.5 + 0.5*progress))
-			b := uint8(float64(col.B) * (0.5 + 0.5*progress))
-
-			for dx := 0; dx < barW; dx++ {
-				fbX := x + dx
-				if fbX < 0 || fbX >= fbWidth {
-					continue
-				}
-
-				offset := y*lineLength + fbX*(bpp/8)
-				switch bpp {
-				case 16:
-					color := uint16(r>>3)<<11 | uint16(g>>2)<<5 | uint16(b>>3)
-					binary.LittleEndian.PutUint16(dbuffer.backBuffer[offset:], color)
-				case 32:
-					dbuffer.backBuffer[offset] = b
-					dbuffer.backBuffer[offset+1] = g
-					dbuffer.backBuffer[offset+2] = r
-					dbuffer.backBuffer[offset+3] = 0xFF
-				}
-			}
-		}
-	}
-}
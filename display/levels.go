@@ -0,0 +1,193 @@
+package display
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// ============================================================================
+// 电平驱动的监听/说话动画
+// ============================================================================
+
+// levelDrawFunc 根据平滑后的电平（0.0-1.0）绘制一帧到后台缓冲
+type levelDrawFunc func(level float64, phase float64, col struct{ R, G, B uint8 })
+
+// ShowListeningAnimation 显示随麦克风电平变化的监听动画
+func (dc *DisplayController) ShowListeningAnimation(levelChan <-chan float64, color interface{}) error {
+	return dc.startLevelAnimation("listening_meter", levelChan, color, dc.drawListeningFrame)
+}
+
+// ShowSpeakingAnimation 显示随 TTS 播放电平开合的嘴巴动画
+func (dc *DisplayController) ShowSpeakingAnimation(levelChan <-chan float64, color interface{}) error {
+	return dc.startLevelAnimation("speaking_meter", levelChan, color, dc.drawSpeakingFrame)
+}
+
+// startLevelAnimation 中断当前任务并启动电平动画
+func (dc *DisplayController) startLevelAnimation(taskType string, levelChan <-chan float64, color interface{}, draw levelDrawFunc) error {
+	dc.taskMutex.Lock()
+	defer dc.taskMutex.Unlock()
+
+	// 中断当前任务
+	if dc.currentTask != nil {
+		slog.Info("Interrupting current display task", "type", dc.currentTask.taskType)
+		dc.currentTask.cancel()
+		dc.waitTaskDone()
+	}
+
+	// 清除动画记录
+	dc.animMutex.Lock()
+	dc.currentAnim = ""
+	dc.animMutex.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dc.currentTask = &taskContext{
+		ctx:      ctx,
+		cancel:   cancel,
+		taskType: taskType,
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(dc.currentTask.done)
+		dc.runLevelAnimation(ctx, levelChan, color, draw)
+	}()
+
+	return nil
+}
+
+// runLevelAnimation 电平动画主循环
+func (dc *DisplayController) runLevelAnimation(ctx context.Context, levelChan <-chan float64, colorValue interface{}, draw levelDrawFunc) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("电平动画 panic 恢复", "错误", r)
+		}
+	}()
+
+	if ctx.Err() != nil {
+		slog.Info("电平动画在开始前已被取消")
+		return
+	}
+
+	if err := dc.initFramebuffer(); err != nil {
+		slog.Error("初始化帧缓冲失败", "错误", err)
+		return
+	}
+	defer dc.closeFramebuffer()
+
+	var col struct{ R, G, B uint8 }
+	switch v := colorValue.(type) {
+	case struct{ R, G, B uint8 }:
+		col = v
+	default:
+		col = struct{ R, G, B uint8 }{R: 0, G: 200, B: 255}
+	}
+
+	ticker := time.NewTicker(33 * time.Millisecond) // ~30 FPS
+	defer ticker.Stop()
+
+	target, level, phase := 0.0, 0.0, 0.0
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("电平动画被中断")
+			return
+		case v, ok := <-levelChan:
+			if !ok {
+				return
+			}
+			target = v
+		case <-ticker.C:
+			// 快起慢落，避免画面抖动
+			if target > level {
+				level += (target - level) * 0.6
+			} else {
+				level += (target - level) * 0.15
+			}
+			phase += 0.1
+
+			for i := range dbuffer.backBuffer {
+				dbuffer.backBuffer[i] = 0
+			}
+			draw(level, phase, col)
+
+			dc.waitForVSync()
+			copy(dbuffer.frontBuffer, dbuffer.backBuffer)
+			copy(fbData, dbuffer.frontBuffer)
+		}
+	}
+}
+
+// drawListeningFrame 绘制监听动画：中心圆随电平放大，外圈缓慢呼吸
+func (dc *DisplayController) drawListeningFrame(level, phase float64, col struct{ R, G, B uint8 }) {
+	cx, cy := fbWidth/2, fbHeight/2
+	minDim := fbWidth
+	if fbHeight < minDim {
+		minDim = fbHeight
+	}
+
+	base := float64(minDim) * 0.12
+	radius := base + float64(minDim)*0.25*math.Min(level*2.5, 1)
+	ring := radius + float64(minDim)*0.04*(1+math.Sin(phase))
+
+	// 外圈
+	dim := struct{ R, G, B uint8 }{R: col.R / 3, G: col.G / 3, B: col.B / 3}
+	dc.fillEllipse(cx, cy, ring, ring, dim)
+	dc.fillEllipse(cx, cy, ring-3, ring-3, struct{ R, G, B uint8 }{})
+	// 中心圆
+	dc.fillEllipse(cx, cy, radius, radius, col)
+}
+
+// drawSpeakingFrame 绘制说话动画：两只眼睛和随电平开合的嘴巴
+func (dc *DisplayController) drawSpeakingFrame(level, _ float64, col struct{ R, G, B uint8 }) {
+	cx, cy := fbWidth/2, fbHeight/2
+	minDim := fbWidth
+	if fbHeight < minDim {
+		minDim = fbHeight
+	}
+
+	eyeR := float64(minDim) * 0.07
+	eyeDX := minDim / 5
+	eyeY := cy - minDim/6
+	dc.fillEllipse(cx-eyeDX, eyeY, eyeR, eyeR, col)
+	dc.fillEllipse(cx+eyeDX, eyeY, eyeR, eyeR, col)
+
+	mouthW := float64(minDim) * 0.22
+	mouthH := float64(minDim) * (0.02 + 0.16*math.Min(level*3, 1))
+	dc.fillEllipse(cx, cy+minDim/6, mouthW, mouthH, col)
+}
+
+// fillEllipse 在后台缓冲绘制实心椭圆
+func (dc *DisplayController) fillEllipse(cx, cy int, rx, ry float64, col struct{ R, G, B uint8 }) {
+	if rx <= 0 || ry <= 0 {
+		return
+	}
+	for dy := -int(ry); dy <= int(ry); dy++ {
+		t := float64(dy) / ry
+		half := int(rx * math.Sqrt(math.Max(0, 1-t*t)))
+		for dx := -half; dx <= half; dx++ {
+			setBackPixel(cx+dx, cy+dy, col.R, col.G, col.B)
+		}
+	}
+}
+
+// setBackPixel 设置后台缓冲中的单个像素
+func setBackPixel(x, y int, r, g, b uint8) {
+	if x < 0 || y < 0 || x >= fbWidth || y >= fbHeight {
+		return
+	}
+	offset := y*lineLength + x*(bpp/8)
+	switch bpp {
+	case 16:
+		color := uint16(r>>3)<<11 | uint16(g>>2)<<5 | uint16(b>>3)
+		binary.LittleEndian.PutUint16(dbuffer.backBuffer[offset:], color)
+	case 32:
+		dbuffer.backBuffer[offset] = b
+		dbuffer.backBuffer[offset+1] = g
+		dbuffer.backBuffer[offset+2] = r
+		dbuffer.backBuffer[offset+3] = 0xFF
+	}
+}
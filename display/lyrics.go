@@ -0,0 +1,50 @@
+package display
+
+import "math"
+
+// ============================================================================
+// 音乐歌词
+// ============================================================================
+
+// MusicLyricsFunc 返回当前歌词行、下一行，以及切换到下一行前的滚动进度（0.0-1.0）
+// 没有同步歌词时 current 和 next 都为空
+type MusicLyricsFunc func() (current, next string, scroll float64)
+
+// lyricsBarHeight 显示歌词时频谱柱的最大高度，给歌词让出屏幕中部
+func lyricsBarHeight() int {
+	return fbHeight * 2 / 5
+}
+
+// drawMusicLyrics 在进度条和频谱之间居中绘制当前行（白色）和下一行（暗色）
+// 滚动时两行一起上移，当前行淡出，下一行变亮
+func (dc *DisplayController) drawMusicLyrics(current, next string, scroll float64) {
+	if dc.fontFace == nil || (current == "" && next == "") {
+		return
+	}
+
+	lineHeight := dc.fontFace.Metrics().Height.Ceil() * 3 / 2
+	baseY := fbHeight/4 + lineHeight // 进度条下方一行
+	maxWidth := fbWidth - 2*(fbWidth/12)
+
+	// 缓动：开始和结束时较慢
+	t := (1 - math.Cos(math.Pi*scroll)) / 2
+	offset := int(float64(lineHeight) * t)
+
+	const bright, dim = 255.0, 110.0
+	lines := []struct {
+		text  string
+		y     int
+		level float64
+	}{
+		{current, baseY - offset, bright - (bright-dim*0.3)*t},
+		{next, baseY + lineHeight - offset, dim + (bright-dim)*t},
+	}
+	for _, line := range lines {
+		if line.text == "" {
+			continue
+		}
+		text, width := dc.fitText(line.text, maxWidth)
+		v := uint8(line.level)
+		dc.drawString(text, (fbWidth-width)/2, line.y, struct{ R, G, B uint8 }{R: v, G: v, B: v})
+	}
+}
@@ -0,0 +1,303 @@
+package display
+
+import (
+	"bytes"
+	"image"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+)
+
+// ============================================================================
+// 音乐正在播放卡片
+// ============================================================================
+
+// MusicNowPlaying 正在播放卡片显示的歌曲信息
+type MusicNowPlaying struct {
+	Key    string // 歌曲标识（如文件路径），变化时重新加载封面；为空表示没有封面（如电台）
+	Title  string
+	Artist string
+	Paused bool
+}
+
+// MusicNowPlayingFunc 返回正在播放的歌曲信息
+type MusicNowPlayingFunc func() MusicNowPlaying
+
+// MusicCoverFunc 读取歌曲封面（JPEG/PNG 数据），没有封面时返回错误
+type MusicCoverFunc func(key string) ([]byte, error)
+
+// 卡片参数
+const (
+	cardFrameInterval      = 100 * time.Millisecond // 卡片的刷新间隔，封面静止时不需要更高帧率
+	musicAnimationInterval = 100 * time.Millisecond // 没有封面时动画的帧间隔
+	maxAnimationFrames     = 300                    // 动画最多预加载的帧数
+)
+
+// nowPlayingArt 封面区域的内容：当前歌曲的封面，没有封面时为动画帧
+type nowPlayingArt struct {
+	cover    MusicCoverFunc
+	animPath string
+
+	key   string
+	size  int
+	image *image.RGBA // 缩放到封面区域的封面，没有封面时为 nil
+
+	frames       []*image.RGBA // 缩放后的动画帧，第一次需要时加载
+	framesLoaded bool
+	start        time.Time
+}
+
+// frame 返回封面区域当前应显示的图片，没有封面也没有动画时返回 nil
+func (a *nowPlayingArt) frame(dc *DisplayController, key string, size int) *image.RGBA {
+	if size != a.size {
+		a.framesLoaded = false
+	}
+	if key != a.key || size != a.size {
+		a.key, a.size = key, size
+		a.image = nil
+		if key != "" && a.cover != nil {
+			if data, err := a.cover(key); err == nil {
+				if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+					a.image = fitImage(img, size)
+				} else {
+					slog.Warn("封面解码失败", "歌曲", key, "错误", err)
+				}
+			}
+		}
+	}
+	if a.image != nil {
+		return a.image
+	}
+
+	if !a.framesLoaded {
+		a.framesLoaded = true
+		a.frames = dc.loadAnimationFrames(a.animPath, size)
+		a.start = time.Now()
+	}
+	if len(a.frames) == 0 {
+		return nil
+	}
+	i := int(time.Since(a.start)/musicAnimationInterval) % len(a.frames)
+	return a.frames[i]
+}
+
+// loadAnimationFrames 加载动画目录中的图片并缩放到封面区域
+func (dc *DisplayController) loadAnimationFrames(folderPath string, size int) []*image.RGBA {
+	if folderPath == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(folderPath)
+	if err != nil {
+		slog.Warn("读取音乐动画目录失败", "文件夹", folderPath, "错误", err)
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".jpg", ".jpeg", ".png", ".bmp":
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	if len(names) > maxAnimationFrames {
+		names = names[:maxAnimationFrames]
+	}
+
+	var frames []*image.RGBA
+	for _, name := range names {
+		img, err := dc.loadImage(filepath.Join(folderPath, name))
+		if err != nil {
+			slog.Warn("跳过图片", "文件", name, "错误", err)
+			continue
+		}
+		frames = append(frames, fitImage(img, size))
+	}
+	return frames
+}
+
+// fitImage 保持宽高比缩放到 size×size 的正方形内，居中放置，空白处为黑色
+func fitImage(img image.Image, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return dst
+	}
+	w, h := size, size
+	if b.Dx() > b.Dy() {
+		h = size * b.Dy() / b.Dx()
+	} else {
+		w = size * b.Dx() / b.Dy()
+	}
+	rect := image.Rect((size-w)/2, (size-h)/2, (size-w)/2+w, (size-h)/2+h)
+	xdraw.CatmullRom.Scale(dst, rect, img, b, xdraw.Src, nil)
+	return dst
+}
+
+// drawRGBA 将图片绘制到后台缓冲的 (x, y) 处
+func drawRGBA(img *image.RGBA, x, y int) {
+	b := img.Bounds()
+	for py := 0; py < b.Dy(); py++ {
+		row := img.Pix[py*img.Stride:]
+		for px := 0; px < b.Dx(); px++ {
+			setBackPixel(x+px, y+py, row[px*4], row[px*4+1], row[px*4+2])
+		}
+	}
+}
+
+// lineHeight 当前字体的行高，没有字体时按进度字号估算
+func (dc *DisplayController) lineHeight() int {
+	if dc.fontFace == nil {
+		return int(progressFontSize() * 1.2)
+	}
+	return dc.fontFace.Metrics().Height.Ceil()
+}
+
+// cardLayout 卡片各元素的位置，文字位置为基线
+type cardLayout struct {
+	artX, artY, artSize int
+	textX, textW        int
+	centered            bool // 竖屏时文字居中显示在封面下方
+	titleY, artistY     int
+	lyricY              int
+	barY, clockY        int
+}
+
+// newCardLayout 横屏时封面在左、文字在右；竖屏或方屏时封面在上、文字在下
+func newCardLayout(lineHeight int) cardLayout {
+	margin := fbWidth / 16
+	rowH := lineHeight * 5 / 4
+	var l cardLayout
+	var top int
+	if fbWidth*4 > fbHeight*5 {
+		l.artSize = min(fbHeight-2*margin, fbWidth*2/5)
+		l.artX, l.artY = margin, margin
+		l.textX = 2*margin + l.artSize
+		l.textW = fbWidth - l.textX - margin
+		top = margin
+	} else {
+		l.artSize = min(fbWidth-2*margin, fbHeight-2*margin-5*rowH)
+		if l.artSize < 0 {
+			l.artSize = 0
+		}
+		l.artX, l.artY = (fbWidth-l.artSize)/2, margin
+		l.textX, l.textW = margin, fbWidth-2*margin
+		l.centered = true
+		top = l.artY + l.artSize + margin/2
+	}
+	l.titleY = top + lineHeight
+	l.artistY = l.titleY + rowH
+	l.lyricY = l.artistY + rowH
+	l.barY = l.lyricY + rowH/2
+	l.clockY = l.barY + progressBarHeight() + lineHeight
+	if !l.centered {
+		// 横屏时进度与封面底部对齐
+		l.clockY = max(l.clockY, l.artY+l.artSize)
+		l.barY = l.clockY - lineHeight - progressBarHeight()
+	}
+	return l
+}
+
+// drawNowPlaying 绘制正在播放卡片：封面、标题、歌手、当前歌词、进度条、播放状态，睡眠倒计时叠加在封面上
+func (dc *DisplayController) drawNowPlaying(art *image.RGBA, np MusicNowPlaying, lyric string, position, duration time.Duration, sleep string, style MusicVisualizerStyle) {
+	if dc.fontFace == nil {
+		if art != nil {
+			drawRGBA(art, (fbWidth-art.Bounds().Dx())/2, (fbHeight-art.Bounds().Dy())/2)
+		}
+		return
+	}
+	lineHeight := dc.lineHeight()
+	l := newCardLayout(lineHeight)
+	if art != nil {
+		drawRGBA(art, l.artX, l.artY)
+	}
+
+	white := struct{ R, G, B uint8 }{R: 255, G: 255, B: 255}
+	grey := struct{ R, G, B uint8 }{R: 160, G: 160, B: 160}
+	dc.drawCardText(np.Title, l, l.titleY, white)
+	dc.drawCardText(np.Artist, l, l.artistY, grey)
+	dc.drawCardText(lyric, l, l.lyricY, style.Top)
+
+	// 进度条
+	ratio := 0.0
+	if duration > 0 {
+		ratio = min(1, float64(position)/float64(duration))
+	}
+	filled := int(float64(l.textW) * ratio)
+	dim := struct{ R, G, B uint8 }{R: style.Bottom.R / 4, G: style.Bottom.G / 4, B: style.Bottom.B / 4}
+	for y := l.barY; y < l.barY+progressBarHeight(); y++ {
+		for x := 0; x < l.textW; x++ {
+			c := dim
+			if x < filled {
+				c = style.Bottom
+			}
+			setBackPixel(l.textX+x, y, c.R, c.G, c.B)
+		}
+	}
+
+	// 时间：左侧已播放，右侧剩余，中间为播放状态和睡眠倒计时
+	dc.drawString(formatClock(position), l.textX, l.clockY, white)
+	if duration > 0 {
+		remaining := "-" + formatClock(duration-position)
+		width := font.MeasureString(dc.fontFace, remaining).Ceil()
+		dc.drawString(remaining, l.textX+l.textW-width, l.clockY, white)
+	}
+
+	iconSize := lineHeight * 3 / 5
+	drawPlayState(l.textX+(l.textW-iconSize)/2, l.clockY-iconSize, iconSize, np.Paused, white)
+
+	// 睡眠倒计时显示在封面右下角
+	if sleep != "" && art != nil {
+		width := font.MeasureString(dc.fontFace, sleep).Ceil()
+		pad := lineHeight / 4
+		x0, y1 := l.artX+l.artSize-width-2*pad, l.artY+l.artSize
+		for y := y1 - lineHeight - pad; y < y1; y++ {
+			for x := x0; x < l.artX+l.artSize; x++ {
+				setBackPixel(x, y, 0, 0, 0)
+			}
+		}
+		dc.drawString(sleep, x0+pad, y1-pad-lineHeight/5, style.Top)
+	}
+}
+
+// drawCardText 绘制卡片中的一行文字，超出宽度时截断
+func (dc *DisplayController) drawCardText(text string, l cardLayout, y int, col struct{ R, G, B uint8 }) {
+	if text == "" {
+		return
+	}
+	text, width := dc.fitText(text, l.textW)
+	x := l.textX
+	if l.centered {
+		x = l.textX + (l.textW-width)/2
+	}
+	dc.drawString(text, x, y, col)
+}
+
+// drawPlayState 在 (x, y) 处绘制 size×size 的播放（三角形）或暂停（双竖条）图标
+func drawPlayState(x, y, size int, paused bool, col struct{ R, G, B uint8 }) {
+	if paused {
+		w := size / 3
+		for dy := 0; dy < size; dy++ {
+			for dx := 0; dx < w; dx++ {
+				setBackPixel(x+dx, y+dy, col.R, col.G, col.B)
+				setBackPixel(x+size-w+dx, y+dy, col.R, col.G, col.B)
+			}
+		}
+		return
+	}
+	for dy := 0; dy < size; dy++ {
+		// 指向右侧的等边三角形，每行宽度先增大后减小
+		w := min(dy, size-1-dy) * 2 * 87 / 100
+		for dx := 0; dx <= w; dx++ {
+			setBackPixel(x+dx, y+dy, col.R, col.G, col.B)
+		}
+	}
+}
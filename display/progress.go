@@ -0,0 +1,133 @@
+package display
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/image/font"
+)
+
+// ============================================================================
+// 音乐播放进度
+// ============================================================================
+
+// MusicProgressFunc 返回当前播放位置和总时长（未知时为 0）
+type MusicProgressFunc func() (position, duration time.Duration)
+
+// MusicSleepFunc 返回睡眠定时的剩余时间，active 为 false 时不显示倒计时
+type MusicSleepFunc func() (remaining time.Duration, active bool)
+
+// MusicLabelFunc 返回音乐模式顶部显示的文字（如“播放列表 · 歌名”），切歌后随之更新
+type MusicLabelFunc func() string
+
+// progressFontSize 根据屏幕高度选择进度时间的字号
+func progressFontSize() float64 {
+	size := float64(fbHeight) / 12
+	if size < 12 {
+		size = 12
+	}
+	return size
+}
+
+// drawMusicProgress 在波形上方绘制进度条，以及已播放/剩余时间
+func (dc *DisplayController) drawMusicProgress(position, duration time.Duration, col struct{ R, G, B uint8 }, showClock bool) {
+	margin := fbWidth / 12
+	barHeight := progressBarHeight()
+	barY := fbHeight / 4
+	barWidth := fbWidth - 2*margin
+
+	// 总时长未知时只显示已播放时间
+	ratio := 0.0
+	if duration > 0 {
+		ratio = float64(position) / float64(duration)
+		if ratio > 1 {
+			ratio = 1
+		}
+	}
+	filled := int(float64(barWidth) * ratio)
+
+	dim := struct{ R, G, B uint8 }{R: col.R / 4, G: col.G / 4, B: col.B / 4}
+	for y := barY; y < barY+barHeight; y++ {
+		for x := 0; x < barWidth; x++ {
+			c := dim
+			if x < filled {
+				c = col
+			}
+			setBackPixel(margin+x, y, c.R, c.G, c.B)
+		}
+	}
+
+	if !showClock || dc.fontFace == nil {
+		return
+	}
+
+	// 时间显示在进度条上方：左侧已播放，右侧剩余
+	textY := clockBaseline()
+	white := struct{ R, G, B uint8 }{R: 255, G: 255, B: 255}
+	dc.drawString(formatClock(position), margin, textY, white)
+
+	if duration > 0 {
+		remaining := "-" + formatClock(duration-position)
+		width := font.MeasureString(dc.fontFace, remaining).Ceil()
+		dc.drawString(remaining, fbWidth-margin-width, textY, white)
+	}
+}
+
+// progressBarHeight 进度条的高度
+func progressBarHeight() int {
+	if h := fbHeight / 60; h > 3 {
+		return h
+	}
+	return 3
+}
+
+// clockBaseline 进度时间的文字基线，位于进度条上方
+func clockBaseline() int {
+	return fbHeight/4 - progressBarHeight()*2
+}
+
+// drawMusicSleep 在进度时间中间绘制睡眠定时倒计时
+func (dc *DisplayController) drawMusicSleep(remaining time.Duration, col struct{ R, G, B uint8 }) {
+	if dc.fontFace == nil {
+		return
+	}
+	text := "睡眠 " + formatClock(remaining)
+	width := font.MeasureString(dc.fontFace, text).Ceil()
+	dc.drawString(text, (fbWidth-width)/2, clockBaseline(), col)
+}
+
+// drawMusicLabel 在屏幕顶部居中绘制文字，超出屏幕宽度时截断
+func (dc *DisplayController) drawMusicLabel(text string) {
+	if text == "" || dc.fontFace == nil {
+		return
+	}
+
+	text, width := dc.fitText(text, fbWidth-2*(fbWidth/12))
+	white := struct{ R, G, B uint8 }{R: 255, G: 255, B: 255}
+	dc.drawString(text, (fbWidth-width)/2, fbHeight/8, white)
+}
+
+// fitText 截断超出 maxWidth 的文字并加省略号，返回文字和绘制宽度
+func (dc *DisplayController) fitText(text string, maxWidth int) (string, int) {
+	runes := []rune(text)
+	width := font.MeasureString(dc.fontFace, text).Ceil()
+	for width > maxWidth && len(runes) > 1 {
+		runes = runes[:len(runes)-1]
+		text = string(runes) + "…"
+		width = font.MeasureString(dc.fontFace, text).Ceil()
+	}
+	return text, width
+}
+
+// formatClock 格式化为 m:ss，超过一小时为 h:mm:ss
+func formatClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	total := int(d.Seconds())
+	h, m, s := total/3600, total/60%60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,147 @@
+package display
+
+// ============================================================================
+// 音乐频谱
+// ============================================================================
+
+// 频谱柱动画参数（按 ~30 FPS 的帧计）
+const (
+	spectrumAttack    = 0.6   // 上升时的平滑系数
+	spectrumDecay     = 0.2   // 下降时的平滑系数
+	peakHoldFrames    = 15    // 峰值帽停留约 0.5 秒
+	peakFallAccel     = 0.004 // 峰值帽下落的加速度（每帧）
+	defaultBarSpacing = 2
+	defaultBarCount   = 16 // 收到第一帧之前的柱数
+)
+
+// MusicVisualizerStyle 频谱柱的颜色：柱子从 Bottom 渐变到 Top，峰值帽使用 Peak
+type MusicVisualizerStyle struct {
+	Bottom struct{ R, G, B uint8 }
+	Top    struct{ R, G, B uint8 }
+	Peak   struct{ R, G, B uint8 }
+}
+
+// DefaultMusicVisualizerStyle 默认青色到紫色渐变，白色峰值帽
+func DefaultMusicVisualizerStyle() MusicVisualizerStyle {
+	var style MusicVisualizerStyle
+	style.Bottom = struct{ R, G, B uint8 }{R: 0, G: 180, B: 255}
+	style.Top = struct{ R, G, B uint8 }{R: 200, G: 80, B: 255}
+	style.Peak = struct{ R, G, B uint8 }{R: 255, G: 255, B: 255}
+	return style
+}
+
+// spectrumBars 平滑后的频谱柱高度和峰值帽状态
+type spectrumBars struct {
+	heights   []float64
+	targets   []float64
+	peaks     []float64
+	peakHold  []int
+	peakSpeed []float64
+}
+
+func newSpectrumBars(count int) *spectrumBars {
+	return &spectrumBars{
+		heights:   make([]float64, count),
+		targets:   make([]float64, count),
+		peaks:     make([]float64, count),
+		peakHold:  make([]int, count),
+		peakSpeed: make([]float64, count),
+	}
+}
+
+// setTargets 更新目标高度，频带数与柱数不同时按比例重采样
+func (s *spectrumBars) setTargets(frame []float64) {
+	if len(frame) == 0 {
+		return
+	}
+	for i := range s.targets {
+		s.targets[i] = frame[i*len(frame)/len(s.targets)]
+	}
+}
+
+// step 推进一帧：柱子快升慢降，峰值帽停留后加速下落
+func (s *spectrumBars) step() {
+	for i := range s.heights {
+		factor := spectrumDecay
+		if s.targets[i] > s.heights[i] {
+			factor = spectrumAttack
+		}
+		s.heights[i] += (s.targets[i] - s.heights[i]) * factor
+
+		switch {
+		case s.heights[i] >= s.peaks[i]:
+			s.peaks[i] = s.heights[i]
+			s.peakHold[i] = peakHoldFrames
+			s.peakSpeed[i] = 0
+		case s.peakHold[i] > 0:
+			s.peakHold[i]--
+		default:
+			s.peakSpeed[i] += peakFallAccel
+			s.peaks[i] -= s.peakSpeed[i]
+			if s.peaks[i] < s.heights[i] {
+				s.peaks[i] = s.heights[i]
+			}
+		}
+	}
+}
+
+// drawSpectrumBars 从底部绘制渐变频谱柱和峰值帽
+func drawSpectrumBars(bars *spectrumBars, maxHeight int, style MusicVisualizerStyle) {
+	count := len(bars.heights)
+	if count == 0 {
+		return
+	}
+	barWidth := fbWidth / count
+	spacing := defaultBarSpacing
+	if barWidth <= spacing {
+		spacing = 0
+	}
+	barW := barWidth - spacing
+	if barW < 1 {
+		barW = 1
+	}
+	capHeight := maxHeight / 40
+	if capHeight < 2 {
+		capHeight = 2
+	}
+
+	for i := 0; i < count; i++ {
+		x := i*barWidth + spacing/2
+
+		height := int(bars.heights[i] * float64(maxHeight))
+		if height < 2 {
+			height = 2
+		}
+		for y := fbHeight - height; y < fbHeight; y++ {
+			// 颜色按绝对高度渐变，高柱子的顶部接近 Top 颜色
+			t := float64(fbHeight-y) / float64(maxHeight)
+			r := lerpColor(style.Bottom.R, style.Top.R, t)
+			g := lerpColor(style.Bottom.G, style.Top.G, t)
+			b := lerpColor(style.Bottom.B, style.Top.B, t)
+			for dx := 0; dx < barW; dx++ {
+				setBackPixel(x+dx, y, r, g, b)
+			}
+		}
+
+		// 峰值帽画在柱顶之上
+		if bars.peaks[i] <= 0.01 {
+			continue
+		}
+		peakY := fbHeight - int(bars.peaks[i]*float64(maxHeight)) - capHeight - 1
+		for y := peakY; y < peakY+capHeight; y++ {
+			for dx := 0; dx < barW; dx++ {
+				setBackPixel(x+dx, y, style.Peak.R, style.Peak.G, style.Peak.B)
+			}
+		}
+	}
+}
+
+// lerpColor 颜色分量线性插值
+func lerpColor(a, b uint8, t float64) uint8 {
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
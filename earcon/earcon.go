@@ -0,0 +1,163 @@
+package earcon
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/audio"
+)
+
+// 内置默认音效
+//
+//go:embed assets/*.wav
+var defaultAssets embed.FS
+
+// Event 提示音事件
+type Event string
+
+const (
+	EventWake         Event = "wake"          // 唤醒，开始监听
+	EventListenEnd    Event = "listen_end"    // 监听结束
+	EventError        Event = "error"         // 出错
+	EventDisconnected Event = "disconnected"  // 连接断开
+	EventReconnected  Event = "reconnected"   // 重新连接成功
+	EventMusicStart   Event = "music_start"   // 开始播放音乐
+	EventMediaAdded   Event = "media_added"   // 插入了带歌曲的 U 盘等存储
+	EventMediaRemoved Event = "media_removed" // 存储已移除
+	EventAlarm        Event = "alarm"         // 闹钟铃声，响铃期间循环播放
+)
+
+// Events 所有支持的提示音事件
+var Events = []Event{
+	EventWake,
+	EventListenEnd,
+	EventError,
+	EventDisconnected,
+	EventReconnected,
+	EventMusicStart,
+	EventMediaAdded,
+	EventMediaRemoved,
+	EventAlarm,
+}
+
+// EventConfig 单个事件的提示音配置
+type EventConfig struct {
+	Enabled *bool  `mapstructure:"enabled"` // 未设置时默认启用
+	Volume  int    `mapstructure:"volume"`  // 0-100，0 表示使用全局音量
+	File    string `mapstructure:"file"`    // WAV/Opus 文件路径，空表示使用内置音效
+}
+
+// Config 提示音配置
+type Config struct {
+	Enabled bool                   `mapstructure:"enabled"`
+	Volume  int                    `mapstructure:"volume"` // 全局音量 0-100，0 表示 100
+	Events  map[string]EventConfig `mapstructure:"events"`
+}
+
+// Player 提示音播放器，解码后的音效会被缓存
+type Player struct {
+	config   Config
+	audioCfg audio.Config
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	cache map[Event][]int16
+}
+
+// NewPlayer 创建提示音播放器，audioCfg 决定音效的输出采样率和声道数
+func NewPlayer(cfg Config, audioCfg audio.Config, logger *slog.Logger) *Player {
+	return &Player{
+		config:   cfg,
+		audioCfg: audioCfg,
+		logger:   logger,
+		cache:    make(map[Event][]int16),
+	}
+}
+
+// Enabled 判断事件的提示音是否启用，闹钟铃声不受全局开关影响，只能单独关闭
+func (p *Player) Enabled(ev Event) bool {
+	if !p.config.Enabled && ev != EventAlarm {
+		return false
+	}
+	evCfg, ok := p.config.Events[string(ev)]
+	if !ok || evCfg.Enabled == nil {
+		return true
+	}
+	return *evCfg.Enabled
+}
+
+// Play 通过音频管理器播放事件提示音，返回音效时长
+func (p *Player) Play(m audio.Manager, ev Event) (time.Duration, error) {
+	if !p.Enabled(ev) {
+		return 0, nil
+	}
+	if m == nil {
+		return 0, fmt.Errorf("audio manager not initialized")
+	}
+
+	pcm, err := p.load(ev)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load earcon %s: %w", ev, err)
+	}
+
+	// 复制后再调整音量，避免修改缓存
+	samples := make([]int16, len(pcm))
+	copy(samples, pcm)
+	audio.ApplyGain(samples, audio.VolumeToGain(p.volume(ev)))
+
+	p.logger.Debug("Playing earcon", "event", ev, "samples", len(samples))
+	return audio.PlayClip(m, samples, p.audioCfg)
+}
+
+// volume 返回事件的有效音量
+func (p *Player) volume(ev Event) int {
+	if evCfg, ok := p.config.Events[string(ev)]; ok && evCfg.Volume > 0 {
+		return evCfg.Volume
+	}
+	if p.config.Volume > 0 {
+		return p.config.Volume
+	}
+	return 100
+}
+
+// load 加载并缓存事件音效
+func (p *Player) load(ev Event) ([]int16, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pcm, ok := p.cache[ev]; ok {
+		return pcm, nil
+	}
+
+	var r io.Reader
+	name := string(ev) + ".wav"
+	if evCfg, ok := p.config.Events[string(ev)]; ok && evCfg.File != "" {
+		data, err := os.ReadFile(evCfg.File)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+		name = filepath.Base(evCfg.File)
+	} else {
+		data, err := defaultAssets.ReadFile("assets/" + name)
+		if err != nil {
+			return nil, fmt.Errorf("no built-in sound for event %s", ev)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	pcm, err := audio.DecodeClip(r, name, p.audioCfg.SampleRate, p.audioCfg.Channels, p.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	p.cache[ev] = pcm
+	return pcm, nil
+}
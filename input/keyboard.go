@@ -28,6 +28,7 @@ type KeyEvent struct {
 type StateGetter interface {
 	GetCurrentState() string
 	GetDisplayMode() string
+	AlarmRinging() bool // 是否有闹钟正在响铃
 }
 
 type KeyboardListener struct {
@@ -41,6 +42,7 @@ type KeyboardListener struct {
 	actionFunc    func(string)
 	lastKeyTime   time.Time     // 上次按键时间
 	lastKeyCode   uint16        // 上次按键代码
+	lastAction    string        // 上次单击触发的动作
 	doubleTapTime time.Duration // 双击时间间隔
 }
 
@@ -105,8 +107,13 @@ func (k *KeyboardListener) Start() error {
 						"timeDiff", now.Sub(k.lastKeyTime))
 					// 检查是否是双击
 					if event.Code == k.lastKeyCode && now.Sub(k.lastKeyTime) < k.doubleTapTime {
-						k.actionFunc("reset")       // 双击重置为初始状态
+						if k.lastAction == "alarm_snooze" {
+							k.actionFunc("alarm_dismiss") // 响铃时双击关闭闹钟（第一下已贪睡）
+						} else {
+							k.actionFunc("reset") // 双击重置为初始状态
+						}
 						k.lastKeyTime = time.Time{} // 重置时间，避免连续触发
+						k.lastAction = ""
 						continue
 					}
 
@@ -114,16 +121,24 @@ func (k *KeyboardListener) Start() error {
 					currentState := k.stateGetter.GetCurrentState()
 					displayMode := k.stateGetter.GetDisplayMode()
 
-					// 特殊处理：时钟模式下单击切换回表情模式并唤醒
-					if displayMode == "clock" && (currentState == "idle" || currentState == "disconnected") {
-						k.actionFunc("wakeup_from_clock")
+					action := ""
+					if k.stateGetter.AlarmRinging() {
+						// 闹钟响铃时单击贪睡
+						action = "alarm_snooze"
+					} else if displayMode == "clock" && (currentState == "idle" || currentState == "disconnected") {
+						// 特殊处理：时钟模式下单击切换回表情模式并唤醒
+						action = "wakeup_from_clock"
 					} else if currentState == "idle" || currentState == "disconnected" {
-						k.actionFunc("wakeup")
+						action = "wakeup"
 					} else if currentState != "listening" {
 						// 非 idle/listening 状态下，单击触发 interrupt（中断当前操作）
-						k.actionFunc("interrupt")
+						action = "interrupt"
+					}
+					if action != "" {
+						k.actionFunc(action)
 					}
 
+					k.lastAction = action
 					k.lastKeyCode = event.Code
 					k.lastKeyTime = now
 				}
@@ -0,0 +1,442 @@
+package mpd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/music"
+)
+
+// response 一条命令的输出
+type response struct {
+	strings.Builder
+}
+
+// kv 输出一行 "key: value"
+func (r *response) kv(key string, value interface{}) {
+	fmt.Fprintf(r, "%s: %v\n", key, value)
+}
+
+// supportedCommands 支持的命令，commands 命令按此列表返回
+var supportedCommands = []string{
+	"add", "close", "commands", "currentsong", "idle", "lsinfo", "next", "noidle",
+	"notcommands", "outputs", "pause", "ping", "play", "playid", "playlistid",
+	"playlistinfo", "plchanges", "plchangesposid", "previous", "setvol", "stats",
+	"status", "stop", "tagtypes",
+}
+
+// exec 执行一条命令
+func (s *Server) exec(out *response, name string, args []string) error {
+	switch name {
+	case "ping":
+		return nil
+	case "status":
+		return s.status(out)
+	case "currentsong":
+		return s.currentSong(out)
+	case "stats":
+		return s.stats(out)
+	case "play":
+		return s.play(args, false)
+	case "playid":
+		return s.play(args, true)
+	case "pause":
+		return s.pause(args)
+	case "stop":
+		s.player.Stop()
+		return nil
+	case "next":
+		if s.player.Status().State == music.StateStopped {
+			return nil
+		}
+		return s.player.Next()
+	case "previous":
+		if s.player.Status().State == music.StateStopped {
+			return nil
+		}
+		return s.player.Previous()
+	case "playlistinfo", "playlistid":
+		return s.playlistInfo(out, args, name == "playlistid")
+	case "plchanges", "plchangesposid":
+		return s.plChanges(out, args, name == "plchangesposid")
+	case "lsinfo":
+		return s.lsinfo(out, args)
+	case "add":
+		return s.add(args)
+	case "setvol":
+		return s.setVol(args)
+	case "noidle":
+		// 不在 idle 中时忽略
+		return nil
+	case "commands":
+		for _, cmd := range supportedCommands {
+			out.kv("command", cmd)
+		}
+		return nil
+	case "notcommands":
+		return nil
+	case "tagtypes":
+		if len(args) > 0 {
+			// tagtypes enable/disable/clear/all：标签固定，忽略
+			return nil
+		}
+		for _, tag := range []string{"Artist", "Album", "Title", "Track", "Name"} {
+			out.kv("tagtype", tag)
+		}
+		return nil
+	case "outputs":
+		out.kv("outputid", 0)
+		out.kv("outputname", "xiaozhi")
+		out.kv("plugin", "xiaozhi")
+		out.kv("outputenabled", 1)
+		return nil
+	case "idle":
+		return newAck(ackErrorNotList, "idle is not allowed in a command list")
+	}
+	return newAck(ackErrorUnknown, "unknown command %q", name)
+}
+
+// status 播放状态，播放模式映射为 repeat/random/single
+func (s *Server) status(out *response) error {
+	status := s.player.Status()
+	songs := s.player.GetSongs()
+
+	repeat, random, single := 0, 0, 0
+	switch s.player.Mode() {
+	case music.ModeRepeatAll:
+		repeat = 1
+	case music.ModeRepeatOne:
+		repeat, single = 1, 1
+	case music.ModeShuffle:
+		repeat, random = 1, 1
+	case music.ModeOnce:
+		single = 1
+	}
+
+	out.kv("volume", s.ctrl.Volume())
+	out.kv("repeat", repeat)
+	out.kv("random", random)
+	out.kv("single", single)
+	out.kv("consume", 0)
+	out.kv("playlist", s.playlistVersion())
+	out.kv("playlistlength", len(songs))
+
+	state := "stop"
+	switch status.State {
+	case music.StatePlaying:
+		state = "play"
+	case music.StatePaused:
+		state = "pause"
+	}
+	out.kv("state", state)
+
+	if status.Index >= 0 && status.Index < len(songs) {
+		out.kv("song", status.Index)
+		out.kv("songid", status.Index+1)
+	}
+	if status.State != music.StateStopped {
+		elapsed, duration := status.Position.Seconds(), status.Duration.Seconds()
+		out.kv("time", fmt.Sprintf("%d:%d", int(elapsed), int(duration)))
+		out.kv("elapsed", fmt.Sprintf("%.3f", elapsed))
+		if duration > 0 {
+			out.kv("duration", fmt.Sprintf("%.3f", duration))
+		}
+	}
+	return nil
+}
+
+// currentSong 当前歌曲，电台输出电台名和节目标题
+func (s *Server) currentSong(out *response) error {
+	status := s.player.Status()
+	if status.Song == nil {
+		return nil
+	}
+	if status.Radio {
+		out.kv("file", status.Song.Path)
+		out.kv("Name", status.Song.Name)
+		if status.StreamTitle != "" {
+			out.kv("Title", status.StreamTitle)
+		}
+		return nil
+	}
+	s.writeSong(out, *status.Song, s.uri(*status.Song, s.player.MediaSources()), status.Index)
+	return nil
+}
+
+// stats 曲库统计
+func (s *Server) stats(out *response) error {
+	songs := s.player.GetSongs()
+	artists, albums := make(map[string]bool), make(map[string]bool)
+	var total time.Duration
+	for _, song := range songs {
+		if song.Artist != "" {
+			artists[song.Artist] = true
+		}
+		if song.Album != "" {
+			albums[song.Album] = true
+		}
+		total += song.Duration
+	}
+	out.kv("artists", len(artists))
+	out.kv("albums", len(albums))
+	out.kv("songs", len(songs))
+	out.kv("db_playtime", int(total.Seconds()))
+	return nil
+}
+
+// play 从暂停处继续，或播放指定位置（playid 为歌曲 ID）的歌曲
+func (s *Server) play(args []string, byID bool) error {
+	if len(args) == 0 {
+		if s.player.IsPaused() {
+			s.player.Resume()
+			return nil
+		}
+		if s.player.IsPlaying() {
+			return nil
+		}
+		return s.ctrl.StartMusic(s.player.Play)
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return newAck(ackErrorArg, "Integer expected: %s", args[0])
+	}
+	if n < 0 {
+		return s.play(nil, byID)
+	}
+	index := n
+	if byID {
+		index = n - 1
+	}
+	if index < 0 || index >= len(s.player.GetSongs()) {
+		return newAck(ackErrorNoExist, "No such song")
+	}
+	return s.ctrl.StartMusic(func() error { return s.player.PlaySong(index) })
+}
+
+// pause pause 1 暂停，pause 0 继续，不带参数时切换
+func (s *Server) pause(args []string) error {
+	if !s.player.IsPlaying() {
+		return nil
+	}
+	if len(args) == 0 {
+		s.player.TogglePause()
+		return nil
+	}
+	switch args[0] {
+	case "1":
+		s.player.Pause()
+	case "0":
+		s.player.Resume()
+	default:
+		return newAck(ackErrorArg, "Boolean (0/1) expected: %s", args[0])
+	}
+	return nil
+}
+
+// playlistInfo 播放列表即整个曲库，可按位置/范围（playlistid 按 ID）查询
+func (s *Server) playlistInfo(out *response, args []string, byID bool) error {
+	songs := s.player.GetSongs()
+	start, end := 0, len(songs)
+	if len(args) > 0 {
+		if byID {
+			id, err := strconv.Atoi(args[0])
+			if err != nil {
+				return newAck(ackErrorArg, "Integer expected: %s", args[0])
+			}
+			if id < 1 || id > len(songs) {
+				return newAck(ackErrorNoExist, "No such song")
+			}
+			start, end = id-1, id
+		} else {
+			var err error
+			if start, end, err = parseRange(args[0], len(songs)); err != nil {
+				return err
+			}
+		}
+	}
+
+	sources := s.player.MediaSources()
+	for i := start; i < end; i++ {
+		s.writeSong(out, songs[i], s.uri(songs[i], sources), i)
+	}
+	return nil
+}
+
+// plChanges 客户端的播放列表版本较旧时返回整个列表
+func (s *Server) plChanges(out *response, args []string, posID bool) error {
+	if len(args) == 0 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"plchanges\"")
+	}
+	version, err := strconv.Atoi(args[0])
+	if err != nil {
+		return newAck(ackErrorArg, "Integer expected: %s", args[0])
+	}
+	if version >= s.playlistVersion() {
+		return nil
+	}
+	if !posID {
+		return s.playlistInfo(out, nil, false)
+	}
+	for i := range s.player.GetSongs() {
+		out.kv("cpos", i)
+		out.kv("Id", i+1)
+	}
+	return nil
+}
+
+// lsinfo 按目录浏览曲库，可移动存储显示为以卷标命名的目录
+func (s *Server) lsinfo(out *response, args []string) error {
+	dir := ""
+	if len(args) > 0 {
+		dir = strings.Trim(args[0], "/")
+	}
+
+	songs := s.player.GetSongs()
+	sources := s.player.MediaSources()
+	dirs := make(map[string]bool)
+	found := dir == ""
+	for i, song := range songs {
+		uri := s.uri(song, sources)
+		rel := uri
+		if dir != "" {
+			if !strings.HasPrefix(uri, dir+"/") {
+				continue
+			}
+			rel = uri[len(dir)+1:]
+		}
+		found = true
+		if slash := strings.IndexByte(rel, '/'); slash >= 0 {
+			dirs[rel[:slash]] = true
+			continue
+		}
+		s.writeSong(out, song, uri, i)
+	}
+	if !found {
+		return newAck(ackErrorNoExist, "Not found")
+	}
+
+	names := make([]string, 0, len(dirs))
+	for name := range dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if dir != "" {
+			name = dir + "/" + name
+		}
+		out.kv("directory", name)
+	}
+	return nil
+}
+
+// add 将歌曲（或目录下的所有歌曲）加入播放队列，排在已加入的歌曲之后
+// 播放列表固定为整个曲库，加入的歌曲在当前歌曲结束后播放
+func (s *Server) add(args []string) error {
+	if len(args) == 0 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"add\"")
+	}
+	target := strings.Trim(args[0], "/")
+
+	songs := s.player.GetSongs()
+	sources := s.player.MediaSources()
+	added := 0
+	for i, song := range songs {
+		uri := s.uri(song, sources)
+		if target != "" && uri != target && !strings.HasPrefix(uri, target+"/") && song.Path != args[0] {
+			continue
+		}
+		if _, err := s.player.QueueAdd(i, false); err != nil {
+			return newAck(ackErrorSystem, "%v", err)
+		}
+		added++
+	}
+	if added == 0 {
+		return newAck(ackErrorNoExist, "No such song")
+	}
+	return nil
+}
+
+// setVol 设置音量
+func (s *Server) setVol(args []string) error {
+	if len(args) == 0 {
+		return newAck(ackErrorArg, "wrong number of arguments for \"setvol\"")
+	}
+	volume, err := strconv.Atoi(args[0])
+	if err != nil || volume < 0 || volume > 100 {
+		return newAck(ackErrorArg, "Invalid volume value: %s", args[0])
+	}
+	if err := s.ctrl.SetVolume(volume); err != nil {
+		return newAck(ackErrorSystem, "%v", err)
+	}
+	return nil
+}
+
+// writeSong 输出一首歌曲的信息，pos 为在播放列表中的位置
+func (s *Server) writeSong(out *response, song music.SongInfo, uri string, pos int) {
+	out.kv("file", uri)
+	if !song.ModTime.IsZero() {
+		out.kv("Last-Modified", song.ModTime.UTC().Format(time.RFC3339))
+	}
+	if song.Artist != "" {
+		out.kv("Artist", song.Artist)
+	}
+	if song.Album != "" {
+		out.kv("Album", song.Album)
+	}
+	out.kv("Title", song.Name)
+	if song.Track > 0 {
+		out.kv("Track", song.Track)
+	}
+	if song.Duration > 0 {
+		out.kv("Time", int(song.Duration.Seconds()))
+		out.kv("duration", fmt.Sprintf("%.3f", song.Duration.Seconds()))
+	}
+	out.kv("Pos", pos)
+	out.kv("Id", pos+1)
+}
+
+// uri 歌曲的 URI：曲库中的歌曲为相对曲库目录的路径，可移动存储中的歌曲以卷标开头
+func (s *Server) uri(song music.SongInfo, sources []music.MediaSource) string {
+	base := s.root
+	prefix := ""
+	if song.Source != "" {
+		for _, src := range sources {
+			if src.Label == song.Source && strings.HasPrefix(song.Path, src.Path+string(filepath.Separator)) {
+				base, prefix = src.Path, src.Label+"/"
+				break
+			}
+		}
+	}
+	rel, err := filepath.Rel(base, song.Path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(song.Path)
+	}
+	return prefix + filepath.ToSlash(rel)
+}
+
+// parseRange 解析位置 "N" 或范围 "START:END"（END 可省略）
+func parseRange(arg string, length int) (int, int, error) {
+	startStr, endStr, isRange := strings.Cut(arg, ":")
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 0 {
+		return 0, 0, newAck(ackErrorArg, "Integer expected: %s", arg)
+	}
+	end := start + 1
+	if isRange {
+		end = length
+		if endStr != "" {
+			if end, err = strconv.Atoi(endStr); err != nil || end < start {
+				return 0, 0, newAck(ackErrorArg, "Invalid range: %s", arg)
+			}
+		}
+	}
+	if start >= length && !(isRange && start == length) {
+		return 0, 0, newAck(ackErrorArg, "Bad song index")
+	}
+	return start, min(end, length), nil
+}
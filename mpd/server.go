@@ -0,0 +1,488 @@
+// Package mpd 实现 MPD（Music Player Daemon）协议的一个子集，
+// 让手机上常见的 MPD 客户端可以在局域网内浏览曲库和控制音乐播放
+//
+// 与 MPD 的对应关系：MPD 的“当前播放列表”固定为整个曲库（按曲库顺序），歌曲 ID 为位置加 1，
+// playlistinfo、plchanges、play、playid 都按此编号；add 不修改这个列表，而是把歌曲加入播放器的
+// 待播队列，在当前歌曲结束后依次播放，因此 add 之后 playlistinfo 的结果不变。
+// 播放模式映射为 repeat/random/single 三个开关
+package mpd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/music"
+)
+
+// 服务器参数
+const (
+	DefaultAddress  = ":6600"
+	protocolVersion = "0.23.0"
+	pollInterval    = 200 * time.Millisecond // 检查播放器状态变化的间隔，用于 idle
+	maxLineLength   = 64 * 1024
+)
+
+// 与 MPD 一致的 ACK 错误码
+const (
+	ackErrorNotList = 1
+	ackErrorArg     = 2
+	ackErrorUnknown = 5
+	ackErrorNoExist = 50
+	ackErrorSystem  = 52
+)
+
+// idle 子系统
+const (
+	subsystemDatabase = "database"
+	subsystemPlaylist = "playlist"
+	subsystemPlayer   = "player"
+	subsystemMixer    = "mixer"
+	subsystemOptions  = "options"
+)
+
+var subsystems = []string{subsystemDatabase, subsystemPlaylist, subsystemPlayer, subsystemMixer, subsystemOptions}
+
+// Controller 由客户端实现的播放控制：开始播放前需要释放语音对话占用的音频设备
+type Controller interface {
+	// StartMusic 准备音频设备后调用 start 开始播放
+	StartMusic(start func() error) error
+	// Volume 当前音量 0-100，未知时返回 -1
+	Volume() int
+	SetVolume(volume int) error
+}
+
+// ackError 返回给客户端的错误
+type ackError struct {
+	code int
+	msg  string
+}
+
+func (e *ackError) Error() string {
+	return e.msg
+}
+
+func newAck(code int, format string, args ...interface{}) error {
+	return &ackError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// Server MPD 协议服务器
+type Server struct {
+	addr   string
+	root   string // 曲库目录，歌曲 URI 为相对该目录的路径
+	player *music.Player
+	ctrl   Controller
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*conn]struct{}
+	version  int // 播放列表版本，歌曲列表变化时递增
+	last     snapshot
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer 创建 MPD 服务器，addr 为空时监听 DefaultAddress
+func NewServer(addr, root string, player *music.Player, ctrl Controller, logger *slog.Logger) *Server {
+	if addr == "" {
+		addr = DefaultAddress
+	}
+	return &Server{
+		addr:    addr,
+		root:    root,
+		player:  player,
+		ctrl:    ctrl,
+		logger:  logger,
+		conns:   make(map[*conn]struct{}),
+		version: 1,
+		done:    make(chan struct{}),
+	}
+}
+
+// Start 开始监听
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	s.mu.Lock()
+	s.listener = ln
+	s.last = s.snapshot()
+	s.mu.Unlock()
+
+	s.wg.Add(2)
+	go s.acceptLoop(ln)
+	go s.pollLoop()
+	s.logger.Info("MPD server started", "address", ln.Addr().String())
+	return nil
+}
+
+// Addr 实际监听的地址
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close 停止监听并断开所有客户端
+func (s *Server) Close() error {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return nil
+	default:
+	}
+	close(s.done)
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// acceptLoop 接受客户端连接
+func (s *Server) acceptLoop(ln net.Listener) {
+	defer s.wg.Done()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			s.logger.Warn("MPD accept failed", "error", err)
+			return
+		}
+
+		c := &conn{
+			Conn:   nc,
+			w:      bufio.NewWriter(nc),
+			events: make(map[string]bool),
+			notify: make(chan struct{}, 1),
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+// conn 一个客户端连接
+type conn struct {
+	net.Conn
+	w *bufio.Writer
+
+	mu     sync.Mutex
+	events map[string]bool // 上次 idle 以来发生变化的子系统
+	notify chan struct{}
+}
+
+// addEvents 记录变化的子系统并唤醒 idle
+func (c *conn) addEvents(changed []string) {
+	c.mu.Lock()
+	for _, name := range changed {
+		c.events[name] = true
+	}
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// takeEvents 取出 filter 中（filter 为空时为全部）已发生变化的子系统
+func (c *conn) takeEvents(filter map[string]bool) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var changed []string
+	for _, name := range subsystems {
+		if c.events[name] && (len(filter) == 0 || filter[name]) {
+			changed = append(changed, name)
+			delete(c.events, name)
+		}
+	}
+	return changed
+}
+
+// serve 处理一个连接：逐行读取命令，支持命令列表和 idle
+func (s *Server) serve(c *conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	s.logger.Debug("MPD client connected", "remote", c.RemoteAddr().String())
+	fmt.Fprintf(c.w, "OK MPD %s\n", protocolVersion)
+	if c.w.Flush() != nil {
+		return
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(c)
+		scanner.Buffer(make([]byte, 4096), maxLineLength)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	var list []string // 命令列表中尚未执行的命令
+	inList, listOK := false, false
+	for {
+		var line string
+		var ok bool
+		select {
+		case line, ok = <-lines:
+			if !ok {
+				return
+			}
+		case <-s.done:
+			return
+		}
+
+		if inList {
+			if line != "command_list_end" {
+				list = append(list, line)
+				continue
+			}
+			s.runList(c, list, listOK)
+			list, inList = nil, false
+		} else {
+			switch line {
+			case "command_list_begin", "command_list_ok_begin":
+				inList, listOK = true, line == "command_list_ok_begin"
+				continue
+			case "close":
+				return
+			}
+			args, err := parseArgs(line)
+			if err == nil && len(args) > 0 && args[0] == "idle" {
+				if !s.idle(c, args[1:], lines) {
+					return
+				}
+				continue
+			}
+			s.runList(c, []string{line}, false)
+		}
+		if c.w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// runList 依次执行命令，出错时输出 ACK 并放弃剩余命令
+func (s *Server) runList(c *conn, list []string, listOK bool) {
+	for i, line := range list {
+		name := ""
+		out := &response{}
+		args, err := parseArgs(line)
+		if err == nil && len(args) == 0 {
+			err = newAck(ackErrorUnknown, "No command given")
+		}
+		if err == nil {
+			name = args[0]
+			err = s.exec(out, name, args[1:])
+		}
+		c.w.WriteString(out.String())
+		if err != nil {
+			code := ackErrorSystem
+			var ack *ackError
+			if errors.As(err, &ack) {
+				code = ack.code
+			}
+			fmt.Fprintf(c.w, "ACK [%d@%d] {%s} %s\n", code, i, name, err.Error())
+			return
+		}
+		if listOK {
+			c.w.WriteString("list_OK\n")
+		}
+	}
+	c.w.WriteString("OK\n")
+}
+
+// idle 等待子系统变化，返回 false 表示连接应关闭
+// idle 期间只接受 noidle，收到其他命令时按协议断开连接
+func (s *Server) idle(c *conn, names []string, lines <-chan string) bool {
+	filter := make(map[string]bool)
+	for _, name := range names {
+		filter[strings.ToLower(name)] = true
+	}
+
+	write := func(changed []string) bool {
+		for _, name := range changed {
+			fmt.Fprintf(c.w, "changed: %s\n", name)
+		}
+		c.w.WriteString("OK\n")
+		return c.w.Flush() == nil
+	}
+
+	for {
+		if changed := c.takeEvents(filter); len(changed) > 0 {
+			return write(changed)
+		}
+		select {
+		case <-c.notify:
+		case line, ok := <-lines:
+			if !ok || strings.TrimSpace(line) != "noidle" {
+				return false
+			}
+			return write(c.takeEvents(filter))
+		case <-s.done:
+			return false
+		}
+	}
+}
+
+// snapshot 用于判断 idle 子系统变化的播放器状态
+type snapshot struct {
+	songs  string // 歌曲列表的标识（切片地址与长度），列表替换时变化
+	state  string
+	song   string
+	index  int
+	title  string
+	mode   music.Mode
+	volume int
+}
+
+func (s *Server) snapshot() snapshot {
+	status := s.player.Status()
+	songs := s.player.GetSongs()
+	snap := snapshot{
+		songs:  fmt.Sprintf("%p/%d", songs, len(songs)),
+		state:  status.State,
+		index:  status.Index,
+		title:  status.StreamTitle,
+		mode:   s.player.Mode(),
+		volume: s.ctrl.Volume(),
+	}
+	if status.Song != nil {
+		snap.song = status.Song.Path
+	}
+	return snap
+}
+
+// pollLoop 定期比较播放器状态，通知正在 idle 的客户端
+func (s *Server) pollLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		snap := s.snapshot()
+		s.mu.Lock()
+		last := s.last
+		s.last = snap
+
+		var changed []string
+		if snap.songs != last.songs {
+			s.version++
+			changed = append(changed, subsystemDatabase, subsystemPlaylist)
+		}
+		if snap.state != last.state || snap.song != last.song || snap.index != last.index || snap.title != last.title {
+			changed = append(changed, subsystemPlayer)
+		}
+		if snap.volume != last.volume {
+			changed = append(changed, subsystemMixer)
+		}
+		if snap.mode != last.mode {
+			changed = append(changed, subsystemOptions)
+		}
+		if len(changed) > 0 {
+			for c := range s.conns {
+				c.addEvents(changed)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// playlistVersion 当前播放列表版本
+func (s *Server) playlistVersion() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.version
+}
+
+// parseArgs 按 MPD 的规则拆分命令行：空格分隔，双引号内可包含空格，反斜杠转义
+func parseArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg, quoted := false, false
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quoted && ch == '\\':
+			if i+1 < len(line) {
+				i++
+				cur.WriteByte(line[i])
+			}
+		case quoted && ch == '"':
+			quoted = false
+			args = append(args, cur.String())
+			cur.Reset()
+			inArg = false
+		case quoted:
+			cur.WriteByte(ch)
+		case ch == '"':
+			if inArg {
+				return nil, newAck(ackErrorArg, "Invalid quotes")
+			}
+			quoted, inArg = true, true
+		case ch == ' ' || ch == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, newAck(ackErrorArg, "Missing closing '\"'")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
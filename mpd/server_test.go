@@ -0,0 +1,397 @@
+package mpd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/music"
+)
+
+// fakeController 直接开始播放，记录音量
+type fakeController struct {
+	mu     sync.Mutex
+	volume int
+	starts int
+}
+
+func (c *fakeController) StartMusic(start func() error) error {
+	c.mu.Lock()
+	c.starts++
+	c.mu.Unlock()
+	return start()
+}
+
+func (c *fakeController) Volume() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.volume
+}
+
+func (c *fakeController) SetVolume(volume int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.volume = volume
+	return nil
+}
+
+// writeWAV 写入一段 8kHz 单声道静音
+func writeWAV(t *testing.T, path string, d time.Duration) {
+	t.Helper()
+	const rate = 8000
+	samples := int(d.Seconds() * rate)
+	buf := make([]byte, 44+samples*2)
+	copy(buf[0:], "RIFF")
+	binary.LittleEndian.PutUint32(buf[4:], uint32(36+samples*2))
+	copy(buf[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(buf[16:], 16)
+	binary.LittleEndian.PutUint16(buf[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(buf[22:], 1) // 单声道
+	binary.LittleEndian.PutUint32(buf[24:], rate)
+	binary.LittleEndian.PutUint32(buf[28:], rate*2)
+	binary.LittleEndian.PutUint16(buf[32:], 2)
+	binary.LittleEndian.PutUint16(buf[34:], 16)
+	copy(buf[36:], "data")
+	binary.LittleEndian.PutUint32(buf[40:], uint32(samples*2))
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// client 测试用的 MPD 客户端连接
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, addr net.Addr) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+	if greeting := c.line(); greeting != "OK MPD "+protocolVersion {
+		t.Fatalf("greeting = %q", greeting)
+	}
+	return c
+}
+
+func (c *client) line() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	return strings.TrimSuffix(line, "\n")
+}
+
+func (c *client) send(line string) {
+	c.t.Helper()
+	if _, err := io.WriteString(c.conn, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// response 读取到 OK 或 ACK 为止的输出
+func (c *client) response() ([]string, string) {
+	c.t.Helper()
+	var lines []string
+	for {
+		line := c.line()
+		if line == "OK" || strings.HasPrefix(line, "ACK ") {
+			return lines, line
+		}
+		lines = append(lines, line)
+	}
+}
+
+// cmd 执行一条命令，要求返回 OK
+func (c *client) cmd(line string) []string {
+	c.t.Helper()
+	c.send(line)
+	lines, end := c.response()
+	if end != "OK" {
+		c.t.Fatalf("%s: %s", line, end)
+	}
+	return lines
+}
+
+// ack 执行一条命令，返回 ACK 行
+func (c *client) ack(line string) string {
+	c.t.Helper()
+	c.send(line)
+	_, end := c.response()
+	if end == "OK" {
+		c.t.Fatalf("%s: expected ACK", line)
+	}
+	return end
+}
+
+// values 将 "key: value" 行转换为 map，重复的键保留最后一个
+func values(lines []string) map[string]string {
+	m := make(map[string]string)
+	for _, line := range lines {
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// field 所有同名键的值
+func field(lines []string, key string) []string {
+	var out []string
+	for _, line := range lines {
+		if k, v, ok := strings.Cut(line, ": "); ok && k == key {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func startServer(t *testing.T) (*Server, *music.Player, *fakeController) {
+	t.Helper()
+	logger := slog.New(slog.DiscardHandler)
+	root := t.TempDir()
+	for _, name := range []string{"a.wav", "b.wav", filepath.Join("sub", "c.wav")} {
+		writeWAV(t, filepath.Join(root, name), 5*time.Second)
+	}
+
+	player := music.NewPlayer(root, []string{".wav"}, logger)
+	player.SetIndexPath(filepath.Join(t.TempDir(), "index.json"))
+	player.SetPlaylistPath(filepath.Join(t.TempDir(), "playlists.json"))
+	player.SetOutput(music.NullOutput)
+	if err := player.LoadSongs(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { player.Close() })
+
+	ctrl := &fakeController{volume: 50}
+	srv := NewServer("127.0.0.1:0", root, player, ctrl, logger)
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv, player, ctrl
+}
+
+func TestServer(t *testing.T) {
+	srv, player, ctrl := startServer(t)
+	c := dial(t, srv.Addr())
+
+	c.cmd("ping")
+
+	status := values(c.cmd("status"))
+	for key, want := range map[string]string{"state": "stop", "volume": "50", "playlistlength": "3", "repeat": "0", "random": "0"} {
+		if status[key] != want {
+			t.Errorf("status %s = %q, want %q", key, status[key], want)
+		}
+	}
+	if _, ok := status["song"]; ok {
+		t.Error("stopped status should not report a song")
+	}
+	if lines := c.cmd("currentsong"); len(lines) != 0 {
+		t.Errorf("currentsong while stopped = %q", lines)
+	}
+
+	// 播放列表即整个曲库，URI 相对曲库目录
+	info := c.cmd("playlistinfo")
+	files := field(info, "file")
+	if want := []string{"a.wav", "b.wav", "sub/c.wav"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("playlistinfo files = %q, want %q", files, want)
+	}
+	if ids := field(info, "Id"); !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+		t.Errorf("playlistinfo ids = %q", ids)
+	}
+	if got := field(c.cmd("playlistinfo 1:"), "file"); !reflect.DeepEqual(got, []string{"b.wav", "sub/c.wav"}) {
+		t.Errorf("playlistinfo 1: = %q", got)
+	}
+	if got := field(c.cmd("playlistid 3"), "file"); !reflect.DeepEqual(got, []string{"sub/c.wav"}) {
+		t.Errorf("playlistid 3 = %q", got)
+	}
+
+	// 音量
+	c.cmd("setvol 30")
+	if ctrl.Volume() != 30 || values(c.cmd("status"))["volume"] != "30" {
+		t.Errorf("volume after setvol = %d", ctrl.Volume())
+	}
+
+	// 播放、暂停、继续
+	c.cmd("play 1")
+	status = values(c.cmd("status"))
+	if status["state"] != "play" || status["song"] != "1" || status["songid"] != "2" {
+		t.Errorf("status after play 1 = %v", status)
+	}
+	current := values(c.cmd("currentsong"))
+	if current["file"] != "b.wav" || current["Pos"] != "1" || current["Title"] == "" {
+		t.Errorf("currentsong = %v", current)
+	}
+	c.cmd("pause 1")
+	if state := values(c.cmd("status"))["state"]; state != "pause" || !player.IsPaused() {
+		t.Errorf("state after pause 1 = %q", state)
+	}
+	c.cmd("pause")
+	if state := values(c.cmd("status"))["state"]; state != "play" {
+		t.Errorf("state after toggling pause = %q", state)
+	}
+	c.cmd("playid 3")
+	if file := values(c.cmd("currentsong"))["file"]; file != "sub/c.wav" {
+		t.Errorf("currentsong after playid 3 = %q", file)
+	}
+	c.cmd("stop")
+	if state := values(c.cmd("status"))["state"]; state != "stop" {
+		t.Errorf("state after stop = %q", state)
+	}
+	if ctrl.starts != 2 {
+		t.Errorf("StartMusic calls = %d, want 2", ctrl.starts)
+	}
+
+	// add 只加入待播队列，不改变播放列表
+	c.cmd(`add "sub"`)
+	if queue := player.Queue(); len(queue) != 1 || filepath.Base(queue[0].Path) != "c.wav" {
+		t.Errorf("queue after add = %+v", queue)
+	}
+	if got := len(field(c.cmd("playlistinfo"), "file")); got != 3 {
+		t.Errorf("playlistinfo after add has %d songs, want 3", got)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	srv, _, _ := startServer(t)
+	c := dial(t, srv.Addr())
+
+	tests := map[string]string{
+		"setvol 101":       "ACK [2@0] {setvol} Invalid volume value: 101",
+		"play 9":           "ACK [50@0] {play} No such song",
+		"playid x":         "ACK [2@0] {playid} Integer expected: x",
+		"pause":            "",
+		"add missing.wav":  "ACK [50@0] {add} No such song",
+		"frobnicate":       `ACK [5@0] {frobnicate} unknown command "frobnicate"`,
+		`lsinfo "unclosed`: `ACK [2@0] {} Missing closing '"'`,
+	}
+	for line, want := range tests {
+		if want == "" {
+			c.cmd(line)
+			continue
+		}
+		if got := c.ack(line); got != want {
+			t.Errorf("%s: got %q, want %q", line, got, want)
+		}
+	}
+
+	// 命令列表：list_OK 分隔每条命令，出错时给出序号并放弃剩余命令
+	c.send("command_list_ok_begin\nping\nstatus\ncommand_list_end")
+	lines, end := c.response()
+	if end != "OK" || len(field(lines, "state")) != 1 || lines[0] != "list_OK" || lines[len(lines)-1] != "list_OK" {
+		t.Errorf("command list = %q, %q", lines, end)
+	}
+	c.send("command_list_begin\nping\nsetvol 200\nping\ncommand_list_end")
+	if _, end := c.response(); !strings.HasPrefix(end, "ACK [2@1] {setvol}") {
+		t.Errorf("failed command list = %q", end)
+	}
+}
+
+func TestServerIdle(t *testing.T) {
+	srv, _, _ := startServer(t)
+	c := dial(t, srv.Addr())
+	watcher := dial(t, srv.Addr())
+
+	// noidle 立即结束 idle
+	watcher.send("idle")
+	watcher.send("noidle")
+	if lines, end := watcher.response(); end != "OK" || len(lines) != 0 {
+		t.Errorf("noidle = %q, %q", lines, end)
+	}
+
+	watcher.send("idle mixer")
+	c.cmd("setvol 10")
+	if lines, end := watcher.response(); end != "OK" || !reflect.DeepEqual(lines, []string{"changed: mixer"}) {
+		t.Errorf("idle mixer = %q, %q", lines, end)
+	}
+
+	// 只等待 player，期间的音量变化留到下一次 idle
+	watcher.send("idle player")
+	c.cmd("setvol 20")
+	c.cmd("play 0")
+	if lines, end := watcher.response(); end != "OK" || !reflect.DeepEqual(lines, []string{"changed: player"}) {
+		t.Errorf("idle player = %q, %q", lines, end)
+	}
+	watcher.send("idle")
+	if lines, end := watcher.response(); end != "OK" || !reflect.DeepEqual(lines, []string{"changed: mixer"}) {
+		t.Errorf("pending idle = %q, %q", lines, end)
+	}
+
+	// idle 期间发送其他命令时断开连接
+	watcher.send("idle")
+	watcher.send("status")
+	watcher.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := watcher.r.ReadString('\n'); err == nil {
+		t.Error("connection should be closed after a command during idle")
+	}
+	c.cmd("stop")
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"status", []string{"status"}, false},
+		{"  play   1  ", []string{"play", "1"}, false},
+		{`add "sub dir/a b.mp3"`, []string{"add", "sub dir/a b.mp3"}, false},
+		{`find "title" "say \"hi\" \\ now"`, []string{"find", "title", `say "hi" \ now`}, false},
+		{`add ""`, []string{"add", ""}, false},
+		{`add a"b"`, nil, true},
+		{`add "open`, nil, true},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseArgs(tt.line)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseArgs(%q) = %q, %v; want %q, err %v", tt.line, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		arg        string
+		start, end int
+		wantErr    bool
+	}{
+		{"0", 0, 1, false},
+		{"2", 2, 3, false},
+		{"1:", 1, 3, false},
+		{"0:2", 0, 2, false},
+		{"1:10", 1, 3, false},
+		{"3:", 3, 3, false},
+		{"3", 0, 0, true},
+		{"2:1", 0, 0, true},
+		{"-1", 0, 0, true},
+		{"x", 0, 0, true},
+	}
+	for _, tt := range tests {
+		start, end, err := parseRange(tt.arg, 3)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && (start != tt.start || end != tt.end)) {
+			t.Errorf("parseRange(%q) = %d, %d, %v; want %d, %d, err %v", tt.arg, start, end, err, tt.start, tt.end, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package music
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/audio"
+)
+
+// MaxCrossfade 交叉淡化的最大时长
+const MaxCrossfade = 10 * time.Second
+
+// SetCrossfade 设置曲目之间交叉淡化的时长，0 表示关闭（曲目之间无缝衔接）
+func (p *Player) SetCrossfade(d time.Duration) error {
+	if d < 0 || d > MaxCrossfade {
+		return fmt.Errorf("crossfade must be between 0 and %v", MaxCrossfade)
+	}
+
+	p.mu.Lock()
+	p.crossfade = d
+	p.mu.Unlock()
+	p.logger.Info("Music crossfade changed", "duration", d)
+	return nil
+}
+
+// Crossfade 当前交叉淡化时长
+func (p *Player) Crossfade() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.crossfade
+}
+
+// crossfadeFor 曲目结尾的淡化时长，不超过曲目的三分之一，时长未知时不淡化
+func (p *Player) crossfadeFor(duration time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if duration <= 0 {
+		return 0
+	}
+	return min(p.crossfade, duration/3)
+}
+
+// crossfadeAllowed 当前是否可以开始淡出并切到下一首
+// 单曲循环、播放一次、睡眠定时播完停止、暂停中、顺序播放到末尾时不淡化
+func (p *Player) crossfadeAllowed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.playing || p.paused || p.radio != nil || p.sleepEndOfTrack {
+		return false
+	}
+	switch p.mode {
+	case ModeOnce, ModeRepeatOne:
+		return false
+	case ModeSequential:
+		if len(p.queue) == 0 {
+			scope := p.scopeLocked()
+			return positionOf(scope, p.currentIndex)+1 < len(scope)
+		}
+	}
+	return true
+}
+
+// fadeTail 交叉淡化中正在淡出的上一首，由下一首的播放循环混合
+type fadeTail struct {
+	song        SongInfo
+	dec         Decoder
+	channels    int // 解码声道数
+	outChannels int
+	gain        float64
+	lim         *limiter
+	buf         []int16
+	total       int64 // 淡化的总帧数
+	mixed       int64 // 已混合的帧数
+}
+
+// matches 上一首与下一首的输出格式相同时才能混合
+func (t *fadeTail) matches(sampleRate, outChannels int) bool {
+	return t.dec.SampleRate() == sampleRate && t.outChannels == outChannels
+}
+
+// read 读取最多 frames 帧并施加增益，返回输出声道的样本，结束时返回 io.EOF
+func (t *fadeTail) read(frames int) ([]int16, error) {
+	if need := frames * t.channels; cap(t.buf) < need {
+		t.buf = make([]int16, need)
+	}
+	n, err := readFull(t.dec, t.buf[:frames*t.channels])
+	pcm := append([]int16(nil), audio.ConvertChannels(t.buf[:n], t.channels, t.outChannels)...)
+	if t.gain != 1 {
+		t.lim.process(pcm, t.gain)
+	}
+	return pcm, err
+}
+
+// mix 将上一首按等功率曲线淡出，同时淡入 pcm，原地写入 pcm
+// 上一首先结束时返回 true
+func (t *fadeTail) mix(pcm []int16) (bool, error) {
+	frames := len(pcm) / t.outChannels
+	tail, err := t.read(frames)
+	if err != nil && err != io.EOF {
+		return true, err
+	}
+
+	for i := 0; i < frames; i++ {
+		x := 1.0
+		if t.total > 0 {
+			x = math.Min(1, float64(t.mixed)/float64(t.total))
+		}
+		in, out := math.Sin(x*math.Pi/2), math.Cos(x*math.Pi/2)
+		for ch := 0; ch < t.outChannels; ch++ {
+			j := i*t.outChannels + ch
+			v := float64(pcm[j]) * in
+			if j < len(tail) {
+				v += float64(tail[j]) * out
+			}
+			pcm[j] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+		}
+		t.mixed++
+	}
+	return err == io.EOF || t.mixed >= t.total, nil
+}
+
+// close 关闭上一首的解码器
+func (t *fadeTail) close() {
+	t.dec.Close()
+}
+
+// drain 无法混合（格式不同或没有下一首）时，不淡化直接播完上一首
+func (t *fadeTail) drain(out *output, stopChan <-chan struct{}) error {
+	for {
+		select {
+		case <-stopChan:
+			return nil
+		default:
+		}
+		pcm, err := t.read(out.frameSamples() / t.outChannels)
+		if len(pcm) > 0 {
+			if werr := out.write(pcm, stopChan); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// fadeOut 下一首已经结束，上一首单独按原曲线淡出
+func (t *fadeTail) fadeOut(out *output, stopChan <-chan struct{}) error {
+	for {
+		select {
+		case <-stopChan:
+			return nil
+		default:
+		}
+		pcm := make([]int16, out.frameSamples())
+		done, err := t.mix(pcm)
+		if err != nil {
+			return err
+		}
+		if werr := out.write(pcm, stopChan); werr != nil {
+			return werr
+		}
+		if done {
+			return nil
+		}
+	}
+}
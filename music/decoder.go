@@ -0,0 +1,509 @@
+package music
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/jfreymuth/oggvorbis"
+	"github.com/lisuiheng/xiaozhi-go/audio"
+	"github.com/mewkiz/flac"
+)
+
+// opusSampleRate Ogg Opus 解码输出采样率
+const opusSampleRate = 48000
+
+// ErrUnsupportedFormat 无法识别的音频格式
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// ErrNotSeekable 音频源不支持定位（如网络流）
+var ErrNotSeekable = errors.New("audio source is not seekable")
+
+// Decoder 音频解码器，输出 16 位交错 PCM
+type Decoder interface {
+	SampleRate() int
+	Channels() int
+	// Duration 返回总时长，未知时返回 0
+	Duration() time.Duration
+	// Read 读取交错样本，返回读取的样本数，结束时返回 io.EOF
+	Read(pcm []int16) (int, error)
+	// Seek 定位到指定位置
+	Seek(pos time.Duration) error
+	Close() error
+}
+
+// Format 音频容器/编码格式
+type Format string
+
+const (
+	FormatWAV    Format = "wav"
+	FormatMP3    Format = "mp3"
+	FormatFLAC   Format = "flac"
+	FormatVorbis Format = "vorbis"
+	FormatOpus   Format = "opus"
+	FormatAAC    Format = "aac" // 没有内置解码器，启用外部解码时由 ffmpeg 解码
+)
+
+// OpenDecoder 打开音频文件并根据文件头选择解码器，无法识别时参考扩展名；
+// 没有内置解码器的格式（AAC、M4A 等）在启用外部解码时交给 ffmpeg，否则返回 ErrUnsupportedFormat
+func OpenDecoder(path string, logger *slog.Logger) (Decoder, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 64)
+	n, _ := io.ReadFull(file, header)
+	format := detectFormat(header[:n], path)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// 解码器直接读取文件，以便支持定位
+	var dec Decoder
+	switch format {
+	case FormatWAV:
+		dec, err = newWAVDecoder(file)
+	case FormatMP3:
+		dec, err = newMP3Decoder(file)
+	case FormatFLAC:
+		dec, err = newFLACDecoder(file)
+	case FormatVorbis:
+		dec, err = newVorbisDecoder(file)
+	case FormatOpus:
+		dec, err = newOpusDecoder(file, logger)
+	default:
+		file.Close()
+		dec, err = newExternalDecoder(path, logger)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s (%v)", ErrUnsupportedFormat, filepath.Base(path), err)
+		}
+		return dec, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to open %s decoder: %w", format, err)
+	}
+	return dec, nil
+}
+
+// detectFormat 通过魔数识别格式
+func detectFormat(header []byte, path string) Format {
+	switch {
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return FormatWAV
+	case len(header) >= 4 && string(header[0:4]) == "fLaC":
+		return FormatFLAC
+	case len(header) >= 4 && string(header[0:4]) == "OggS":
+		// 第一个 Ogg 页只包含编码头部包
+		if bytes.Contains(header, []byte("OpusHead")) {
+			return FormatOpus
+		}
+		if bytes.Contains(header, []byte("\x01vorbis")) {
+			return FormatVorbis
+		}
+	case len(header) >= 3 && string(header[0:3]) == "ID3":
+		// ID3v2 标签可能位于 MP3 或 FLAC 之前，按扩展名区分
+		if strings.ToLower(filepath.Ext(path)) == ".flac" {
+			return FormatFLAC
+		}
+		return FormatMP3
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xF6 == 0xF0:
+		// ADTS 同步字与 MP3 相同，但 layer 位为 0
+		return FormatAAC
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		return FormatMP3
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		return FormatWAV
+	case ".mp3":
+		return FormatMP3
+	case ".flac":
+		return FormatFLAC
+	case ".opus":
+		return FormatOpus
+	case ".ogg", ".oga":
+		return FormatVorbis
+	case ".aac":
+		return FormatAAC
+	}
+	return ""
+}
+
+// durationToFrames 将时长换算为帧数
+func durationToFrames(pos time.Duration, sampleRate int) int64 {
+	if pos < 0 {
+		return 0
+	}
+	return int64(pos) * int64(sampleRate) / int64(time.Second)
+}
+
+// framesToDuration 将帧数换算为时长
+func framesToDuration(frames int64, sampleRate int) time.Duration {
+	if sampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(frames) * time.Second / time.Duration(sampleRate)
+}
+
+// ============================================================================
+// WAV
+// ============================================================================
+
+type wavDecoder struct {
+	wav  *audio.WAVReader
+	file *os.File
+}
+
+func newWAVDecoder(file *os.File) (*wavDecoder, error) {
+	wav, err := audio.NewWAVReader(file)
+	if err != nil {
+		return nil, err
+	}
+	return &wavDecoder{wav: wav, file: file}, nil
+}
+
+func (d *wavDecoder) SampleRate() int               { return d.wav.Format.SampleRate }
+func (d *wavDecoder) Channels() int                 { return d.wav.Format.Channels }
+func (d *wavDecoder) Duration() time.Duration       { return d.wav.Duration() }
+func (d *wavDecoder) Read(pcm []int16) (int, error) { return d.wav.Read(pcm) }
+func (d *wavDecoder) Close() error                  { return d.file.Close() }
+
+func (d *wavDecoder) Seek(pos time.Duration) error {
+	return d.wav.SeekFrame(durationToFrames(pos, d.SampleRate()))
+}
+
+// ============================================================================
+// MP3
+// ============================================================================
+
+// mp3Decoder go-mp3 始终输出 16 位小端立体声
+type mp3Decoder struct {
+	dec *mp3.Decoder
+	src io.ReadCloser // 文件或网络流，网络流不支持定位
+	buf []byte
+}
+
+// mp3FrameBytes go-mp3 每个立体声帧的字节数
+const mp3FrameBytes = 4
+
+func newMP3Decoder(src io.ReadCloser) (*mp3Decoder, error) {
+	dec, err := mp3.NewDecoder(src)
+	if err != nil {
+		return nil, err
+	}
+	return &mp3Decoder{dec: dec, src: src}, nil
+}
+
+func (d *mp3Decoder) SampleRate() int { return d.dec.SampleRate() }
+func (d *mp3Decoder) Channels() int   { return 2 }
+
+func (d *mp3Decoder) Duration() time.Duration {
+	if d.dec.Length() <= 0 {
+		return 0
+	}
+	return framesToDuration(d.dec.Length()/mp3FrameBytes, d.SampleRate())
+}
+
+func (d *mp3Decoder) Seek(pos time.Duration) error {
+	if _, ok := d.src.(io.Seeker); !ok {
+		return ErrNotSeekable
+	}
+	_, err := d.dec.Seek(durationToFrames(pos, d.SampleRate())*mp3FrameBytes, io.SeekStart)
+	return err
+}
+
+func (d *mp3Decoder) Read(pcm []int16) (int, error) {
+	// 只读取完整的立体声帧
+	want := len(pcm) / 2 * mp3FrameBytes
+	if want == 0 {
+		return 0, nil
+	}
+	if cap(d.buf) < want {
+		d.buf = make([]byte, want)
+	}
+	buf := d.buf[:want]
+
+	n, err := io.ReadFull(d.dec, buf)
+	n -= n % mp3FrameBytes
+	for i := 0; i < n/2; i++ {
+		pcm[i] = int16(binary.LittleEndian.Uint16(buf[i*2:]))
+	}
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+		if n == 0 {
+			err = io.EOF
+		}
+	}
+	return n / 2, err
+}
+
+func (d *mp3Decoder) Close() error { return d.src.Close() }
+
+// ============================================================================
+// FLAC
+// ============================================================================
+
+type flacDecoder struct {
+	stream  *flac.Stream
+	file    *os.File
+	shift   int // 转换为 16 位时的位移量
+	pending []int16
+	skip    int // 定位后需要丢弃的样本数（定位只能到帧边界）
+}
+
+func newFLACDecoder(file *os.File) (*flacDecoder, error) {
+	stream, err := flac.NewSeek(file)
+	if err != nil {
+		return nil, err
+	}
+	return &flacDecoder{
+		stream: stream,
+		file:   file,
+		shift:  int(stream.Info.BitsPerSample) - 16,
+	}, nil
+}
+
+func (d *flacDecoder) SampleRate() int { return int(d.stream.Info.SampleRate) }
+func (d *flacDecoder) Channels() int   { return int(d.stream.Info.NChannels) }
+
+func (d *flacDecoder) Duration() time.Duration {
+	return framesToDuration(int64(d.stream.Info.NSamples), d.SampleRate())
+}
+
+func (d *flacDecoder) Seek(pos time.Duration) error {
+	target := uint64(durationToFrames(pos, d.SampleRate()))
+	if n := d.stream.Info.NSamples; n > 0 && target >= n {
+		target = n - 1
+	}
+	actual, err := d.stream.Seek(target)
+	if err != nil {
+		return err
+	}
+	d.pending = nil
+	d.skip = int(target-actual) * d.Channels()
+	return nil
+}
+
+func (d *flacDecoder) Read(pcm []int16) (int, error) {
+	for len(d.pending) == 0 {
+		frame, err := d.stream.ParseNext()
+		if err != nil {
+			return 0, err
+		}
+		if len(frame.Subframes) == 0 {
+			continue
+		}
+
+		channels := len(frame.Subframes)
+		samples := len(frame.Subframes[0].Samples)
+		out := make([]int16, samples*channels)
+		for ch, sub := range frame.Subframes {
+			for i, s := range sub.Samples {
+				out[i*channels+ch] = d.toInt16(s)
+			}
+		}
+		if d.skip > 0 {
+			drop := min(d.skip, len(out))
+			out = out[drop:]
+			d.skip -= drop
+		}
+		d.pending = out
+	}
+
+	n := copy(pcm, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func (d *flacDecoder) toInt16(s int32) int16 {
+	if d.shift > 0 {
+		return int16(s >> d.shift)
+	}
+	return int16(s << -d.shift)
+}
+
+func (d *flacDecoder) Close() error { return d.file.Close() }
+
+// ============================================================================
+// Ogg Vorbis
+// ============================================================================
+
+type vorbisDecoder struct {
+	reader *oggvorbis.Reader
+	src    io.ReadCloser // 文件或网络流，网络流不支持定位
+	buf    []float32
+}
+
+func newVorbisDecoder(src io.ReadCloser) (*vorbisDecoder, error) {
+	reader, err := oggvorbis.NewReader(src)
+	if err != nil {
+		return nil, err
+	}
+	return &vorbisDecoder{reader: reader, src: src}, nil
+}
+
+func (d *vorbisDecoder) SampleRate() int { return d.reader.SampleRate() }
+func (d *vorbisDecoder) Channels() int   { return d.reader.Channels() }
+
+func (d *vorbisDecoder) Duration() time.Duration {
+	return framesToDuration(d.reader.Length(), d.SampleRate())
+}
+
+func (d *vorbisDecoder) Seek(pos time.Duration) error {
+	if _, ok := d.src.(io.Seeker); !ok {
+		return ErrNotSeekable
+	}
+	return d.reader.SetPosition(durationToFrames(pos, d.SampleRate()))
+}
+
+func (d *vorbisDecoder) Read(pcm []int16) (int, error) {
+	if cap(d.buf) < len(pcm) {
+		d.buf = make([]float32, len(pcm))
+	}
+	buf := d.buf[:len(pcm)]
+
+	n, err := d.reader.Read(buf)
+	for i := 0; i < n; i++ {
+		pcm[i] = floatToInt16(buf[i])
+	}
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (d *vorbisDecoder) Close() error { return d.src.Close() }
+
+// ============================================================================
+// Ogg Opus
+// ============================================================================
+
+// opusDecoder OggOpusReader 不支持随机访问，定位时从头解码并丢弃
+type opusDecoder struct {
+	reader   *audio.OggOpusReader
+	file     *os.File // 网络流时为 nil，不支持定位
+	logger   *slog.Logger
+	duration time.Duration
+}
+
+func newOpusDecoder(file *os.File, logger *slog.Logger) (*opusDecoder, error) {
+	// 最后一页的 granule position 即为 48kHz 下的总样本数
+	var duration time.Duration
+	if granule, err := lastOggGranule(file); err == nil && granule > 0 {
+		duration = framesToDuration(granule, opusSampleRate)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	reader, err := audio.NewOggOpusReader(file, opusSampleRate, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &opusDecoder{reader: reader, file: file, logger: logger, duration: duration}, nil
+}
+
+// newOpusStreamDecoder 解码网络流中的 Ogg Opus
+func newOpusStreamDecoder(r io.Reader, logger *slog.Logger) (*opusDecoder, error) {
+	reader, err := audio.NewOggOpusReader(r, opusSampleRate, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &opusDecoder{reader: reader, logger: logger}, nil
+}
+
+func (d *opusDecoder) SampleRate() int               { return d.reader.SampleRate }
+func (d *opusDecoder) Channels() int                 { return d.reader.Channels }
+func (d *opusDecoder) Duration() time.Duration       { return d.duration }
+func (d *opusDecoder) Read(pcm []int16) (int, error) { return d.reader.Read(pcm) }
+
+func (d *opusDecoder) Seek(pos time.Duration) error {
+	if d.file == nil {
+		return ErrNotSeekable
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader, err := audio.NewOggOpusReader(d.file, opusSampleRate, d.logger)
+	if err != nil {
+		return err
+	}
+	d.reader.Close()
+	d.reader = reader
+
+	remaining := durationToFrames(pos, opusSampleRate) * int64(reader.Channels)
+	buf := make([]int16, 5760*reader.Channels)
+	for remaining > 0 {
+		chunk := buf
+		if int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		n, err := reader.Read(chunk)
+		remaining -= int64(n)
+		if err == io.EOF {
+			// 超出结尾，后续读取直接返回 io.EOF
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *opusDecoder) Close() error {
+	d.reader.Close()
+	if d.file == nil {
+		return nil
+	}
+	return d.file.Close()
+}
+
+// lastOggGranule 从文件末尾查找最后一个 Ogg 页的 granule position
+func lastOggGranule(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// Ogg 页最大约 64KB
+	size := info.Size()
+	tail := int64(65307)
+	if tail > size {
+		tail = size
+	}
+	buf := make([]byte, tail)
+	if _, err := file.ReadAt(buf, size-tail); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	idx := bytes.LastIndex(buf, []byte("OggS"))
+	if idx < 0 || idx+14 > len(buf) {
+		return 0, errors.New("no ogg page found")
+	}
+	return int64(binary.LittleEndian.Uint64(buf[idx+6 : idx+14])), nil
+}
+
+// floatToInt16 将 [-1, 1] 浮点样本转换为 16 位，超出范围时削波
+func floatToInt16(f float32) int16 {
+	v := f * 32768
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
@@ -0,0 +1,197 @@
+package music
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+	"github.com/mewkiz/flac/meta"
+)
+
+// 生成的 WAV/FLAC 夹具：8kHz 立体声 2 秒，每个样本的值等于其帧序号，便于检查定位是否精确
+const (
+	rampRate   = 8000
+	rampFrames = 2 * rampRate
+)
+
+// writeRampWAV 写入 16 位立体声 WAV
+func writeRampWAV(t *testing.T, path string) {
+	t.Helper()
+	data := make([]byte, 44+rampFrames*4)
+	copy(data[0:], "RIFF")
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+	copy(data[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(data[16:], 16)
+	binary.LittleEndian.PutUint16(data[20:], 1)
+	binary.LittleEndian.PutUint16(data[22:], 2)
+	binary.LittleEndian.PutUint32(data[24:], rampRate)
+	binary.LittleEndian.PutUint32(data[28:], rampRate*4)
+	binary.LittleEndian.PutUint16(data[32:], 4)
+	binary.LittleEndian.PutUint16(data[34:], 16)
+	copy(data[36:], "data")
+	binary.LittleEndian.PutUint32(data[40:], rampFrames*4)
+	for i := 0; i < rampFrames; i++ {
+		binary.LittleEndian.PutUint16(data[44+i*4:], uint16(i))
+		binary.LittleEndian.PutUint16(data[46+i*4:], uint16(i))
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeRampFLAC 用未压缩子帧写入 16 位立体声 FLAC
+func writeRampFLAC(t *testing.T, path string) {
+	t.Helper()
+	const blockSize = 1024
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &meta.StreamInfo{
+		BlockSizeMin:  blockSize,
+		BlockSizeMax:  blockSize,
+		SampleRate:    rampRate,
+		NChannels:     2,
+		BitsPerSample: 16,
+		NSamples:      rampFrames,
+	}
+	enc, err := flac.NewEncoder(file, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for start := 0; start < rampFrames; start += blockSize {
+		n := min(blockSize, rampFrames-start)
+		f := &frame.Frame{Header: frame.Header{
+			HasFixedBlockSize: true,
+			BlockSize:         uint16(n),
+			SampleRate:        rampRate,
+			Channels:          frame.ChannelsLR,
+			BitsPerSample:     16,
+		}}
+		for ch := 0; ch < 2; ch++ {
+			samples := make([]int32, n)
+			for i := range samples {
+				samples[i] = int32(start + i)
+			}
+			f.Subframes = append(f.Subframes, &frame.Subframe{
+				SubHeader: frame.SubHeader{Pred: frame.PredVerbatim},
+				Samples:   samples,
+				NSamples:  n,
+			})
+		}
+		if err := enc.WriteFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readAll 读取剩余全部样本
+func readAll(t *testing.T, dec Decoder) []int16 {
+	t.Helper()
+	var all []int16
+	buf := make([]int16, 4096)
+	for {
+		n, err := dec.Read(buf)
+		all = append(all, buf[:n]...)
+		if err == io.EOF {
+			return all
+		}
+		if err != nil {
+			t.Fatalf("Read after %d samples: %v", len(all), err)
+		}
+	}
+}
+
+func TestOpenDecoder(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		path       string
+		sampleRate int
+		channels   int
+		duration   time.Duration
+		tolerance  time.Duration // 解码时长与 Duration 的允许误差（编码延迟、末尾裁剪）
+		ramp       bool          // 样本值等于帧序号，定位后检查第一个样本
+	}{
+		{"wav", filepath.Join(dir, "ramp.wav"), rampRate, 2, 2 * time.Second, 0, true},
+		{"flac", filepath.Join(dir, "ramp.flac"), rampRate, 2, 2 * time.Second, 0, true},
+		{"mp3", "testdata/sample.mp3", 44100, 2, 1044897959 * time.Nanosecond, 30 * time.Millisecond, false},
+		{"vorbis", "testdata/sample.ogg", 44100, 1, time.Second, 0, false},
+		{"opus", "testdata/speech.opus", opusSampleRate, 1, 10806500 * time.Microsecond, 20 * time.Millisecond, false},
+	}
+	writeRampWAV(t, tests[0].path)
+	writeRampFLAC(t, tests[1].path)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := OpenDecoder(tt.path, testLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dec.Close()
+
+			if dec.SampleRate() != tt.sampleRate || dec.Channels() != tt.channels {
+				t.Errorf("format = %d Hz/%d ch, want %d Hz/%d ch", dec.SampleRate(), dec.Channels(), tt.sampleRate, tt.channels)
+			}
+			if d := dec.Duration(); d != tt.duration {
+				t.Errorf("Duration = %v, want %v", d, tt.duration)
+			}
+
+			frames := func(samples []int16) time.Duration {
+				return framesToDuration(int64(len(samples)/tt.channels), tt.sampleRate)
+			}
+			if got := frames(readAll(t, dec)); (got - tt.duration).Abs() > tt.tolerance {
+				t.Errorf("decoded %v, want %v ± %v", got, tt.duration, tt.tolerance)
+			}
+
+			half := tt.duration / 2
+			if err := dec.Seek(half); err != nil {
+				t.Fatalf("Seek(%v): %v", half, err)
+			}
+			rest := readAll(t, dec)
+			if got := frames(rest); (got - (tt.duration - half)).Abs() > tt.tolerance {
+				t.Errorf("decoded %v after Seek(%v), want %v ± %v", got, half, tt.duration-half, tt.tolerance)
+			}
+			if tt.ramp && (len(rest) < 2 || rest[0] != rampFrames/2 || rest[1] != rampFrames/2) {
+				t.Errorf("first frame after Seek(%v) = %v, want %d", half, rest[:min(2, len(rest))], rampFrames/2)
+			}
+		})
+	}
+}
+
+func TestOpenDecoderExternalDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "song.m4a")
+	if err := os.WriteFile(path, []byte("\x00\x00\x00\x20ftypM4A "), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ExternalDecoderEnabled() {
+		t.Fatal("external decoder enabled by default")
+	}
+	if _, err := OpenDecoder(path, testLogger()); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("OpenDecoder error = %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestSetExternalDecoderWithoutFFmpeg(t *testing.T) {
+	// 清空 PATH，确保找不到 ffmpeg
+	t.Setenv("PATH", t.TempDir())
+	t.Cleanup(func() { SetExternalDecoder(false) })
+
+	if err := SetExternalDecoder(true); !errors.Is(err, ErrNoExternalDecoder) {
+		t.Errorf("SetExternalDecoder(true) error = %v, want ErrNoExternalDecoder", err)
+	}
+	if ExternalDecoderEnabled() {
+		t.Error("external decoder enabled without ffmpeg")
+	}
+}
@@ -0,0 +1,229 @@
+package music
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 外部解码参数：ffmpeg 统一输出 44.1kHz 立体声 16 位 PCM
+const (
+	externalSampleRate = 44100
+	externalChannels   = 2
+	externalFrameBytes = externalChannels * 2
+	externalProbeLimit = 10 * time.Second // ffprobe 读取时长的超时
+	externalWaitDelay  = time.Second      // 结束进程后等待输入输出复制结束的最长时间
+)
+
+// ErrNoExternalDecoder 没有内置解码器的格式需要 ffmpeg，但未启用外部解码或系统中找不到
+var ErrNoExternalDecoder = errors.New("external decoder (ffmpeg) not enabled")
+
+// externalEnabled 是否允许启动 ffmpeg/ffprobe 子进程，默认关闭，
+// 未启用时只使用内置解码器，扫描曲库也不会为无法识别的文件启动进程
+var externalEnabled atomic.Bool
+
+// SetExternalDecoder 启用或关闭 ffmpeg 外部解码，启用时系统中必须能找到 ffmpeg
+func SetExternalDecoder(enabled bool) error {
+	if enabled {
+		if _, err := exec.LookPath("ffmpeg"); err != nil {
+			externalEnabled.Store(false)
+			return fmt.Errorf("%w: %v", ErrNoExternalDecoder, err)
+		}
+	}
+	externalEnabled.Store(enabled)
+	return nil
+}
+
+// ExternalDecoderEnabled 是否启用了 ffmpeg 外部解码
+func ExternalDecoderEnabled() bool {
+	return externalEnabled.Load()
+}
+
+// externalDecoder 通过 ffmpeg 子进程解码内置解码器不支持的格式（AAC、M4A/ALAC、WMA 等）
+// 子进程在首次读取时启动，输出经管道进入与内置解码相同的播放流程：
+// 暂停时停止读取，ffmpeg 因管道写满而阻塞，恢复后从原位置继续；定位时以 -ss 重新启动
+type externalDecoder struct {
+	path     string    // 本地文件，网络流时为空
+	src      io.Reader // 网络流数据，经标准输入传给 ffmpeg
+	closer   io.Closer // 关闭解码器时一并关闭的数据源
+	logger   *slog.Logger
+	duration time.Duration
+
+	start  time.Duration // 下一次启动进程时的起始位置
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	buf    []byte
+	ended  bool // 当前进程的输出已读完
+
+	closeOnce sync.Once
+}
+
+// newExternalDecoder 用 ffmpeg 解码本地文件
+func newExternalDecoder(path string, logger *slog.Logger) (*externalDecoder, error) {
+	if !externalEnabled.Load() {
+		return nil, ErrNoExternalDecoder
+	}
+	return &externalDecoder{
+		path:     path,
+		logger:   logger,
+		duration: probeDuration(path),
+	}, nil
+}
+
+// newExternalStreamDecoder 用 ffmpeg 解码网络流，src 关闭时一并关闭
+func newExternalStreamDecoder(src io.ReadCloser, logger *slog.Logger) (*externalDecoder, error) {
+	if !externalEnabled.Load() {
+		return nil, ErrNoExternalDecoder
+	}
+	return &externalDecoder{src: src, closer: src, logger: logger}, nil
+}
+
+func (d *externalDecoder) SampleRate() int         { return externalSampleRate }
+func (d *externalDecoder) Channels() int           { return externalChannels }
+func (d *externalDecoder) Duration() time.Duration { return d.duration }
+
+// Seek 结束当前进程，下一次读取时从 pos 重新解码
+func (d *externalDecoder) Seek(pos time.Duration) error {
+	if d.path == "" {
+		return ErrNotSeekable
+	}
+	d.stop()
+	d.start = max(pos, 0)
+	d.ended = false
+	return nil
+}
+
+func (d *externalDecoder) Read(pcm []int16) (int, error) {
+	if d.ended {
+		return 0, io.EOF
+	}
+	if d.cmd == nil {
+		if err := d.run(); err != nil {
+			return 0, err
+		}
+	}
+
+	// 只读取完整的立体声帧
+	want := len(pcm) / externalChannels * externalFrameBytes
+	if want == 0 {
+		return 0, nil
+	}
+	if cap(d.buf) < want {
+		d.buf = make([]byte, want)
+	}
+	buf := d.buf[:want]
+
+	n, err := io.ReadFull(d.stdout, buf)
+	n -= n % externalFrameBytes
+	for i := 0; i < n/2; i++ {
+		pcm[i] = int16(binary.LittleEndian.Uint16(buf[i*2:]))
+	}
+	switch {
+	case err == io.ErrUnexpectedEOF && n > 0:
+		return n / 2, nil
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		// 输出结束后检查 ffmpeg 是否正常退出
+		d.ended = true
+		waitErr := d.cmd.Wait()
+		d.cancel()
+		d.cmd = nil
+		if waitErr != nil {
+			return 0, fmt.Errorf("ffmpeg failed: %w: %s", waitErr, strings.TrimSpace(d.stderr.String()))
+		}
+		return n / 2, io.EOF
+	}
+	return n / 2, err
+}
+
+// run 启动 ffmpeg，从 d.start 开始输出原始 PCM
+func (d *externalDecoder) run() error {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	input := d.path
+	if d.path == "" {
+		input = "pipe:0"
+	} else {
+		args = append(args, "-nostdin")
+		if d.start > 0 {
+			args = append(args, "-ss", strconv.FormatFloat(d.start.Seconds(), 'f', 3, 64))
+		}
+	}
+	args = append(args,
+		"-i", input,
+		"-vn", "-f", "s16le", "-acodec", "pcm_s16le",
+		"-ar", strconv.Itoa(externalSampleRate), "-ac", strconv.Itoa(externalChannels),
+		"pipe:1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if d.src != nil {
+		cmd.Stdin = d.src
+	}
+	// 网络读取可能一直阻塞，结束进程后不无限等待标准输入和错误输出的复制
+	cmd.WaitDelay = externalWaitDelay
+	d.stderr = &bytes.Buffer{}
+	cmd.Stderr = d.stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	d.cmd, d.cancel, d.stdout = cmd, cancel, stdout
+	d.logger.Debug("External decoder started", "path", d.path, "start", d.start)
+	return nil
+}
+
+// stop 结束正在运行的 ffmpeg
+func (d *externalDecoder) stop() {
+	if d.cmd == nil {
+		return
+	}
+	d.cancel()
+	d.cmd.Wait()
+	d.cmd = nil
+}
+
+func (d *externalDecoder) Close() error {
+	d.closeOnce.Do(func() {
+		if d.closer != nil {
+			d.closer.Close()
+		}
+		d.stop()
+	})
+	return nil
+}
+
+// probeDuration 用 ffprobe 读取时长，失败时返回 0（未知）
+func probeDuration(path string) time.Duration {
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), externalProbeLimit)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ffprobe", "-v", "error",
+		"-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
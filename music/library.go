@@ -0,0 +1,547 @@
+package music
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/fsnotify/fsnotify"
+)
+
+// 曲库索引参数
+const (
+	indexVersion      = 2 // 2：增加 ReplayGain 信息
+	indexFileName     = "music_index.json"
+	rescanDebounce    = 2 * time.Second // 文件变化后延迟重新扫描，合并连续事件
+	unknownTrackOrder = 1 << 30
+)
+
+// SongInfo 歌曲信息
+type SongInfo struct {
+	Path     string        `json:"path"`
+	Name     string        `json:"name"` // 显示名称：标签标题，没有标签时为文件名
+	Title    string        `json:"title,omitempty"`
+	Artist   string        `json:"artist,omitempty"`
+	Album    string        `json:"album,omitempty"`
+	Track    int           `json:"track,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	HasCover bool          `json:"has_cover,omitempty"` // 是否内嵌封面
+	Gain     *ReplayGain   `json:"gain,omitempty"`      // 音量标准化信息，没有标签且尚未测量时为 nil
+	Source   string        `json:"source,omitempty"`    // 所在的可移动存储，本地曲库为空
+	Size     int64         `json:"size"`
+	ModTime  time.Time     `json:"mod_time"`
+}
+
+// libraryIndex 持久化的曲库索引
+type libraryIndex struct {
+	Version int        `json:"version"`
+	Root    string     `json:"root"`
+	Songs   []SongInfo `json:"songs"`
+}
+
+// Library 递归扫描音乐目录，解析标签并缓存到 JSON 索引
+type Library struct {
+	root      string
+	formats   []string
+	indexPath string
+	logger    *slog.Logger
+
+	scanMu  sync.Mutex
+	cache   map[string]SongInfo // 按路径缓存的歌曲信息
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+
+	playlistMu    sync.Mutex
+	playlistFiles []string // 扫描到的 M3U/M3U8/PLS 文件
+
+	unmeasurable map[string]bool // 无法测量响度的文件，本次运行不再重试
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+// DefaultIndexPath 默认索引位置：用户缓存目录下的 xiaozhi/music_index.json
+func DefaultIndexPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "xiaozhi", indexFileName)
+}
+
+// NewLibrary 创建曲库，indexPath 为空时使用默认位置
+func NewLibrary(root string, formats []string, indexPath string, logger *slog.Logger) *Library {
+	if indexPath == "" {
+		indexPath = DefaultIndexPath()
+	}
+	return &Library{
+		root:      root,
+		formats:   formats,
+		indexPath: indexPath,
+		logger:    logger,
+		closed:    make(chan struct{}),
+	}
+}
+
+// Scan 递归扫描曲库，未变化的文件（大小和修改时间相同）直接使用缓存的标签
+func (l *Library) Scan() ([]SongInfo, error) {
+	l.scanMu.Lock()
+	defer l.scanMu.Unlock()
+
+	if l.cache == nil {
+		l.cache = l.loadIndex()
+	}
+
+	if _, err := os.Stat(l.root); err != nil {
+		return nil, fmt.Errorf("failed to read music directory: %w", err)
+	}
+
+	start := time.Now()
+	songs := []SongInfo{}
+	var playlistFiles []string
+	parsed := 0
+
+	err := filepath.WalkDir(l.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			l.logger.Warn("Failed to access music path", "path", path, "error", err)
+			if d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		// 跳过隐藏文件和目录
+		if path != l.root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			l.watchDir(path)
+			return nil
+		}
+		if isPlaylistFile(path) {
+			playlistFiles = append(playlistFiles, path)
+			return nil
+		}
+		if !l.supported(path) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		if cached, ok := l.cache[path]; ok && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+			songs = append(songs, cached)
+			return nil
+		}
+
+		song := l.readSongInfo(path, info)
+		parsed++
+		songs = append(songs, song)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan music directory: %w", err)
+	}
+
+	sortSongs(songs)
+
+	l.playlistMu.Lock()
+	l.playlistFiles = playlistFiles
+	l.playlistMu.Unlock()
+
+	l.cache = make(map[string]SongInfo, len(songs))
+	for _, song := range songs {
+		l.cache[song.Path] = song
+	}
+	if err := l.saveIndex(songs); err != nil {
+		l.logger.Warn("Failed to save music index", "path", l.indexPath, "error", err)
+	}
+
+	l.logger.Info("Music library scanned", "count", len(songs), "playlists", len(playlistFiles), "parsed", parsed, "elapsed", time.Since(start))
+	return songs, nil
+}
+
+// PlaylistFiles 最近一次扫描到的播放列表文件
+func (l *Library) PlaylistFiles() []string {
+	l.playlistMu.Lock()
+	defer l.playlistMu.Unlock()
+	return append([]string(nil), l.playlistFiles...)
+}
+
+// supported 是否为支持的音频格式
+func (l *Library) supported(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, format := range l.formats {
+		if ext == strings.ToLower(format) {
+			return true
+		}
+	}
+	return false
+}
+
+// readSongInfo 解析标签和时长，失败时退回到文件名
+func (l *Library) readSongInfo(path string, info fs.FileInfo) SongInfo {
+	song := SongInfo{
+		Path:    path,
+		Name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+
+	if file, err := os.Open(path); err == nil {
+		if m, err := tag.ReadFrom(file); err == nil {
+			song.Title = strings.TrimSpace(m.Title())
+			song.Artist = strings.TrimSpace(m.Artist())
+			if song.Artist == "" {
+				song.Artist = strings.TrimSpace(m.AlbumArtist())
+			}
+			song.Album = strings.TrimSpace(m.Album())
+			song.Track, _ = m.Track()
+			song.HasCover = m.Picture() != nil
+			song.Gain = readReplayGain(m)
+		} else if !errors.Is(err, tag.ErrNoTagsFound) {
+			l.logger.Debug("Failed to read tags", "path", path, "error", err)
+		}
+		file.Close()
+	}
+	if song.Title != "" {
+		song.Name = song.Title
+	}
+
+	if dec, err := OpenDecoder(path, l.logger); err == nil {
+		song.Duration = dec.Duration()
+		dec.Close()
+	} else {
+		l.logger.Debug("Failed to probe duration", "path", path, "error", err)
+	}
+
+	return song
+}
+
+// sortSongs 按目录排序，同一目录内按音轨号和文件名排序
+func sortSongs(songs []SongInfo) {
+	trackOrder := func(s SongInfo) int {
+		if s.Track > 0 {
+			return s.Track
+		}
+		return unknownTrackOrder
+	}
+	sort.SliceStable(songs, func(i, j int) bool {
+		di, dj := filepath.Dir(songs[i].Path), filepath.Dir(songs[j].Path)
+		if di != dj {
+			return di < dj
+		}
+		if ti, tj := trackOrder(songs[i]), trackOrder(songs[j]); ti != tj {
+			return ti < tj
+		}
+		return songs[i].Path < songs[j].Path
+	})
+}
+
+// loadIndex 读取索引，索引不存在、版本或根目录不匹配时返回空缓存
+func (l *Library) loadIndex() map[string]SongInfo {
+	cache := make(map[string]SongInfo)
+
+	data, err := os.ReadFile(l.indexPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.logger.Warn("Failed to read music index", "path", l.indexPath, "error", err)
+		}
+		return cache
+	}
+
+	var index libraryIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		l.logger.Warn("Invalid music index, rebuilding", "path", l.indexPath, "error", err)
+		return cache
+	}
+	if index.Version != indexVersion || index.Root != l.root {
+		return cache
+	}
+
+	for _, song := range index.Songs {
+		cache[song.Path] = song
+	}
+	l.logger.Info("Music index loaded", "path", l.indexPath, "count", len(cache))
+	return cache
+}
+
+// saveIndex 原子地写入索引
+func (l *Library) saveIndex(songs []SongInfo) error {
+	data, err := json.MarshalIndent(libraryIndex{
+		Version: indexVersion,
+		Root:    l.root,
+		Songs:   songs,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.indexPath), 0755); err != nil {
+		return err
+	}
+	tmp := l.indexPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.indexPath)
+}
+
+// Watch 监听曲库目录变化，变化后增量重新扫描并回调
+func (l *Library) Watch(onChange func([]SongInfo)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create music watcher: %w", err)
+	}
+
+	l.scanMu.Lock()
+	if l.watcher != nil {
+		l.scanMu.Unlock()
+		watcher.Close()
+		return errors.New("music library is already being watched")
+	}
+	l.watcher = watcher
+	l.done = make(chan struct{})
+	l.scanMu.Unlock()
+
+	// fsnotify 不支持递归监听，逐个添加子目录
+	err = filepath.WalkDir(l.root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			if path != l.root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			l.watchDir(path)
+		}
+		return nil
+	})
+	if err != nil {
+		l.logger.Warn("Failed to walk music directory for watching", "error", err)
+	}
+
+	go l.watchLoop(watcher, l.done, onChange)
+	l.logger.Info("Watching music library", "path", l.root)
+	return nil
+}
+
+// watchDir 将目录加入监听（未启用监听时忽略）
+func (l *Library) watchDir(path string) {
+	if l.watcher == nil {
+		return
+	}
+	if err := l.watcher.Add(path); err != nil {
+		l.logger.Debug("Failed to watch music directory", "path", path, "error", err)
+	}
+}
+
+// watchLoop 合并文件事件后重新扫描
+func (l *Library) watchLoop(watcher *fsnotify.Watcher, done chan struct{}, onChange func([]SongInfo)) {
+	var timer *time.Timer
+	var timerC <-chan time.Time
+
+	for {
+		select {
+		case <-done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			l.logger.Debug("Music library changed", "event", event.String())
+			if timer == nil {
+				timer = time.NewTimer(rescanDebounce)
+			} else {
+				timer.Reset(rescanDebounce)
+			}
+			timerC = timer.C
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			l.logger.Warn("Music watcher error", "error", err)
+		case <-timerC:
+			timerC = nil
+			songs, err := l.Scan()
+			if err != nil {
+				l.logger.Warn("Failed to rescan music library", "error", err)
+				continue
+			}
+			if onChange != nil {
+				onChange(songs)
+			}
+		}
+	}
+}
+
+// MeasureLoudness 为没有 ReplayGain 标签的歌曲估算响度，结果缓存到索引
+// 逐首在后台执行，直到没有待测歌曲或曲库关闭；onMeasured 在每首测量完成后回调
+func (l *Library) MeasureLoudness(onMeasured func(SongInfo)) {
+	start := time.Now()
+	measured := 0
+	for {
+		select {
+		case <-l.closed:
+			return
+		default:
+		}
+
+		path, pending := l.nextUnmeasured()
+		if path == "" {
+			break
+		}
+		if measured == 0 {
+			l.logger.Info("Measuring music loudness", "pending", pending)
+		}
+
+		loudness, err := MeasureLoudness(path, l.logger)
+
+		l.scanMu.Lock()
+		song, ok := l.cache[path]
+		switch {
+		case !ok:
+			// 测量期间文件已被移除
+		case err != nil && !errors.Is(err, ErrNoAudio):
+			l.logger.Debug("Failed to measure loudness", "path", path, "error", err)
+			if l.unmeasurable == nil {
+				l.unmeasurable = make(map[string]bool)
+			}
+			l.unmeasurable[path] = true
+			ok = false
+		case err != nil:
+			// 静音或过短，不调整音量
+			song.Gain = &ReplayGain{Source: GainSourceMeasured}
+		default:
+			song.Gain = &ReplayGain{TrackGain: ReferenceLoudness - loudness, Loudness: loudness, Source: GainSourceMeasured}
+		}
+		if ok {
+			l.cache[path] = song
+			measured++
+			if measured%20 == 0 {
+				l.saveCacheLocked()
+			}
+		}
+		l.scanMu.Unlock()
+
+		if ok && onMeasured != nil {
+			onMeasured(song)
+		}
+	}
+
+	if measured > 0 {
+		l.scanMu.Lock()
+		l.saveCacheLocked()
+		l.scanMu.Unlock()
+		l.logger.Info("Music loudness measured", "count", measured, "elapsed", time.Since(start))
+	}
+}
+
+// nextUnmeasured 返回下一首待测歌曲和待测总数
+func (l *Library) nextUnmeasured() (string, int) {
+	l.scanMu.Lock()
+	defer l.scanMu.Unlock()
+
+	next, pending := "", 0
+	for path, song := range l.cache {
+		if song.Gain != nil || l.unmeasurable[path] {
+			continue
+		}
+		if next == "" || path < next {
+			next = path
+		}
+		pending++
+	}
+	return next, pending
+}
+
+// saveCacheLocked 将缓存写入索引
+func (l *Library) saveCacheLocked() {
+	songs := make([]SongInfo, 0, len(l.cache))
+	for _, song := range l.cache {
+		songs = append(songs, song)
+	}
+	sortSongs(songs)
+	if err := l.saveIndex(songs); err != nil {
+		l.logger.Warn("Failed to save music index", "path", l.indexPath, "error", err)
+	}
+}
+
+// Close 停止监听和响度测量
+func (l *Library) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+
+	l.scanMu.Lock()
+	defer l.scanMu.Unlock()
+
+	if l.watcher == nil {
+		return nil
+	}
+	close(l.done)
+	err := l.watcher.Close()
+	l.watcher = nil
+	return err
+}
+
+// Cover 读取歌曲内嵌的封面图片，返回图片数据和 MIME 类型
+func Cover(path string) ([]byte, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	m, err := tag.ReadFrom(file)
+	if err != nil {
+		return nil, "", err
+	}
+	picture := m.Picture()
+	if picture == nil || len(picture.Data) == 0 {
+		return nil, "", errors.New("no embedded cover art")
+	}
+	return picture.Data, picture.MIMEType, nil
+}
+
+// coverFileNames 目录中的封面文件名（不含扩展名，按优先级排列）
+var coverFileNames = []string{"cover", "folder", "front", "album"}
+
+// FindCover 查找歌曲封面：优先使用内嵌封面，其次是同目录的 cover/folder/front/album 图片（jpg/png）
+func FindCover(path string) ([]byte, error) {
+	if data, _, err := Cover(path); err == nil {
+		return data, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range coverFileNames {
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if entry.IsDir() || (ext != ".jpg" && ext != ".jpeg" && ext != ".png") {
+				continue
+			}
+			if strings.EqualFold(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())), name) {
+				return os.ReadFile(filepath.Join(filepath.Dir(path), entry.Name()))
+			}
+		}
+	}
+	return nil, errors.New("no cover art")
+}
@@ -0,0 +1,279 @@
+package music
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhowden/tag"
+	"github.com/lisuiheng/xiaozhi-go/audio"
+)
+
+// 响度测量参数（ITU-R BS.1770 / EBU R128）
+const (
+	ReferenceLoudness = -18.0 // ReplayGain 2.0 的参考响度（LUFS）
+
+	loudnessBlock       = 400 * time.Millisecond // 门限块长度
+	loudnessStep        = 100 * time.Millisecond // 块之间的步长（75% 重叠）
+	loudnessAbsGate     = -70.0                  // 绝对门限（LUFS）
+	loudnessRelGate     = -10.0                  // 相对门限（LU）
+	loudnessSegments    = 12                     // 估算时均匀抽取的片段数
+	loudnessSegmentLen  = 5 * time.Second        // 每个片段的长度
+	loudnessMaxAnalyze  = 10 * time.Minute       // 不能定位时最多分析的时长
+	r128ToReplayGainRef = 5.0                    // R128 以 -23 LUFS 为参考，比 ReplayGain 低 5 dB
+)
+
+// ReplayGain 音量标准化信息
+type ReplayGain struct {
+	TrackGain float64 `json:"track_gain"`           // dB
+	TrackPeak float64 `json:"track_peak,omitempty"` // 线性峰值，未知时为 0
+	AlbumGain float64 `json:"album_gain,omitempty"`
+	AlbumPeak float64 `json:"album_peak,omitempty"`
+	HasAlbum  bool    `json:"has_album,omitempty"`
+	Loudness  float64 `json:"loudness,omitempty"` // 估算的积分响度（LUFS），静音时为 0
+	Source    string  `json:"source"`             // "tag"：来自标签；"measured"：扫描时估算
+}
+
+// 增益来源
+const (
+	GainSourceTag      = "tag"
+	GainSourceMeasured = "measured"
+)
+
+// ErrNoAudio 没有足够的音频用于测量响度（如静音）
+var ErrNoAudio = errors.New("not enough audio to measure loudness")
+
+// readReplayGain 从标签读取 ReplayGain（ID3 TXXX、Vorbis 注释、MP4 自定义字段）或 Opus 的 R128 增益
+func readReplayGain(m tag.Metadata) *ReplayGain {
+	rg := &ReplayGain{Source: GainSourceTag}
+	hasTrack := false
+
+	for key, value := range m.Raw() {
+		var text string
+		switch v := value.(type) {
+		case *tag.Comm:
+			key, text = v.Description, v.Text
+		case string:
+			text = v
+		case []string:
+			text = strings.Join(v, "")
+		default:
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "replaygain_track_gain":
+			if gain, ok := parseGainDB(text); ok {
+				rg.TrackGain, hasTrack = gain, true
+			}
+		case "replaygain_track_peak":
+			rg.TrackPeak, _ = strconv.ParseFloat(strings.TrimSpace(text), 64)
+		case "replaygain_album_gain":
+			if gain, ok := parseGainDB(text); ok {
+				rg.AlbumGain, rg.HasAlbum = gain, true
+			}
+		case "replaygain_album_peak":
+			rg.AlbumPeak, _ = strconv.ParseFloat(strings.TrimSpace(text), 64)
+		case "r128_track_gain":
+			// Q7.8 定点数，相对 -23 LUFS
+			if q, err := strconv.Atoi(strings.TrimSpace(text)); err == nil {
+				rg.TrackGain, hasTrack = float64(q)/256+r128ToReplayGainRef, true
+			}
+		case "r128_album_gain":
+			if q, err := strconv.Atoi(strings.TrimSpace(text)); err == nil {
+				rg.AlbumGain, rg.HasAlbum = float64(q)/256+r128ToReplayGainRef, true
+			}
+		}
+	}
+
+	if !hasTrack {
+		if !rg.HasAlbum {
+			return nil
+		}
+		// 只有专辑增益时也用于单曲模式
+		rg.TrackGain, rg.TrackPeak = rg.AlbumGain, rg.AlbumPeak
+	}
+	return rg
+}
+
+// parseGainDB 解析 "-6.50 dB" 形式的增益
+func parseGainDB(text string) (float64, bool) {
+	text = strings.TrimSpace(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(text)), "db"))
+	gain, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(gain) || math.IsInf(gain, 0) {
+		return 0, false
+	}
+	return gain, true
+}
+
+// MeasureLoudness 估算文件的积分响度（LUFS）
+// 可以定位的长曲目只均匀抽取若干片段分析，以缩短扫描曲库的时间
+func MeasureLoudness(path string, logger *slog.Logger) (float64, error) {
+	dec, err := OpenDecoder(path, logger)
+	if err != nil {
+		return 0, err
+	}
+	defer dec.Close()
+
+	meter := newLoudnessMeter(dec.SampleRate(), dec.Channels())
+	duration := dec.Duration()
+
+	sampled := duration > 2*loudnessSegments*loudnessSegmentLen
+	if sampled {
+		for i := 0; i < loudnessSegments; i++ {
+			start := duration*time.Duration(2*i+1)/(2*loudnessSegments) - loudnessSegmentLen/2
+			if err := dec.Seek(start); err != nil {
+				if i == 0 {
+					// 不支持定位，改为从头分析
+					sampled = false
+					break
+				}
+				return 0, err
+			}
+			meter.startSegment()
+			if err := meter.feed(dec, loudnessSegmentLen); err != nil && err != io.EOF {
+				return 0, err
+			}
+		}
+	}
+	if !sampled {
+		meter.startSegment()
+		if err := meter.feed(dec, loudnessMaxAnalyze); err != nil && err != io.EOF {
+			return 0, err
+		}
+	}
+
+	return meter.integrated()
+}
+
+// loudnessMeter K 加权后按 400ms 块计算均方，门限后得到积分响度
+type loudnessMeter struct {
+	rate     int
+	channels int // 参与计算的声道数（最多 2 个，各声道权重为 1）
+	filters  [][2]audio.Biquad
+
+	stepFrames int
+	stepSum    float64 // 当前 100ms 子块的平方和（各声道相加）
+	stepCount  int
+	steps      []float64 // 当前片段最近的子块均方
+	blocks     []float64 // 所有 400ms 块的均方
+}
+
+func newLoudnessMeter(rate, channels int) *loudnessMeter {
+	m := &loudnessMeter{
+		rate:       rate,
+		channels:   min(channels, 2),
+		stepFrames: int(int64(rate) * int64(loudnessStep) / int64(time.Second)),
+	}
+
+	// K 加权：高频搁架 + RLB 高通，按 libebur128 的方法换算到任意采样率
+	fs := float64(rate)
+	f0, g, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, g/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	shelf := audio.NewBiquad(vh+vb*k/q+k*k, 2*(k*k-vh), vh-vb*k/q+k*k, 1+k/q+k*k, 2*(k*k-1), 1-k/q+k*k)
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	highpass := audio.NewBiquad(1, -2, 1, 1+k/q+k*k, 2*(k*k-1), 1-k/q+k*k)
+
+	m.filters = make([][2]audio.Biquad, m.channels)
+	for ch := range m.filters {
+		m.filters[ch] = [2]audio.Biquad{shelf, highpass}
+	}
+	return m
+}
+
+// startSegment 开始一个新片段，片段之间的块不重叠
+func (m *loudnessMeter) startSegment() {
+	for ch := range m.filters {
+		m.filters[ch][0].Reset()
+		m.filters[ch][1].Reset()
+	}
+	m.stepSum, m.stepCount = 0, 0
+	m.steps = m.steps[:0]
+}
+
+// feed 读取最多 limit 时长的音频
+func (m *loudnessMeter) feed(dec Decoder, limit time.Duration) error {
+	channels := dec.Channels()
+	remaining := int64(m.rate) * int64(limit) / int64(time.Second)
+	buf := make([]int16, 4096*channels)
+	blockSteps := int(loudnessBlock / loudnessStep)
+
+	for remaining > 0 {
+		n, err := dec.Read(buf)
+		frames := n / channels
+		if int64(frames) > remaining {
+			frames = int(remaining)
+		}
+		for i := 0; i < frames; i++ {
+			for ch := 0; ch < m.channels; ch++ {
+				x := float64(buf[i*channels+ch]) / 32768.0
+				y := m.filters[ch][1].Process(m.filters[ch][0].Process(x))
+				m.stepSum += y * y
+			}
+			m.stepCount++
+			if m.stepCount == m.stepFrames {
+				m.steps = append(m.steps, m.stepSum/float64(m.stepFrames))
+				m.stepSum, m.stepCount = 0, 0
+				if len(m.steps) >= blockSteps {
+					var sum float64
+					for _, s := range m.steps[len(m.steps)-blockSteps:] {
+						sum += s
+					}
+					m.blocks = append(m.blocks, sum/float64(blockSteps))
+				}
+			}
+		}
+		remaining -= int64(frames)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrNoProgress
+		}
+	}
+	return nil
+}
+
+// integrated 先用绝对门限，再用比平均响度低 10 LU 的相对门限，返回剩余块的平均响度
+func (m *loudnessMeter) integrated() (float64, error) {
+	gated := func(threshold float64) (float64, int) {
+		var sum float64
+		count := 0
+		for _, z := range m.blocks {
+			if blockLoudness(z) > threshold {
+				sum += z
+				count++
+			}
+		}
+		if count == 0 {
+			return 0, 0
+		}
+		return sum / float64(count), count
+	}
+
+	mean, count := gated(loudnessAbsGate)
+	if count == 0 {
+		return 0, ErrNoAudio
+	}
+	mean, count = gated(blockLoudness(mean) + loudnessRelGate)
+	if count == 0 {
+		return 0, ErrNoAudio
+	}
+	return blockLoudness(mean), nil
+}
+
+// blockLoudness 均方换算为 LUFS
+func blockLoudness(z float64) float64 {
+	if z <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(z)
+}
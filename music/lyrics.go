@@ -0,0 +1,252 @@
+package music
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dhowden/tag"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// 歌词参数
+const (
+	lyricsExtension  = ".lrc"
+	lyricsScrollTime = 400 * time.Millisecond // 切换到下一行前的滚动时长
+)
+
+// ErrNoLyrics 歌曲没有歌词
+var ErrNoLyrics = errors.New("no lyrics found")
+
+var (
+	lrcTimeTag = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	lrcMetaTag = regexp.MustCompile(`^\[([a-zA-Z]+):(.*)\]$`)
+	lrcWordTag = regexp.MustCompile(`<\d+:\d{1,2}(?:[.:]\d{1,3})?>`) // 增强格式的逐字时间
+)
+
+// LyricLine 一行歌词
+type LyricLine struct {
+	Time time.Duration `json:"time"`
+	Text string        `json:"text"`
+}
+
+// Lyrics 歌曲歌词，Synced 为 false 时没有时间信息（如不带时间的内嵌歌词）
+type Lyrics struct {
+	Lines  []LyricLine
+	Synced bool
+	Source string // 歌词文件路径，内嵌歌词为 "embedded"
+}
+
+// LoadLyrics 加载歌曲的歌词：优先同目录同名的 .lrc 文件，其次是内嵌的 USLT 等歌词标签
+func LoadLyrics(songPath string) (*Lyrics, error) {
+	if IsStream(songPath) {
+		return nil, ErrNoLyrics
+	}
+
+	if lrcPath := findLyricsFile(songPath); lrcPath != "" {
+		data, err := os.ReadFile(lrcPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lyrics: %w", err)
+		}
+		lyrics := ParseLRC(decodeLyricsText(data))
+		if len(lyrics.Lines) > 0 {
+			lyrics.Source = lrcPath
+			return lyrics, nil
+		}
+	}
+
+	file, err := os.Open(songPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	m, err := tag.ReadFrom(file)
+	if err != nil || strings.TrimSpace(m.Lyrics()) == "" {
+		return nil, ErrNoLyrics
+	}
+	lyrics := ParseLRC(m.Lyrics())
+	if len(lyrics.Lines) == 0 {
+		return nil, ErrNoLyrics
+	}
+	lyrics.Source = "embedded"
+	return lyrics, nil
+}
+
+// findLyricsFile 查找同目录下同名的 .lrc 文件（扩展名不区分大小写）
+func findLyricsFile(songPath string) string {
+	dir := filepath.Dir(songPath)
+	want := strings.TrimSuffix(filepath.Base(songPath), filepath.Ext(songPath)) + lyricsExtension
+
+	candidate := filepath.Join(dir, want)
+	if _, err := os.Stat(candidate); err == nil {
+		return candidate
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(entry.Name(), want) {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	return ""
+}
+
+// decodeLyricsText 去掉 BOM，非 UTF-8 的歌词按 GB18030（兼容 GBK）解码
+func decodeLyricsText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	if decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data); err == nil {
+		return string(decoded)
+	}
+	return string(data)
+}
+
+// ParseLRC 解析 LRC 歌词，支持一行多个时间标签、[offset:] 和增强格式的逐字时间
+// 没有时间标签时按行返回不同步的歌词
+func ParseLRC(text string) *Lyrics {
+	var synced, plain []LyricLine
+	var offset time.Duration
+
+	for _, raw := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+
+		var times []time.Duration
+		for {
+			match := lrcTimeTag.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			times = append(times, parseLRCTime(match[1], match[2], match[3]))
+			line = line[len(match[0]):]
+		}
+
+		if len(times) == 0 {
+			if meta := lrcMetaTag.FindStringSubmatch(line); meta != nil {
+				if strings.EqualFold(meta[1], "offset") {
+					if ms, err := strconv.Atoi(strings.TrimSpace(meta[2])); err == nil {
+						offset = time.Duration(ms) * time.Millisecond
+					}
+				}
+				continue
+			}
+			plain = append(plain, LyricLine{Text: line})
+			continue
+		}
+
+		// 空文本的时间标签保留，用于在间奏时清空显示
+		text := strings.TrimSpace(lrcWordTag.ReplaceAllString(line, ""))
+		for _, t := range times {
+			synced = append(synced, LyricLine{Time: t, Text: text})
+		}
+	}
+
+	if len(synced) == 0 {
+		return &Lyrics{Lines: plain}
+	}
+
+	// 正的 offset 表示歌词提前显示
+	for i := range synced {
+		synced[i].Time -= offset
+		if synced[i].Time < 0 {
+			synced[i].Time = 0
+		}
+	}
+	sort.SliceStable(synced, func(i, j int) bool { return synced[i].Time < synced[j].Time })
+	return &Lyrics{Lines: synced, Synced: true}
+}
+
+// parseLRCTime 解析 mm:ss.xx，小数部分按位数换算（.5 = 500ms，.05 = 50ms）
+func parseLRCTime(min, sec, frac string) time.Duration {
+	m, _ := strconv.Atoi(min)
+	s, _ := strconv.Atoi(sec)
+	d := time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if frac != "" {
+		f, _ := strconv.Atoi(frac)
+		for i := len(frac); i < 3; i++ {
+			f *= 10
+		}
+		d += time.Duration(f) * time.Millisecond
+	}
+	return d
+}
+
+// Index 返回 pos 时刻正在显示的行，第一行之前或不同步时返回 -1
+func (l *Lyrics) Index(pos time.Duration) int {
+	if !l.Synced {
+		return -1
+	}
+	return sort.Search(len(l.Lines), func(i int) bool { return l.Lines[i].Time > pos }) - 1
+}
+
+// Window 返回 pos 时刻的当前行和下一行，以及切换到下一行前的滚动进度（0.0-1.0）
+func (l *Lyrics) Window(pos time.Duration) (current, next string, scroll float64) {
+	i := l.Index(pos)
+	if !l.Synced {
+		return "", "", 0
+	}
+	if i >= 0 {
+		current = l.Lines[i].Text
+	}
+	if i+1 < len(l.Lines) {
+		next = l.Lines[i+1].Text
+		if remaining := l.Lines[i+1].Time - pos; remaining < lyricsScrollTime {
+			scroll = 1 - float64(remaining)/float64(lyricsScrollTime)
+		}
+	}
+	return current, next, scroll
+}
+
+// Text 全部歌词文本，每行一句，跳过空行和重复的相邻行
+func (l *Lyrics) Text() string {
+	var lines []string
+	for _, line := range l.Lines {
+		if line.Text == "" || (len(lines) > 0 && lines[len(lines)-1] == line.Text) {
+			continue
+		}
+		lines = append(lines, line.Text)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CurrentLyrics 当前歌曲的歌词，按歌曲路径缓存
+func (p *Player) CurrentLyrics() (*Lyrics, error) {
+	song := p.GetCurrentSong()
+	if song == nil {
+		return nil, errors.New("no song is playing")
+	}
+
+	p.mu.Lock()
+	if p.lyricsPath == song.Path {
+		lyrics, err := p.lyrics, p.lyricsErr
+		p.mu.Unlock()
+		return lyrics, err
+	}
+	p.mu.Unlock()
+
+	lyrics, err := LoadLyrics(song.Path)
+	if err != nil && !errors.Is(err, ErrNoLyrics) {
+		p.logger.Warn("Failed to load lyrics", "song", song.Name, "error", err)
+	} else if err == nil {
+		p.logger.Info("Lyrics loaded", "song", song.Name, "source", lyrics.Source, "lines", len(lyrics.Lines), "synced", lyrics.Synced)
+	}
+
+	p.mu.Lock()
+	p.lyricsPath, p.lyrics, p.lyricsErr = song.Path, lyrics, err
+	p.mu.Unlock()
+	return lyrics, err
+}
@@ -0,0 +1,173 @@
+package music
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func ms(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+
+func TestParseLRC(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		wantSynced bool
+		want       []LyricLine
+	}{
+		{
+			name: "basic with metadata",
+			text: "[ti:歌名]\n[ar:歌手]\n[00:01.00]第一行\n[00:03.50]第二行\n",
+			want: []LyricLine{
+				{Time: ms(1000), Text: "第一行"},
+				{Time: ms(3500), Text: "第二行"},
+			},
+			wantSynced: true,
+		},
+		{
+			name: "fraction digits",
+			text: "[00:01.5]a\n[00:02.05]b\n[00:03.123]c\n[00:04:20]d\n[01:02]e",
+			want: []LyricLine{
+				{Time: ms(1500), Text: "a"},
+				{Time: ms(2050), Text: "b"},
+				{Time: ms(3123), Text: "c"},
+				{Time: ms(4200), Text: "d"},
+				{Time: ms(62000), Text: "e"},
+			},
+			wantSynced: true,
+		},
+		{
+			name: "multiple timestamps per line sorted",
+			text: "[00:10.00][00:30.00]副歌\r\n[00:20.00]主歌\r\n",
+			want: []LyricLine{
+				{Time: ms(10000), Text: "副歌"},
+				{Time: ms(20000), Text: "主歌"},
+				{Time: ms(30000), Text: "副歌"},
+			},
+			wantSynced: true,
+		},
+		{
+			name: "positive offset shows lyrics earlier",
+			text: "[offset:500]\n[00:00.20]开头\n[00:02.00]第二行",
+			want: []LyricLine{
+				{Time: 0, Text: "开头"},
+				{Time: ms(1500), Text: "第二行"},
+			},
+			wantSynced: true,
+		},
+		{
+			name: "negative offset applies to earlier lines",
+			text: "[00:01.00]a\n[offset: -250]\n",
+			want: []LyricLine{
+				{Time: ms(1250), Text: "a"},
+			},
+			wantSynced: true,
+		},
+		{
+			name: "enhanced word tags and empty interlude",
+			text: "[00:01.00]<00:01.00>逐<00:01.30>字\n[00:05.00]\n",
+			want: []LyricLine{
+				{Time: ms(1000), Text: "逐字"},
+				{Time: ms(5000), Text: ""},
+			},
+			wantSynced: true,
+		},
+		{
+			name: "unsynced fallback",
+			text: "第一行\n\n  第二行  \n[ar:歌手]\n",
+			want: []LyricLine{
+				{Text: "第一行"},
+				{Text: "第二行"},
+			},
+		},
+		{
+			name: "empty",
+			text: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseLRC(tt.text)
+			if got.Synced != tt.wantSynced {
+				t.Errorf("Synced = %v, want %v", got.Synced, tt.wantSynced)
+			}
+			if !reflect.DeepEqual(got.Lines, tt.want) {
+				t.Errorf("Lines = %+v, want %+v", got.Lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestLyricsIndexAndWindow(t *testing.T) {
+	lyrics := ParseLRC("[00:01.00]a\n[00:03.00]b\n[00:05.00]c")
+
+	for _, tt := range []struct {
+		pos  time.Duration
+		want int
+	}{
+		{0, -1}, {ms(999), -1}, {ms(1000), 0}, {ms(2999), 0}, {ms(3000), 1}, {ms(9000), 2},
+	} {
+		if got := lyrics.Index(tt.pos); got != tt.want {
+			t.Errorf("Index(%v) = %d, want %d", tt.pos, got, tt.want)
+		}
+	}
+
+	current, next, scroll := lyrics.Window(ms(2800))
+	if current != "a" || next != "b" || scroll != 0.5 {
+		t.Errorf("Window(2.8s) = %q, %q, %v; want a, b, 0.5", current, next, scroll)
+	}
+	current, next, scroll = lyrics.Window(ms(6000))
+	if current != "c" || next != "" || scroll != 0 {
+		t.Errorf("Window(6s) = %q, %q, %v; want c, \"\", 0", current, next, scroll)
+	}
+
+	plain := ParseLRC("a\nb")
+	if plain.Index(ms(5000)) != -1 {
+		t.Error("unsynced lyrics should not have a current line")
+	}
+}
+
+func TestLyricsText(t *testing.T) {
+	lyrics := ParseLRC("[00:01.00]副歌\n[00:02.00]副歌\n[00:03.00]\n[00:04.00]尾声")
+	if got, want := lyrics.Text(), "副歌\n尾声"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
+
+func TestLoadLyricsFile(t *testing.T) {
+	dir := t.TempDir()
+	song := filepath.Join(dir, "歌曲.mp3")
+	if err := os.WriteFile(song, []byte("not audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有歌词文件，也没有可读的标签
+	if _, err := LoadLyrics(song); err != ErrNoLyrics {
+		t.Fatalf("LoadLyrics without lrc = %v, want ErrNoLyrics", err)
+	}
+
+	// 扩展名大小写不同、GBK 编码的歌词文件
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte("[00:01.00]你好"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lrc := filepath.Join(dir, "歌曲.LRC")
+	if err := os.WriteFile(lrc, gbk, 0644); err != nil {
+		t.Fatal(err)
+	}
+	lyrics, err := LoadLyrics(song)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lyrics.Source != lrc || !lyrics.Synced || len(lyrics.Lines) != 1 || lyrics.Lines[0].Text != "你好" {
+		t.Errorf("LoadLyrics = %+v", lyrics)
+	}
+
+	if _, err := LoadLyrics("http://example.com/live"); err != ErrNoLyrics {
+		t.Errorf("LoadLyrics(stream) = %v, want ErrNoLyrics", err)
+	}
+}
@@ -0,0 +1,379 @@
+package music
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 可移动存储参数
+const (
+	mediaPollInterval = 2 * time.Second // 检查挂载点变化的间隔
+	mountsFile        = "/proc/self/mounts"
+)
+
+// DefaultMediaRoots 默认监听的挂载根目录
+var DefaultMediaRoots = []string{"/media", "/run/media"}
+
+// MediaSource 已加入曲库的可移动存储（U 盘、SD 卡等）
+type MediaSource struct {
+	Path  string // 挂载点
+	Label string // 显示名称，取挂载点目录名
+	Songs int
+}
+
+// MediaEvent 可移动存储插入或移除
+type MediaEvent struct {
+	Source  MediaSource
+	Removed bool
+}
+
+// mediaSource 一个挂载点的曲库
+type mediaSource struct {
+	label   string
+	library *Library
+	songs   []SongInfo
+}
+
+// mediaWatcher 定期检查挂载根目录
+type mediaWatcher struct {
+	roots    []string
+	onChange func(MediaEvent)
+	done     chan struct{}
+	settling map[string]bool // 上次检查时新出现的挂载点，再次出现时才扫描，避免在写入过程中扫描
+}
+
+// WatchMedia 监听挂载根目录，发现新的文件系统时扫描其中的音频文件并作为单独的来源加入曲库，
+// 卸载后移除；roots 为空时使用 DefaultMediaRoots，onChange 在每次插入或移除后回调
+func (p *Player) WatchMedia(roots []string, onChange func(MediaEvent)) error {
+	if len(roots) == 0 {
+		roots = DefaultMediaRoots
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mediaWatcher != nil {
+		return errors.New("removable media is already being watched")
+	}
+	w := &mediaWatcher{
+		roots:    roots,
+		onChange: onChange,
+		done:     make(chan struct{}),
+		settling: make(map[string]bool),
+	}
+	p.mediaWatcher = w
+
+	go p.mediaLoop(w)
+	p.logger.Info("Watching removable media", "roots", roots)
+	return nil
+}
+
+// MediaSources 当前已加入曲库的可移动存储
+func (p *Player) MediaSources() []MediaSource {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sources := make([]MediaSource, 0, len(p.media))
+	for path, src := range p.media {
+		sources = append(sources, MediaSource{Path: path, Label: src.label, Songs: len(src.songs)})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Path < sources[j].Path })
+	return sources
+}
+
+// stopMediaWatcher 停止监听可移动存储
+func (p *Player) stopMediaWatcher() {
+	p.mu.Lock()
+	w := p.mediaWatcher
+	p.mediaWatcher = nil
+	p.mu.Unlock()
+	if w != nil {
+		close(w.done)
+	}
+}
+
+// mediaLoop 比较前后两次的挂载点，扫描新插入的存储并移除已卸载的存储
+func (p *Player) mediaLoop(w *mediaWatcher) {
+	ticker := time.NewTicker(mediaPollInterval)
+	defer ticker.Stop()
+
+	for {
+		current := make(map[string]bool)
+		for _, path := range mountedMedia(w.roots, p.musicPath) {
+			current[path] = true
+		}
+
+		p.mu.Lock()
+		var removed []string
+		for path := range p.media {
+			if !current[path] {
+				removed = append(removed, path)
+			}
+		}
+		p.mu.Unlock()
+
+		for _, path := range removed {
+			if src, ok := p.removeMedia(path); ok && w.onChange != nil {
+				w.onChange(MediaEvent{Source: src, Removed: true})
+			}
+		}
+		settling := make(map[string]bool)
+		for path := range current {
+			if p.hasMedia(path) {
+				continue
+			}
+			if !w.settling[path] {
+				settling[path] = true
+				continue
+			}
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+			if src, ok := p.addMedia(path); ok && w.onChange != nil {
+				w.onChange(MediaEvent{Source: src})
+			}
+		}
+		w.settling = settling
+
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hasMedia 挂载点是否已加入曲库
+func (p *Player) hasMedia(path string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.media[path]
+	return ok
+}
+
+// addMedia 扫描挂载点并加入曲库，扫描期间已被卸载时放弃
+func (p *Player) addMedia(path string) (MediaSource, bool) {
+	label := filepath.Base(path)
+	library := NewLibrary(path, p.supportedFormats, p.mediaIndexPath(path), p.logger)
+	songs, err := library.Scan()
+	if err != nil {
+		p.logger.Warn("Failed to scan removable media", "path", path, "error", err)
+		return MediaSource{}, false
+	}
+	if !isMediaMounted(path) {
+		return MediaSource{}, false
+	}
+	for i := range songs {
+		songs[i].Source = label
+	}
+
+	p.mu.Lock()
+	if p.media == nil {
+		p.media = make(map[string]*mediaSource)
+	}
+	p.media[path] = &mediaSource{label: label, library: library, songs: songs}
+	p.rebuildSongsLocked()
+	p.mu.Unlock()
+
+	// 插入后拷入或删除的歌曲
+	if err := library.Watch(func(songs []SongInfo) { p.updateMedia(path, library, songs) }); err != nil {
+		p.logger.Warn("Failed to watch removable media", "path", path, "error", err)
+	}
+
+	p.logger.Info("Removable media added", "path", path, "label", label, "count", len(songs))
+	return MediaSource{Path: path, Label: label, Songs: len(songs)}, true
+}
+
+// updateMedia 存储中的文件变化后更新其歌曲
+func (p *Player) updateMedia(path string, library *Library, songs []SongInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	src, ok := p.media[path]
+	if !ok || src.library != library {
+		return
+	}
+	for i := range songs {
+		songs[i].Source = src.label
+	}
+	src.songs = songs
+	p.rebuildSongsLocked()
+	p.logger.Info("Removable media updated", "path", path, "count", len(songs))
+}
+
+// removeMedia 从曲库移除挂载点的歌曲，正在播放其中的歌曲时停止播放
+func (p *Player) removeMedia(path string) (MediaSource, bool) {
+	p.mu.Lock()
+	src, ok := p.media[path]
+	if !ok {
+		p.mu.Unlock()
+		return MediaSource{}, false
+	}
+
+	playingFrom := p.playing && p.radio == nil &&
+		p.currentIndex >= 0 && p.currentIndex < len(p.songs) &&
+		withinDir(p.songs[p.currentIndex].Path, path)
+
+	delete(p.media, path)
+	queue := p.queue[:0:0]
+	for _, song := range p.queue {
+		if !withinDir(song.Path, path) {
+			queue = append(queue, song)
+		}
+	}
+	p.queue = queue
+	p.rebuildSongsLocked()
+	p.mu.Unlock()
+
+	src.library.Close()
+	if playingFrom {
+		p.logger.Info("Current song was on removed media, stopping")
+		p.Stop()
+	}
+
+	p.logger.Info("Removable media removed", "path", path, "label", src.label, "count", len(src.songs))
+	return MediaSource{Path: path, Label: src.label, Songs: len(src.songs)}, true
+}
+
+// rebuildSongsLocked 合并本地曲库和各可移动存储的歌曲，可移动存储按挂载点排在后面
+func (p *Player) rebuildSongsLocked() {
+	paths := make([]string, 0, len(p.media))
+	total := len(p.librarySongs)
+	for path, src := range p.media {
+		paths = append(paths, path)
+		total += len(src.songs)
+	}
+	sort.Strings(paths)
+
+	songs := make([]SongInfo, 0, total)
+	songs = append(songs, p.librarySongs...)
+	for _, path := range paths {
+		songs = append(songs, p.media[path].songs...)
+	}
+	p.replaceSongsLocked(songs)
+}
+
+// mediaIndexPath 每个挂载点单独的索引文件，与主索引放在同一目录下
+func (p *Player) mediaIndexPath(path string) string {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return filepath.Join(filepath.Dir(p.library.indexPath), "media", fmt.Sprintf("%016x.json", h.Sum64()))
+}
+
+// mountedMedia 列出挂载根目录下的存储：/proc/self/mounts 中位于根目录之下的挂载点，
+// 以及根目录下非空的直接子目录（不含包含挂载点的上级目录，如 /run/media/<用户>）；
+// 与曲库目录重叠的路径已由曲库本身扫描，不计入
+func mountedMedia(roots []string, musicPath string) []string {
+	mounts := readMountPoints()
+	found := make(map[string]bool)
+
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		for _, mount := range mounts {
+			if mount != root && withinDir(mount, root) {
+				found[mount] = true
+			}
+		}
+
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(root, entry.Name())
+			if found[path] || !isMediaMounted(path) {
+				continue
+			}
+			parent := false
+			for _, mount := range mounts {
+				if mount != path && withinDir(mount, path) {
+					parent = true
+					break
+				}
+			}
+			if !parent {
+				found[path] = true
+			}
+		}
+	}
+
+	var paths []string
+	for path := range found {
+		if musicPath != "" {
+			music := filepath.Clean(musicPath)
+			if withinDir(path, music) || withinDir(music, path) {
+				continue
+			}
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// readMountPoints 读取当前的挂载点，非 Linux 系统返回空
+func readMountPoints() []string {
+	f, err := os.Open(mountsFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var mounts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 {
+			mounts = append(mounts, unescapeMount(fields[1]))
+		}
+	}
+	return mounts
+}
+
+// unescapeMount 还原 /proc/mounts 中的八进制转义（如空格为 \040）
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// isMediaMounted 挂载点存在且非空（卸载后通常留下空目录或被删除）
+func isMediaMounted(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	names, _ := f.Readdirnames(1)
+	return len(names) > 0
+}
+
+// withinDir path 是否为 dir 本身或位于 dir 之下
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,190 @@
+package music
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("not audio"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMountedMedia(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, filepath.Join(root, "usb"), "a.mp3")
+	writeFiles(t, filepath.Join(root, "sd card"), "b.flac")
+	writeFiles(t, filepath.Join(root, ".hidden"), "c.mp3")
+	writeFiles(t, filepath.Join(root, "music"), "d.mp3")
+	writeFiles(t, root, "file.mp3")
+	if err := os.Mkdir(filepath.Join(root, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := mountedMedia([]string{root, filepath.Join(root, "missing")}, filepath.Join(root, "music"))
+	want := []string{filepath.Join(root, "sd card"), filepath.Join(root, "usb")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mountedMedia = %q, want %q", got, want)
+	}
+
+	// 曲库目录本身位于挂载根目录之上时，其下的存储都不计入
+	if got := mountedMedia([]string{root}, root); len(got) != 0 {
+		t.Errorf("mountedMedia under music path = %q, want none", got)
+	}
+}
+
+func TestUnescapeMount(t *testing.T) {
+	tests := map[string]string{
+		"/media/usb":             "/media/usb",
+		`/media/SD\040Card`:      "/media/SD Card",
+		`/media/a\011b\134c`:     "/media/a\tb\\c",
+		`/media/trailing\04`:     `/media/trailing\04`,
+		`/media/\344\270\255文`:   "/media/中文",
+		`/run/media/user/bad\9x`: `/run/media/user/bad\9x`,
+	}
+	for in, want := range tests {
+		if got := unescapeMount(in); got != want {
+			t.Errorf("unescapeMount(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWithinDir(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"/media/usb", "/media/usb", true},
+		{"/media/usb/a.mp3", "/media/usb", true},
+		{"/media/usb2", "/media/usb", false},
+		{"/media", "/media/usb", false},
+		{"/media/..usb/a", "/media", true},
+	}
+	for _, tt := range tests {
+		if got := withinDir(tt.path, tt.dir); got != tt.want {
+			t.Errorf("withinDir(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestWatchMedia(t *testing.T) {
+	if testing.Short() {
+		t.Skip("polls the mount roots")
+	}
+	root := t.TempDir()
+	musicDir := t.TempDir()
+	writeFiles(t, musicDir, "local.mp3")
+
+	p := NewPlayer(musicDir, []string{".mp3", ".flac"}, testLogger())
+	p.SetIndexPath(filepath.Join(t.TempDir(), "index.json"))
+	p.SetPlaylistPath(filepath.Join(t.TempDir(), "playlists.json"))
+	if err := p.LoadSongs(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	events := make(chan MediaEvent, 4)
+	if err := p.WatchMedia([]string{root}, func(ev MediaEvent) { events <- ev }); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.WatchMedia([]string{root}, nil); err == nil {
+		t.Error("second WatchMedia should fail")
+	}
+
+	wait := func() MediaEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(4*mediaPollInterval + 2*time.Second):
+			t.Fatal("timed out waiting for media event")
+			return MediaEvent{}
+		}
+	}
+
+	usb := filepath.Join(root, "usb")
+	writeFiles(t, usb, "a.mp3", "b.flac", "cover.jpg")
+
+	ev := wait()
+	want := MediaEvent{Source: MediaSource{Path: usb, Label: "usb", Songs: 2}}
+	if ev != want {
+		t.Fatalf("add event = %+v, want %+v", ev, want)
+	}
+	if got := p.MediaSources(); !reflect.DeepEqual(got, []MediaSource{want.Source}) {
+		t.Errorf("MediaSources = %+v", got)
+	}
+	songs := p.GetSongs()
+	if len(songs) != 3 || songs[0].Path != filepath.Join(musicDir, "local.mp3") {
+		t.Fatalf("songs after add = %+v", songs)
+	}
+	for _, song := range songs[1:] {
+		if song.Source != "usb" || !withinDir(song.Path, usb) {
+			t.Errorf("media song = %+v", song)
+		}
+	}
+
+	if err := os.RemoveAll(usb); err != nil {
+		t.Fatal(err)
+	}
+	ev = wait()
+	want.Removed = true
+	if ev != want {
+		t.Fatalf("remove event = %+v, want %+v", ev, want)
+	}
+	if got := p.MediaSources(); len(got) != 0 {
+		t.Errorf("MediaSources after remove = %+v", got)
+	}
+	if songs := p.GetSongs(); len(songs) != 1 {
+		t.Errorf("songs after remove = %+v", songs)
+	}
+}
+
+func TestReplaceSongsKeepsHistory(t *testing.T) {
+	p := NewPlayer(t.TempDir(), nil, testLogger())
+	song := func(name string) SongInfo { return SongInfo{Name: name, Path: "/music/" + name + ".mp3"} }
+
+	p.setSongs([]SongInfo{song("a"), song("b"), song("c"), song("d")})
+	p.mu.Lock()
+	p.currentIndex = 2
+	p.history = []int{0, 1, 3, 2}
+	p.shuffleBag = []int{3, 1}
+	p.mu.Unlock()
+
+	// a、d 被删除，新增 e；b、c 的索引前移
+	p.setSongs([]SongInfo{song("b"), song("c"), song("e")})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.currentIndex != 1 {
+		t.Errorf("currentIndex = %d, want 1", p.currentIndex)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(p.history, want) {
+		t.Errorf("history = %v, want %v", p.history, want)
+	}
+	bag := slices.Clone(p.shuffleBag)
+	sort.Ints(bag)
+	if want := []int{0, 2}; !reflect.DeepEqual(bag, want) {
+		t.Errorf("shuffle bag = %v, want %v (b kept, e added)", p.shuffleBag, want)
+	}
+
+	// 当前歌曲被删除
+	p.replaceSongsLocked([]SongInfo{song("e")})
+	if p.currentIndex != -1 {
+		t.Errorf("currentIndex after removal = %d, want -1", p.currentIndex)
+	}
+	if len(p.history) != 0 {
+		t.Errorf("history after removal = %v, want empty", p.history)
+	}
+}
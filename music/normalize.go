@@ -0,0 +1,189 @@
+package music
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Normalization 音量标准化模式
+type Normalization string
+
+const (
+	NormalizationOff   Normalization = "off"
+	NormalizationTrack Normalization = "track" // 每首歌调整到相同响度
+	NormalizationAlbum Normalization = "album" // 整张专辑统一调整，保留专辑内的响度差异
+)
+
+// 音量标准化参数
+const (
+	maxNormalizationGain = 12.0 // 最大提升（dB），避免把很安静的曲目噪声放大太多
+	limiterThreshold     = 0.97 // 限幅器阈值（满幅的比例）
+	limiterRelease       = 100 * time.Millisecond
+)
+
+// ParseNormalization 解析音量标准化模式，空字符串表示关闭
+func ParseNormalization(s string) (Normalization, error) {
+	switch mode := Normalization(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return NormalizationOff, nil
+	case NormalizationOff, NormalizationTrack, NormalizationAlbum:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown normalization mode: %q", s)
+}
+
+// SetNormalization 设置音量标准化模式，开启时在后台为缺少 ReplayGain 标签的歌曲估算响度
+func (p *Player) SetNormalization(mode Normalization) error {
+	if _, err := ParseNormalization(string(mode)); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.normalization = mode
+	p.mu.Unlock()
+	p.logger.Info("Music normalization changed", "mode", mode)
+
+	p.measureLoudness()
+	return nil
+}
+
+// Normalization 当前音量标准化模式
+func (p *Player) Normalization() Normalization {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.normalization
+}
+
+// measureLoudness 后台测量缺少增益信息的歌曲，同一时间只有一个测量任务
+func (p *Player) measureLoudness() {
+	p.mu.Lock()
+	if p.normalization == NormalizationOff || p.measuring {
+		p.mu.Unlock()
+		return
+	}
+	p.measuring = true
+	library := p.library
+	p.mu.Unlock()
+
+	go func() {
+		library.MeasureLoudness(p.updateSong)
+		p.mu.Lock()
+		p.measuring = false
+		p.mu.Unlock()
+	}()
+}
+
+// updateSong 更新歌曲信息（如测量得到的增益），GetSongs 返回的切片可能仍在使用，写时复制
+func (p *Player) updateSong(song SongInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.indexOfLocked(song.Path)
+	if i < 0 {
+		return
+	}
+	songs := append([]SongInfo(nil), p.songs...)
+	songs[i] = song
+	p.songs = songs
+
+	for j := range p.librarySongs {
+		if p.librarySongs[j].Path == song.Path {
+			library := append([]SongInfo(nil), p.librarySongs...)
+			library[j] = song
+			p.librarySongs = library
+			break
+		}
+	}
+}
+
+// normalizationGain 按当前模式计算歌曲的线性增益，1.0 表示不调整
+func (p *Player) normalizationGain(song SongInfo) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 播放列表中的曲目可能不是最新的，以曲库中的信息为准
+	if i := p.indexOfLocked(song.Path); i >= 0 {
+		song = p.songs[i]
+	}
+	if p.normalization == NormalizationOff || song.Gain == nil {
+		return 1
+	}
+
+	gain, peak := song.Gain.TrackGain, song.Gain.TrackPeak
+	if p.normalization == NormalizationAlbum {
+		switch {
+		case song.Gain.HasAlbum:
+			gain, peak = song.Gain.AlbumGain, song.Gain.AlbumPeak
+		case song.Gain.Source == GainSourceMeasured && song.Album != "":
+			gain, peak = p.albumGainLocked(song), 0
+		}
+	}
+
+	linear := math.Pow(10, math.Min(gain, maxNormalizationGain)/20)
+	// ReplayGain 的防削波：增益后峰值不超过满幅
+	if peak > 0 && linear*peak > 1 {
+		linear = 1 / peak
+	}
+	return linear
+}
+
+// albumGainLocked 用同一目录下同一专辑各曲目的测量值（按能量平均）计算专辑增益
+func (p *Player) albumGainLocked(song SongInfo) float64 {
+	dir := filepath.Dir(song.Path)
+	var energy float64
+	count := 0
+	for _, s := range p.songs {
+		if s.Album != song.Album || s.Gain == nil || s.Gain.Loudness >= 0 || filepath.Dir(s.Path) != dir {
+			continue
+		}
+		energy += math.Pow(10, s.Gain.Loudness/10)
+		count++
+	}
+	if count == 0 {
+		return song.Gain.TrackGain
+	}
+	return ReferenceLoudness - 10*math.Log10(energy/float64(count))
+}
+
+// limiter 峰值限幅器：瞬时压低超过阈值的样本，之后按释放时间恢复
+type limiter struct {
+	channels int
+	env      float64 // 当前的增益衰减（1.0 为不衰减）
+	release  float64 // 每帧的恢复系数
+}
+
+func newLimiter(rate, channels int) *limiter {
+	return &limiter{
+		channels: channels,
+		env:      1,
+		release:  math.Exp(-1 / (limiterRelease.Seconds() * float64(rate))),
+	}
+}
+
+// process 原地施加增益并限幅
+func (l *limiter) process(pcm []int16, gain float64) {
+	for i := 0; i+l.channels <= len(pcm); i += l.channels {
+		peak := 0.0
+		for ch := 0; ch < l.channels; ch++ {
+			peak = math.Max(peak, math.Abs(float64(pcm[i+ch])*gain/32768))
+		}
+
+		target := 1.0
+		if peak > limiterThreshold {
+			target = limiterThreshold / peak
+		}
+		if target < l.env {
+			l.env = target
+		} else {
+			l.env = target + (l.env-target)*l.release
+		}
+
+		for ch := 0; ch < l.channels; ch++ {
+			v := float64(pcm[i+ch]) * gain * l.env
+			pcm[i+ch] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package music
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizationGain(t *testing.T) {
+	db := func(d float64) float64 { return math.Pow(10, d/20) }
+	tagged := &ReplayGain{TrackGain: -6, TrackPeak: 0.5, AlbumGain: -3, AlbumPeak: 0.5, HasAlbum: true, Source: GainSourceTag}
+
+	tests := []struct {
+		name string
+		mode Normalization
+		gain *ReplayGain
+		want float64
+	}{
+		{"off", NormalizationOff, tagged, 1},
+		{"no gain", NormalizationTrack, nil, 1},
+		{"track", NormalizationTrack, tagged, db(-6)},
+		{"album", NormalizationAlbum, tagged, db(-3)},
+		{"album without album gain", NormalizationAlbum, &ReplayGain{TrackGain: -4, Source: GainSourceTag}, db(-4)},
+		// 提升 6 dB 后峰值 0.8 会削波，增益限制为 1/0.8
+		{"track peak cap", NormalizationTrack, &ReplayGain{TrackGain: 6, TrackPeak: 0.8, Source: GainSourceTag}, 1 / 0.8},
+		{"album peak cap", NormalizationAlbum, &ReplayGain{TrackGain: 2, TrackPeak: 0.1, AlbumGain: 6, AlbumPeak: 0.9, HasAlbum: true, Source: GainSourceTag}, 1 / 0.9},
+		{"unknown peak", NormalizationTrack, &ReplayGain{TrackGain: 6, Source: GainSourceTag}, db(6)},
+		{"max boost", NormalizationTrack, &ReplayGain{TrackGain: 30, Source: GainSourceTag}, db(maxNormalizationGain)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPlayer(t.TempDir(), nil, testLogger())
+			song := SongInfo{Name: "song", Path: "/music/song.mp3", Gain: tt.gain}
+			p.setSongs([]SongInfo{song})
+			p.normalization = tt.mode
+
+			if got := p.normalizationGain(song); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("gain = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizationAlbumMeasured(t *testing.T) {
+	p := NewPlayer(t.TempDir(), nil, testLogger())
+	measured := func(path string, loudness float64) SongInfo {
+		return SongInfo{Name: path, Path: path, Album: "album", Gain: &ReplayGain{
+			TrackGain: ReferenceLoudness - loudness, Loudness: loudness, Source: GainSourceMeasured,
+		}}
+	}
+	// 两首 -12 LUFS 与 -24 LUFS 的曲目按能量平均约为 -14.74 LUFS
+	quiet, loud := measured("/music/a/quiet.mp3", -24), measured("/music/a/loud.mp3", -12)
+	p.setSongs([]SongInfo{quiet, loud, measured("/music/b/other.mp3", -40)})
+	p.normalization = NormalizationAlbum
+
+	album := ReferenceLoudness - 10*math.Log10((math.Pow(10, -2.4)+math.Pow(10, -1.2))/2)
+	want := math.Pow(10, album/20)
+	for _, song := range []SongInfo{quiet, loud} {
+		if got := p.normalizationGain(song); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s gain = %.4f, want album gain %.4f", song.Name, got, want)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	const rate, channels = 8000, 2
+	limit := limiterThreshold * 32768
+
+	// 满幅附近的正弦波提升 12 dB
+	pcm := make([]int16, rate*channels)
+	for i := 0; i < rate; i++ {
+		v := int16(30000 * math.Sin(2*math.Pi*440*float64(i)/rate))
+		pcm[i*channels], pcm[i*channels+1] = v, -v
+	}
+	newLimiter(rate, channels).process(pcm, 4)
+	for i, v := range pcm {
+		if math.Abs(float64(v)) > limit+1 {
+			t.Fatalf("sample %d = %d, above limiter threshold %.0f", i, v, limit)
+		}
+	}
+
+	// 低于阈值的信号不受影响
+	quiet := []int16{1000, -1000, 2000, -2000}
+	newLimiter(rate, channels).process(quiet, 2)
+	for i, want := range []int16{2000, -2000, 4000, -4000} {
+		if quiet[i] != want {
+			t.Errorf("quiet[%d] = %d, want %d", i, quiet[i], want)
+		}
+	}
+
+	// 峰值过后按释放时间逐渐恢复
+	l := newLimiter(rate, 1)
+	l.process([]int16{32000}, 4)
+	after := make([]int16, rate/2) // 五倍释放时间
+	for i := range after {
+		after[i] = 1000
+	}
+	l.process(after, 1)
+	if after[0] >= after[len(after)-1] || after[len(after)-1] < 990 {
+		t.Errorf("limiter release: %d … %d, want recovery towards 1000", after[0], after[len(after)-1])
+	}
+}
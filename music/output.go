@@ -0,0 +1,194 @@
+package music
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/audio"
+)
+
+// 输出参数
+const (
+	outputFrameDuration = 20                     // 每次提交给播放器的帧时长（毫秒）
+	outputLead          = 200 * time.Millisecond // 最多领先实际播放的时长，保证停止及时、可视化同步
+	outputOpenRetries   = 5                      // 打开设备的重试次数（语音模块可能尚未释放设备）
+	outputRetryDelay    = time.Second
+)
+
+// DeviceOpener 按格式打开播放设备，frameDuration 为每次提交的帧时长（毫秒）
+type DeviceOpener func(sampleRate, frameDuration, channels int, logger *slog.Logger) (audio.AudioPlayer, error)
+
+// NullOutput 丢弃所有样本的播放设备，播放进度仍按实时推进，用于测试和没有扬声器的环境
+func NullOutput(sampleRate, frameDuration, channels int, logger *slog.Logger) (audio.AudioPlayer, error) {
+	return nullPlayer{}, nil
+}
+
+type nullPlayer struct{}
+
+func (nullPlayer) Play([]int16) error { return nil }
+func (nullPlayer) Close() error       { return nil }
+
+// openPortAudio 默认的播放设备
+func openPortAudio(sampleRate, frameDuration, channels int, logger *slog.Logger) (audio.AudioPlayer, error) {
+	player, err := audio.NewPCMPlayer(sampleRate, frameDuration, channels, logger)
+	if err != nil {
+		return nil, err
+	}
+	return player, nil
+}
+
+// output 音乐输出，按解码格式打开播放设备，格式变化时重新打开
+type output struct {
+	logger     *slog.Logger
+	eq         *audio.Equalizer
+	openDevice DeviceOpener
+	player     audio.AudioPlayer
+	sampleRate int
+	channels   int
+
+	started time.Time // 当前连续播放的起点
+	written int64     // 自起点以来写入的帧数
+	pending []int16   // 不足一帧的样本，与下一次写入拼接，曲目之间不插入静音
+}
+
+func newOutput(eq *audio.Equalizer, logger *slog.Logger) *output {
+	return &output{eq: eq, logger: logger, openDevice: openPortAudio}
+}
+
+// open 确保播放设备以指定格式打开，设备忙时重试
+func (o *output) open(sampleRate, channels int, stopChan <-chan struct{}) error {
+	if o.player != nil && o.sampleRate == sampleRate && o.channels == channels {
+		return nil
+	}
+	if o.player != nil {
+		// 格式变化，先播完上一首剩余的样本
+		if err := o.finish(stopChan); err != nil {
+			o.logger.Warn("Failed to flush music output", "error", err)
+		}
+	}
+	o.close()
+
+	var lastErr error
+	for attempt := 0; attempt < outputOpenRetries; attempt++ {
+		if attempt > 0 {
+			o.logger.Info("Retrying music output", "attempt", attempt+1, "max", outputOpenRetries, "error", lastErr)
+			select {
+			case <-stopChan:
+				return nil
+			case <-time.After(outputRetryDelay):
+			}
+		}
+
+		player, err := o.openDevice(sampleRate, outputFrameDuration, channels, o.logger)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		o.player = player
+		o.sampleRate = sampleRate
+		o.channels = channels
+		o.resetClock()
+		o.logger.Info("Music output opened", "sample_rate", sampleRate, "channels", channels)
+		return nil
+	}
+	return fmt.Errorf("failed to open music output after %d attempts: %w", outputOpenRetries, lastErr)
+}
+
+// frameSamples 每次提交的样本数（所有声道）
+func (o *output) frameSamples() int {
+	return o.sampleRate * outputFrameDuration / 1000 * o.channels
+}
+
+// write 经均衡器原地处理后写入任意长度的 PCM，凑满一帧才提交给播放器，剩余样本留到下一次写入
+func (o *output) write(pcm []int16, stopChan <-chan struct{}) error {
+	if o.player == nil {
+		return fmt.Errorf("music output not open")
+	}
+
+	o.eq.Process(pcm, o.sampleRate, o.channels)
+	o.pending = append(o.pending, pcm...)
+	size := o.frameSamples()
+	for len(o.pending) >= size {
+		// 播放器异步消费，每帧使用新的缓冲
+		frame := append([]int16(nil), o.pending[:size]...)
+		o.pending = o.pending[:copy(o.pending, o.pending[size:])]
+		if err := o.submit(frame, stopChan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish 补齐静音写出剩余样本，并等待已缓冲的数据播完（播放结束或切换格式前）
+func (o *output) finish(stopChan <-chan struct{}) error {
+	if o.player == nil {
+		return nil
+	}
+	if len(o.pending) > 0 {
+		frame := make([]int16, o.frameSamples())
+		copy(frame, o.pending)
+		o.pending = o.pending[:0]
+		if err := o.submit(frame, stopChan); err != nil {
+			return err
+		}
+	}
+	select {
+	case <-stopChan:
+	case <-time.After(o.buffered()):
+	}
+	return nil
+}
+
+// submit 写入一帧，写入速度不超过实际播放速度
+// 播放器回调一次最多消费一个缓冲块，因此 pcm 长度必须为 frameSamples
+func (o *output) submit(pcm []int16, stopChan <-chan struct{}) error {
+	ahead := time.Duration(o.written)*time.Second/time.Duration(o.sampleRate) - time.Since(o.started)
+	if ahead > outputLead {
+		select {
+		case <-stopChan:
+			return nil
+		case <-time.After(ahead - outputLead):
+		}
+	} else if ahead < -outputLead {
+		// 发生了欠载（如解码卡顿），重新计时
+		o.resetClock()
+	}
+
+	if err := o.player.Play(pcm); err != nil {
+		return err
+	}
+	o.written += int64(len(pcm) / o.channels)
+	return nil
+}
+
+// buffered 已写入但尚未播放的时长
+func (o *output) buffered() time.Duration {
+	if o.player == nil {
+		return 0
+	}
+	ahead := time.Duration(o.written)*time.Second/time.Duration(o.sampleRate) - time.Since(o.started)
+	if ahead < 0 {
+		return 0
+	}
+	return ahead
+}
+
+// resetClock 重新开始计时（打开设备或暂停恢复后）
+func (o *output) resetClock() {
+	o.started = time.Now()
+	o.written = 0
+}
+
+// close 关闭播放设备
+func (o *output) close() {
+	if o.player == nil {
+		return
+	}
+	if err := o.player.Close(); err != nil {
+		o.logger.Warn("Failed to close music output", "error", err)
+	}
+	o.player = nil
+	o.pending = nil
+}
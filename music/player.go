@@ -1,88 +1,247 @@
 package music
 
 import (
-	"encoding/binary"
 	"fmt"
 	"io"
 	"log/slog"
-	"math"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"strings"
+	"math/rand"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/audio"
 )
 
 // Player 音乐播放器
 type Player struct {
 	musicPath        string
 	supportedFormats []string
+	library          *Library
 	songs            []SongInfo
 	currentIndex     int
 	playing          bool
 	paused           bool
 	stopChan         chan struct{}
-	cmd              *exec.Cmd
+	resumeChan       chan struct{} // 暂停期间有效，恢复或停止时关闭
+	pausedChan       chan struct{} // 请求暂停后播放循环停止写入时关闭，恢复或停止时也关闭
+	position         time.Duration // 当前歌曲的播放位置
+	duration         time.Duration // 当前歌曲的总时长，未知时为 0
+	seekTo           time.Duration // 待执行的定位目标
+	seekPending      bool
 	mu               sync.Mutex
 	logger           *slog.Logger
 
-	// 音频可视化
-	visualizeChan chan float64 // 音量级别通道 (0.0-1.0)
-	visualizeStop chan struct{}
-	audioData     []int16 // 当前音频数据
-	audioDataMu   sync.Mutex
+	// 播放模式与队列（见 queue.go）
+	mode       Mode
+	queue      []SongInfo
+	shuffleBag []int // 随机模式下本轮尚未播放的歌曲
+	history    []int // 最近播放的歌曲，用于上一首
+
+	// 网络电台（见 stream.go）
+	stations    []SongInfo
+	radio       *SongInfo // 正在播放的网络流，为 nil 时播放曲库
+	streamTitle string    // 网络流元数据中的当前节目/歌曲
+	onStreamEnd func(SongInfo)
+
+	// 播放列表（见 playlist.go）
+	playlists *playlistStore
+	playlist  *activePlaylist // 正在播放的列表，为 nil 时在整个曲库中切歌
+
+	// 播放记录（见 state.go）
+	state             *playState
+	resumeMinDuration time.Duration // 不短于该时长的曲目自动从上次位置继续
+
+	// 音量标准化（见 normalize.go）
+	normalization Normalization
+	measuring     bool // 后台正在测量响度
+
+	// 可移动存储（见 media.go），songs 为本地曲库与各存储的歌曲合并后的列表
+	librarySongs []SongInfo
+	media        map[string]*mediaSource // 按挂载点
+	mediaWatcher *mediaWatcher
+
+	// 交叉淡化（见 crossfade.go），为 0 时曲目之间无缝衔接
+	crossfade time.Duration
+
+	// 睡眠定时（见 sleep.go）
+	sleepTimer      *time.Timer
+	sleepAt         time.Time
+	sleepEndOfTrack bool
+
+	// 当前歌曲的歌词缓存（见 lyrics.go）
+	lyricsPath string
+	lyrics     *Lyrics
+	lyricsErr  error
+
+	searchIndex []searchEntry // 搜索用的拼音索引（见 search.go），歌曲列表变化时重建
+
+	// 音频输出，同一时间只有一个播放循环持有
+	out   *output
+	outMu sync.Mutex
+	eq    *audio.Equalizer // 音乐播放的均衡器，与语音播放分开设置
+
+	// 音频可视化（见 spectrum.go）
+	visualizeChan chan []float64 // 频谱帧通道，每帧为各频带能量 (0.0-1.0)
+	spectrumBands int
 }
 
-// SongInfo 歌曲信息
-type SongInfo struct {
-	Path string
-	Name string
+// 播放状态
+const (
+	StatePlaying = "playing"
+	StatePaused  = "paused"
+	StateStopped = "stopped"
+)
+
+// pauseTimeout 暂停时等待播放循环停止写入的最长时间
+const pauseTimeout = time.Second
+
+// Status 播放状态快照
+type Status struct {
+	Song        *SongInfo
+	Index       int
+	State       string
+	Position    time.Duration
+	Duration    time.Duration
+	Radio       bool   // Song 为网络流
+	StreamTitle string // 网络流的当前节目/歌曲
 }
 
 // NewPlayer 创建新的音乐播放器
 func NewPlayer(musicPath string, supportedFormats []string, logger *slog.Logger) *Player {
+	eq := audio.NewEqualizer()
 	return &Player{
-		musicPath:        musicPath,
-		supportedFormats: supportedFormats,
-		currentIndex:     -1,
-		stopChan:         make(chan struct{}),
-		visualizeChan:    make(chan float64, 10),
-		visualizeStop:    make(chan struct{}),
-		logger:           logger,
+		musicPath:         musicPath,
+		supportedFormats:  supportedFormats,
+		library:           NewLibrary(musicPath, supportedFormats, "", logger),
+		currentIndex:      -1,
+		mode:              ModeSequential,
+		normalization:     NormalizationOff,
+		resumeMinDuration: DefaultResumeMinDuration,
+		stopChan:          make(chan struct{}),
+		visualizeChan:     make(chan []float64, 4),
+		spectrumBands:     DefaultSpectrumBands,
+		out:               newOutput(eq, logger),
+		eq:                eq,
+		logger:            logger,
 	}
 }
 
-// LoadSongs 加载音乐列表
-func (p *Player) LoadSongs() error {
+// SetIndexPath 设置曲库索引文件位置，需在 LoadSongs 之前调用
+func (p *Player) SetIndexPath(indexPath string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.library = NewLibrary(p.musicPath, p.supportedFormats, indexPath, p.logger)
+}
 
-	files, err := os.ReadDir(p.musicPath)
+// SetOutput 替换打开播放设备的方式（默认为 PortAudio 默认设备），在下一次打开设备时生效
+func (p *Player) SetOutput(open DeviceOpener) {
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	p.out.openDevice = open
+}
+
+// LoadSongs 递归扫描音乐目录并加载歌曲列表
+func (p *Player) LoadSongs() error {
+	p.mu.Lock()
+	library := p.library
+	p.mu.Unlock()
+
+	// 扫描可能较慢（首次需要解析标签），不持有播放器锁
+	songs, err := library.Scan()
 	if err != nil {
-		return fmt.Errorf("failed to read music directory: %w", err)
+		return err
 	}
+	p.setSongs(songs)
 
-	p.songs = []SongInfo{}
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
+	p.logger.Info("Music loaded", "count", len(songs), "path", p.musicPath)
+	p.measureLoudness()
+	return nil
+}
+
+// WatchLibrary 监听音乐目录，文件变化时自动更新歌曲列表
+func (p *Player) WatchLibrary() error {
+	p.mu.Lock()
+	library := p.library
+	p.mu.Unlock()
+
+	return library.Watch(func(songs []SongInfo) {
+		p.setSongs(songs)
+		p.logger.Info("Music library updated", "count", len(songs))
+		p.measureLoudness()
+	})
+}
+
+// setSongs 替换本地曲库的歌曲，与可移动存储的歌曲合并后作为歌曲列表
+func (p *Player) setSongs(songs []SongInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.librarySongs = songs
+	p.rebuildSongsLocked()
+}
+
+// replaceSongsLocked 替换歌曲列表，按路径保持当前歌曲、播放历史和随机模式本轮剩余歌曲的索引，
+// 已不存在的歌曲从中移除
+func (p *Player) replaceSongsLocked(songs []SongInfo) {
+	old := p.songs
+	current := ""
+	if p.currentIndex >= 0 && p.currentIndex < len(old) {
+		current = old[p.currentIndex].Path
+	}
 
-		ext := strings.ToLower(filepath.Ext(file.Name()))
-		for _, format := range p.supportedFormats {
-			if ext == format {
-				p.songs = append(p.songs, SongInfo{
-					Path: filepath.Join(p.musicPath, file.Name()),
-					Name: strings.TrimSuffix(file.Name(), ext),
-				})
-				break
+	p.songs = songs
+	p.searchIndex = nil
+	p.lyricsPath = "" // 目录变化可能新增了 .lrc 文件
+
+	byPath := make(map[string]int, len(songs))
+	for i, song := range songs {
+		byPath[song.Path] = i
+	}
+	remap := func(indices []int) []int {
+		kept := indices[:0:0]
+		for _, idx := range indices {
+			if idx < 0 || idx >= len(old) {
+				continue
+			}
+			if i, ok := byPath[old[idx].Path]; ok {
+				kept = append(kept, i)
 			}
 		}
+		return kept
+	}
+	p.history = remap(p.history)
+	if len(p.shuffleBag) > 0 {
+		bag := remap(p.shuffleBag)
+		// 新增的歌曲本轮还没有播放过，随机插入
+		known := make(map[string]bool, len(old))
+		for _, song := range old {
+			known[song.Path] = true
+		}
+		for _, idx := range p.scopeLocked() {
+			if !known[songs[idx].Path] {
+				bag = slices.Insert(bag, rand.Intn(len(bag)+1), idx)
+			}
+		}
+		p.shuffleBag = bag
 	}
 
-	p.logger.Info("Music loaded", "count", len(p.songs), "path", p.musicPath)
-	return nil
+	p.currentIndex = -1
+	if i, ok := byPath[current]; ok && current != "" {
+		p.currentIndex = i
+	}
+}
+
+// Close 停止播放并停止监听曲库
+func (p *Player) Close() error {
+	p.Stop()
+
+	p.stopMediaWatcher()
+	p.mu.Lock()
+	library := p.library
+	for _, src := range p.media {
+		src.library.Close()
+	}
+	p.mu.Unlock()
+	return library.Close()
 }
 
 // GetSongs 获取歌曲列表
@@ -97,6 +256,10 @@ func (p *Player) GetCurrentSong() *SongInfo {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	if p.radio != nil {
+		station := *p.radio
+		return &station
+	}
 	if p.currentIndex < 0 || p.currentIndex >= len(p.songs) {
 		return nil
 	}
@@ -117,37 +280,61 @@ func (p *Player) IsPaused() bool {
 	return p.paused
 }
 
-// GetVisualizeChannel 获取可视化数据通道
-func (p *Player) GetVisualizeChannel() <-chan float64 {
+// GetVisualizeChannel 获取频谱帧通道
+func (p *Player) GetVisualizeChannel() <-chan []float64 {
 	return p.visualizeChan
 }
 
+// SetSpectrumBands 设置频谱的频带数（即可视化的柱数），下一首开始生效
+func (p *Player) SetSpectrumBands(bands int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.spectrumBands = bands
+}
+
+// Equalizer 返回音乐播放使用的均衡器，修改后立即生效
+func (p *Player) Equalizer() *audio.Equalizer {
+	return p.eq
+}
+
 // Play 播放
 func (p *Player) Play() error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if len(p.songs) == 0 {
-		return fmt.Errorf("no songs available")
-	}
-
 	if p.playing && !p.paused {
 		return nil
 	}
 
 	if p.paused {
-		p.paused = false
+		p.resumeLocked()
 		return nil
 	}
 
+	// 上次播放的是电台时继续播放该电台
+	if p.radio != nil {
+		p.playing = true
+		p.stopChan = make(chan struct{})
+		go p.playLoop(p.stopChan)
+		p.logger.Info("Music started", "station", p.radio.Name)
+		return nil
+	}
+
+	if len(p.songs) == 0 {
+		return fmt.Errorf("no songs available")
+	}
+
 	if p.currentIndex < 0 {
-		p.currentIndex = 0
+		p.currentIndex = p.scopeLocked()[0]
+		if p.mode == ModeShuffle {
+			p.currentIndex = p.shuffleNextLocked()
+		}
 	}
 
 	p.playing = true
 	p.stopChan = make(chan struct{})
 
-	go p.playLoop()
+	go p.playLoop(p.stopChan)
 	p.logger.Info("Music started", "song", p.songs[p.currentIndex].Name)
 
 	return nil
@@ -155,6 +342,11 @@ func (p *Player) Play() error {
 
 // PlaySong 播放指定歌曲
 func (p *Player) PlaySong(index int) error {
+	return p.playSong(index, true, 0)
+}
+
+// playSong 从 start 位置开始播放指定歌曲，record 表示是否将当前歌曲记入播放历史
+func (p *Player) playSong(index int, record bool, start time.Duration) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -162,126 +354,286 @@ func (p *Player) PlaySong(index int) error {
 		return fmt.Errorf("invalid song index: %d", index)
 	}
 
+	if record && p.currentIndex >= 0 && p.currentIndex != index {
+		p.pushHistoryLocked(p.currentIndex)
+	}
+	p.radio = nil
+	p.streamTitle = ""
+
+	// 播放列表以外的歌曲结束列表播放
+	if p.playlist != nil && !p.playlist.paths[p.songs[index].Path] {
+		p.logger.Info("Leaving playlist", "name", p.playlist.name)
+		p.playlist = nil
+		p.shuffleBag = nil
+	}
+	p.removeFromShuffleLocked(index)
+
 	if p.playing {
 		close(p.stopChan)
-		if p.cmd != nil {
-			p.cmd.Process.Kill()
-		}
 	}
+	p.resumeLocked()
 
 	p.currentIndex = index
 	p.playing = true
-	p.paused = false
+	p.position = start
+	p.seekTo = start
+	p.seekPending = start > 0
 	p.stopChan = make(chan struct{})
 
 	song := p.songs[p.currentIndex]
 	p.logger.Info("PlaySong called", "index", index, "name", song.Name, "path", song.Path)
 
-	go p.playLoop()
+	go p.playLoop(p.stopChan)
 
 	return nil
 }
 
-// Pause 暂停
-func (p *Player) Pause() {
+// Pause 暂停，保留播放位置，返回暂停时的位置
+// 等待播放循环停止写入后返回，与之后 Position 和保存的播放记录一致
+func (p *Player) Pause() time.Duration {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	if !p.playing || p.paused {
+		defer p.mu.Unlock()
+		return p.position
+	}
+	p.paused = true
+	p.resumeChan = make(chan struct{})
+	paused := make(chan struct{})
+	p.pausedChan = paused
+	p.mu.Unlock()
 
-	if p.playing && !p.paused {
-		p.paused = true
-		p.logger.Info("Music paused")
+	// 播放循环正在打开设备或等待网络流时无法及时响应，超时后返回当前位置
+	select {
+	case <-paused:
+	case <-time.After(pauseTimeout):
 	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logger.Info("Music paused", "position", p.position)
+	return p.position
 }
 
-// Resume 恢复
+// Resume 从暂停位置继续播放
 func (p *Player) Resume() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	if p.playing && p.paused {
-		p.paused = false
-		p.logger.Info("Music resumed")
+		p.resumeLocked()
+		p.logger.Info("Music resumed", "position", p.position)
+	}
+}
+
+// TogglePause 切换暂停/播放状态，返回切换后是否暂停以及当前位置
+func (p *Player) TogglePause() (bool, time.Duration) {
+	if p.IsPaused() {
+		p.Resume()
+		return false, p.Position()
+	}
+	return true, p.Pause()
+}
+
+// Position 当前歌曲的播放位置
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position
+}
+
+// Duration 当前歌曲的总时长，未知时返回 0
+func (p *Player) Duration() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.duration
+}
+
+// Seek 定位到当前歌曲的指定位置，超出范围时截断，返回实际定位的位置
+// 暂停状态下定位会在恢复播放时生效
+func (p *Player) Seek(pos time.Duration) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.playing {
+		return 0, fmt.Errorf("no song is playing")
+	}
+	if p.radio != nil {
+		return 0, ErrNotSeekable
+	}
+
+	if pos < 0 {
+		pos = 0
+	}
+	if p.duration > 0 && pos > p.duration {
+		pos = p.duration
+	}
+
+	p.seekTo = pos
+	p.seekPending = true
+	p.position = pos
+	p.logger.Info("Music seek requested", "position", pos)
+	return pos, nil
+}
+
+// Status 返回当前播放状态
+func (p *Player) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := Status{
+		Index:    p.currentIndex,
+		State:    StateStopped,
+		Position: p.position,
+		Duration: p.duration,
 	}
+	if p.playing {
+		status.State = StatePlaying
+		if p.paused {
+			status.State = StatePaused
+		}
+	}
+	if p.radio != nil {
+		station := *p.radio
+		status.Song = &station
+		status.Index = -1
+		status.Radio = true
+		status.StreamTitle = p.streamTitle
+	} else if p.currentIndex >= 0 && p.currentIndex < len(p.songs) {
+		song := p.songs[p.currentIndex]
+		status.Song = &song
+	}
+	return status
 }
 
-// Stop 停止
+// resumeLocked 清除暂停状态并唤醒播放循环，调用方需持有 p.mu
+func (p *Player) resumeLocked() {
+	p.paused = false
+	if p.resumeChan != nil {
+		close(p.resumeChan)
+		p.resumeChan = nil
+	}
+	if p.pausedChan != nil {
+		close(p.pausedChan)
+		p.pausedChan = nil
+	}
+}
+
+// Stop 停止播放并取消睡眠定时
 func (p *Player) Stop() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.cancelSleepLocked()
 	if p.playing {
 		close(p.stopChan)
-		if p.cmd != nil {
-			p.cmd.Process.Kill()
-			p.cmd = nil
-		}
+		p.resumeLocked()
 		p.playing = false
-		p.paused = false
+		p.position = 0
+		p.seekPending = false
+		p.duration = 0
 		p.logger.Info("Music stopped")
 	}
 }
 
-// Next 下一首
+// Next 下一首：优先播放队列，否则按播放模式选择
 func (p *Player) Next() error {
 	p.mu.Lock()
-	songsLen := len(p.songs)
-	currentIdx := p.currentIndex
-	p.mu.Unlock()
-
-	if songsLen == 0 {
+	if station, ok := p.adjacentStationLocked(1); ok {
+		p.mu.Unlock()
+		return p.PlayStream(station)
+	}
+	if len(p.songs) == 0 {
+		p.mu.Unlock()
 		return fmt.Errorf("no songs available")
 	}
+	nextIdx, _ := p.nextIndexLocked(false)
+	p.mu.Unlock()
 
-	nextIdx := (currentIdx + 1) % songsLen
 	return p.PlaySong(nextIdx)
 }
 
-// Previous 上一首
+// Previous 上一首：优先回到播放历史中的上一首
 func (p *Player) Previous() error {
 	p.mu.Lock()
+	if station, ok := p.adjacentStationLocked(-1); ok {
+		p.mu.Unlock()
+		return p.PlayStream(station)
+	}
 	songsLen := len(p.songs)
-	currentIdx := p.currentIndex
-	p.mu.Unlock()
-
 	if songsLen == 0 {
+		p.mu.Unlock()
 		return fmt.Errorf("no songs available")
 	}
 
-	prevIdx := currentIdx - 1
+	prevIdx := -1
+	for len(p.history) > 0 && prevIdx < 0 {
+		last := p.history[len(p.history)-1]
+		p.history = p.history[:len(p.history)-1]
+		if last >= 0 && last < songsLen {
+			prevIdx = last
+		}
+	}
 	if prevIdx < 0 {
-		prevIdx = songsLen - 1
+		scope := p.scopeLocked()
+		pos := positionOf(scope, p.currentIndex) - 1
+		if pos < 0 {
+			pos = len(scope) - 1
+		}
+		prevIdx = scope[pos]
 	}
-	return p.PlaySong(prevIdx)
+	p.mu.Unlock()
+
+	return p.playSong(prevIdx, false, 0)
 }
 
 // playLoop 播放循环
-func (p *Player) playLoop() {
+func (p *Player) playLoop(stopChan chan struct{}) {
 	p.logger.Info("playLoop started")
+
+	// 等待上一个播放循环释放输出设备
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	defer p.out.close()
+
+	// 交叉淡化中尚未播完的上一首
+	var tail *fadeTail
+	defer func() {
+		if tail != nil {
+			tail.close()
+		}
+	}()
+
 	for {
 		select {
-		case <-p.stopChan:
+		case <-stopChan:
 			p.logger.Info("playLoop stopped by stopChan")
 			return
 		default:
 			p.mu.Lock()
-			if !p.playing || p.paused {
+			if !p.playing {
 				p.mu.Unlock()
-				p.logger.Info("playLoop exiting", "playing", p.playing, "paused", p.paused)
+				p.logger.Info("playLoop exiting", "playing", p.playing)
 				return
 			}
 
-			if p.currentIndex < 0 || p.currentIndex >= len(p.songs) {
+			var song SongInfo
+			radio := p.radio != nil
+			if radio {
+				song = *p.radio
+			} else if p.currentIndex >= 0 && p.currentIndex < len(p.songs) {
+				song = p.songs[p.currentIndex]
+			} else {
 				p.playing = false
 				p.mu.Unlock()
 				p.logger.Info("playLoop: invalid index, stopping")
 				return
 			}
-
-			song := p.songs[p.currentIndex]
 			p.mu.Unlock()
 
-			p.logger.Info("playLoop: calling playFileWithVisualize", "song", song.Name, "path", song.Path)
-			if err := p.playFileWithVisualize(song.Path); err != nil {
+			p.logger.Info("playLoop: playing file", "song", song.Name, "path", song.Path)
+			completed, next, err := p.playFile(song, radio, tail, stopChan)
+			tail = next
+			if err != nil {
 				p.logger.Warn("Failed to play song, stopping playback", "song", song.Name, "error", err)
 				// 播放失败时停止，不继续重试
 				p.mu.Lock()
@@ -291,328 +643,287 @@ func (p *Player) playLoop() {
 			}
 
 			p.mu.Lock()
-			if !p.playing {
+			if !p.playing || !completed {
+				p.mu.Unlock()
+				return
+			}
+			// 网络流结束后不切换到曲库
+			if radio {
+				p.playing = false
+				onEnd := p.onStreamEnd
+				p.mu.Unlock()
+				p.logger.Info("playLoop: stream ended", "station", song.Name)
+				p.finishOutput(tail, stopChan)
+				if onEnd != nil {
+					go onEnd(song)
+				}
+				return
+			}
+			// 睡眠定时设为播完当前曲目
+			if p.sleepEndOfTrack {
+				p.cancelSleepLocked()
+				p.playing = false
+				p.mu.Unlock()
+				p.logger.Info("playLoop: sleep timer reached end of track")
+				p.finishOutput(tail, stopChan)
+				return
+			}
+			// 按队列和播放模式选择下一首，没有下一首时结束播放并交还音频设备
+			nextIdx, ok := p.nextIndexLocked(true)
+			if !ok {
+				p.playing = false
 				p.mu.Unlock()
+				p.logger.Info("playLoop: reached end of playback", "mode", p.mode)
+				p.finishOutput(tail, stopChan)
 				return
 			}
-			p.currentIndex = (p.currentIndex + 1) % len(p.songs)
+			p.pushHistoryLocked(p.currentIndex)
+			p.currentIndex = nextIdx
+			p.position = 0
 			p.mu.Unlock()
 		}
 	}
 }
 
-// playFileWithVisualize 播放文件并发送可视化数据
-func (p *Player) playFileWithVisualize(filePath string) error {
-	ext := strings.ToLower(filepath.Ext(filePath))
-
-	p.logger.Info("playFileWithVisualize called", "filePath", filePath, "ext", ext)
-
-	// WAV 文件使用内部播放器以获取音频数据
-	if ext == ".wav" {
-		p.logger.Info("Playing WAV file with visualization")
-		return p.playWavWithVisualize(filePath)
-	}
-
-	// 其他格式使用外部播放器，发送模拟可视化数据
-	p.logger.Info("Playing with external player (MP3, etc)")
-	go p.sendSimulatedVisualize()
+// playFile 解码文件并写入音频输出，同时发送可视化数据
+// tail 为交叉淡化中的上一首，与本曲开头混合；本曲接近结尾且可以交叉淡化时提前返回，
+// 由 next 交给下一首继续淡出
+// 返回 completed 表示文件完整播放结束（而不是被停止）
+func (p *Player) playFile(song SongInfo, radio bool, tail *fadeTail, stopChan <-chan struct{}) (completed bool, next *fadeTail, err error) {
 	defer func() {
-		select {
-		case <-p.visualizeStop:
-		default:
-			close(p.visualizeStop)
-			p.visualizeStop = make(chan struct{})
+		if tail != nil {
+			tail.close()
 		}
 	}()
 
-	return p.playWithExternalPlayer(filePath)
-}
-
-// playWavWithVisualize 播放 WAV 文件并发送实时可视化数据
-func (p *Player) playWavWithVisualize(filePath string) error {
-	file, err := os.Open(filePath)
+	var dec Decoder
+	if IsStream(song.Path) {
+		dec, err = OpenStream(song.Path, stopChan, p.setStreamTitle, p.logger)
+	} else {
+		dec, err = OpenDecoder(song.Path, p.logger)
+	}
 	if err != nil {
-		return err
+		return false, nil, err
 	}
-	defer file.Close()
+	defer func() {
+		if next == nil {
+			dec.Close()
+		}
+	}()
 
-	// 跳过 WAV 头
-	if _, err = file.Seek(44, io.SeekStart); err != nil {
-		return err
+	// 播放设备最多支持立体声
+	channels := dec.Channels()
+	outChannels := channels
+	if outChannels > 2 {
+		outChannels = 2
 	}
 
-	// 打开音频设备
-	audioDev, err := os.OpenFile("/dev/snd/pcmC0D0p", os.O_WRONLY, 0)
-	if err != nil {
-		// 如果无法打开音频设备，回退到 aplay
-		return p.playWav(filePath)
+	// 格式不同无法混合，先播完上一首
+	if tail != nil && !tail.matches(dec.SampleRate(), outChannels) {
+		p.logger.Info("Track format changed, skipping crossfade", "song", song.Name)
+		if err := tail.drain(p.out, stopChan); err != nil {
+			p.logger.Warn("Failed to finish previous track", "song", tail.song.Name, "error", err)
+		}
+		tail.close()
+		tail = nil
 	}
-	defer audioDev.Close()
 
-	buf := make([]byte, 4096)
-	ticker := time.NewTicker(50 * time.Millisecond) // 20 FPS 可视化更新
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-p.stopChan:
-			return nil
-		case <-ticker.C:
-			// 计算音量级别并发送
-			p.audioDataMu.Lock()
-			level := p.calculateVolumeLevel(p.audioData)
-			p.audioDataMu.Unlock()
-
-			select {
-			case p.visualizeChan <- level:
-			default:
-			}
-		default:
-			n, err := file.Read(buf)
-			if err == io.EOF {
-				return nil
-			}
-			if err != nil {
-				return err
-			}
-
-			// 保存音频数据用于分析
-			sampleCount := n / 2
-			samples := make([]int16, sampleCount)
-			for i := 0; i < sampleCount; i++ {
-				samples[i] = int16(binary.LittleEndian.Uint16(buf[i*2:]))
-			}
+	if err := p.out.open(dec.SampleRate(), outChannels, stopChan); err != nil {
+		return false, nil, err
+	}
 
-			p.audioDataMu.Lock()
-			p.audioData = samples
-			p.audioDataMu.Unlock()
+	frame := make([]int16, p.out.frameSamples()/outChannels*channels)
+	lastVisualize := time.Time{}
+	var played int64 // 已写入的帧数
 
-			if _, err := audioDev.Write(buf[:n]); err != nil {
-				return err
-			}
-		}
-	}
-}
+	p.mu.Lock()
+	p.duration = dec.Duration()
+	spectrum := NewSpectrum(p.spectrumBands)
+	p.mu.Unlock()
 
-// calculateVolumeLevel 计算音量级别
-func (p *Player) calculateVolumeLevel(samples []int16) float64 {
-	if len(samples) == 0 {
-		return 0
+	// 音量标准化，提升音量时由限幅器防止削波
+	gain := 1.0
+	var fadeLen time.Duration
+	if !radio {
+		gain = p.normalizationGain(song)
+		fadeLen = p.crossfadeFor(dec.Duration())
 	}
+	lim := newLimiter(dec.SampleRate(), outChannels)
+	p.setPosition(0)
 
-	// 计算 RMS (均方根) 音量
-	var sum float64
-	for _, s := range samples {
-		val := float64(s) / 32768.0
-		sum += val * val
+	// 记录播放历史，长曲目从上次停止的位置继续；退出时保存位置
+	current := func() time.Duration {
+		return time.Duration(played)*time.Second/time.Duration(dec.SampleRate()) - p.out.buffered()
 	}
-	rms := math.Sqrt(sum / float64(len(samples)))
-
-	// 归一化到 0.0-1.0 范围
-	level := rms * 3.0 // 放大系数
-	if level > 1.0 {
-		level = 1.0
+	lastSaved := time.Now()
+	if !radio {
+		if resume := p.trackStarted(song, dec.Duration()); resume > 0 {
+			p.mu.Lock()
+			if !p.seekPending {
+				p.seekTo, p.seekPending = resume, true
+				p.logger.Info("Resuming from saved position", "song", song.Name, "position", resume)
+			}
+			p.mu.Unlock()
+		}
+		defer func() {
+			p.rememberPosition(song, current(), dec.Duration(), completed)
+		}()
 	}
 
-	return level
-}
-
-// sendSimulatedVisualize 发送模拟的可视化数据
-func (p *Player) sendSimulatedVisualize() {
-	ticker := time.NewTicker(50 * time.Millisecond)
-	defer ticker.Stop()
-
-	// 简单的模拟波形
-	phase := 0.0
 	for {
 		select {
-		case <-p.visualizeStop:
-			return
-		case <-ticker.C:
-			// 生成模拟音量级别
-			phase += 0.3
-			level := 0.3 + 0.4*math.Abs(math.Sin(phase)) + 0.3*math.Abs(math.Sin(phase*2.5))
+		case <-stopChan:
+			return false, nil, nil
+		default:
+		}
 
+		// 处理定位请求
+		p.mu.Lock()
+		seekTo, seek := p.seekTo, p.seekPending
+		p.seekPending = false
+		resume := p.resumeChan
+		p.mu.Unlock()
+		if seek {
+			if err := dec.Seek(seekTo); err != nil {
+				p.logger.Warn("Failed to seek", "position", seekTo, "error", err)
+			} else {
+				played = int64(seekTo) * int64(dec.SampleRate()) / int64(time.Second)
+				spectrum.Reset()
+			}
+		}
+
+		// 暂停：停止写入，设备播完已缓冲的数据后输出静音
+		// 恢复时从已写入的位置继续，因此暂停位置不扣除缓冲
+		if resume != nil {
+			pos := time.Duration(played) * time.Second / time.Duration(dec.SampleRate())
 			p.mu.Lock()
-			paused := p.paused
+			p.position = pos
+			if p.pausedChan != nil {
+				close(p.pausedChan)
+				p.pausedChan = nil
+			}
 			p.mu.Unlock()
-
-			if paused {
-				level = 0
+			if !radio {
+				p.rememberPosition(song, pos, dec.Duration(), false)
 			}
-
 			select {
-			case p.visualizeChan <- level:
+			case p.visualizeChan <- make([]float64, spectrum.Bands()):
 			default:
 			}
+			select {
+			case <-resume:
+				p.out.resetClock()
+			case <-stopChan:
+				return false, nil, nil
+			}
 		}
-	}
-}
-
-// playWithExternalPlayer 使用外部播放器
-func (p *Player) playWithExternalPlayer(filePath string) error {
-	ext := strings.ToLower(filepath.Ext(filePath))
-
-	p.logger.Info("playWithExternalPlayer called", "filePath", filePath, "ext", ext)
-
-	// 最多重试 5 次，每次等待 1 秒，给音频设备更多释放时间
-	maxRetries := 5
-	retryDelay := 1 * time.Second
 
-	for retry := 0; retry < maxRetries; retry++ {
-		if retry > 0 {
-			p.logger.Info("Retrying playback", "attempt", retry+1, "max", maxRetries)
-			time.Sleep(retryDelay)
+		// 接近结尾时开始交叉淡化，剩余部分交给下一首混合
+		if fadeLen > 0 && tail == nil {
+			remaining := dec.Duration() - time.Duration(played)*time.Second/time.Duration(dec.SampleRate())
+			if remaining <= fadeLen && p.crossfadeAllowed() {
+				p.logger.Info("Crossfading to next track", "song", song.Name, "duration", remaining)
+				return true, &fadeTail{
+					song:        song,
+					dec:         dec,
+					channels:    channels,
+					outChannels: outChannels,
+					gain:        gain,
+					lim:         lim,
+					total:       int64(remaining) * int64(dec.SampleRate()) / int64(time.Second),
+				}, nil
+			}
 		}
 
-		var err error
+		n, readErr := readFull(dec, frame)
+		if n > 0 {
+			pcm := audio.ConvertChannels(frame[:n], channels, outChannels)
+			if gain != 1 {
+				lim.process(pcm, gain)
+			}
+			if tail != nil {
+				if done, err := tail.mix(pcm); done {
+					if err != nil {
+						p.logger.Warn("Failed to decode previous track", "song", tail.song.Name, "error", err)
+					}
+					tail.close()
+					tail = nil
+				}
+			}
+			if fade := p.sleepGain(p.out.buffered()); fade < 1 {
+				applyGain(pcm, fade)
+			}
 
-		p.mu.Lock()
-		switch ext {
-		case ".mp3":
-			// 优先使用 ffplay
-			if _, err := exec.LookPath("ffplay"); err == nil {
-				p.cmd = exec.Command("ffplay", "-nodisp", "-autoexit", "-loglevel", "warning", filePath)
-				p.logger.Info("Using ffplay for MP3 playback")
-			} else {
-				// 尝试 ffmpeg + aplay
-				_, hasFfmpeg := exec.LookPath("ffmpeg")
-				_, hasAplay := exec.LookPath("aplay")
-				if hasFfmpeg == nil && hasAplay == nil {
-					p.mu.Unlock()
-					p.logger.Info("Using ffmpeg + aplay for MP3 playback")
-					return p.playMp3WithFFmpegAplay(filePath)
-				} else if _, err := exec.LookPath("mpg123"); err == nil {
-					p.cmd = exec.Command("mpg123", filePath)
-					p.logger.Info("Using mpg123 for MP3 playback")
-				} else if _, err := exec.LookPath("madplay"); err == nil {
-					p.cmd = exec.Command("madplay", filePath)
-					p.logger.Info("Using madplay for MP3 playback")
-				} else {
-					p.mu.Unlock()
-					return fmt.Errorf("no MP3 player found (need ffplay, ffmpeg+aplay, mpg123, or madplay)")
+			spectrum.Push(pcm, outChannels)
+			if time.Since(lastVisualize) >= visualizeInterval {
+				lastVisualize = time.Now()
+				select {
+				case p.visualizeChan <- spectrum.Analyze(dec.SampleRate()):
+				default:
 				}
 			}
-		default:
-			p.cmd = exec.Command("aplay", filePath)
-			p.logger.Info("Using aplay for playback")
-		}
-		p.mu.Unlock()
 
-		p.logger.Info("Starting player command")
+			if err := p.out.write(pcm, stopChan); err != nil {
+				return false, nil, err
+			}
+			played += int64(n / channels)
+			p.setPosition(current())
 
-		output, err := p.cmd.CombinedOutput()
-		// 打印播放器输出，无论成功与否
-		if len(output) > 0 {
-			p.logger.Info("Player output", "output", string(output))
-		}
-		if err != nil {
-			p.logger.Error("Player command failed", "error", err, "output", string(output))
-			// 检查输出中是否有设备忙碌错误，如果是则重试
-			outputStr := string(output)
-			if strings.Contains(outputStr, "Device or resource busy") ||
-				strings.Contains(outputStr, "Couldn't open audio device") ||
-				strings.Contains(outputStr, "audio open failed") {
-				p.logger.Warn("Audio device busy, will retry", "attempt", retry+1)
-				continue
+			if !radio && time.Since(lastSaved) >= stateSaveInterval {
+				lastSaved = time.Now()
+				p.rememberPosition(song, current(), dec.Duration(), false)
 			}
-			return fmt.Errorf("player failed: %w, output: %s", err, string(output))
 		}
 
-		p.logger.Info("Player command completed successfully")
-
-		// 播放完成后，更新播放状态
-		p.mu.Lock()
-		p.playing = false
-		p.mu.Unlock()
-
-		return nil
+		if readErr == io.EOF {
+			// 本曲比淡化时间还短，继续淡出上一首
+			if tail != nil {
+				if err := tail.fadeOut(p.out, stopChan); err != nil {
+					p.logger.Warn("Failed to finish previous track", "song", tail.song.Name, "error", err)
+				}
+			}
+			return true, nil, nil
+		}
+		if readErr != nil {
+			return false, nil, readErr
+		}
 	}
-
-	return fmt.Errorf("failed to play audio after %d retries: device busy", maxRetries)
 }
 
-// playMp3WithFFmpegAplay 使用 ffmpeg 解码并通过管道输出到 aplay
-func (p *Player) playMp3WithFFmpegAplay(filePath string) error {
-	// 创建 ffmpeg 命令（解码 MP3 为 WAV 格式输出到 stdout）
-	ffmpegCmd := exec.Command("ffmpeg",
-		"-i", filePath,
-		"-f", "wav",
-		"-acodec", "pcm_s16le",
-		"-ar", "24000",
-		"-ac", "1",
-		"-")
-
-	// 创建 aplay 命令（从 stdin 读取并播放）
-	aplayCmd := exec.Command("aplay", "-q", "-r", "24000", "-c", "1", "-f", "S16_LE")
-
-	// 设置管道：ffmpeg stdout -> aplay stdin
-	pipeReader, pipeWriter := io.Pipe()
-	ffmpegCmd.Stdout = pipeWriter
-	aplayCmd.Stdin = pipeReader
-
-	// 保存命令引用以便可以停止
-	p.mu.Lock()
-	p.cmd = aplayCmd
-	p.mu.Unlock()
-
-	p.logger.Info("Starting ffmpeg + aplay pipeline")
-
-	// 启动 ffmpeg
-	if err := ffmpegCmd.Start(); err != nil {
-		pipeReader.Close()
-		pipeWriter.Close()
-		return fmt.Errorf("failed to start ffmpeg: %w", err)
+// finishOutput 播放自然结束：播完尚未淡出的上一首和已缓冲的数据，再交还音频设备
+func (p *Player) finishOutput(tail *fadeTail, stopChan <-chan struct{}) {
+	if tail != nil {
+		if err := tail.drain(p.out, stopChan); err != nil {
+			p.logger.Warn("Failed to finish previous track", "song", tail.song.Name, "error", err)
+		}
 	}
-
-	// 启动 aplay
-	if err := aplayCmd.Start(); err != nil {
-		ffmpegCmd.Process.Kill()
-		pipeReader.Close()
-		pipeWriter.Close()
-		return fmt.Errorf("failed to start aplay: %w", err)
+	if err := p.out.finish(stopChan); err != nil {
+		p.logger.Warn("Failed to flush music output", "error", err)
 	}
+}
 
-	// 等待 ffmpeg 完成
-	ffmpegErr := ffmpegCmd.Wait()
-	pipeWriter.Close()
-
-	// 等待 aplay 完成
-	aplayErr := aplayCmd.Wait()
-	pipeReader.Close()
-
-	if ffmpegErr != nil {
-		return fmt.Errorf("ffmpeg failed: %w", ffmpegErr)
-	}
-	if aplayErr != nil {
-		return fmt.Errorf("aplay failed: %w", aplayErr)
+// setPosition 更新播放位置
+func (p *Player) setPosition(pos time.Duration) {
+	if pos < 0 {
+		pos = 0
 	}
-
-	p.logger.Info("ffmpeg + aplay pipeline completed successfully")
-
-	// 播放完成后，更新播放状态
 	p.mu.Lock()
-	p.playing = false
+	p.position = pos
 	p.mu.Unlock()
-
-	return nil
 }
 
-// playWav 使用 aplay 播放 WAV 文件
-func (p *Player) playWav(filePath string) error {
-	p.mu.Lock()
-	p.cmd = exec.Command("aplay", filePath)
-	p.mu.Unlock()
-
-	output, err := p.cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("aplay failed: %w, output: %s", err, string(output))
+// readFull 读取直到填满 buf 或解码结束，结束时返回已读样本数和 io.EOF
+func readFull(dec Decoder, buf []int16) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := dec.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n == 0 {
+			return total, io.ErrNoProgress
+		}
 	}
-
-	// 播放完成后，更新播放状态
-	p.mu.Lock()
-	p.playing = false
-	p.mu.Unlock()
-
-	return nil
+	return total, nil
 }
@@ -0,0 +1,87 @@
+package music
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSilentWAV 写入一段 8kHz 单声道静音
+func writeSilentWAV(t *testing.T, path string, d time.Duration) {
+	t.Helper()
+	const rate = 8000
+	size := int(d.Seconds()*rate) * 2
+	data := make([]byte, 44+size)
+	copy(data[0:], "RIFF")
+	binary.LittleEndian.PutUint32(data[4:], uint32(36+size))
+	copy(data[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(data[16:], 16)
+	binary.LittleEndian.PutUint16(data[20:], 1)
+	binary.LittleEndian.PutUint16(data[22:], 1)
+	binary.LittleEndian.PutUint32(data[24:], rate)
+	binary.LittleEndian.PutUint32(data[28:], rate*2)
+	binary.LittleEndian.PutUint16(data[32:], 2)
+	binary.LittleEndian.PutUint16(data[34:], 16)
+	copy(data[36:], "data")
+	binary.LittleEndian.PutUint32(data[40:], uint32(size))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPausePosition(t *testing.T) {
+	root := t.TempDir()
+	writeSilentWAV(t, filepath.Join(root, "book.wav"), time.Minute)
+
+	p := NewPlayer(root, []string{".wav"}, testLogger())
+	p.SetIndexPath(filepath.Join(t.TempDir(), "index.json"))
+	p.SetStatePath(filepath.Join(t.TempDir(), "music_state.json"))
+	p.SetOutput(NullOutput)
+	if err := p.LoadSongs(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		p.Close()
+		// 播放循环退出时异步保存位置，等它写完再删除临时目录
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	if err := p.PlaySong(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Seek(20 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Position() < 20*time.Second+300*time.Millisecond {
+		if time.Now().After(deadline) {
+			t.Fatalf("position stuck at %v", p.Position())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Pause 的返回值、之后的 Position 与保存的播放记录是同一个位置
+	paused := p.Pause()
+	if paused < 20*time.Second {
+		t.Fatalf("Pause = %v, want past the seek target", paused)
+	}
+	time.Sleep(outputLead + 100*time.Millisecond)
+	if pos := p.Position(); pos != paused {
+		t.Errorf("Position after pause = %v, want %v", pos, paused)
+	}
+	if recent := p.Recent(1); len(recent) != 1 || recent[0].Position != paused.Truncate(time.Second) {
+		t.Errorf("saved position = %+v, want %v", recent, paused.Truncate(time.Second))
+	}
+	if again := p.Pause(); again != paused {
+		t.Errorf("second Pause = %v, want %v", again, paused)
+	}
+
+	// 恢复后从暂停位置继续
+	p.Resume()
+	time.Sleep(100 * time.Millisecond)
+	if pos := p.Position(); pos < paused-outputLead || pos > paused+time.Second {
+		t.Errorf("Position after resume = %v, want near %v", pos, paused)
+	}
+}
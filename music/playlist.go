@@ -0,0 +1,510 @@
+package music
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 播放列表来源
+const (
+	PlaylistSourceFile = "file" // 音乐目录中的 M3U/M3U8/PLS 文件，只读
+	PlaylistSourceUser = "user" // 通过工具创建的播放列表，保存在 playlists.json
+)
+
+// playlistExtensions 识别为播放列表的文件扩展名
+var playlistExtensions = []string{".m3u", ".m3u8", ".pls"}
+
+// Playlist 播放列表
+type Playlist struct {
+	Name   string   `json:"name"`
+	Paths  []string `json:"paths"`
+	Source string   `json:"-"`
+}
+
+// PlaylistInfo 播放列表摘要
+type PlaylistInfo struct {
+	Name   string
+	Source string
+	Count  int // 曲库中存在的歌曲数
+}
+
+// DefaultPlaylistPath 默认的用户播放列表位置：用户配置目录下的 xiaozhi/playlists.json
+func DefaultPlaylistPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "xiaozhi", "playlists.json")
+}
+
+// isPlaylistFile 是否为播放列表文件
+func isPlaylistFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range playlistExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePlaylistFile 解析 M3U/M3U8/PLS 文件，相对路径以列表文件所在目录为基准
+// 网络地址原样保留，由调用方决定是否使用
+func ParsePlaylistFile(path string) (*Playlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pls := strings.EqualFold(filepath.Ext(path), ".pls")
+	dir := filepath.Dir(path)
+	type entry struct {
+		order int
+		path  string
+	}
+	var entries []entry
+
+	scanner := bufio.NewScanner(file)
+	for line := 0; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if line == 0 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+
+		order := line
+		if pls {
+			// FileN=路径，其余行（Title、Length、NumberOfEntries 等）忽略
+			key, value, ok := strings.Cut(text, "=")
+			if !ok || !strings.HasPrefix(strings.ToLower(key), "file") {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(key[len("file"):]))
+			if err != nil {
+				continue
+			}
+			order, text = n, strings.TrimSpace(value)
+		} else if strings.HasPrefix(text, "#") {
+			// #EXTM3U、#EXTINF 等扩展信息
+			continue
+		}
+
+		entries = append(entries, entry{order: order, path: resolvePlaylistEntry(dir, text)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].order < entries[j].order })
+	playlist := &Playlist{
+		Name:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Source: PlaylistSourceFile,
+	}
+	for _, e := range entries {
+		playlist.Paths = append(playlist.Paths, e.path)
+	}
+	return playlist, nil
+}
+
+// resolvePlaylistEntry 将列表项转换为本地路径，兼容 Windows 分隔符和 file:// 地址
+func resolvePlaylistEntry(dir, entry string) string {
+	if strings.HasPrefix(entry, "file://") {
+		return filepath.Clean(strings.TrimPrefix(entry, "file://"))
+	}
+	if strings.Contains(entry, "://") {
+		return entry
+	}
+	entry = filepath.FromSlash(strings.ReplaceAll(entry, "\\", "/"))
+	if filepath.IsAbs(entry) {
+		return filepath.Clean(entry)
+	}
+	return filepath.Join(dir, entry)
+}
+
+// playlistStore 用户播放列表的持久化存储
+type playlistStore struct {
+	path      string
+	playlists []*Playlist
+	loaded    bool
+}
+
+// load 首次使用时读取存储文件
+func (s *playlistStore) load() error {
+	if s.loaded {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read playlists: %w", err)
+	}
+	if len(data) > 0 {
+		var stored struct {
+			Playlists []*Playlist `json:"playlists"`
+		}
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("invalid playlists file: %w", err)
+		}
+		for _, pl := range stored.Playlists {
+			pl.Source = PlaylistSourceUser
+		}
+		s.playlists = stored.Playlists
+	}
+	s.loaded = true
+	return nil
+}
+
+// save 原子地写入存储文件
+func (s *playlistStore) save() error {
+	data, err := json.MarshalIndent(map[string]interface{}{"playlists": s.playlists}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// find 按名称查找（不区分大小写）
+func (s *playlistStore) find(name string) *Playlist {
+	for _, pl := range s.playlists {
+		if strings.EqualFold(pl.Name, name) {
+			return pl
+		}
+	}
+	return nil
+}
+
+// activePlaylist 正在播放的播放列表，下一首/上一首/随机只在列表内选择
+type activePlaylist struct {
+	name  string
+	paths map[string]bool
+	order []string
+}
+
+// SetPlaylistPath 设置用户播放列表的存储位置，需在使用播放列表之前调用
+func (p *Player) SetPlaylistPath(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.playlists = &playlistStore{path: path}
+}
+
+// Playlists 返回所有播放列表：用户创建的在前，音乐目录中的列表文件在后
+func (p *Player) Playlists() ([]PlaylistInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all, err := p.allPlaylistsLocked()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]PlaylistInfo, len(all))
+	for i, pl := range all {
+		infos[i] = PlaylistInfo{Name: pl.Name, Source: pl.Source, Count: len(p.resolveLocked(pl.Paths))}
+	}
+	return infos, nil
+}
+
+// PlaylistSongs 返回播放列表中曲库存在的歌曲
+func (p *Player) PlaylistSongs(name string) (string, []SongInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl, err := p.findPlaylistLocked(name)
+	if err != nil {
+		return "", nil, err
+	}
+	indices := p.resolveLocked(pl.Paths)
+	songs := make([]SongInfo, len(indices))
+	for i, idx := range indices {
+		songs[i] = p.songs[idx]
+	}
+	return pl.Name, songs, nil
+}
+
+// CreatePlaylist 创建用户播放列表
+func (p *Player) CreatePlaylist(name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("playlist name is required")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	all, err := p.allPlaylistsLocked()
+	if err != nil {
+		return err
+	}
+	for _, pl := range all {
+		if strings.EqualFold(pl.Name, name) {
+			return fmt.Errorf("playlist %q already exists", pl.Name)
+		}
+	}
+
+	p.playlists.playlists = append(p.playlists.playlists, &Playlist{Name: name, Source: PlaylistSourceUser})
+	p.logger.Info("Playlist created", "name", name)
+	return p.playlists.save()
+}
+
+// AddToPlaylist 将歌曲加入用户播放列表，列表不存在时自动创建
+func (p *Player) AddToPlaylist(name string, index int) (SongInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return SongInfo{}, errors.New("playlist name is required")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if index < 0 || index >= len(p.songs) {
+		return SongInfo{}, fmt.Errorf("invalid song index: %d", index)
+	}
+	if err := p.loadPlaylistsLocked(); err != nil {
+		return SongInfo{}, err
+	}
+
+	pl := p.playlists.find(name)
+	if pl == nil {
+		if file := p.findFilePlaylistLocked(name); file != nil {
+			return SongInfo{}, fmt.Errorf("playlist %q is a read-only playlist file", file.Name)
+		}
+		pl = &Playlist{Name: name, Source: PlaylistSourceUser}
+		p.playlists.playlists = append(p.playlists.playlists, pl)
+		p.logger.Info("Playlist created", "name", name)
+	}
+
+	song := p.songs[index]
+	pl.Paths = append(pl.Paths, song.Path)
+	p.refreshActiveLocked(pl)
+	p.logger.Info("Song added to playlist", "playlist", pl.Name, "song", song.Name, "count", len(pl.Paths))
+	return song, p.playlists.save()
+}
+
+// RemoveFromPlaylist 按列表位置（从 0 开始，与 PlaylistSongs 的顺序一致）移除用户播放列表中的歌曲
+func (p *Player) RemoveFromPlaylist(name string, position int) (SongInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl, err := p.findUserPlaylistLocked(name)
+	if err != nil {
+		return SongInfo{}, err
+	}
+	indices := p.resolveLocked(pl.Paths)
+	if position < 0 || position >= len(indices) {
+		return SongInfo{}, fmt.Errorf("invalid playlist position: %d", position)
+	}
+
+	// 曲库中已不存在的歌曲不计入位置
+	song := p.songs[indices[position]]
+	seen := 0
+	for i, path := range pl.Paths {
+		if p.indexOfLocked(path) < 0 {
+			continue
+		}
+		if seen == position {
+			pl.Paths = append(pl.Paths[:i], pl.Paths[i+1:]...)
+			break
+		}
+		seen++
+	}
+	p.refreshActiveLocked(pl)
+	return song, p.playlists.save()
+}
+
+// DeletePlaylist 删除用户播放列表
+func (p *Player) DeletePlaylist(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pl, err := p.findUserPlaylistLocked(name)
+	if err != nil {
+		return err
+	}
+	for i, existing := range p.playlists.playlists {
+		if existing == pl {
+			p.playlists.playlists = append(p.playlists.playlists[:i], p.playlists.playlists[i+1:]...)
+			break
+		}
+	}
+	if p.playlist != nil && p.playlist.name == pl.Name {
+		p.playlist = nil
+	}
+	p.logger.Info("Playlist deleted", "name", pl.Name)
+	return p.playlists.save()
+}
+
+// PlayPlaylist 播放播放列表，之后的切歌和播放模式只在列表内生效
+func (p *Player) PlayPlaylist(name string) (string, error) {
+	p.mu.Lock()
+	pl, err := p.findPlaylistLocked(name)
+	if err != nil {
+		p.mu.Unlock()
+		return "", err
+	}
+	indices := p.resolveLocked(pl.Paths)
+	if len(indices) == 0 {
+		p.mu.Unlock()
+		return "", fmt.Errorf("playlist %q has no playable songs", pl.Name)
+	}
+
+	p.setActiveLocked(pl)
+	p.shuffleBag = nil
+	first := indices[0]
+	if p.mode == ModeShuffle {
+		first = p.shuffleNextLocked()
+	}
+	p.mu.Unlock()
+
+	p.logger.Info("Playing playlist", "name", pl.Name, "songs", len(indices))
+	return pl.Name, p.PlaySong(first)
+}
+
+// CurrentPlaylist 正在播放的播放列表名称，未播放列表时为空
+func (p *Player) CurrentPlaylist() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.playlist == nil {
+		return ""
+	}
+	return p.playlist.name
+}
+
+// loadPlaylistsLocked 确保用户播放列表已加载
+func (p *Player) loadPlaylistsLocked() error {
+	if p.playlists == nil {
+		p.playlists = &playlistStore{path: DefaultPlaylistPath()}
+	}
+	return p.playlists.load()
+}
+
+// allPlaylistsLocked 用户播放列表和列表文件，同名时用户列表优先
+func (p *Player) allPlaylistsLocked() ([]*Playlist, error) {
+	if err := p.loadPlaylistsLocked(); err != nil {
+		return nil, err
+	}
+
+	all := append([]*Playlist(nil), p.playlists.playlists...)
+	for _, path := range p.library.PlaylistFiles() {
+		pl, err := ParsePlaylistFile(path)
+		if err != nil {
+			p.logger.Warn("Failed to parse playlist file", "path", path, "error", err)
+			continue
+		}
+		if p.playlists.find(pl.Name) == nil {
+			all = append(all, pl)
+		}
+	}
+	return all, nil
+}
+
+// findPlaylistLocked 按名称查找播放列表，找不到时按拼音模糊匹配
+func (p *Player) findPlaylistLocked(name string) (*Playlist, error) {
+	all, err := p.allPlaylistsLocked()
+	if err != nil {
+		return nil, err
+	}
+	for _, pl := range all {
+		if strings.EqualFold(pl.Name, strings.TrimSpace(name)) {
+			return pl, nil
+		}
+	}
+
+	query := newSearchText(name)
+	var best *Playlist
+	bestScore := MinSearchScore
+	for _, pl := range all {
+		if s := textSimilarity(query, newSearchText(pl.Name)); s >= bestScore {
+			best, bestScore = pl, s
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("playlist %q not found", name)
+	}
+	return best, nil
+}
+
+// findUserPlaylistLocked 查找可编辑的用户播放列表
+func (p *Player) findUserPlaylistLocked(name string) (*Playlist, error) {
+	pl, err := p.findPlaylistLocked(name)
+	if err != nil {
+		return nil, err
+	}
+	if pl.Source != PlaylistSourceUser {
+		return nil, fmt.Errorf("playlist %q is a read-only playlist file", pl.Name)
+	}
+	return pl, nil
+}
+
+// findFilePlaylistLocked 按名称查找列表文件
+func (p *Player) findFilePlaylistLocked(name string) *Playlist {
+	for _, path := range p.library.PlaylistFiles() {
+		base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if strings.EqualFold(base, name) {
+			return &Playlist{Name: base, Source: PlaylistSourceFile}
+		}
+	}
+	return nil
+}
+
+// resolveLocked 将路径转换为曲库中的歌曲索引，跳过曲库中不存在的歌曲
+func (p *Player) resolveLocked(paths []string) []int {
+	byPath := make(map[string]int, len(p.songs))
+	for i, song := range p.songs {
+		byPath[song.Path] = i
+	}
+
+	indices := make([]int, 0, len(paths))
+	for _, path := range paths {
+		if idx, ok := byPath[path]; ok {
+			indices = append(indices, idx)
+		}
+	}
+	return indices
+}
+
+// setActiveLocked 将播放范围设为播放列表
+func (p *Player) setActiveLocked(pl *Playlist) {
+	active := &activePlaylist{name: pl.Name, paths: make(map[string]bool, len(pl.Paths)), order: pl.Paths}
+	for _, path := range pl.Paths {
+		active.paths[path] = true
+	}
+	p.playlist = active
+}
+
+// refreshActiveLocked 编辑正在播放的列表后更新播放范围
+func (p *Player) refreshActiveLocked(pl *Playlist) {
+	if p.playlist != nil && p.playlist.name == pl.Name {
+		p.setActiveLocked(pl)
+		p.shuffleBag = nil
+	}
+}
+
+// scopeLocked 当前播放范围内的歌曲索引：播放列表或整个曲库
+func (p *Player) scopeLocked() []int {
+	if p.playlist != nil {
+		if indices := p.resolveLocked(p.playlist.order); len(indices) > 0 {
+			return indices
+		}
+	}
+	indices := make([]int, len(p.songs))
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
@@ -0,0 +1,112 @@
+package music
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePlaylistFile(t *testing.T) {
+	dir := t.TempDir()
+	abs := filepath.Join(dir, "other", "abs.mp3")
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name: "m3u relative and absolute",
+			file: "mix.m3u",
+			content: "#EXTM3U\n" +
+				"#EXTINF:215,歌手 - 第一首\n" +
+				"a.mp3\n" +
+				"\n" +
+				"#EXTINF:180,第二首\n" +
+				"sub/b.flac\n" +
+				abs + "\n",
+			want: []string{
+				filepath.Join(dir, "a.mp3"),
+				filepath.Join(dir, "sub", "b.flac"),
+				abs,
+			},
+		},
+		{
+			name:    "m3u8 with BOM and windows separators",
+			file:    "utf8.m3u8",
+			content: "\ufeff#EXTM3U\r\nsub\\c.ogg\r\n..\\up.mp3\r\n",
+			want: []string{
+				filepath.Join(dir, "sub", "c.ogg"),
+				filepath.Join(filepath.Dir(dir), "up.mp3"),
+			},
+		},
+		{
+			name:    "m3u urls",
+			file:    "urls.m3u",
+			content: "http://radio.example.com/live\nfile://" + abs + "\n",
+			want: []string{
+				"http://radio.example.com/live",
+				abs,
+			},
+		},
+		{
+			name: "pls ordered by FileN",
+			file: "list.pls",
+			content: "[playlist]\n" +
+				"NumberOfEntries=3\n" +
+				"File3=c.mp3\n" +
+				"Title3=第三首\n" +
+				"File1=a.mp3\n" +
+				"Length1=200\n" +
+				"file2 = sub/b.mp3\n" +
+				"Version=2\n",
+			want: []string{
+				filepath.Join(dir, "a.mp3"),
+				filepath.Join(dir, "sub", "b.mp3"),
+				filepath.Join(dir, "c.mp3"),
+			},
+		},
+		{
+			name:    "pls ignores malformed keys",
+			file:    "bad.pls",
+			content: "[playlist]\nFileX=x.mp3\nFile=y.mp3\nFile1=a.mp3\n",
+			want:    []string{filepath.Join(dir, "a.mp3")},
+		},
+		{
+			name:    "empty",
+			file:    "empty.m3u",
+			content: "#EXTM3U\n",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			pl, err := ParsePlaylistFile(path)
+			if err != nil {
+				t.Fatalf("ParsePlaylistFile: %v", err)
+			}
+			if !reflect.DeepEqual(pl.Paths, tt.want) {
+				t.Errorf("paths = %q, want %q", pl.Paths, tt.want)
+			}
+			if want := tt.file[:len(tt.file)-len(filepath.Ext(tt.file))]; pl.Name != want {
+				t.Errorf("name = %q, want %q", pl.Name, want)
+			}
+			if pl.Source != PlaylistSourceFile {
+				t.Errorf("source = %q, want %q", pl.Source, PlaylistSourceFile)
+			}
+		})
+	}
+}
+
+func TestParsePlaylistFileMissing(t *testing.T) {
+	if _, err := ParsePlaylistFile(filepath.Join(t.TempDir(), "missing.m3u")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
@@ -0,0 +1,215 @@
+package music
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Mode 播放模式
+type Mode string
+
+const (
+	ModeOnce       Mode = "once"       // 播放完当前歌曲后停止
+	ModeSequential Mode = "sequential" // 顺序播放，列表结束后停止
+	ModeRepeatAll  Mode = "repeat_all" // 列表循环
+	ModeRepeatOne  Mode = "repeat_one" // 单曲循环
+	ModeShuffle    Mode = "shuffle"    // 随机播放，一轮内不重复
+)
+
+// maxHistory 播放历史保留的歌曲数
+const maxHistory = 50
+
+// Modes 返回所有支持的播放模式
+func Modes() []Mode {
+	return []Mode{ModeOnce, ModeSequential, ModeRepeatAll, ModeRepeatOne, ModeShuffle}
+}
+
+// ParseMode 解析播放模式，兼容连字符写法（如 repeat-one）
+func ParseMode(s string) (Mode, error) {
+	normalized := Mode(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(s)), "-", "_"))
+	for _, m := range Modes() {
+		if m == normalized {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown play mode: %q", s)
+}
+
+// Mode 当前播放模式
+func (p *Player) Mode() Mode {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mode
+}
+
+// SetMode 设置播放模式，切换到随机模式时重新洗牌
+func (p *Player) SetMode(mode Mode) error {
+	if _, err := ParseMode(string(mode)); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if mode != p.mode {
+		p.shuffleBag = nil
+	}
+	p.mode = mode
+	p.logger.Info("Music play mode changed", "mode", mode)
+	return nil
+}
+
+// QueueAdd 将歌曲加入播放队列，playNext 为 true 时插入队首
+func (p *Player) QueueAdd(index int, playNext bool) (SongInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if index < 0 || index >= len(p.songs) {
+		return SongInfo{}, fmt.Errorf("invalid song index: %d", index)
+	}
+
+	song := p.songs[index]
+	if playNext {
+		p.queue = append([]SongInfo{song}, p.queue...)
+	} else {
+		p.queue = append(p.queue, song)
+	}
+	p.logger.Info("Song queued", "song", song.Name, "play_next", playNext, "queue_length", len(p.queue))
+	return song, nil
+}
+
+// QueueRemove 按队列位置（从 0 开始）移除歌曲
+func (p *Player) QueueRemove(position int) (SongInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if position < 0 || position >= len(p.queue) {
+		return SongInfo{}, fmt.Errorf("invalid queue position: %d", position)
+	}
+
+	song := p.queue[position]
+	p.queue = append(p.queue[:position], p.queue[position+1:]...)
+	return song, nil
+}
+
+// QueueClear 清空播放队列
+func (p *Player) QueueClear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = nil
+}
+
+// Queue 返回播放队列的副本
+func (p *Player) Queue() []SongInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]SongInfo(nil), p.queue...)
+}
+
+// nextIndexLocked 选择下一首歌曲，调用方需持有 p.mu
+// auto 为 true 表示当前歌曲自然播放结束；为 false 表示用户主动切换，
+// 此时单曲循环和播放一次模式也会前进到下一首，列表末尾回到开头
+// 正在播放播放列表时只在列表内选择
+func (p *Player) nextIndexLocked(auto bool) (int, bool) {
+	if len(p.songs) == 0 {
+		return -1, false
+	}
+
+	// 队列优先，跳过已不在曲库中的歌曲
+	for len(p.queue) > 0 {
+		song := p.queue[0]
+		p.queue = p.queue[1:]
+		if idx := p.indexOfLocked(song.Path); idx >= 0 {
+			return idx, true
+		}
+		p.logger.Warn("Queued song no longer in library, skipping", "song", song.Name)
+	}
+
+	scope := p.scopeLocked()
+	count := len(scope)
+	next := positionOf(scope, p.currentIndex) + 1
+	switch p.mode {
+	case ModeOnce:
+		if auto {
+			return -1, false
+		}
+	case ModeRepeatOne:
+		if auto && p.currentIndex >= 0 {
+			return p.currentIndex, true
+		}
+	case ModeSequential:
+		if auto && next >= count {
+			return -1, false
+		}
+	case ModeShuffle:
+		return p.shuffleNextLocked(), true
+	}
+	return scope[next%count], true
+}
+
+// shuffleNextLocked 从本轮未播放的歌曲中随机取一首，一轮播完后重新洗牌
+func (p *Player) shuffleNextLocked() int {
+	scope := p.scopeLocked()
+	if len(p.shuffleBag) == 0 {
+		p.shuffleBag = make([]int, len(scope))
+		for i, j := range rand.Perm(len(scope)) {
+			p.shuffleBag[i] = scope[j]
+		}
+		// 避免新一轮的第一首与刚播放的歌曲相同
+		if len(p.shuffleBag) > 1 && p.shuffleBag[0] == p.currentIndex {
+			last := len(p.shuffleBag) - 1
+			p.shuffleBag[0], p.shuffleBag[last] = p.shuffleBag[last], p.shuffleBag[0]
+		}
+	}
+
+	idx := p.shuffleBag[0]
+	p.shuffleBag = p.shuffleBag[1:]
+	if idx >= len(p.songs) {
+		// 曲库在本轮中变小
+		p.shuffleBag = nil
+		return p.shuffleNextLocked()
+	}
+	return idx
+}
+
+// positionOf 歌曲索引在播放范围中的位置，不在范围内时返回 -1
+func positionOf(scope []int, index int) int {
+	for i, idx := range scope {
+		if idx == index {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeFromShuffleLocked 将手动播放的歌曲从本轮随机列表中移除
+func (p *Player) removeFromShuffleLocked(index int) {
+	for i, idx := range p.shuffleBag {
+		if idx == index {
+			p.shuffleBag = append(p.shuffleBag[:i], p.shuffleBag[i+1:]...)
+			return
+		}
+	}
+}
+
+// indexOfLocked 按路径查找歌曲索引
+func (p *Player) indexOfLocked(path string) int {
+	for i, song := range p.songs {
+		if song.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+// pushHistoryLocked 记录播放历史
+func (p *Player) pushHistoryLocked(index int) {
+	if index < 0 {
+		return
+	}
+	p.history = append(p.history, index)
+	if len(p.history) > maxHistory {
+		p.history = p.history[len(p.history)-maxHistory:]
+	}
+}
@@ -0,0 +1,183 @@
+package music
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// queuePlayer 创建含 n 首歌曲的播放器，歌曲名为 s0、s1……
+func queuePlayer(t *testing.T, n int) *Player {
+	t.Helper()
+	p := NewPlayer(t.TempDir(), nil, testLogger())
+	songs := make([]SongInfo, n)
+	for i := range songs {
+		name := fmt.Sprintf("s%d", i)
+		songs[i] = SongInfo{Name: name, Path: "/music/" + name + ".mp3"}
+	}
+	p.setSongs(songs)
+	return p
+}
+
+// next 在持锁状态下选择下一首，并像播放循环一样把它设为当前歌曲
+func next(p *Player, auto bool) (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	idx, ok := p.nextIndexLocked(auto)
+	if ok {
+		p.currentIndex = idx
+	}
+	return idx, ok
+}
+
+func TestNextIndex(t *testing.T) {
+	tests := []struct {
+		mode    Mode
+		current int
+		auto    bool
+		want    int // -1 表示停止
+	}{
+		{ModeOnce, 1, true, -1},
+		{ModeOnce, 1, false, 2},
+		{ModeOnce, 3, false, 0},
+		{ModeSequential, 1, true, 2},
+		{ModeSequential, 3, true, -1},
+		{ModeSequential, 3, false, 0},
+		{ModeSequential, -1, true, 0},
+		{ModeRepeatAll, 1, true, 2},
+		{ModeRepeatAll, 3, true, 0},
+		{ModeRepeatAll, 3, false, 0},
+		{ModeRepeatOne, 1, true, 1},
+		{ModeRepeatOne, 1, false, 2},
+		{ModeRepeatOne, 3, false, 0},
+		{ModeRepeatOne, -1, true, 0},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("%s/current=%d/auto=%v", tt.mode, tt.current, tt.auto)
+		t.Run(name, func(t *testing.T) {
+			p := queuePlayer(t, 4)
+			if err := p.SetMode(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			p.currentIndex = tt.current
+
+			idx, ok := next(p, tt.auto)
+			if tt.want < 0 {
+				if ok {
+					t.Errorf("next = %d, want stop", idx)
+				}
+				return
+			}
+			if !ok || idx != tt.want {
+				t.Errorf("next = %d, %v, want %d", idx, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestShuffleRounds(t *testing.T) {
+	const songs, rounds = 5, 200
+	p := queuePlayer(t, songs)
+	if err := p.SetMode(ModeShuffle); err != nil {
+		t.Fatal(err)
+	}
+
+	prev := -1
+	for round := 0; round < rounds; round++ {
+		var played []int
+		for i := 0; i < songs; i++ {
+			idx, ok := next(p, true)
+			if !ok {
+				t.Fatalf("round %d: shuffle stopped", round)
+			}
+			if idx == prev {
+				t.Fatalf("round %d: song %d played twice in a row", round, idx)
+			}
+			played = append(played, idx)
+			prev = idx
+		}
+		slices.Sort(played)
+		if want := []int{0, 1, 2, 3, 4}; !slices.Equal(played, want) {
+			t.Fatalf("round %d played %v, want every song once", round, played)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	p := queuePlayer(t, 5)
+	p.currentIndex = 0
+
+	for _, tc := range []struct {
+		index    int
+		playNext bool
+	}{{3, false}, {4, false}, {1, true}} {
+		if _, err := p.QueueAdd(tc.index, tc.playNext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.QueueAdd(9, false); err == nil {
+		t.Error("QueueAdd(9) succeeded")
+	}
+	names := func() []string {
+		var out []string
+		for _, s := range p.Queue() {
+			out = append(out, s.Name)
+		}
+		return out
+	}
+	if got, want := names(), []string{"s1", "s3", "s4"}; !slices.Equal(got, want) {
+		t.Fatalf("queue = %v, want %v", got, want)
+	}
+
+	if song, err := p.QueueRemove(1); err != nil || song.Name != "s3" {
+		t.Errorf("QueueRemove(1) = %v, %v, want s3", song.Name, err)
+	}
+	if _, err := p.QueueRemove(5); err == nil {
+		t.Error("QueueRemove(5) succeeded")
+	}
+	if got, want := names(), []string{"s1", "s4"}; !slices.Equal(got, want) {
+		t.Fatalf("queue after remove = %v, want %v", got, want)
+	}
+
+	// 队列优先于播放模式，单曲循环也先播放队列
+	if err := p.SetMode(ModeRepeatOne); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []int{1, 4, 4} {
+		if idx, ok := next(p, true); !ok || idx != want {
+			t.Errorf("next = %d, %v, want %d", idx, ok, want)
+		}
+	}
+
+	if _, err := p.QueueAdd(2, false); err != nil {
+		t.Fatal(err)
+	}
+	p.QueueClear()
+	if q := p.Queue(); len(q) != 0 {
+		t.Errorf("queue after clear = %v", q)
+	}
+}
+
+func TestQueueSkipsRemovedSongs(t *testing.T) {
+	p := queuePlayer(t, 4)
+	p.currentIndex = 0
+	for _, idx := range []int{2, 3} {
+		if _, err := p.QueueAdd(idx, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// s2 被移出曲库，s3 的索引前移到 2
+	p.mu.Lock()
+	songs := slices.Clone(p.songs)
+	p.mu.Unlock()
+	p.setSongs(slices.Delete(songs, 2, 3))
+
+	idx, ok := next(p, true)
+	if !ok || p.songs[idx].Name != "s3" {
+		t.Errorf("next = %d, %v, want s3 (index 2)", idx, ok)
+	}
+	if q := p.Queue(); len(q) != 0 {
+		t.Errorf("queue = %v, want empty", q)
+	}
+}
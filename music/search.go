@@ -0,0 +1,270 @@
+package music
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// 搜索参数
+const (
+	MinSearchScore     = 0.5 // 低于该分数的结果不返回
+	defaultSearchLimit = 5
+)
+
+// 字段权重：标题最重要，其次歌手、专辑
+const (
+	titleWeight  = 1.0
+	artistWeight = 0.9
+	albumWeight  = 0.7
+)
+
+// 不同表示方式的折扣：原文 > 拼音（同音字） > 首字母
+const (
+	pinyinDiscount  = 0.95
+	initialDiscount = 0.85
+)
+
+// SearchResult 搜索结果
+type SearchResult struct {
+	Index int
+	Song  SongInfo
+	Score float64 // 0-1，越大越匹配
+}
+
+// searchText 一段文本的三种表示，用于原文、同音字和首字母匹配
+type searchText struct {
+	raw      []rune // 小写，去掉空白和标点
+	pinyin   []rune // 模糊拼音（平翘舌、前后鼻音、n/l 不区分）
+	initials []rune // 拼音首字母
+}
+
+// searchEntry 一首歌的可搜索字段
+type searchEntry struct {
+	title, artist, album searchText
+}
+
+// Search 按标题、歌手、专辑模糊搜索歌曲，支持拼音、首字母和同音字，按匹配度排序
+// 查询中可以用空格或“的”分隔歌手和歌名，如“周杰伦的晴天”
+func (p *Player) Search(query string, limit int) []SearchResult {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	whole := newSearchText(query)
+	if len(whole.raw) == 0 {
+		return nil
+	}
+	var tokens []searchText
+	for _, part := range splitQuery(query) {
+		if t := newSearchText(part); len(t.raw) > 0 {
+			tokens = append(tokens, t)
+		}
+	}
+
+	p.mu.Lock()
+	if p.searchIndex == nil {
+		p.searchIndex = make([]searchEntry, len(p.songs))
+		for i, song := range p.songs {
+			title := song.Title
+			if title == "" {
+				title = song.Name
+			}
+			p.searchIndex[i] = searchEntry{
+				title:  newSearchText(title),
+				artist: newSearchText(song.Artist),
+				album:  newSearchText(song.Album),
+			}
+		}
+	}
+	songs := p.songs
+	index := p.searchIndex
+	p.mu.Unlock()
+
+	var results []SearchResult
+	for i, entry := range index {
+		score := entry.score(whole)
+
+		// 多个关键词时（如“歌手 歌名”），每个关键词匹配最合适的字段后取平均
+		if len(tokens) > 1 {
+			total := 0.0
+			for _, token := range tokens {
+				total += entry.score(token)
+			}
+			if avg := total / float64(len(tokens)); avg > score {
+				score = avg
+			}
+		}
+
+		if score >= MinSearchScore {
+			results = append(results, SearchResult{Index: i, Song: songs[i], Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// score 查询与歌曲各字段的最佳匹配分数
+func (e searchEntry) score(q searchText) float64 {
+	best := textSimilarity(q, e.title) * titleWeight
+	if s := textSimilarity(q, e.artist) * artistWeight; s > best {
+		best = s
+	}
+	if s := textSimilarity(q, e.album) * albumWeight; s > best {
+		best = s
+	}
+
+	// 查询同时包含歌手和歌名
+	if restRaw, restPinyin, ok := withoutArtist(q, e.artist); ok {
+		title := runeSimilarity(restRaw, e.title.raw)
+		if s := runeSimilarity(restPinyin, e.title.pinyin) * pinyinDiscount; s > title {
+			title = s
+		}
+		if s := (artistWeight + title*titleWeight) / 2; s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// splitQuery 按空白、标点和“的”拆分查询
+func splitQuery(query string) []string {
+	return strings.FieldsFunc(query, func(r rune) bool {
+		return r == '的' || unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+}
+
+// newSearchText 生成文本的搜索表示
+func newSearchText(s string) searchText {
+	var t searchText
+	var py strings.Builder
+	args := pinyin.NewArgs()
+
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			t.raw = append(t.raw, r)
+			syllables := pinyin.SinglePinyin(r, args)
+			if len(syllables) == 0 || syllables[0] == "" {
+				py.WriteRune(r)
+				t.initials = append(t.initials, r)
+				continue
+			}
+			py.WriteString(syllables[0])
+			t.initials = append(t.initials, rune(syllables[0][0]))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			t.raw = append(t.raw, r)
+			py.WriteRune(r)
+			t.initials = append(t.initials, r)
+		}
+	}
+	t.pinyin = []rune(fuzzyPinyin(py.String()))
+	return t
+}
+
+// fuzzyReplacer 归一化语音识别和方言中容易混淆的音：平翘舌、前后鼻音、n/l
+// 对查询和歌曲使用同样的替换，直接输入的拼音（如 “qingtian”）也能匹配
+var fuzzyReplacer = strings.NewReplacer("zh", "z", "ch", "c", "sh", "s", "ng", "n", "l", "n")
+
+func fuzzyPinyin(s string) string {
+	return fuzzyReplacer.Replace(s)
+}
+
+// withoutArtist 去掉查询中的歌手名，返回剩余部分（用于“孙燕姿晴天”这类无分隔的查询）
+func withoutArtist(q, artist searchText) ([]rune, []rune, bool) {
+	if len(artist.raw) < 2 {
+		return nil, nil, false
+	}
+	if rest := strings.Replace(string(q.raw), string(artist.raw), "", 1); len(rest) < len(string(q.raw)) && rest != "" {
+		r := newSearchText(rest)
+		return r.raw, r.pinyin, true
+	}
+	if rest := strings.Replace(string(q.pinyin), string(artist.pinyin), "", 1); len(rest) < len(string(q.pinyin)) && rest != "" {
+		return nil, []rune(rest), true
+	}
+	return nil, nil, false
+}
+
+// textSimilarity 取原文、拼音、首字母三种表示中的最高相似度
+func textSimilarity(q, t searchText) float64 {
+	if len(t.raw) == 0 {
+		return 0
+	}
+	best := runeSimilarity(q.raw, t.raw)
+	if s := runeSimilarity(q.pinyin, t.pinyin) * pinyinDiscount; s > best {
+		best = s
+	}
+	// 首字母只用于纯字母的查询（如 “zjl”）
+	if isASCII(q.raw) {
+		if s := runeSimilarity(q.raw, t.initials) * initialDiscount; s > best {
+			best = s
+		}
+	}
+	return best
+}
+
+// runeSimilarity 相似度：完全相同为 1，包含关系按长度比例打分，否则按编辑距离
+func runeSimilarity(q, t []rune) float64 {
+	if len(q) == 0 || len(t) == 0 {
+		return 0
+	}
+	if string(q) == string(t) {
+		return 1
+	}
+
+	shorter, longer := len(q), len(t)
+	if shorter > longer {
+		shorter, longer = longer, shorter
+	}
+	ratio := float64(shorter) / float64(longer)
+
+	switch {
+	case strings.Contains(string(t), string(q)):
+		// 查询是字段的一部分，如“晴天”匹配“晴天 (Live)”
+		return 0.7 + 0.2*ratio
+	case len(t) >= 2 && strings.Contains(string(q), string(t)):
+		// 字段是查询的一部分，如“周杰伦晴天”包含“晴天”
+		return 0.5 + 0.3*ratio
+	}
+
+	distance := levenshtein(q, t)
+	return 0.9 * (1 - float64(distance)/float64(longer))
+}
+
+// levenshtein 编辑距离
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func isASCII(rs []rune) bool {
+	for _, r := range rs {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package music
+
+import (
+	"testing"
+)
+
+func searchPlayer(t *testing.T) *Player {
+	t.Helper()
+	p := NewPlayer(t.TempDir(), nil, testLogger())
+	song := func(title, artist, album string) SongInfo {
+		return SongInfo{Name: title, Title: title, Artist: artist, Album: album, Path: "/music/" + artist + "/" + title + ".mp3"}
+	}
+	p.setSongs([]SongInfo{
+		song("晴天", "周杰伦", "叶惠美"),
+		song("七里香", "周杰伦", "七里香"),
+		song("稻香", "周杰伦", "魔杰座"),
+		song("遇见", "孙燕姿", "The Moment"),
+		song("天黑黑", "孙燕姿", "孙燕姿同名专辑"),
+		song("江南", "林俊杰", "第二天堂"),
+		song("十年", "陈奕迅", "黑白灰"),
+		song("晴天 (Live)", "乐队翻唱", "现场"),
+	})
+	return p
+}
+
+func TestSearch(t *testing.T) {
+	p := searchPlayer(t)
+
+	tests := []struct {
+		query      string
+		wantTitle  string // 第一条结果的标题，为空表示不应有结果
+		wantArtist string // 只检查歌手时使用
+	}{
+		{query: "晴天", wantTitle: "晴天"},
+		{query: "周杰伦的晴天", wantTitle: "晴天"},
+		{query: "周杰伦 七里香", wantTitle: "七里香"},
+		{query: "晴添", wantTitle: "晴天"},       // 同音字
+		{query: "qingtian", wantTitle: "晴天"}, // 直接输入拼音
+		{query: "zjl", wantArtist: "周杰伦"},    // 歌手首字母
+		{query: "孙燕子遇见", wantTitle: "遇见"},    // 歌手同音字且无分隔
+		{query: "江兰", wantTitle: "江南"},       // n/l 不分
+		{query: "sinian", wantTitle: "十年"},   // 平翘舌不分
+		{query: "天黑黑", wantTitle: "天黑黑"},
+		{query: "量子力学"},
+		{query: "xyzxyz"},
+		{query: "  ，"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results := p.Search(tt.query, 10)
+			if tt.wantTitle == "" && tt.wantArtist == "" {
+				if len(results) != 0 {
+					t.Errorf("Search(%q) = %v, want no results", tt.query, results)
+				}
+				return
+			}
+			if len(results) == 0 {
+				t.Fatalf("Search(%q) returned nothing", tt.query)
+			}
+
+			top := results[0].Song
+			if tt.wantTitle != "" && top.Title != tt.wantTitle {
+				t.Errorf("Search(%q) top = %q by %s (%.2f), want %q", tt.query, top.Title, top.Artist, results[0].Score, tt.wantTitle)
+			}
+			if tt.wantArtist != "" && top.Artist != tt.wantArtist {
+				t.Errorf("Search(%q) top artist = %s, want %s", tt.query, top.Artist, tt.wantArtist)
+			}
+			for i, r := range results {
+				if r.Score < MinSearchScore || r.Score > 1 {
+					t.Errorf("Search(%q)[%d] score = %.2f, out of range", tt.query, i, r.Score)
+				}
+				if i > 0 && r.Score > results[i-1].Score {
+					t.Errorf("Search(%q) scores not sorted: %.2f after %.2f", tt.query, r.Score, results[i-1].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	p := searchPlayer(t)
+
+	// 原文完全匹配优于同音字，同音字优于首字母
+	exact := p.Search("晴天", 1)[0].Score
+	homophone := p.Search("晴添", 1)[0].Score
+	initials := p.Search("qt", 1)
+	if !(exact > homophone) {
+		t.Errorf("exact %.2f should outrank homophone %.2f", exact, homophone)
+	}
+	if len(initials) > 0 && !(homophone > initials[0].Score) {
+		t.Errorf("homophone %.2f should outrank initials %.2f", homophone, initials[0].Score)
+	}
+
+	// 部分匹配排在完全匹配之后
+	results := p.Search("晴天", 10)
+	if len(results) < 2 || results[0].Song.Artist != "周杰伦" || results[1].Song.Title != "晴天 (Live)" {
+		t.Errorf("Search(晴天) order = %v", results)
+	}
+
+	if got := p.Search("周杰伦", 2); len(got) != 2 {
+		t.Errorf("limit 2 returned %d results", len(got))
+	}
+}
+
+func TestFuzzyPinyin(t *testing.T) {
+	tests := map[string]string{
+		"zhongguo": "zonguo",
+		"shanghai": "sanhai",
+		"chang":    "can",
+		"nan":      "nan",
+		"lan":      "nan",
+		"xing":     "xin",
+	}
+	for in, want := range tests {
+		if got := fuzzyPinyin(in); got != want {
+			t.Errorf("fuzzyPinyin(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
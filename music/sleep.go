@@ -0,0 +1,136 @@
+package music
+
+import (
+	"errors"
+	"time"
+)
+
+// sleepFadeDuration 睡眠定时结束前淡出的时长
+const sleepFadeDuration = 15 * time.Second
+
+// SleepTimer 睡眠定时状态
+type SleepTimer struct {
+	Active     bool
+	EndOfTrack bool          // 播完当前曲目后停止
+	Remaining  time.Duration // 距停止的时间，播完停止且时长未知时为 0
+}
+
+// SetSleepTimer 在 d 之后停止播放，最后 sleepFadeDuration 内逐渐减小音量
+// 可以在开始播放前设置；切歌不影响定时，手动停止时取消
+func (p *Player) SetSleepTimer(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("sleep duration must be positive")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.cancelSleepLocked()
+	p.sleepAt = time.Now().Add(d)
+	p.sleepTimer = time.AfterFunc(d, p.sleepFired)
+	p.logger.Info("Sleep timer set", "duration", d)
+	return nil
+}
+
+// SetSleepAtEndOfTrack 播完当前曲目后停止，结尾淡出
+func (p *Player) SetSleepAtEndOfTrack() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.radio != nil {
+		return errors.New("radio streams have no end of track")
+	}
+	p.cancelSleepLocked()
+	p.sleepEndOfTrack = true
+	p.logger.Info("Sleep timer set", "end_of_track", true)
+	return nil
+}
+
+// CancelSleepTimer 取消睡眠定时，返回之前是否设置了定时
+func (p *Player) CancelSleepTimer() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	active := p.sleepTimer != nil || p.sleepEndOfTrack
+	p.cancelSleepLocked()
+	if active {
+		p.logger.Info("Sleep timer canceled")
+	}
+	return active
+}
+
+// SleepTimer 当前睡眠定时状态
+func (p *Player) SleepTimer() SleepTimer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.sleepEndOfTrack:
+		timer := SleepTimer{Active: true, EndOfTrack: true}
+		if p.duration > 0 {
+			timer.Remaining = p.duration - p.position
+		}
+		return timer
+	case p.sleepTimer != nil:
+		return SleepTimer{Active: true, Remaining: time.Until(p.sleepAt)}
+	}
+	return SleepTimer{}
+}
+
+// cancelSleepLocked 清除睡眠定时
+func (p *Player) cancelSleepLocked() {
+	if p.sleepTimer != nil {
+		p.sleepTimer.Stop()
+		p.sleepTimer = nil
+	}
+	p.sleepAt = time.Time{}
+	p.sleepEndOfTrack = false
+}
+
+// sleepFired 定时到达，停止播放
+func (p *Player) sleepFired() {
+	p.mu.Lock()
+	if p.sleepTimer == nil || time.Now().Before(p.sleepAt) {
+		// 已取消或被重新设置
+		p.mu.Unlock()
+		return
+	}
+	p.sleepTimer = nil
+	p.sleepAt = time.Time{}
+	p.mu.Unlock()
+
+	p.logger.Info("Sleep timer fired, stopping music")
+	p.Stop()
+}
+
+// sleepGain 睡眠定时的淡出增益（1.0 为不衰减），ahead 为解码领先实际播放的时长
+func (p *Player) sleepGain(ahead time.Duration) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var remaining time.Duration
+	switch {
+	case p.sleepTimer != nil:
+		remaining = time.Until(p.sleepAt) - ahead
+	case p.sleepEndOfTrack && p.duration > 0:
+		remaining = p.duration - p.position - ahead
+	default:
+		return 1
+	}
+	if remaining >= sleepFadeDuration {
+		return 1
+	}
+	if remaining <= 0 {
+		return 0
+	}
+	// 按平方曲线衰减，听感上更均匀
+	r := float64(remaining) / float64(sleepFadeDuration)
+	return r * r
+}
+
+// applyGain 原地按增益缩放 PCM
+func applyGain(pcm []int16, gain float64) {
+	for i, s := range pcm {
+		pcm[i] = int16(float64(s) * gain)
+	}
+}
@@ -0,0 +1,169 @@
+package music
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// 频谱分析参数
+const (
+	DefaultSpectrumBands = 16
+	maxSpectrumBands     = 64
+	spectrumFFTSize      = 2048    // 48kHz 下约 43ms，频率分辨率约 23Hz
+	spectrumMinFreq      = 40.0    // 最低频带的下限
+	spectrumMaxFreq      = 16000.0 // 最高频带的上限（不超过采样率的一半）
+	spectrumFloorDB      = -60.0   // 低于该能量的频带显示为 0
+	visualizeInterval    = 33 * time.Millisecond
+)
+
+// Spectrum 对最近的 PCM 做 FFT，按对数间隔分组为若干频带能量（0.0-1.0）
+type Spectrum struct {
+	bands  int
+	window []float64
+	ring   []float64 // 最近 spectrumFFTSize 个单声道样本
+	pos    int
+	buf    []complex128
+
+	sampleRate int
+	edges      []int // 各频带的起始 FFT 下标，最后一个为结束下标
+}
+
+// NewSpectrum 创建频谱分析器，bands 超出范围时使用默认值
+func NewSpectrum(bands int) *Spectrum {
+	if bands <= 0 || bands > maxSpectrumBands {
+		bands = DefaultSpectrumBands
+	}
+	window := make([]float64, spectrumFFTSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(spectrumFFTSize-1)) // Hann 窗
+	}
+	return &Spectrum{
+		bands:  bands,
+		window: window,
+		ring:   make([]float64, spectrumFFTSize),
+		buf:    make([]complex128, spectrumFFTSize),
+	}
+}
+
+// Bands 频带数量
+func (s *Spectrum) Bands() int {
+	return s.bands
+}
+
+// Push 写入交错 PCM，多声道混合为单声道
+func (s *Spectrum) Push(pcm []int16, channels int) {
+	if channels <= 0 {
+		channels = 1
+	}
+	for i := 0; i+channels <= len(pcm); i += channels {
+		var sum float64
+		for ch := 0; ch < channels; ch++ {
+			sum += float64(pcm[i+ch])
+		}
+		s.ring[s.pos] = sum / float64(channels) / 32768.0
+		s.pos = (s.pos + 1) % len(s.ring)
+	}
+}
+
+// Reset 清空已缓冲的样本（定位后调用）
+func (s *Spectrum) Reset() {
+	for i := range s.ring {
+		s.ring[i] = 0
+	}
+	s.pos = 0
+}
+
+// Analyze 计算当前各频带的能量，返回新分配的切片
+func (s *Spectrum) Analyze(sampleRate int) []float64 {
+	if sampleRate != s.sampleRate {
+		s.sampleRate = sampleRate
+		s.edges = bandEdges(s.bands, sampleRate)
+	}
+
+	// 按时间顺序加窗
+	for i := range s.buf {
+		s.buf[i] = complex(s.ring[(s.pos+i)%len(s.ring)]*s.window[i], 0)
+	}
+	fft(s.buf)
+
+	// 满幅正弦波归一化到 0dB（Hann 窗相干增益 0.5）
+	scale := 4.0 / float64(spectrumFFTSize)
+	levels := make([]float64, s.bands)
+	for b := 0; b < s.bands; b++ {
+		var power float64
+		for k := s.edges[b]; k < s.edges[b+1]; k++ {
+			m := cmplx.Abs(s.buf[k]) * scale
+			power += m * m
+		}
+		if power <= 0 {
+			continue
+		}
+		level := (10*math.Log10(power) - spectrumFloorDB) / -spectrumFloorDB
+		levels[b] = math.Max(0, math.Min(1, level))
+	}
+	return levels
+}
+
+// bandEdges 按对数间隔划分 FFT 下标，保证每个频带至少包含一个下标
+func bandEdges(bands, sampleRate int) []int {
+	binHz := float64(sampleRate) / spectrumFFTSize
+	maxFreq := math.Min(spectrumMaxFreq, float64(sampleRate)/2)
+	maxBin := int(maxFreq / binHz)
+	if maxBin > spectrumFFTSize/2 {
+		maxBin = spectrumFFTSize / 2
+	}
+
+	edges := make([]int, bands+1)
+	ratio := math.Pow(maxFreq/spectrumMinFreq, 1/float64(bands))
+	prev := 0
+	for b := 0; b <= bands; b++ {
+		bin := int(math.Round(spectrumMinFreq * math.Pow(ratio, float64(b)) / binHz))
+		if bin < 1 {
+			bin = 1 // 跳过直流分量
+		}
+		if b > 0 && bin <= prev {
+			bin = prev + 1
+		}
+		edges[b] = bin
+		prev = bin
+	}
+	// 频带很多而采样率很低时，把越界的频带压回可用范围
+	for b := bands; b >= 0; b-- {
+		limit := maxBin + 1 - (bands - b)
+		if edges[b] > limit {
+			edges[b] = limit
+		}
+	}
+	return edges
+}
+
+// fft 原地计算基 2 FFT，len(x) 必须是 2 的幂
+func fft(x []complex128) {
+	n := len(x)
+
+	// 位反转重排
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, -2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*w
+				x[start+k] = a + b
+				x[start+k+size/2] = a - b
+				w *= step
+			}
+		}
+	}
+}
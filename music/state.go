@@ -0,0 +1,256 @@
+package music
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 播放记录参数
+const (
+	DefaultResumeMinDuration = 10 * time.Minute // 不短于该时长的曲目（有声书、播客）自动从上次位置继续
+	resumeMinPosition        = 10 * time.Second // 播放不到该时长不记录位置
+	resumeEndMargin          = 30 * time.Second // 距结尾不足该时长视为已听完
+	stateSaveInterval        = 10 * time.Second // 播放中定期保存位置
+	maxRecent                = 50
+)
+
+// TrackState 单首曲目的播放记录
+type TrackState struct {
+	Name       string        `json:"name"`
+	Position   time.Duration `json:"position,omitempty"` // 上次停止的位置，听完后清除
+	PlayCount  int           `json:"play_count"`
+	LastPlayed time.Time     `json:"last_played"`
+}
+
+// RecentTrack 最近播放的曲目
+type RecentTrack struct {
+	Index int // 曲库中的索引，已不在曲库中时为 -1
+	Path  string
+	TrackState
+}
+
+// DefaultStatePath 默认的播放记录位置：用户配置目录下的 xiaozhi/music_state.json
+func DefaultStatePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "xiaozhi", "music_state.json")
+}
+
+// playState 持久化的播放位置、最近播放和播放次数
+type playState struct {
+	path   string
+	loaded bool
+
+	Tracks map[string]*TrackState `json:"tracks"`
+	Recent []string               `json:"recent"` // 路径，最近的在前
+}
+
+// load 首次使用时读取状态文件，文件损坏时从空状态开始
+func (s *playState) load() error {
+	if s.loaded {
+		return nil
+	}
+	s.loaded = true
+	s.Tracks = make(map[string]*TrackState)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read music state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		s.Tracks = make(map[string]*TrackState)
+		s.Recent = nil
+		return fmt.Errorf("invalid music state file: %w", err)
+	}
+	if s.Tracks == nil {
+		s.Tracks = make(map[string]*TrackState)
+	}
+	return nil
+}
+
+// save 原子地写入状态文件
+func (s *playState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// track 获取或创建曲目记录
+func (s *playState) track(path, name string) *TrackState {
+	t, ok := s.Tracks[path]
+	if !ok {
+		t = &TrackState{}
+		s.Tracks[path] = t
+	}
+	if name != "" {
+		t.Name = name
+	}
+	return t
+}
+
+// started 记录曲目开始播放：播放次数加一并移到最近播放的最前面
+func (s *playState) started(path, name string) {
+	t := s.track(path, name)
+	t.PlayCount++
+	t.LastPlayed = time.Now()
+
+	recent := []string{path}
+	for _, p := range s.Recent {
+		if p != path && len(recent) < maxRecent {
+			recent = append(recent, p)
+		}
+	}
+	s.Recent = recent
+}
+
+// SetStatePath 设置播放记录文件位置，需在播放之前调用
+func (p *Player) SetStatePath(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = &playState{path: path}
+}
+
+// SetResumeMinDuration 设置自动续播的最短曲目时长，0 表示所有曲目都自动续播
+func (p *Player) SetResumeMinDuration(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resumeMinDuration = d
+}
+
+// Recent 最近播放的曲目，最近的在前
+func (p *Player) Recent(limit int) []RecentTrack {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loadStateLocked()
+	var tracks []RecentTrack
+	for _, path := range p.state.Recent {
+		if limit > 0 && len(tracks) >= limit {
+			break
+		}
+		t, ok := p.state.Tracks[path]
+		if !ok {
+			continue
+		}
+		tracks = append(tracks, RecentTrack{Index: p.indexOfLocked(path), Path: path, TrackState: *t})
+	}
+	return tracks
+}
+
+// MostPlayed 播放次数最多的曲目
+func (p *Player) MostPlayed(limit int) []RecentTrack {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loadStateLocked()
+	tracks := make([]RecentTrack, 0, len(p.state.Tracks))
+	for path, t := range p.state.Tracks {
+		tracks = append(tracks, RecentTrack{Index: p.indexOfLocked(path), Path: path, TrackState: *t})
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		if tracks[i].PlayCount != tracks[j].PlayCount {
+			return tracks[i].PlayCount > tracks[j].PlayCount
+		}
+		return tracks[i].LastPlayed.After(tracks[j].LastPlayed)
+	})
+	if limit > 0 && len(tracks) > limit {
+		tracks = tracks[:limit]
+	}
+	return tracks
+}
+
+// ResumeLast 从上次停止的位置继续播放最近播放的曲目（不受曲目时长限制）
+func (p *Player) ResumeLast() (SongInfo, time.Duration, error) {
+	p.mu.Lock()
+	p.loadStateLocked()
+
+	index := -1
+	var song SongInfo
+	var position time.Duration
+	for _, path := range p.state.Recent {
+		if index = p.indexOfLocked(path); index >= 0 {
+			song = p.songs[index]
+			position = p.state.Tracks[path].Position
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	if index < 0 {
+		return SongInfo{}, 0, errors.New("no recently played song in library")
+	}
+	if err := p.playSong(index, true, position); err != nil {
+		return SongInfo{}, 0, err
+	}
+	return song, position, nil
+}
+
+// loadStateLocked 确保播放记录已加载，读取失败时从空记录开始
+func (p *Player) loadStateLocked() {
+	if p.state == nil {
+		p.state = &playState{path: DefaultStatePath()}
+	}
+	if err := p.state.load(); err != nil {
+		p.logger.Warn("Failed to load music state", "path", p.state.path, "error", err)
+	}
+}
+
+// trackStarted 记录曲目开始播放，返回应自动续播的位置（不续播时为 0）
+func (p *Player) trackStarted(song SongInfo, duration time.Duration) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loadStateLocked()
+	var resume time.Duration
+	if t, ok := p.state.Tracks[song.Path]; ok && duration >= p.resumeMinDuration {
+		resume = t.Position
+	}
+	p.state.started(song.Path, song.Name)
+	p.saveStateLocked()
+	return resume
+}
+
+// rememberPosition 保存曲目的播放位置，听完或接近结尾时清除
+func (p *Player) rememberPosition(song SongInfo, position, duration time.Duration, completed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loadStateLocked()
+	t := p.state.track(song.Path, song.Name)
+	switch {
+	case completed, duration > 0 && position >= duration-resumeEndMargin:
+		t.Position = 0
+	case position < resumeMinPosition:
+		// 刚开始就停止，保留之前的记录
+		return
+	default:
+		t.Position = position.Truncate(time.Second)
+	}
+	p.saveStateLocked()
+}
+
+// saveStateLocked 写入播放记录，失败只记录日志
+func (p *Player) saveStateLocked() {
+	if err := p.state.save(); err != nil {
+		p.logger.Warn("Failed to save music state", "path", p.state.path, "error", err)
+	}
+}
@@ -0,0 +1,116 @@
+package music
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// statePlayer 创建使用临时播放记录文件的播放器
+func statePlayer(t *testing.T, path string) *Player {
+	t.Helper()
+	p := NewPlayer(t.TempDir(), nil, testLogger())
+	p.setSongs([]SongInfo{
+		{Name: "book", Path: "/music/book.mp3"},
+		{Name: "song", Path: "/music/song.mp3"},
+	})
+	p.SetStatePath(path)
+	return p
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xiaozhi", "music_state.json")
+	book := SongInfo{Name: "book", Path: "/music/book.mp3"}
+	song := SongInfo{Name: "song", Path: "/music/song.mp3"}
+
+	p := statePlayer(t, path)
+	p.trackStarted(book, time.Hour)
+	p.rememberPosition(book, 12*time.Minute+500*time.Millisecond, time.Hour, false)
+	p.trackStarted(song, 3*time.Minute)
+	p.trackStarted(song, 3*time.Minute)
+
+	// 重新加载后记录不变
+	p = statePlayer(t, path)
+	recent := p.Recent(0)
+	if len(recent) != 2 || recent[0].Name != "song" || recent[1].Name != "book" {
+		t.Fatalf("Recent = %+v, want song, book", recent)
+	}
+	if recent[0].Index != 1 || recent[0].PlayCount != 2 {
+		t.Errorf("song = %+v, want index 1 played twice", recent[0])
+	}
+	if got := recent[1].Position; got != 12*time.Minute {
+		t.Errorf("book position = %v, want 12m0s", got)
+	}
+	if most := p.MostPlayed(1); len(most) != 1 || most[0].Name != "song" {
+		t.Errorf("MostPlayed(1) = %+v, want song", most)
+	}
+
+	// 长曲目自动续播，短曲目从头开始
+	if got := p.trackStarted(book, time.Hour); got != 12*time.Minute {
+		t.Errorf("trackStarted(book) = %v, want 12m0s", got)
+	}
+	p.rememberPosition(song, time.Minute, 3*time.Minute, false)
+	if got := p.trackStarted(song, 3*time.Minute); got != 0 {
+		t.Errorf("trackStarted(song) = %v, want 0", got)
+	}
+}
+
+func TestStateRememberPosition(t *testing.T) {
+	book := SongInfo{Name: "book", Path: "/music/book.mp3"}
+	tests := []struct {
+		name      string
+		position  time.Duration
+		completed bool
+		want      time.Duration
+	}{
+		{"middle", 20 * time.Minute, false, 20 * time.Minute},
+		{"near end", time.Hour - 10*time.Second, false, 0},
+		{"completed", 20 * time.Minute, true, 0},
+		{"just started", 5 * time.Second, false, 10 * time.Minute}, // 保留之前的记录
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "music_state.json")
+			p := statePlayer(t, path)
+			p.trackStarted(book, time.Hour)
+			p.rememberPosition(book, 10*time.Minute, time.Hour, false)
+			p.rememberPosition(book, tt.position, time.Hour, tt.completed)
+
+			p = statePlayer(t, path)
+			if got := p.Recent(1)[0].Position; got != tt.want {
+				t.Errorf("position = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "music_state.json")
+	if err := os.WriteFile(path, []byte(`{"tracks": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &playState{path: path}
+	if err := s.load(); err == nil {
+		t.Error("load of corrupt state succeeded")
+	}
+	if s.Tracks == nil || len(s.Recent) != 0 {
+		t.Errorf("corrupt state not reset: %+v", s)
+	}
+
+	// 播放器从空记录开始，并在下次保存时覆盖损坏的文件
+	p := statePlayer(t, path)
+	if recent := p.Recent(0); len(recent) != 0 {
+		t.Errorf("Recent = %+v, want empty", recent)
+	}
+	p.trackStarted(SongInfo{Name: "song", Path: "/music/song.mp3"}, time.Minute)
+
+	p = statePlayer(t, path)
+	if recent := p.Recent(0); len(recent) != 1 || recent[0].PlayCount != 1 {
+		t.Errorf("Recent after rewrite = %+v", recent)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary state file left behind: %v", err)
+	}
+}
@@ -0,0 +1,601 @@
+package music
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 网络流参数
+const (
+	streamConnectTimeout    = 10 * time.Second // 建立连接并收到响应头的超时
+	streamReadTimeout       = 15 * time.Second // 超过该时长没有收到数据视为断流
+	streamReconnectAttempts = 5
+	streamReconnectDelay    = time.Second // 重连间隔，每次失败后翻倍
+	streamBufferSize        = 64 * 1024
+	streamUserAgent         = "xiaozhi-go"
+)
+
+// IsStream 是否为网络流地址
+func IsStream(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// CheckStation 检查电台地址能否播放：必须是 HTTP(S) 地址，
+// 从扩展名可以看出是 AAC 的流在未启用外部解码时无法解码。
+// 没有扩展名的流只能在连接后按 Content-Type 判断
+func CheckStation(streamURL string) error {
+	if !IsStream(streamURL) {
+		return fmt.Errorf("not an HTTP(S) stream: %s", streamURL)
+	}
+	u, err := url.Parse(streamURL)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".aac", ".aacp", ".adts", ".m4a":
+		if !ExternalDecoderEnabled() {
+			return fmt.Errorf("%w: AAC stream requires music.external_decoder", ErrUnsupportedFormat)
+		}
+	}
+	return nil
+}
+
+// streamDecoder 解码 HTTP(S) 音频流（MP3、Ogg Vorbis、Ogg Opus，启用外部解码时 AAC 交给 ffmpeg），
+// 处理 Icecast/SHOUTcast 元数据，直播流断流时自动重连，有长度的音频文件断开时从断点续传
+type streamDecoder struct {
+	url     string
+	logger  *slog.Logger
+	onTitle func(string)
+
+	ctx    context.Context // 停止播放或关闭时取消
+	cancel context.CancelFunc
+
+	dec        Decoder
+	finite     bool // 有长度的音频文件，读完即结束
+	sampleRate int
+	channels   int
+}
+
+// OpenStream 连接网络流并根据内容选择解码器
+// stopChan 关闭时中断连接和重连；onTitle 在收到 StreamTitle 时回调，可为 nil
+func OpenStream(url string, stopChan <-chan struct{}, onTitle func(string), logger *slog.Logger) (Decoder, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-stopChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	d := &streamDecoder{
+		url:     url,
+		logger:  logger,
+		onTitle: onTitle,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if err := d.connect(); err != nil {
+		cancel()
+		return nil, err
+	}
+	d.sampleRate = d.dec.SampleRate()
+	d.channels = d.dec.Channels()
+	return d, nil
+}
+
+func (d *streamDecoder) SampleRate() int              { return d.sampleRate }
+func (d *streamDecoder) Channels() int                { return d.channels }
+func (d *streamDecoder) Duration() time.Duration      { return 0 }
+func (d *streamDecoder) Seek(pos time.Duration) error { return ErrNotSeekable }
+
+// Read 读取样本，连接断开时重连后继续
+func (d *streamDecoder) Read(pcm []int16) (int, error) {
+	for {
+		n, err := d.dec.Read(pcm)
+		if n > 0 || err == nil {
+			return n, nil
+		}
+		if d.ctx.Err() != nil {
+			return 0, io.EOF
+		}
+		// 有长度的普通音频文件读完即结束，中途断开已由 streamConn 续传，直播流断开则重连
+		if d.finite {
+			return 0, err
+		}
+
+		d.logger.Warn("Stream interrupted, reconnecting", "url", d.url, "error", err)
+		if err := d.reconnect(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (d *streamDecoder) Close() error {
+	d.cancel()
+	if d.dec != nil {
+		d.dec.Close()
+	}
+	return nil
+}
+
+// reconnect 按退避间隔重连，格式变化时放弃（播放设备已按原格式打开）
+func (d *streamDecoder) reconnect() error {
+	d.dec.Close()
+
+	delay := streamReconnectDelay
+	var lastErr error
+	for attempt := 1; attempt <= streamReconnectAttempts; attempt++ {
+		select {
+		case <-d.ctx.Done():
+			return io.EOF
+		case <-time.After(delay):
+		}
+		delay *= 2
+
+		if err := d.connect(); err != nil {
+			lastErr = err
+			d.logger.Warn("Stream reconnect failed", "attempt", attempt, "max", streamReconnectAttempts, "error", err)
+			continue
+		}
+		if d.dec.SampleRate() != d.sampleRate || d.dec.Channels() != d.channels {
+			d.dec.Close()
+			return fmt.Errorf("stream format changed after reconnect: %d Hz/%d ch", d.dec.SampleRate(), d.dec.Channels())
+		}
+		d.logger.Info("Stream reconnected", "url", d.url, "attempt", attempt)
+		return nil
+	}
+	return fmt.Errorf("failed to reconnect stream after %d attempts: %w", streamReconnectAttempts, lastErr)
+}
+
+// connect 建立连接，识别格式并创建解码器
+func (d *streamDecoder) connect() error {
+	conn, err := openStreamConn(d.ctx, d.url, d.onTitle, d.logger)
+	if err != nil {
+		return err
+	}
+
+	header, _ := conn.reader.Peek(64)
+	format := detectStreamFormat(header, conn.contentType)
+	src := &streamSource{Reader: conn.reader, conn: conn}
+
+	var dec Decoder
+	switch format {
+	case FormatMP3:
+		dec, err = newMP3Decoder(src)
+	case FormatVorbis:
+		dec, err = newVorbisDecoder(src)
+	case FormatOpus:
+		dec, err = newOpusStreamDecoder(conn.reader, d.logger)
+	case FormatAAC:
+		if conn.finite {
+			// MP4 容器的索引可能在文件末尾，交给 ffmpeg 直接读取地址以便按需跳转
+			conn.close()
+			dec, err = newExternalDecoder(d.url, d.logger)
+		} else {
+			dec, err = newExternalStreamDecoder(src, d.logger)
+		}
+	default:
+		err = fmt.Errorf("%w: content type %q", ErrUnsupportedFormat, conn.contentType)
+	}
+	if err != nil {
+		conn.close()
+		if errors.Is(err, ErrNoExternalDecoder) {
+			err = fmt.Errorf("%w: %s stream requires music.external_decoder", ErrUnsupportedFormat, format)
+		}
+		return err
+	}
+
+	if format == FormatOpus {
+		dec = &closingDecoder{Decoder: dec, conn: conn}
+	}
+	d.dec = dec
+	d.finite = conn.finite
+	d.logger.Info("Stream connected", "url", d.url, "format", format, "content_type", conn.contentType,
+		"sample_rate", dec.SampleRate(), "channels", dec.Channels(), "metaint", conn.metaint)
+	return nil
+}
+
+// detectStreamFormat 优先按数据识别格式，其次按 Content-Type
+func detectStreamFormat(header []byte, contentType string) Format {
+	if format := detectFormat(header, ""); format != "" {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3", "audio/mpeg3":
+		return FormatMP3
+	case "audio/aac", "audio/aacp", "audio/x-aac", "audio/mp4":
+		return FormatAAC
+	case "audio/ogg", "application/ogg", "audio/vorbis":
+		return FormatVorbis
+	case "audio/opus":
+		return FormatOpus
+	}
+	return ""
+}
+
+// streamConn 一次播放的 HTTP 连接，有长度的响应中途断开时用 Range 请求续传
+type streamConn struct {
+	ctx         context.Context // 整个播放的上下文，取消后不再续传
+	url         string
+	logger      *slog.Logger
+	body        io.ReadCloser
+	reader      *bufio.Reader
+	contentType string
+	metaint     int
+	finite      bool  // 响应有 Content-Length 且不带元数据，不是直播流
+	length      int64 // Content-Length
+	offset      int64 // 已读取的字节数，续传的起点
+	cancel      context.CancelFunc
+	watchdog    *time.Timer
+}
+
+// openStreamConn 发起请求并请求 Icecast 元数据
+func openStreamConn(parent context.Context, url string, onTitle func(string), logger *slog.Logger) (*streamConn, error) {
+	conn := &streamConn{ctx: parent, url: url, logger: logger}
+	resp, err := conn.request(0)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.contentType = resp.Header.Get("Content-Type")
+	conn.metaint, _ = strconv.Atoi(resp.Header.Get("Icy-Metaint"))
+	conn.finite = resp.ContentLength > 0 && conn.metaint == 0
+	conn.length = resp.ContentLength
+
+	var r io.Reader = conn
+	if conn.metaint > 0 {
+		r = &icyReader{r: r, metaint: conn.metaint, remaining: conn.metaint, onTitle: onTitle}
+	}
+	conn.reader = bufio.NewReaderSize(r, streamBufferSize)
+	return conn, nil
+}
+
+// request 从 offset 开始请求，服务器不支持 Range 时丢弃已读取的部分
+func (c *streamConn) request(offset int64) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(c.ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("User-Agent", streamUserAgent)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		req.Header.Set("Icy-MetaData", "1")
+	}
+
+	// 只限制等待响应头的时间，流本身没有总时长
+	timer := time.AfterFunc(streamConnectTimeout, cancel)
+	resp, err := http.DefaultClient.Do(req)
+	timer.Stop()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to connect stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && (offset == 0 || resp.StatusCode != http.StatusPartialContent) {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("stream returned HTTP %d", resp.StatusCode)
+	}
+
+	// 长时间收不到数据时取消请求，让读取返回错误从而触发重连或续传
+	c.body, c.cancel = resp.Body, cancel
+	c.watchdog = time.AfterFunc(streamReadTimeout, cancel)
+
+	if offset > 0 && resp.StatusCode == http.StatusOK {
+		if err := c.skip(offset); err != nil {
+			c.close()
+			return nil, fmt.Errorf("failed to skip to offset %d: %w", offset, err)
+		}
+	}
+	c.offset = offset
+	return resp, nil
+}
+
+// skip 丢弃响应体开头的 n 字节
+func (c *streamConn) skip(n int64) error {
+	buf := make([]byte, 32*1024)
+	for n > 0 {
+		m, err := c.body.Read(buf[:min(n, int64(len(buf)))])
+		if m > 0 {
+			n -= int64(m)
+			c.watchdog.Reset(streamReadTimeout)
+		}
+		if err != nil && n > 0 {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read 读取响应体，每次读到数据时重置断流计时；有长度的响应提前断开时从断点续传
+func (c *streamConn) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	if n > 0 {
+		c.offset += int64(n)
+		c.watchdog.Reset(streamReadTimeout)
+	}
+	if err == nil || !c.finite || c.offset >= c.length || c.ctx.Err() != nil {
+		return n, err
+	}
+	if err := c.resume(err); err != nil {
+		return n, err
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return c.Read(p)
+}
+
+// resume 按退避间隔从 offset 重新请求
+func (c *streamConn) resume(cause error) error {
+	c.logger.Warn("Stream interrupted, resuming", "url", c.url, "offset", c.offset, "length", c.length, "error", cause)
+	c.close()
+
+	delay := streamReconnectDelay
+	lastErr := cause
+	for attempt := 1; attempt <= streamReconnectAttempts; attempt++ {
+		select {
+		case <-c.ctx.Done():
+			return io.EOF
+		case <-time.After(delay):
+		}
+		delay *= 2
+
+		if _, err := c.request(c.offset); err != nil {
+			lastErr = err
+			c.logger.Warn("Stream resume failed", "attempt", attempt, "max", streamReconnectAttempts, "error", err)
+			continue
+		}
+		c.logger.Info("Stream resumed", "url", c.url, "offset", c.offset, "attempt", attempt)
+		return nil
+	}
+	return fmt.Errorf("failed to resume stream after %d attempts: %w", streamReconnectAttempts, lastErr)
+}
+
+func (c *streamConn) close() {
+	c.watchdog.Stop()
+	c.cancel()
+	c.body.Close()
+}
+
+// streamSource 将连接包装为解码器的数据源，不实现 io.Seeker
+type streamSource struct {
+	io.Reader
+	conn *streamConn
+}
+
+func (s *streamSource) Close() error {
+	s.conn.close()
+	return nil
+}
+
+// closingDecoder 关闭解码器时一并关闭连接（Opus 解码器不持有数据源）
+type closingDecoder struct {
+	Decoder
+	conn *streamConn
+}
+
+func (d *closingDecoder) Close() error {
+	err := d.Decoder.Close()
+	d.conn.close()
+	return err
+}
+
+// icyReader 去除 Icecast/SHOUTcast 插入在音频数据中的元数据块
+// 每 metaint 字节音频后跟一个长度字节（×16）和元数据，如 StreamTitle='歌手 - 歌名';
+type icyReader struct {
+	r         io.Reader
+	metaint   int
+	remaining int // 距下一个元数据块的音频字节数
+	onTitle   func(string)
+	title     string
+}
+
+func (r *icyReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		if err := r.readMetadata(); err != nil {
+			return 0, err
+		}
+		r.remaining = r.metaint
+	}
+
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= n
+	return n, err
+}
+
+func (r *icyReader) readMetadata() error {
+	var length [1]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		return err
+	}
+	if length[0] == 0 {
+		return nil
+	}
+
+	meta := make([]byte, int(length[0])*16)
+	if _, err := io.ReadFull(r.r, meta); err != nil {
+		return err
+	}
+
+	title, ok := parseStreamTitle(string(meta))
+	if ok && title != r.title {
+		r.title = title
+		if r.onTitle != nil {
+			r.onTitle(title)
+		}
+	}
+	return nil
+}
+
+// parseStreamTitle 从元数据中提取 StreamTitle
+func parseStreamTitle(meta string) (string, bool) {
+	meta = strings.TrimRight(meta, "\x00")
+	const key = "StreamTitle='"
+	start := strings.Index(meta, key)
+	if start < 0 {
+		return "", false
+	}
+	value := meta[start+len(key):]
+	if end := strings.Index(value, "';"); end >= 0 {
+		value = value[:end]
+	} else {
+		value = strings.TrimSuffix(value, "'")
+	}
+	if !utf8.ValidString(value) {
+		value = strings.ToValidUTF8(value, "")
+	}
+	return strings.TrimSpace(value), true
+}
+
+// ============================================================================
+// 电台
+// ============================================================================
+
+// ErrNoStations 未配置电台
+var ErrNoStations = errors.New("no radio stations configured")
+
+// SetStations 设置电台列表，Path 为流地址
+func (p *Player) SetStations(stations []SongInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stations = stations
+}
+
+// Stations 返回电台列表
+func (p *Player) Stations() []SongInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]SongInfo(nil), p.stations...)
+}
+
+// FindStation 按名称查找电台，支持拼音和模糊匹配
+func (p *Player) FindStation(name string) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.stations) == 0 {
+		return -1, ErrNoStations
+	}
+	query := newSearchText(name)
+	best, bestScore := -1, 0.0
+	for i, station := range p.stations {
+		if s := textSimilarity(query, newSearchText(station.Name)); s > bestScore {
+			best, bestScore = i, s
+		}
+	}
+	if bestScore < MinSearchScore {
+		return -1, fmt.Errorf("radio station %q not found", name)
+	}
+	return best, nil
+}
+
+// PlayStation 播放指定电台
+func (p *Player) PlayStation(index int) error {
+	p.mu.Lock()
+	if index < 0 || index >= len(p.stations) {
+		p.mu.Unlock()
+		return fmt.Errorf("invalid station index: %d", index)
+	}
+	station := p.stations[index]
+	p.mu.Unlock()
+
+	return p.PlayStream(station)
+}
+
+// PlayStream 播放网络流，之后的上一首/下一首在电台列表中切换
+func (p *Player) PlayStream(station SongInfo) error {
+	if !IsStream(station.Path) {
+		return fmt.Errorf("not a stream url: %s", station.Path)
+	}
+	if station.Name == "" {
+		station.Name = station.Path
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.playing {
+		close(p.stopChan)
+	}
+	p.resumeLocked()
+
+	p.radio = &station
+	p.streamTitle = ""
+	p.playing = true
+	p.position = 0
+	p.duration = 0
+	p.seekPending = false
+	p.stopChan = make(chan struct{})
+
+	p.logger.Info("Playing stream", "name", station.Name, "url", station.Path)
+	go p.playLoop(p.stopChan)
+	return nil
+}
+
+// OnStreamEnd 设置网络流自然播完（不是被停止或切换）时的回调，如投屏时接着播放下一首
+func (p *Player) OnStreamEnd(fn func(SongInfo)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onStreamEnd = fn
+}
+
+// IsRadio 当前音源是否为网络流
+func (p *Player) IsRadio() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.radio != nil
+}
+
+// adjacentStationLocked 当前电台的上一个或下一个电台
+func (p *Player) adjacentStationLocked(step int) (SongInfo, bool) {
+	if p.radio == nil || len(p.stations) == 0 {
+		return SongInfo{}, false
+	}
+	current := -1
+	for i, station := range p.stations {
+		if station.Path == p.radio.Path {
+			current = i
+			break
+		}
+	}
+	count := len(p.stations)
+	next := ((current+step)%count + count) % count
+	if current < 0 && step < 0 {
+		next = count - 1
+	}
+	return p.stations[next], true
+}
+
+// setStreamTitle 更新网络流的当前节目/歌曲名称
+func (p *Player) setStreamTitle(title string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.radio == nil {
+		return
+	}
+	p.streamTitle = title
+	p.logger.Info("Stream title changed", "station", p.radio.Name, "title", title)
+}
@@ -0,0 +1,359 @@
+package music
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+func TestDetectStreamFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      []byte
+		contentType string
+		want        Format
+	}{
+		{"mp3 frame sync", []byte{0xFF, 0xFB, 0x90, 0x00}, "", FormatMP3},
+		{"mp3 id3", []byte("ID3\x04\x00"), "application/octet-stream", FormatMP3},
+		{"adts", []byte{0xFF, 0xF1, 0x50, 0x80}, "audio/mpeg", FormatAAC},
+		{"ogg vorbis", append([]byte("OggS\x00\x02"), []byte("....\x01vorbis")...), "", FormatVorbis},
+		{"ogg opus", append([]byte("OggS\x00\x02"), []byte("....OpusHead")...), "audio/ogg", FormatOpus},
+		{"content type mpeg", []byte("junk"), "audio/mpeg", FormatMP3},
+		{"content type aacp", []byte("junk"), "audio/aacp", FormatAAC},
+		{"content type mp4", []byte("\x00\x00\x00\x20ftypM4A "), "audio/mp4", FormatAAC},
+		{"content type with params", []byte("junk"), "audio/ogg; codecs=vorbis", FormatVorbis},
+		{"content type opus", nil, "audio/opus", FormatOpus},
+		{"unknown", []byte("<html>"), "text/html; charset=utf-8", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectStreamFormat(tt.header, tt.contentType); got != tt.want {
+				t.Errorf("detectStreamFormat = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStreamTitle(t *testing.T) {
+	tests := []struct {
+		meta   string
+		want   string
+		wantOK bool
+	}{
+		{"StreamTitle='歌手 - 歌名';StreamUrl='';\x00\x00", "歌手 - 歌名", true},
+		{"StreamTitle='It''s';", "It''s", true},
+		{"StreamTitle='no terminator'\x00", "no terminator", true},
+		{"StreamTitle='';", "", true},
+		{"StreamUrl='http://example.com';", "", false},
+		{"StreamTitle=' bad \xff utf8 ';", "bad  utf8", true},
+	}
+	for _, tt := range tests {
+		got, ok := parseStreamTitle(tt.meta)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseStreamTitle(%q) = %q, %v; want %q, %v", tt.meta, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// icyBody 按 metaint 在音频数据中插入元数据块
+func icyBody(audio []byte, metaint int, titles []string) []byte {
+	var out bytes.Buffer
+	for i := 0; len(audio) > 0; i++ {
+		n := min(metaint, len(audio))
+		out.Write(audio[:n])
+		audio = audio[n:]
+		if n < metaint {
+			break
+		}
+		if i >= len(titles) {
+			out.WriteByte(0)
+			continue
+		}
+		meta := []byte(fmt.Sprintf("StreamTitle='%s';", titles[i]))
+		blocks := (len(meta) + 15) / 16
+		out.WriteByte(byte(blocks))
+		out.Write(meta)
+		out.Write(make([]byte, blocks*16-len(meta)))
+	}
+	return out.Bytes()
+}
+
+func TestStreamConnICYMetadata(t *testing.T) {
+	audio := bytes.Repeat([]byte("0123456789abcdef"), 64) // 1024 字节
+	const metaint = 100
+	titles := []string{"第一首", "第一首", "Artist - Second", "", "第三首"}
+	body := icyBody(audio, metaint, titles)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Icy-MetaData") != "1" {
+			t.Errorf("Icy-MetaData header = %q", r.Header.Get("Icy-MetaData"))
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("Icy-Metaint", strconv.Itoa(metaint))
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	var got []string
+	conn, err := openStreamConn(context.Background(), srv.URL, func(title string) { got = append(got, title) }, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	if conn.metaint != metaint || conn.finite {
+		t.Errorf("metaint = %d, finite = %v; want %d, false", conn.metaint, conn.finite, metaint)
+	}
+	data, err := io.ReadAll(conn.reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, audio) {
+		t.Errorf("audio data mismatch: got %d bytes, want %d", len(data), len(audio))
+	}
+	// 重复的标题只回调一次，空标题也是一次变化
+	want := []string{"第一首", "Artist - Second", "", "第三首"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("titles = %q, want %q", got, want)
+	}
+}
+
+// cutServer 返回有长度的响应，每个连接只发送 chunk 字节后断开，rangeOK 决定是否支持 Range
+func cutServer(t *testing.T, data []byte, chunk int, rangeOK bool) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		requests := len(ranges)
+		mu.Unlock()
+
+		start := 0
+		if rng := r.Header.Get("Range"); rng != "" && rangeOK {
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if err != nil {
+				t.Errorf("bad Range header %q", rng)
+			}
+			start = n
+		}
+
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)-start))
+		if start > 0 {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		// 没有 Range 支持时每次从头发送，多发送已读取的部分以便测试推进
+		end := min(start+chunk, len(data))
+		if !rangeOK {
+			end = min(chunk*requests, len(data))
+		}
+		w.Write(data[start:end])
+	}))
+	return srv, &ranges
+}
+
+func TestStreamConnResume(t *testing.T) {
+	data := make([]byte, 10000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	tests := []struct {
+		name       string
+		rangeOK    bool
+		wantRanges []string
+	}{
+		{"range", true, []string{"", "bytes=4000-", "bytes=8000-"}},
+		{"no range support", false, []string{"", "bytes=4000-", "bytes=8000-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, ranges := cutServer(t, data, 4000, tt.rangeOK)
+			defer srv.Close()
+
+			conn, err := openStreamConn(context.Background(), srv.URL, nil, testLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.close()
+			if !conn.finite || conn.length != int64(len(data)) {
+				t.Fatalf("finite = %v, length = %d", conn.finite, conn.length)
+			}
+
+			got, err := io.ReadAll(conn.reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("resumed data mismatch: got %d bytes, want %d", len(got), len(data))
+			}
+			if strings.Join(*ranges, ",") != strings.Join(tt.wantRanges, ",") {
+				t.Errorf("ranges = %q, want %q", *ranges, tt.wantRanges)
+			}
+		})
+	}
+}
+
+func TestStreamConnStopDuringResume(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			http.Error(w, "gone", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Length", "100")
+		w.Write(make([]byte, 10))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := openStreamConn(ctx, srv.URL, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.close()
+
+	buf := make([]byte, 100)
+	if n, _ := io.ReadFull(conn.reader, buf[:10]); n != 10 {
+		t.Fatalf("read %d bytes, want 10", n)
+	}
+
+	// 续传等待期间停止播放，读取立即结束
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := conn.reader.Read(buf); err != io.EOF {
+		t.Errorf("read after stop = %v, want EOF", err)
+	}
+}
+
+func TestOpenStreamUnsupported(t *testing.T) {
+	// 清空 PATH，确保找不到 ffmpeg
+	t.Setenv("PATH", t.TempDir())
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{"html", "text/html", []byte("<html></html>")},
+		{"aac without external decoder", "audio/aac", []byte{0xFF, 0xF1, 0x50, 0x80, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			_, err := OpenStream(srv.URL, make(chan struct{}), nil, testLogger())
+			if !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("OpenStream error = %v, want ErrUnsupportedFormat", err)
+			}
+		})
+	}
+}
+
+func TestCheckStation(t *testing.T) {
+	tests := map[string]bool{
+		"http://radio.local:8000/stream":       true,
+		"https://radio.local/live.mp3":         true,
+		"http://radio.local/live.AAC?token=1":  false,
+		"http://radio.local/mount/station.m4a": false,
+		"/media/music/song.mp3":                false,
+		"rtsp://radio.local/live":              false,
+	}
+	for url, ok := range tests {
+		if err := CheckStation(url); (err == nil) != ok {
+			t.Errorf("CheckStation(%q) = %v, want ok=%v", url, err, ok)
+		}
+	}
+}
+
+func TestOpenStreamHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	if _, err := OpenStream(srv.URL, make(chan struct{}), nil, testLogger()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("OpenStream error = %v, want HTTP 404", err)
+	}
+}
+
+func TestStreamDecoderReconnect(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	// 用原样输出标准输入的脚本代替 ffmpeg，使 AAC 数据直接作为 PCM 读出
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte("#!/bin/sh\nexec /bin/cat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	if err := SetExternalDecoder(true); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetExternalDecoder(false) })
+
+	chunk := make([]byte, 4000)
+	chunk[0], chunk[1] = 0xFF, 0xF1 // ADTS 同步字
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		// 直播流没有长度，发送一段后断开
+		w.Header().Set("Content-Type", "audio/aac")
+		w.Write(chunk)
+		w.(http.Flusher).Flush()
+	}))
+	defer srv.Close()
+
+	stop := make(chan struct{})
+	dec, err := OpenStream(srv.URL, stop, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	if dec.SampleRate() != externalSampleRate || dec.Channels() != externalChannels {
+		t.Errorf("format = %d Hz/%d ch", dec.SampleRate(), dec.Channels())
+	}
+
+	// 读完两次连接的数据，第二次需要重连
+	pcm := make([]int16, 1000)
+	total := 0
+	for total < len(chunk) {
+		n, err := dec.Read(pcm)
+		if err != nil {
+			t.Fatalf("Read after %d samples: %v", total, err)
+		}
+		total += n
+	}
+	mu.Lock()
+	got := requests
+	mu.Unlock()
+	if got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+
+	close(stop)
+	if _, err := dec.Read(pcm); err != nil && err != io.EOF {
+		t.Errorf("Read after stop = %v", err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 var (
 	ErrConnectionFailed    = errors.New("connection failed")
 	ErrUnsupportedProtocol = errors.New("unsupported protocol")
+	ErrAuthFailed          = errors.New("authentication failed")
 )
 
 type TransportProtocol interface {
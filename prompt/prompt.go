@@ -0,0 +1,217 @@
+package prompt
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/audio"
+)
+
+// ErrClipNotFound 当前语言下没有录制对应的语音
+var ErrClipNotFound = errors.New("voice prompt clip not found")
+
+// DefaultLocale 默认语言，其他语言缺少某条语音时回退到该语言
+const DefaultLocale = "zh-CN"
+
+// 内置语音包，按 assets/<locale>/<key>.wav 组织
+//
+//go:embed assets/*/*.wav
+var defaultAssets embed.FS
+
+// 支持的语音文件扩展名，按优先级排列
+var clipExts = []string{".opus", ".ogg", ".wav"}
+
+// Key 语音提示条件
+type Key string
+
+const (
+	KeyNotConnected       Key = "not_connected"       // 未连接服务器时尝试对话
+	KeyConnectFailed      Key = "connect_failed"      // 连接服务器失败
+	KeyReconnected        Key = "reconnected"         // 断线后重新连接成功
+	KeyServerError        Key = "server_error"        // 服务器返回错误
+	KeyAuthFailed         Key = "auth_failed"         // 鉴权失败
+	KeyActivationRequired Key = "activation_required" // 设备需要激活
+	KeyMicUnavailable     Key = "mic_unavailable"     // 麦克风不可用
+)
+
+// transcripts 各语言的提示文本，也是录制语音时使用的台本
+var transcripts = map[string]map[Key]string{
+	"zh-CN": {
+		KeyNotConnected:       "网络未连接，正在尝试重新连接，请稍后再试。",
+		KeyConnectFailed:      "无法连接到服务器，请检查网络设置。",
+		KeyReconnected:        "网络已恢复。",
+		KeyServerError:        "服务器出了点问题，请稍后再试。",
+		KeyAuthFailed:         "设备鉴权失败，请检查访问令牌配置。",
+		KeyActivationRequired: "设备尚未激活，请先在控制台完成激活。",
+		KeyMicUnavailable:     "麦克风不可用，正在尝试恢复。",
+	},
+	"en-US": {
+		KeyNotConnected:       "I'm offline right now. Reconnecting, please try again in a moment.",
+		KeyConnectFailed:      "I can't reach the server. Please check the network settings.",
+		KeyReconnected:        "I'm back online.",
+		KeyServerError:        "Something went wrong on the server. Please try again later.",
+		KeyAuthFailed:         "Authentication failed. Please check the access token.",
+		KeyActivationRequired: "This device isn't activated yet. Please activate it in the console first.",
+		KeyMicUnavailable:     "The microphone is unavailable. Trying to recover.",
+	},
+}
+
+// Config 离线语音提示配置
+type Config struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Locale  string `mapstructure:"locale"` // 语言，如 zh-CN、en-US
+	Path    string `mapstructure:"path"`   // 自定义语音包目录，按 <path>/<locale>/<key>.opus 组织，优先于内置语音
+	Volume  int    `mapstructure:"volume"` // 0-100，0 表示 100
+}
+
+// Library 离线语音提示库，解码后的语音会被缓存
+type Library struct {
+	config   Config
+	audioCfg audio.Config
+	logger   *slog.Logger
+	locale   string
+
+	mu    sync.Mutex
+	cache map[Key][]int16
+}
+
+// NewLibrary 创建语音提示库
+func NewLibrary(cfg Config, audioCfg audio.Config, logger *slog.Logger) *Library {
+	return &Library{
+		config:   cfg,
+		audioCfg: audioCfg,
+		logger:   logger,
+		locale:   resolveLocale(cfg.Locale),
+		cache:    make(map[Key][]int16),
+	}
+}
+
+// resolveLocale 选择最接近的已支持语言：完全匹配、语言前缀匹配，否则使用默认语言
+func resolveLocale(locale string) string {
+	if locale == "" {
+		return DefaultLocale
+	}
+	locale = strings.ReplaceAll(locale, "_", "-")
+	for l := range transcripts {
+		if strings.EqualFold(l, locale) {
+			return l
+		}
+	}
+	lang := strings.SplitN(locale, "-", 2)[0]
+	for l := range transcripts {
+		if strings.EqualFold(strings.SplitN(l, "-", 2)[0], lang) {
+			return l
+		}
+	}
+	return DefaultLocale
+}
+
+// Enabled 是否启用语音提示
+func (l *Library) Enabled() bool {
+	return l.config.Enabled
+}
+
+// Locale 返回实际使用的语言
+func (l *Library) Locale() string {
+	return l.locale
+}
+
+// Text 返回提示的文本
+func (l *Library) Text(key Key) string {
+	if text, ok := transcripts[l.locale][key]; ok {
+		return text
+	}
+	return transcripts[DefaultLocale][key]
+}
+
+// Play 通过音频管理器播放语音提示，返回语音时长
+// 自定义目录和内置语音包都没有对应语音时返回 ErrClipNotFound，调用方可改用提示音
+func (l *Library) Play(m audio.Manager, key Key) (time.Duration, error) {
+	if !l.config.Enabled {
+		return 0, nil
+	}
+	if m == nil {
+		return 0, fmt.Errorf("audio manager not initialized")
+	}
+
+	pcm, err := l.load(key)
+	if err != nil {
+		return 0, err
+	}
+
+	samples := make([]int16, len(pcm))
+	copy(samples, pcm)
+	if l.config.Volume > 0 {
+		audio.ApplyGain(samples, audio.VolumeToGain(l.config.Volume))
+	}
+
+	l.logger.Info("Playing voice prompt", "key", key, "locale", l.locale, "text", l.Text(key))
+	return audio.PlayClip(m, samples, l.audioCfg)
+}
+
+// load 加载并缓存语音，依次查找自定义目录和内置语音包，当前语言缺失时回退到默认语言
+func (l *Library) load(key Key) ([]int16, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if pcm, ok := l.cache[key]; ok {
+		return pcm, nil
+	}
+
+	locales := []string{l.locale}
+	if l.locale != DefaultLocale {
+		locales = append(locales, DefaultLocale)
+	}
+
+	var r io.Reader
+	var name string
+	for _, locale := range locales {
+		if name = l.findClip(locale, key); name != "" {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			r = bytes.NewReader(data)
+			break
+		}
+		name = "assets/" + locale + "/" + string(key) + ".wav"
+		if data, err := defaultAssets.ReadFile(name); err == nil {
+			r = bytes.NewReader(data)
+			break
+		}
+	}
+	if r == nil {
+		return nil, fmt.Errorf("%w: %s/%s", ErrClipNotFound, l.locale, key)
+	}
+
+	pcm, err := audio.DecodeClip(r, name, l.audioCfg.SampleRate, l.audioCfg.Channels, l.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode voice prompt %s: %w", name, err)
+	}
+
+	l.cache[key] = pcm
+	return pcm, nil
+}
+
+// findClip 在自定义目录中查找语音文件，未配置目录或文件不存在时返回空
+func (l *Library) findClip(locale string, key Key) string {
+	if l.config.Path == "" {
+		return ""
+	}
+	for _, ext := range clipExts {
+		path := filepath.Join(l.config.Path, locale, string(key)+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
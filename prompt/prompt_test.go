@@ -0,0 +1,134 @@
+package prompt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lisuiheng/xiaozhi-go/audio"
+)
+
+// writeClip 写入一段 16kHz 单声道 WAV 语音
+func writeClip(t *testing.T, root, locale string, key Key, samples int) {
+	t.Helper()
+	dir := filepath.Join(root, locale)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	dataSize := uint32(samples * 2)
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, 36+dataSize)
+	buf.WriteString("WAVEfmt ")
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), uint32(16000), uint32(32000), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, dataSize)
+	buf.Write(make([]byte, dataSize))
+
+	if err := os.WriteFile(filepath.Join(dir, string(key)+".wav"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestLibrary(root, locale string) *Library {
+	cfg := Config{Enabled: true, Locale: locale, Path: root}
+	audioCfg := audio.Config{SampleRate: 16000, Channels: 1, FrameDuration: 60}
+	return NewLibrary(cfg, audioCfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestResolveLocale(t *testing.T) {
+	tests := map[string]string{
+		"":      DefaultLocale,
+		"zh-CN": "zh-CN",
+		"en_us": "en-US",
+		"en-GB": "en-US",
+		"zh-TW": "zh-CN",
+		"fr-FR": DefaultLocale,
+	}
+	for in, want := range tests {
+		if got := resolveLocale(in); got != want {
+			t.Errorf("resolveLocale(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBuiltinClips(t *testing.T) {
+	for locale, texts := range transcripts {
+		lib := newTestLibrary("", locale)
+		for key := range texts {
+			name := "assets/" + locale + "/" + string(key) + ".wav"
+			if _, err := defaultAssets.ReadFile(name); err != nil {
+				t.Errorf("built-in clip %s missing", name)
+				continue
+			}
+			pcm, err := lib.load(key)
+			if err != nil {
+				t.Errorf("load %s/%s: %v", locale, key, err)
+			} else if len(pcm) == 0 {
+				t.Errorf("load %s/%s: empty clip", locale, key)
+			}
+		}
+	}
+}
+
+func TestLoadPrefersCustomClips(t *testing.T) {
+	root := t.TempDir()
+	writeClip(t, root, "en-US", KeyNotConnected, 160)
+	writeClip(t, root, DefaultLocale, KeyServerError, 480)
+	writeClip(t, root, DefaultLocale, Key("custom_only"), 320)
+
+	lib := newTestLibrary(root, "en-US")
+
+	// 自定义语音优先于内置语音
+	pcm, err := lib.load(KeyNotConnected)
+	if err != nil {
+		t.Fatalf("load(%s): %v", KeyNotConnected, err)
+	}
+	if len(pcm) != 160 {
+		t.Errorf("load(%s) = %d samples, want the custom en-US clip (160)", KeyNotConnected, len(pcm))
+	}
+
+	// 当前语言的内置语音优先于默认语言的自定义语音
+	pcm, err = lib.load(KeyServerError)
+	if err != nil {
+		t.Fatalf("load(%s): %v", KeyServerError, err)
+	}
+	if len(pcm) == 480 {
+		t.Errorf("load(%s) used the %s custom clip, want the built-in en-US clip", KeyServerError, DefaultLocale)
+	}
+
+	// 两种语言都没有内置语音时回退到默认语言的自定义语音
+	pcm, err = lib.load("custom_only")
+	if err != nil {
+		t.Fatalf("load(custom_only): %v", err)
+	}
+	if len(pcm) != 320 {
+		t.Errorf("load(custom_only) = %d samples, want the %s clip (320)", len(pcm), DefaultLocale)
+	}
+
+	if _, err := lib.load("missing"); !errors.Is(err, ErrClipNotFound) {
+		t.Errorf("load(missing) error = %v, want ErrClipNotFound", err)
+	}
+}
+
+func TestText(t *testing.T) {
+	lib := newTestLibrary(t.TempDir(), "en-US")
+	if got := lib.Text(KeyAuthFailed); got != transcripts["en-US"][KeyAuthFailed] {
+		t.Errorf("Text(%s) = %q", KeyAuthFailed, got)
+	}
+	for locale, texts := range transcripts {
+		for key := range transcripts[DefaultLocale] {
+			if texts[key] == "" {
+				t.Errorf("transcript %s/%s missing", locale, key)
+			}
+		}
+	}
+}
@@ -61,8 +61,12 @@ func (p *WSProtocol) Connect(ctx context.Context) error {
 	headers.Set("Client-Id", p.config.Device.UUID)
 
 	dialer := websocket.DefaultDialer
-	conn, _, err := dialer.DialContext(ctx, p.config.Server.URL, headers)
+	conn, resp, err := dialer.DialContext(ctx, p.config.Server.URL, headers)
 	if err != nil {
+		// 握手被拒绝（401/403）说明令牌或设备身份无效
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("%w: %v (status %d)", interfaces.ErrAuthFailed, err, resp.StatusCode)
+		}
 		return fmt.Errorf("%w: %v", interfaces.ErrConnectionFailed, err)
 	}
 	p.conn = conn
@@ -0,0 +1,256 @@
+package upnp
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/music"
+)
+
+// avTransport 处理 AVTransport 服务的动作
+func (r *Renderer) avTransport(name string, in args) (args, error) {
+	if err := checkInstance(in); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "SetAVTransportURI":
+		return nil, r.setURI(in.get("CurrentURI"), in.get("CurrentURIMetaData"))
+	case "SetNextAVTransportURI":
+		return nil, r.setNextURI(in.get("NextURI"), in.get("NextURIMetaData"))
+	case "Play":
+		if r.transportState() == statePaused {
+			r.player.Resume()
+			return nil, nil
+		}
+		r.mu.Lock()
+		empty := r.media.uri == ""
+		r.mu.Unlock()
+		if empty {
+			return nil, newError(errNoContents, "no media set")
+		}
+		return nil, r.play()
+	case "Pause":
+		if !r.playing() {
+			return nil, newError(errTransitionInvalid, "not playing")
+		}
+		r.player.Pause()
+		return nil, nil
+	case "Stop":
+		if r.playing() {
+			r.player.Stop()
+		}
+		r.mu.Lock()
+		r.starting = false
+		r.mu.Unlock()
+		return nil, nil
+	case "Seek":
+		return nil, r.seek(in.get("Unit"), in.get("Target"))
+	case "Next":
+		r.mu.Lock()
+		if r.next.uri == "" {
+			r.mu.Unlock()
+			return nil, newError(errTransitionInvalid, "no next track")
+		}
+		r.media, r.next = r.next, track{}
+		r.mu.Unlock()
+		return nil, r.play()
+	case "Previous":
+		return nil, newError(errTransitionInvalid, "no previous track")
+	case "GetTransportInfo":
+		return args{
+			{"CurrentTransportState", r.transportState()},
+			{"CurrentTransportStatus", "OK"},
+			{"CurrentSpeed", "1"},
+		}, nil
+	case "GetPositionInfo":
+		state := r.transportState()
+		r.mu.Lock()
+		media := r.media
+		r.mu.Unlock()
+		duration, position := media.duration, time.Duration(0)
+		if state == statePlaying || state == statePaused {
+			status := r.player.Status()
+			position = status.Position
+			if status.Duration > 0 {
+				duration = status.Duration
+			}
+		}
+		trackNumber := "0"
+		if media.uri != "" {
+			trackNumber = "1"
+		}
+		return args{
+			{"Track", trackNumber},
+			{"TrackDuration", formatDuration(duration)},
+			{"TrackMetaData", media.metadata},
+			{"TrackURI", media.uri},
+			{"RelTime", formatDuration(position)},
+			{"AbsTime", formatDuration(position)},
+			{"RelCount", "2147483647"},
+			{"AbsCount", "2147483647"},
+		}, nil
+	case "GetMediaInfo":
+		r.mu.Lock()
+		media, next := r.media, r.next
+		r.mu.Unlock()
+		tracks, medium := "0", "NONE"
+		if media.uri != "" {
+			tracks, medium = "1", "NETWORK"
+		}
+		return args{
+			{"NrTracks", tracks},
+			{"MediaDuration", formatDuration(media.duration)},
+			{"CurrentURI", media.uri},
+			{"CurrentURIMetaData", media.metadata},
+			{"NextURI", next.uri},
+			{"NextURIMetaData", next.metadata},
+			{"PlayMedium", medium},
+			{"RecordMedium", "NOT_IMPLEMENTED"},
+			{"WriteStatus", "NOT_IMPLEMENTED"},
+		}, nil
+	case "GetDeviceCapabilities":
+		return args{
+			{"PlayMedia", "NETWORK"},
+			{"RecMedia", "NOT_IMPLEMENTED"},
+			{"RecQualityModes", "NOT_IMPLEMENTED"},
+		}, nil
+	case "GetTransportSettings":
+		return args{
+			{"PlayMode", "NORMAL"},
+			{"RecQualityMode", "NOT_IMPLEMENTED"},
+		}, nil
+	case "GetCurrentTransportActions":
+		return args{{"Actions", r.transportActions()}}, nil
+	}
+	return nil, newError(errInvalidAction, "unknown action %s", name)
+}
+
+// checkInstance 只支持实例 0
+func checkInstance(in args) error {
+	if id := strings.TrimSpace(in.get("InstanceID")); id != "0" && id != "" {
+		return newError(errInvalidInstanceID, "invalid instance id %s", id)
+	}
+	return nil
+}
+
+// setURI 设置当前地址；正在播放投屏内容时立即切换到新地址
+func (r *Renderer) setURI(uri, metadata string) error {
+	t, err := newTrack(uri, metadata)
+	if err != nil {
+		return err
+	}
+	wasPlaying := r.transportState() == statePlaying
+
+	r.mu.Lock()
+	r.media = t
+	r.next = track{}
+	r.mu.Unlock()
+
+	r.logger.Info("UPnP media set", "uri", t.uri, "title", t.title)
+	if wasPlaying {
+		return r.play()
+	}
+	return nil
+}
+
+// setNextURI 设置当前地址播完后接着播放的地址，uri 为空时清除
+func (r *Renderer) setNextURI(uri, metadata string) error {
+	var t track
+	if uri != "" {
+		var err error
+		if t, err = newTrack(uri, metadata); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	r.next = t
+	r.mu.Unlock()
+	return nil
+}
+
+// seek 网络流不支持定位，播放器返回错误时转换为 710
+func (r *Renderer) seek(unit, target string) error {
+	if unit != "REL_TIME" && unit != "ABS_TIME" {
+		return newError(errSeekNotSupported, "unsupported seek mode %s", unit)
+	}
+	pos, err := parseDuration(target)
+	if err != nil {
+		return newError(errIllegalSeekTarget, "%v", err)
+	}
+	if !r.playing() {
+		return newError(errTransitionInvalid, "not playing")
+	}
+	if _, err := r.player.Seek(pos); err != nil {
+		return newError(errSeekNotSupported, "%v", err)
+	}
+	return nil
+}
+
+// transportActions 当前状态下可用的操作
+func (r *Renderer) transportActions() string {
+	r.mu.Lock()
+	hasNext := r.next.uri != ""
+	r.mu.Unlock()
+
+	var actions []string
+	switch r.transportState() {
+	case statePlaying:
+		actions = []string{"Pause", "Stop"}
+	case statePaused:
+		actions = []string{"Play", "Stop"}
+	case stateStopped:
+		actions = []string{"Play"}
+	}
+	if hasNext {
+		actions = append(actions, "Next")
+	}
+	return strings.Join(actions, ",")
+}
+
+// newTrack 校验地址并解析元数据
+func newTrack(uri, metadata string) (track, error) {
+	uri = strings.TrimSpace(uri)
+	if uri == "" {
+		return track{}, newError(errInvalidArgs, "empty uri")
+	}
+	if !music.IsStream(uri) {
+		return track{}, newError(errUnsupportedFormat, "unsupported uri %s", uri)
+	}
+	t := track{uri: uri, metadata: metadata}
+	t.title, t.artist, t.duration = parseDIDL(metadata)
+	return t, nil
+}
+
+// parseDIDL 从 DIDL-Lite 元数据中取出标题、艺术家和时长，解析失败时返回空值
+func parseDIDL(metadata string) (title, artist string, duration time.Duration) {
+	if strings.TrimSpace(metadata) == "" {
+		return "", "", 0
+	}
+	var doc struct {
+		Items []struct {
+			Title   string `xml:"title"`
+			Creator string `xml:"creator"`
+			Artist  string `xml:"artist"`
+			Res     []struct {
+				Duration string `xml:"duration,attr"`
+			} `xml:"res"`
+		} `xml:"item"`
+	}
+	if err := xml.Unmarshal([]byte(metadata), &doc); err != nil || len(doc.Items) == 0 {
+		return "", "", 0
+	}
+	item := doc.Items[0]
+	artist = item.Artist
+	if artist == "" {
+		artist = item.Creator
+	}
+	for _, res := range item.Res {
+		if d, err := parseDuration(res.Duration); err == nil {
+			duration = d
+			break
+		}
+	}
+	return strings.TrimSpace(item.Title), strings.TrimSpace(artist), duration
+}
@@ -0,0 +1,344 @@
+package upnp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件订阅参数
+const (
+	defaultTimeout = 1800 * time.Second
+	minTimeout     = 60 * time.Second
+	notifyTimeout  = 5 * time.Second
+	notifyQueue    = 16
+)
+
+// eventManager GENA 事件订阅：控制点订阅服务后，状态变量变化时向回调地址发送 NOTIFY
+type eventManager struct {
+	r      *Renderer
+	logger *slog.Logger
+	client *http.Client
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+	last map[*service]args // 上次通知时各服务的状态
+}
+
+// subscription 一个订阅
+type subscription struct {
+	sid       string
+	service   *service
+	callbacks []string
+	expires   time.Time
+	queue     chan []byte
+}
+
+func newEventManager(r *Renderer, logger *slog.Logger) *eventManager {
+	return &eventManager{
+		r:      r,
+		logger: logger,
+		client: &http.Client{Timeout: notifyTimeout},
+		subs:   make(map[string]*subscription),
+		last:   make(map[*service]args),
+	}
+}
+
+// handle 处理 SUBSCRIBE/UNSUBSCRIBE 请求
+func (m *eventManager) handle(w http.ResponseWriter, req *http.Request, s *service) {
+	switch req.Method {
+	case "SUBSCRIBE":
+		m.subscribe(w, req, s)
+	case "UNSUBSCRIBE":
+		m.mu.Lock()
+		sub, ok := m.subs[req.Header.Get("SID")]
+		if ok {
+			delete(m.subs, sub.sid)
+			close(sub.queue)
+		}
+		m.mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// subscribe 新建或续订订阅，新订阅会立即收到包含全部状态的初始事件
+func (m *eventManager) subscribe(w http.ResponseWriter, req *http.Request, s *service) {
+	timeout := parseTimeout(req.Header.Get("TIMEOUT"))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sid := req.Header.Get("SID"); sid != "" {
+		sub, ok := m.subs[sid]
+		if !ok || req.Header.Get("CALLBACK") != "" || req.Header.Get("NT") != "" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		sub.expires = time.Now().Add(timeout)
+		writeSubscribed(w, sid, timeout)
+		return
+	}
+
+	callbacks := parseCallbacks(req.Header.Get("CALLBACK"))
+	if req.Header.Get("NT") != "upnp:event" || len(callbacks) == 0 {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	sub := &subscription{
+		sid:       newSID(),
+		service:   s,
+		callbacks: callbacks,
+		expires:   time.Now().Add(timeout),
+		queue:     make(chan []byte, notifyQueue),
+	}
+	m.subs[sub.sid] = sub
+	go m.deliver(sub)
+	writeSubscribed(w, sub.sid, timeout)
+
+	sub.queue <- propertySet(s, m.state(s))
+	m.logger.Debug("UPnP subscription added", "service", s.name, "sid", sub.sid, "callback", callbacks[0])
+}
+
+func writeSubscribed(w http.ResponseWriter, sid string, timeout time.Duration) {
+	w.Header().Set("SID", sid)
+	w.Header().Set("TIMEOUT", fmt.Sprintf("Second-%d", int(timeout.Seconds())))
+	w.Header().Set("Server", serverHeader)
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseTimeout 解析 "Second-1800"，缺省或 infinite 时使用默认值
+func parseTimeout(s string) time.Duration {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(s), "Second-"))
+	if err != nil || n <= 0 {
+		return defaultTimeout
+	}
+	timeout := time.Duration(n) * time.Second
+	if timeout < minTimeout {
+		return minTimeout
+	}
+	if timeout > defaultTimeout {
+		return defaultTimeout
+	}
+	return timeout
+}
+
+// parseCallbacks 解析 "<http://a/><http://b/>"
+func parseCallbacks(s string) []string {
+	var callbacks []string
+	for _, part := range strings.Split(s, "<") {
+		url, _, ok := strings.Cut(part, ">")
+		if ok && strings.HasPrefix(url, "http://") {
+			callbacks = append(callbacks, url)
+		}
+	}
+	return callbacks
+}
+
+func newSID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// deliver 按顺序发送一个订阅的事件，SEQ 从 0 开始递增
+func (m *eventManager) deliver(sub *subscription) {
+	var seq uint32
+	for body := range sub.queue {
+		for _, callback := range sub.callbacks {
+			if err := m.notify(callback, sub.sid, seq, body); err != nil {
+				m.logger.Debug("UPnP event delivery failed", "callback", callback, "error", err)
+				continue
+			}
+			break
+		}
+		if seq == ^uint32(0) {
+			seq = 1 // 溢出时回到 1，0 只用于初始事件
+		} else {
+			seq++
+		}
+	}
+}
+
+func (m *eventManager) notify(callback, sid string, seq uint32, body []byte) error {
+	req, err := http.NewRequest("NOTIFY", callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("NT", "upnp:event")
+	req.Header.Set("NTS", "upnp:propchange")
+	req.Header.Set("SID", sid)
+	req.Header.Set("SEQ", strconv.FormatUint(uint64(seq), 10))
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// poll 比较各服务的状态，把变化的变量通知订阅者，并清理过期的订阅
+func (m *eventManager) poll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for sid, sub := range m.subs {
+		if now.After(sub.expires) {
+			delete(m.subs, sid)
+			close(sub.queue)
+			m.logger.Debug("UPnP subscription expired", "sid", sid)
+		}
+	}
+
+	for _, s := range []*service{avTransportService, renderingControlService} {
+		state := m.state(s)
+		last, ok := m.last[s]
+		m.last[s] = state
+		if !ok {
+			continue // 首次检查只记录状态，订阅时已发送完整的初始事件
+		}
+		changed := state.diff(last)
+		if len(changed) == 0 {
+			continue
+		}
+		body := propertySet(s, changed)
+		for _, sub := range m.subs {
+			if sub.service != s {
+				continue
+			}
+			select {
+			case sub.queue <- body:
+			default:
+				m.logger.Debug("UPnP event queue full, dropping event", "sid", sub.sid)
+			}
+		}
+	}
+}
+
+// close 结束所有订阅
+func (m *eventManager) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sid, sub := range m.subs {
+		delete(m.subs, sid)
+		close(sub.queue)
+	}
+}
+
+// diff 与上次相比值发生变化的变量
+func (a args) diff(last args) args {
+	var changed args
+	for _, v := range a {
+		found := false
+		for _, old := range last {
+			if old.name == v.name {
+				found = old.value == v.value
+				break
+			}
+		}
+		if !found {
+			changed = append(changed, v)
+		}
+	}
+	return changed
+}
+
+// state 服务的事件状态变量
+func (m *eventManager) state(s *service) args {
+	r := m.r
+	switch s {
+	case avTransportService:
+		state := r.transportState()
+		actions := r.transportActions()
+		r.mu.Lock()
+		media, next := r.media, r.next
+		r.mu.Unlock()
+		tracks, medium := "0", "NONE"
+		if media.uri != "" {
+			tracks, medium = "1", "NETWORK"
+		}
+		return args{
+			{"TransportState", state},
+			{"TransportStatus", "OK"},
+			{"TransportPlaySpeed", "1"},
+			{"CurrentPlayMode", "NORMAL"},
+			{"CurrentTransportActions", actions},
+			{"PlaybackStorageMedium", medium},
+			{"NumberOfTracks", tracks},
+			{"CurrentTrack", tracks},
+			{"AVTransportURI", media.uri},
+			{"AVTransportURIMetaData", media.metadata},
+			{"CurrentTrackURI", media.uri},
+			{"CurrentTrackMetaData", media.metadata},
+			{"CurrentTrackDuration", formatDuration(media.duration)},
+			{"CurrentMediaDuration", formatDuration(media.duration)},
+			{"NextAVTransportURI", next.uri},
+			{"NextAVTransportURIMetaData", next.metadata},
+		}
+	case renderingControlService:
+		r.mu.Lock()
+		muted := r.muted
+		r.mu.Unlock()
+		return args{
+			{"Volume", strconv.Itoa(r.volume())},
+			{"Mute", formatBool(muted)},
+			{"PresetNameList", "FactoryDefaults"},
+		}
+	}
+	return args{
+		{"SourceProtocolInfo", ""},
+		{"SinkProtocolInfo", sinkProtocolInfo()},
+		{"CurrentConnectionIDs", "0"},
+	}
+}
+
+// propertySet 事件消息体：AVTransport 和 RenderingControl 的变化放在 LastChange 中，
+// ConnectionManager 直接发送变量
+func propertySet(s *service, vars args) []byte {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	b.WriteString(`<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">`)
+
+	namespace := ""
+	switch s {
+	case avTransportService:
+		namespace = "urn:schemas-upnp-org:metadata-1-0/AVT/"
+	case renderingControlService:
+		namespace = "urn:schemas-upnp-org:metadata-1-0/RCS/"
+	}
+	if namespace == "" {
+		for _, v := range vars {
+			fmt.Fprintf(&b, "<e:property><%s>%s</%s></e:property>", v.name, escape(v.value), v.name)
+		}
+	} else {
+		var event strings.Builder
+		fmt.Fprintf(&event, `<Event xmlns="%s"><InstanceID val="0">`, namespace)
+		for _, v := range vars {
+			channel := ""
+			if v.name == "Volume" || v.name == "Mute" {
+				channel = ` channel="Master"`
+			}
+			fmt.Fprintf(&event, `<%s%s val="%s"/>`, v.name, channel, escape(v.value))
+		}
+		event.WriteString("</InstanceID></Event>")
+		fmt.Fprintf(&b, "<e:property><LastChange>%s</LastChange></e:property>", escape(event.String()))
+	}
+
+	b.WriteString("</e:propertyset>")
+	return []byte(b.String())
+}
@@ -0,0 +1,348 @@
+// Package upnp 实现 UPnP/DLNA 媒体渲染器（MediaRenderer），
+// 手机上的控制点可以把网络音频"投屏"到设备上播放
+package upnp
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/music"
+)
+
+// 渲染器参数
+const (
+	DefaultAddress = ":49494"
+	DefaultName    = "小智音箱"
+	maxRequestSize = 256 * 1024
+	pollInterval   = 500 * time.Millisecond // 检查播放状态变化的间隔，用于事件通知
+	serverHeader   = "Linux/1.0 UPnP/1.0 xiaozhi-go/1.0"
+)
+
+// 传输状态
+const (
+	stateStopped       = "STOPPED"
+	statePlaying       = "PLAYING"
+	statePaused        = "PAUSED_PLAYBACK"
+	stateTransitioning = "TRANSITIONING"
+	stateNoMedia       = "NO_MEDIA_PRESENT"
+)
+
+// Controller 由客户端实现的播放控制：开始播放前需要释放语音对话占用的音频设备
+type Controller interface {
+	// StartMusic 准备音频设备后调用 start 开始播放
+	StartMusic(start func() error) error
+	// Volume 当前音量 0-100，未知时返回 -1
+	Volume() int
+	SetVolume(volume int) error
+}
+
+// Renderer UPnP 媒体渲染器：SSDP 发现、设备描述、AVTransport/RenderingControl/ConnectionManager 服务
+// 投屏的地址作为网络流交给音乐播放器，传输状态始终由播放器的实际状态得出
+type Renderer struct {
+	name   string
+	udn    string
+	addr   string
+	player *music.Player
+	ctrl   Controller
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	media    track // 当前地址（AVTransportURI）
+	next     track // 下一首（NextAVTransportURI）
+	starting bool  // 已请求播放，正在等待提示音播完
+	muted    bool
+	unmute   int // 静音前的音量
+
+	server   *http.Server
+	listener net.Listener
+	ssdp     *ssdpServer
+	events   *eventManager
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// track 控制点设置的地址和 DIDL-Lite 元数据
+type track struct {
+	uri      string
+	metadata string
+	title    string
+	artist   string
+	duration time.Duration
+}
+
+// song 转换为播放器的网络流
+func (t track) song() music.SongInfo {
+	name := t.title
+	if name == "" {
+		name = t.uri
+	}
+	return music.SongInfo{Path: t.uri, Name: name, Title: t.title, Artist: t.artist, Duration: t.duration}
+}
+
+// NewRenderer 创建渲染器，name 为控制点中显示的名称，addr 为 HTTP 服务地址
+func NewRenderer(name, addr string, player *music.Player, ctrl Controller, logger *slog.Logger) *Renderer {
+	if name == "" {
+		name = DefaultName
+	}
+	if addr == "" {
+		addr = DefaultAddress
+	}
+	r := &Renderer{
+		name:   name,
+		udn:    deviceUDN(name),
+		addr:   addr,
+		player: player,
+		ctrl:   ctrl,
+		logger: logger,
+		unmute: -1,
+		done:   make(chan struct{}),
+	}
+	r.events = newEventManager(r, logger)
+	return r
+}
+
+// deviceUDN 由主机名和设备名生成固定的 UUID，重启后控制点仍能识别为同一设备
+func deviceUDN(name string) string {
+	host, _ := os.Hostname()
+	sum := sha1.Sum([]byte("xiaozhi-go/upnp/" + host + "/" + name))
+	sum[6] = sum[6]&0x0f | 0x50 // 版本 5
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Start 启动 HTTP 服务和 SSDP 发现；SSDP 失败（如没有组播路由）时只记录警告
+func (r *Renderer) Start() error {
+	ln, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", r.addr, err)
+	}
+	r.listener = ln
+	r.server = &http.Server{Handler: r.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := r.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.Warn("UPnP HTTP server stopped", "error", err)
+		}
+	}()
+
+	r.player.OnStreamEnd(r.streamEnded)
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	ssdp, err := newSSDPServer(r.udn, port, r.logger)
+	if err != nil {
+		r.logger.Warn("UPnP discovery unavailable, control points must be pointed at the description URL",
+			"url", fmt.Sprintf("http://<ip>:%d/description.xml", port), "error", err)
+	} else {
+		r.ssdp = ssdp
+		ssdp.start()
+	}
+
+	r.wg.Add(1)
+	go r.pollLoop()
+	r.logger.Info("UPnP renderer started", "name", r.name, "udn", r.udn, "address", ln.Addr().String())
+	return nil
+}
+
+// Addr HTTP 服务实际监听的地址
+func (r *Renderer) Addr() net.Addr {
+	if r.listener == nil {
+		return nil
+	}
+	return r.listener.Addr()
+}
+
+// Close 发送 byebye 并停止服务
+func (r *Renderer) Close() error {
+	select {
+	case <-r.done:
+		return nil
+	default:
+	}
+	close(r.done)
+	r.player.OnStreamEnd(nil)
+	if r.ssdp != nil {
+		r.ssdp.close()
+	}
+	r.wg.Wait()
+	var err error
+	if r.server != nil {
+		err = r.server.Close()
+	}
+	r.events.close()
+	return err
+}
+
+// handler 设备描述、服务描述、控制和事件订阅的路由
+func (r *Renderer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/description.xml", func(w http.ResponseWriter, req *http.Request) {
+		writeXML(w, deviceDescription(r.name, r.udn))
+	})
+	for _, s := range services {
+		s := s
+		mux.HandleFunc(s.scpdURL(), func(w http.ResponseWriter, req *http.Request) {
+			writeXML(w, s.scpd())
+		})
+		mux.HandleFunc(s.controlURL(), func(w http.ResponseWriter, req *http.Request) {
+			r.control(w, req, s)
+		})
+		mux.HandleFunc(s.eventURL(), func(w http.ResponseWriter, req *http.Request) {
+			r.events.handle(w, req, s)
+		})
+	}
+	return mux
+}
+
+// writeXML 输出描述文档
+func writeXML(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("Server", serverHeader)
+	w.Write(data)
+}
+
+// control 处理 SOAP 控制请求
+func (r *Renderer) control(w http.ResponseWriter, req *http.Request, s *service) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, in, err := readAction(req)
+	if err != nil {
+		writeFault(w, newError(errInvalidAction, "%v", err))
+		return
+	}
+
+	var out args
+	switch s {
+	case avTransportService:
+		out, err = r.avTransport(name, in)
+	case renderingControlService:
+		out, err = r.renderingControl(name, in)
+	default:
+		out, err = r.connectionManager(name, in)
+	}
+	if err != nil {
+		r.logger.Debug("UPnP action failed", "service", s.name, "action", name, "error", err)
+		writeFault(w, err)
+		return
+	}
+	r.logger.Debug("UPnP action", "service", s.name, "action", name)
+	writeResponse(w, s.serviceType, name, out)
+
+	// 控制点通常在操作后立即查询状态，同时尽快推送事件
+	r.events.poll()
+}
+
+// transportState 根据播放器状态得出传输状态：播放器正在播放其他音源时为 STOPPED
+func (r *Renderer) transportState() string {
+	status := r.player.Status()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.media.uri == "" {
+		return stateNoMedia
+	}
+	if status.Radio && status.Song != nil && status.Song.Path == r.media.uri {
+		switch status.State {
+		case music.StatePlaying:
+			return statePlaying
+		case music.StatePaused:
+			return statePaused
+		}
+	}
+	if r.starting {
+		return stateTransitioning
+	}
+	return stateStopped
+}
+
+// playing 播放器当前是否在播放（或暂停在）投屏的地址
+func (r *Renderer) playing() bool {
+	switch r.transportState() {
+	case statePlaying, statePaused:
+		return true
+	}
+	return false
+}
+
+// play 开始播放当前地址
+func (r *Renderer) play() error {
+	r.mu.Lock()
+	media := r.media
+	r.starting = true
+	r.mu.Unlock()
+
+	r.logger.Info("UPnP playback requested", "uri", media.uri, "title", media.title)
+	err := r.ctrl.StartMusic(func() error { return r.player.PlayStream(media.song()) })
+	r.mu.Lock()
+	r.starting = false
+	r.mu.Unlock()
+	if err != nil {
+		return newError(errActionFailed, "%v", err)
+	}
+	return nil
+}
+
+// streamEnded 投屏的地址自然播完时接着播放下一首
+// 此时音乐模式尚未退出，直接交给播放器而不经过 Controller
+func (r *Renderer) streamEnded(song music.SongInfo) {
+	r.mu.Lock()
+	if song.Path != r.media.uri || r.next.uri == "" {
+		r.mu.Unlock()
+		return
+	}
+	r.media, r.next = r.next, track{}
+	next := r.media
+	r.mu.Unlock()
+
+	r.logger.Info("UPnP playing next track", "uri", next.uri, "title", next.title)
+	if err := r.player.PlayStream(next.song()); err != nil {
+		r.logger.Warn("Failed to play next UPnP track", "uri", next.uri, "error", err)
+	}
+	r.events.poll()
+}
+
+// pollLoop 定期检查状态变化并通知订阅者
+func (r *Renderer) pollLoop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.events.poll()
+		}
+	}
+}
+
+// formatDuration 格式化为 H:MM:SS
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	s := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// parseDuration 解析 H:MM:SS[.F] 格式的时长
+func parseDuration(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+	var h, m int
+	var sec float64
+	if _, err := fmt.Sscanf(parts[0]+" "+parts[1]+" "+parts[2], "%d %d %g", &h, &m, &sec); err != nil {
+		return 0, fmt.Errorf("invalid duration: %q", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second)), nil
+}
@@ -0,0 +1,497 @@
+package upnp
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lisuiheng/xiaozhi-go/music"
+)
+
+// speaker 渲染器测试只关心音量，StartMusic 不准备设备直接开始播放
+type speaker struct{ volume atomic.Int32 }
+
+func (s *speaker) StartMusic(start func() error) error { return start() }
+func (s *speaker) Volume() int                         { return int(s.volume.Load()) }
+func (s *speaker) SetVolume(volume int) error          { s.volume.Store(int32(volume)); return nil }
+
+// startRenderer 用 httptest 提供渲染器的 HTTP 接口，不启动 SSDP
+func startRenderer(t *testing.T) (*Renderer, *httptest.Server) {
+	t.Helper()
+	logger := slog.New(slog.DiscardHandler)
+	player := music.NewPlayer(t.TempDir(), nil, logger)
+	player.SetOutput(music.NullOutput)
+	t.Cleanup(func() { player.Close() })
+
+	spk := &speaker{}
+	spk.volume.Store(40)
+	r := NewRenderer("测试音箱", "", player, spk, logger)
+	srv := httptest.NewServer(r.handler())
+	t.Cleanup(func() {
+		srv.Close()
+		r.Close()
+	})
+	return r, srv
+}
+
+// soapResult 解析后的 SOAP 响应或 Fault
+type soapResult struct {
+	status int
+	out    map[string]string
+	code   int
+}
+
+// call 向服务发送 SOAP 请求
+func call(t *testing.T, srv *httptest.Server, s *service, action string, in args) soapResult {
+	t.Helper()
+	var body strings.Builder
+	body.WriteString(xml.Header)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, s.serviceType)
+	for _, a := range in {
+		fmt.Fprintf(&body, "<%s>%s</%s>", a.name, escape(a.value), a.name)
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+s.controlURL(), strings.NewReader(body.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPACTION", fmt.Sprintf(`"%s#%s"`, s.serviceType, action))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// 响应和 Fault 都按 Envelope > Body > 元素 > 子元素 读取
+	var doc struct {
+		Body struct {
+			Inner struct {
+				XMLName xml.Name
+				Values  []struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				} `xml:",any"`
+				Code int `xml:"detail>UPnPError>errorCode"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("%s: invalid response: %v", action, err)
+	}
+	result := soapResult{status: resp.StatusCode, out: make(map[string]string), code: doc.Body.Inner.Code}
+	if resp.StatusCode == http.StatusOK && doc.Body.Inner.XMLName.Local != action+"Response" {
+		t.Errorf("%s: response element %s", action, doc.Body.Inner.XMLName.Local)
+	}
+	for _, v := range doc.Body.Inner.Values {
+		result.out[v.XMLName.Local] = v.Value
+	}
+	return result
+}
+
+// ok 要求动作成功并返回输出参数
+func ok(t *testing.T, srv *httptest.Server, s *service, action string, in args) map[string]string {
+	t.Helper()
+	res := call(t, srv, s, action, in)
+	if res.status != http.StatusOK {
+		t.Fatalf("%s: status %d, UPnP error %d", action, res.status, res.code)
+	}
+	return res.out
+}
+
+func transportState(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	return ok(t, srv, avTransportService, "GetTransportInfo", args{{"InstanceID", "0"}})["CurrentTransportState"]
+}
+
+// waitState 等待播放线程连接上网络流
+func waitState(t *testing.T, srv *httptest.Server, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		state := transportState(t, srv)
+		if state == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("transport state = %s, want %s", state, want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDescription(t *testing.T) {
+	r, srv := startRenderer(t)
+
+	resp, err := http.Get(srv.URL + "/description.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/xml") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var doc struct {
+		XMLName xml.Name `xml:"urn:schemas-upnp-org:device-1-0 root"`
+		Device  struct {
+			DeviceType   string `xml:"deviceType"`
+			FriendlyName string `xml:"friendlyName"`
+			UDN          string `xml:"UDN"`
+			Services     []struct {
+				ServiceType string `xml:"serviceType"`
+				SCPDURL     string `xml:"SCPDURL"`
+				ControlURL  string `xml:"controlURL"`
+			} `xml:"serviceList>service"`
+		} `xml:"device"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Device.DeviceType != deviceType || doc.Device.FriendlyName != "测试音箱" || doc.Device.UDN != r.udn {
+		t.Errorf("device = %+v", doc.Device)
+	}
+	if !strings.HasPrefix(r.udn, "uuid:") || r.udn != deviceUDN("测试音箱") {
+		t.Errorf("udn = %q is not stable", r.udn)
+	}
+
+	types := make(map[string]bool)
+	for _, s := range doc.Device.Services {
+		types[s.ServiceType] = true
+		// 描述中的每个服务文档都可以访问
+		scpd, err := http.Get(srv.URL + s.SCPDURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(scpd.Body)
+		scpd.Body.Close()
+		if scpd.StatusCode != http.StatusOK || !strings.Contains(string(data), "<actionList>") {
+			t.Errorf("%s: status %d", s.SCPDURL, scpd.StatusCode)
+		}
+	}
+	for _, want := range []string{avTransportType, renderingControlType, connectionManagerType} {
+		if !types[want] {
+			t.Errorf("service %s missing", want)
+		}
+	}
+}
+
+func TestAVTransportRoundTrip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffmpeg is a shell script")
+	}
+	// 用原样输出标准输入的脚本代替 ffmpeg，AAC 数据直接作为 PCM 播放
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte("#!/bin/sh\nexec /bin/cat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	if err := music.SetExternalDecoder(true); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { music.SetExternalDecoder(false) })
+
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// 没有长度的直播流，持续发送到连接断开
+		w.Header().Set("Content-Type", "audio/aac")
+		chunk := make([]byte, 4096)
+		chunk[0], chunk[1] = 0xFF, 0xF1 // ADTS 同步字
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+			select {
+			case <-req.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer media.Close()
+
+	_, srv := startRenderer(t)
+	instance := args{{"InstanceID", "0"}}
+
+	if state := transportState(t, srv); state != stateNoMedia {
+		t.Errorf("initial state = %s, want %s", state, stateNoMedia)
+	}
+
+	metadata := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+		`<item id="1" parentID="0" restricted="1"><dc:title>投屏 &amp; 测试</dc:title><upnp:artist>歌手</upnp:artist>` +
+		`<res duration="0:03:25.000" protocolInfo="http-get:*:audio/aac:*">` + media.URL + `</res></item></DIDL-Lite>`
+	ok(t, srv, avTransportService, "SetAVTransportURI", args{
+		{"InstanceID", "0"}, {"CurrentURI", media.URL}, {"CurrentURIMetaData", metadata},
+	})
+	if state := transportState(t, srv); state != stateStopped {
+		t.Errorf("state after SetAVTransportURI = %s, want %s", state, stateStopped)
+	}
+	info := ok(t, srv, avTransportService, "GetMediaInfo", instance)
+	if info["CurrentURI"] != media.URL || info["CurrentURIMetaData"] != metadata || info["MediaDuration"] != "0:03:25" {
+		t.Errorf("GetMediaInfo = %v", info)
+	}
+
+	ok(t, srv, avTransportService, "Play", args{{"InstanceID", "0"}, {"Speed", "1"}})
+	waitState(t, srv, statePlaying)
+	if actions := ok(t, srv, avTransportService, "GetCurrentTransportActions", instance)["Actions"]; actions != "Pause,Stop" {
+		t.Errorf("actions while playing = %q", actions)
+	}
+	position := ok(t, srv, avTransportService, "GetPositionInfo", instance)
+	if position["TrackURI"] != media.URL || position["Track"] != "1" {
+		t.Errorf("GetPositionInfo = %v", position)
+	}
+
+	ok(t, srv, avTransportService, "Pause", instance)
+	waitState(t, srv, statePaused)
+	ok(t, srv, avTransportService, "Play", args{{"InstanceID", "0"}, {"Speed", "1"}})
+	waitState(t, srv, statePlaying)
+	ok(t, srv, avTransportService, "Stop", instance)
+	waitState(t, srv, stateStopped)
+}
+
+func TestRenderingControl(t *testing.T) {
+	r, srv := startRenderer(t)
+	instance := args{{"InstanceID", "0"}}
+
+	if v := ok(t, srv, renderingControlService, "GetVolume", instance)["CurrentVolume"]; v != "40" {
+		t.Errorf("GetVolume = %s, want 40", v)
+	}
+	ok(t, srv, renderingControlService, "SetVolume", args{{"InstanceID", "0"}, {"Channel", "Master"}, {"DesiredVolume", "25"}})
+	if v := r.ctrl.Volume(); v != 25 {
+		t.Errorf("volume after SetVolume = %d, want 25", v)
+	}
+
+	// 静音后恢复原来的音量
+	ok(t, srv, renderingControlService, "SetMute", args{{"InstanceID", "0"}, {"Channel", "Master"}, {"DesiredMute", "1"}})
+	if v := ok(t, srv, renderingControlService, "GetMute", instance)["CurrentMute"]; v != "1" || r.ctrl.Volume() != 0 {
+		t.Errorf("after mute: CurrentMute = %s, volume = %d", v, r.ctrl.Volume())
+	}
+	ok(t, srv, renderingControlService, "SetMute", args{{"InstanceID", "0"}, {"Channel", "Master"}, {"DesiredMute", "false"}})
+	if v := r.ctrl.Volume(); v != 25 {
+		t.Errorf("volume after unmute = %d, want 25", v)
+	}
+}
+
+func TestGetProtocolInfo(t *testing.T) {
+	_, srv := startRenderer(t)
+	sink := ok(t, srv, connectionManagerService, "GetProtocolInfo", nil)["Sink"]
+	if !strings.Contains(sink, "audio/mpeg") || strings.Contains(sink, "audio/aac") {
+		t.Errorf("Sink without external decoder = %q", sink)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	// 任意可执行文件即可让外部解码通过检查
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	if err := music.SetExternalDecoder(true); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { music.SetExternalDecoder(false) })
+
+	sink = ok(t, srv, connectionManagerService, "GetProtocolInfo", nil)["Sink"]
+	if !strings.Contains(sink, "audio/aac") {
+		t.Errorf("Sink with external decoder = %q, want audio/aac", sink)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	_, srv := startRenderer(t)
+
+	tests := []struct {
+		name   string
+		s      *service
+		action string
+		in     args
+		code   int
+	}{
+		{"pause while stopped", avTransportService, "Pause", args{{"InstanceID", "0"}}, errTransitionInvalid},
+		{"previous", avTransportService, "Previous", args{{"InstanceID", "0"}}, errTransitionInvalid},
+		{"next without next uri", avTransportService, "Next", args{{"InstanceID", "0"}}, errTransitionInvalid},
+		{"play without media", avTransportService, "Play", args{{"InstanceID", "0"}, {"Speed", "1"}}, errNoContents},
+		{"local file uri", avTransportService, "SetAVTransportURI", args{{"InstanceID", "0"}, {"CurrentURI", "file:///etc/passwd"}, {"CurrentURIMetaData", ""}}, errUnsupportedFormat},
+		{"rtsp uri", avTransportService, "SetNextAVTransportURI", args{{"InstanceID", "0"}, {"NextURI", "rtsp://example.com/a"}, {"NextURIMetaData", ""}}, errUnsupportedFormat},
+		{"empty uri", avTransportService, "SetAVTransportURI", args{{"InstanceID", "0"}, {"CurrentURI", " "}, {"CurrentURIMetaData", ""}}, errInvalidArgs},
+		{"instance id", avTransportService, "GetTransportInfo", args{{"InstanceID", "1"}}, errInvalidInstanceID},
+		{"rendering instance id", renderingControlService, "GetVolume", args{{"InstanceID", "2"}, {"Channel", "Master"}}, errInvalidInstanceID},
+		{"seek mode", avTransportService, "Seek", args{{"InstanceID", "0"}, {"Unit", "TRACK_NR"}, {"Target", "1"}}, errSeekNotSupported},
+		{"seek target", avTransportService, "Seek", args{{"InstanceID", "0"}, {"Unit", "REL_TIME"}, {"Target", "soon"}}, errIllegalSeekTarget},
+		{"unknown action", avTransportService, "Record", args{{"InstanceID", "0"}}, errInvalidAction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := call(t, srv, tt.s, tt.action, tt.in)
+			if res.status != http.StatusInternalServerError || res.code != tt.code {
+				t.Errorf("status %d, UPnP error %d; want 500, %d", res.status, res.code, tt.code)
+			}
+		})
+	}
+
+	// 控制地址只接受 POST
+	resp, err := http.Get(srv.URL + avTransportService.controlURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET control status = %d", resp.StatusCode)
+	}
+}
+
+func TestReadAction(t *testing.T) {
+	envelope := func(body string) string {
+		return `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>` + body + `</s:Body></s:Envelope>`
+	}
+	tests := []struct {
+		name    string
+		header  string
+		body    string
+		action  string
+		params  args
+		wantErr bool
+	}{
+		{
+			name:   "header action",
+			header: `"urn:schemas-upnp-org:service:AVTransport:1#Play"`,
+			body:   envelope(`<u:Play xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><Speed>1</Speed></u:Play>`),
+			action: "Play",
+			params: args{{"InstanceID", "0"}, {"Speed", "1"}},
+		},
+		{
+			name:   "action from body without header",
+			body:   envelope(`<u:Stop xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID></u:Stop>`),
+			action: "Stop",
+			params: args{{"InstanceID", "0"}},
+		},
+		{
+			name:   "escaped metadata and empty argument",
+			header: `urn:schemas-upnp-org:service:AVTransport:1#SetAVTransportURI`,
+			body: envelope(`<u:SetAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID>` +
+				`<CurrentURI>http://h/a?x=1&amp;y=2</CurrentURI><CurrentURIMetaData>&lt;DIDL-Lite&gt;&lt;/DIDL-Lite&gt;</CurrentURIMetaData><Extra/></u:SetAVTransportURI>`),
+			action: "SetAVTransportURI",
+			params: args{{"InstanceID", "0"}, {"CurrentURI", "http://h/a?x=1&y=2"}, {"CurrentURIMetaData", "<DIDL-Lite></DIDL-Lite>"}, {"Extra", ""}},
+		},
+		{
+			name:   "no arguments",
+			header: `"urn:schemas-upnp-org:service:ConnectionManager:1#GetProtocolInfo"`,
+			body:   envelope(`<u:GetProtocolInfo xmlns:u="urn:schemas-upnp-org:service:ConnectionManager:1"/>`),
+			action: "GetProtocolInfo",
+		},
+		{
+			name:    "malformed xml",
+			header:  `"urn:schemas-upnp-org:service:AVTransport:1#Play"`,
+			body:    envelope(`<u:Play><InstanceID>0</u:Play>`),
+			wantErr: true,
+		},
+		{
+			name:    "missing action",
+			body:    envelope(""),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/AVTransport/control", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("SOAPACTION", tt.header)
+			}
+			action, params, err := readAction(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if action != tt.action || fmt.Sprint(params) != fmt.Sprint(tt.params) {
+				t.Errorf("readAction = %s %v, want %s %v", action, params, tt.action, tt.params)
+			}
+		})
+	}
+}
+
+func TestParseDIDL(t *testing.T) {
+	const ns = `xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/"`
+	tests := []struct {
+		name     string
+		metadata string
+		title    string
+		artist   string
+		duration time.Duration
+	}{
+		{
+			name:     "full item",
+			metadata: `<DIDL-Lite ` + ns + `><item><dc:title> 晴天 </dc:title><upnp:artist>周杰伦</upnp:artist><dc:creator>其他</dc:creator><res duration="0:04:29.500">http://h/a</res></item></DIDL-Lite>`,
+			title:    "晴天",
+			artist:   "周杰伦",
+			duration: 4*time.Minute + 29500*time.Millisecond,
+		},
+		{
+			name:     "creator fallback and invalid first res",
+			metadata: `<DIDL-Lite ` + ns + `><item><dc:title>a</dc:title><dc:creator>b</dc:creator><res duration="bad">x</res><res duration="1:00:00">y</res></item></DIDL-Lite>`,
+			title:    "a",
+			artist:   "b",
+			duration: time.Hour,
+		},
+		{
+			name:     "first of several items",
+			metadata: `<DIDL-Lite ` + ns + `><item><dc:title>one</dc:title></item><item><dc:title>two</dc:title></item></DIDL-Lite>`,
+			title:    "one",
+		},
+		{name: "container only", metadata: `<DIDL-Lite ` + ns + `><container><dc:title>c</dc:title></container></DIDL-Lite>`},
+		{name: "not xml", metadata: "NOT_IMPLEMENTED"},
+		{name: "empty", metadata: "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, artist, duration := parseDIDL(tt.metadata)
+			if title != tt.title || artist != tt.artist || duration != tt.duration {
+				t.Errorf("parseDIDL = %q, %q, %v; want %q, %q, %v", title, artist, duration, tt.title, tt.artist, tt.duration)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"0:00:00", 0, false},
+		{"0:03:25", 3*time.Minute + 25*time.Second, false},
+		{"01:02:03.250", time.Hour + 2*time.Minute + 3250*time.Millisecond, false},
+		{" 10:00:00 ", 10 * time.Hour, false},
+		{"3:25", 0, true},
+		{"a:b:c", 0, true},
+		{"", 0, true},
+		{"NOT_IMPLEMENTED", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseDuration(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v, err %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	// 格式化后可以解析回来（精确到秒）
+	for _, d := range []time.Duration{0, 59 * time.Second, 3*time.Hour + 7*time.Minute + 9*time.Second} {
+		if got, err := parseDuration(formatDuration(d)); err != nil || got != d {
+			t.Errorf("parseDuration(formatDuration(%v)) = %v, %v", d, got, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package upnp
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lisuiheng/xiaozhi-go/music"
+)
+
+// builtinProtocolInfo 网络流内置解码器可以播放的格式
+var builtinProtocolInfo = []string{
+	"http-get:*:audio/mpeg:*",
+	"http-get:*:audio/mp3:*",
+	"http-get:*:audio/ogg:*",
+	"http-get:*:application/ogg:*",
+	"http-get:*:audio/x-ogg:*",
+	"http-get:*:audio/opus:*",
+}
+
+// externalProtocolInfo 启用 ffmpeg 外部解码后额外支持的格式
+var externalProtocolInfo = []string{
+	"http-get:*:audio/aac:*",
+	"http-get:*:audio/aacp:*",
+	"http-get:*:audio/x-aac:*",
+	"http-get:*:audio/mp4:*",
+}
+
+// sinkProtocolInfo 可以播放的格式，与网络流解码器当前支持的格式一致
+func sinkProtocolInfo() string {
+	if music.ExternalDecoderEnabled() {
+		return strings.Join(append(slices.Clone(builtinProtocolInfo), externalProtocolInfo...), ",")
+	}
+	return strings.Join(builtinProtocolInfo, ",")
+}
+
+// renderingControl 处理 RenderingControl 服务的动作
+func (r *Renderer) renderingControl(name string, in args) (args, error) {
+	if err := checkInstance(in); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "GetVolume":
+		return args{{"CurrentVolume", strconv.Itoa(r.volume())}}, nil
+	case "SetVolume":
+		volume, err := parseVolume(in.get("DesiredVolume"))
+		if err != nil {
+			return nil, err
+		}
+		if err := r.ctrl.SetVolume(volume); err != nil {
+			return nil, newError(errActionFailed, "%v", err)
+		}
+		r.mu.Lock()
+		r.muted = false
+		r.mu.Unlock()
+		return nil, nil
+	case "GetMute":
+		r.mu.Lock()
+		muted := r.muted
+		r.mu.Unlock()
+		return args{{"CurrentMute", formatBool(muted)}}, nil
+	case "SetMute":
+		muted, err := parseBool(in.get("DesiredMute"))
+		if err != nil {
+			return nil, err
+		}
+		return nil, r.setMute(muted)
+	case "ListPresets":
+		return args{{"CurrentPresetNameList", "FactoryDefaults"}}, nil
+	case "SelectPreset":
+		if in.get("PresetName") != "FactoryDefaults" {
+			return nil, newError(errInvalidArgs, "unknown preset %s", in.get("PresetName"))
+		}
+		return nil, nil
+	}
+	return nil, newError(errInvalidAction, "unknown action %s", name)
+}
+
+// volume 当前音量，未知时按 100 报告
+func (r *Renderer) volume() int {
+	if v := r.ctrl.Volume(); v >= 0 {
+		return v
+	}
+	return 100
+}
+
+// setMute 设备没有单独的静音开关，静音时把音量设为 0，取消时恢复原音量
+func (r *Renderer) setMute(muted bool) error {
+	r.mu.Lock()
+	if r.muted == muted {
+		r.mu.Unlock()
+		return nil
+	}
+	restore := r.unmute
+	r.mu.Unlock()
+
+	target, saved := 0, r.volume()
+	if !muted {
+		target, saved = restore, -1
+		if target < 0 {
+			target = 100
+		}
+	}
+	if err := r.ctrl.SetVolume(target); err != nil {
+		return newError(errActionFailed, "%v", err)
+	}
+
+	r.mu.Lock()
+	r.muted, r.unmute = muted, saved
+	r.mu.Unlock()
+	return nil
+}
+
+// connectionManager 处理 ConnectionManager 服务的动作，只有一个固定的连接 0
+func (r *Renderer) connectionManager(name string, in args) (args, error) {
+	switch name {
+	case "GetProtocolInfo":
+		return args{{"Source", ""}, {"Sink", sinkProtocolInfo()}}, nil
+	case "GetCurrentConnectionIDs":
+		return args{{"ConnectionIDs", "0"}}, nil
+	case "GetCurrentConnectionInfo":
+		if id := strings.TrimSpace(in.get("ConnectionID")); id != "0" {
+			return nil, newError(errInvalidArgs, "invalid connection id %s", id)
+		}
+		return args{
+			{"RcsID", "0"},
+			{"AVTransportID", "0"},
+			{"ProtocolInfo", ""},
+			{"PeerConnectionManager", ""},
+			{"PeerConnectionID", "-1"},
+			{"Direction", "Input"},
+			{"Status", "OK"},
+		}, nil
+	}
+	return nil, newError(errInvalidAction, "unknown action %s", name)
+}
+
+// parseVolume 解析 0-100 的音量
+func parseVolume(s string) (int, error) {
+	v, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || v < 0 || v > 100 {
+		return 0, newError(errInvalidArgs, "invalid volume %q", s)
+	}
+	return v, nil
+}
+
+// parseBool 解析 UPnP boolean：0/1、true/false、yes/no
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "yes":
+		return true, nil
+	case "0", "false", "no":
+		return false, nil
+	}
+	return false, newError(errInvalidArgs, "invalid boolean %q", s)
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
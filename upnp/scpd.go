@@ -0,0 +1,253 @@
+package upnp
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// 服务类型与 ID
+const (
+	deviceType = "urn:schemas-upnp-org:device:MediaRenderer:1"
+
+	avTransportType       = "urn:schemas-upnp-org:service:AVTransport:1"
+	renderingControlType  = "urn:schemas-upnp-org:service:RenderingControl:1"
+	connectionManagerType = "urn:schemas-upnp-org:service:ConnectionManager:1"
+)
+
+// service 设备提供的一个服务：描述、控制和事件地址由名称生成
+type service struct {
+	name        string // AVTransport、RenderingControl、ConnectionManager
+	serviceType string
+	actions     []action
+	vars        []stateVar
+}
+
+func (s *service) scpdURL() string    { return "/" + s.name + "/scpd.xml" }
+func (s *service) controlURL() string { return "/" + s.name + "/control" }
+func (s *service) eventURL() string   { return "/" + s.name + "/event" }
+
+// action 服务动作，参数为 in/out 方向和关联的状态变量
+type action struct {
+	name string
+	in   []string // 参数名:状态变量
+	out  []string
+}
+
+// stateVar 状态变量
+type stateVar struct {
+	name     string
+	dataType string
+	events   bool
+	allowed  []string
+}
+
+// instanceID 所有 AVTransport/RenderingControl 动作的第一个参数
+const instanceID = "InstanceID:A_ARG_TYPE_InstanceID"
+
+var avTransportService = &service{
+	name:        "AVTransport",
+	serviceType: avTransportType,
+	actions: []action{
+		{name: "SetAVTransportURI", in: []string{instanceID, "CurrentURI:AVTransportURI", "CurrentURIMetaData:AVTransportURIMetaData"}},
+		{name: "SetNextAVTransportURI", in: []string{instanceID, "NextURI:NextAVTransportURI", "NextURIMetaData:NextAVTransportURIMetaData"}},
+		{name: "GetMediaInfo", in: []string{instanceID}, out: []string{
+			"NrTracks:NumberOfTracks", "MediaDuration:CurrentMediaDuration", "CurrentURI:AVTransportURI",
+			"CurrentURIMetaData:AVTransportURIMetaData", "NextURI:NextAVTransportURI", "NextURIMetaData:NextAVTransportURIMetaData",
+			"PlayMedium:PlaybackStorageMedium", "RecordMedium:RecordStorageMedium", "WriteStatus:RecordMediumWriteStatus"}},
+		{name: "GetTransportInfo", in: []string{instanceID}, out: []string{
+			"CurrentTransportState:TransportState", "CurrentTransportStatus:TransportStatus", "CurrentSpeed:TransportPlaySpeed"}},
+		{name: "GetPositionInfo", in: []string{instanceID}, out: []string{
+			"Track:CurrentTrack", "TrackDuration:CurrentTrackDuration", "TrackMetaData:CurrentTrackMetaData",
+			"TrackURI:CurrentTrackURI", "RelTime:RelativeTimePosition", "AbsTime:AbsoluteTimePosition",
+			"RelCount:RelativeCounterPosition", "AbsCount:AbsoluteCounterPosition"}},
+		{name: "GetDeviceCapabilities", in: []string{instanceID}, out: []string{
+			"PlayMedia:PossiblePlaybackStorageMedia", "RecMedia:PossibleRecordStorageMedia", "RecQualityModes:PossibleRecordQualityModes"}},
+		{name: "GetTransportSettings", in: []string{instanceID}, out: []string{
+			"PlayMode:CurrentPlayMode", "RecQualityMode:CurrentRecordQualityMode"}},
+		{name: "GetCurrentTransportActions", in: []string{instanceID}, out: []string{"Actions:CurrentTransportActions"}},
+		{name: "Stop", in: []string{instanceID}},
+		{name: "Play", in: []string{instanceID, "Speed:TransportPlaySpeed"}},
+		{name: "Pause", in: []string{instanceID}},
+		{name: "Seek", in: []string{instanceID, "Unit:A_ARG_TYPE_SeekMode", "Target:A_ARG_TYPE_SeekTarget"}},
+		{name: "Next", in: []string{instanceID}},
+		{name: "Previous", in: []string{instanceID}},
+	},
+	vars: []stateVar{
+		{name: "TransportState", dataType: "string", allowed: []string{"STOPPED", "PLAYING", "PAUSED_PLAYBACK", "TRANSITIONING", "NO_MEDIA_PRESENT"}},
+		{name: "TransportStatus", dataType: "string", allowed: []string{"OK", "ERROR_OCCURRED"}},
+		{name: "PlaybackStorageMedium", dataType: "string", allowed: []string{"NONE", "NETWORK"}},
+		{name: "RecordStorageMedium", dataType: "string", allowed: []string{"NOT_IMPLEMENTED"}},
+		{name: "PossiblePlaybackStorageMedia", dataType: "string"},
+		{name: "PossibleRecordStorageMedia", dataType: "string"},
+		{name: "PossibleRecordQualityModes", dataType: "string"},
+		{name: "CurrentPlayMode", dataType: "string", allowed: []string{"NORMAL"}},
+		{name: "TransportPlaySpeed", dataType: "string", allowed: []string{"1"}},
+		{name: "RecordMediumWriteStatus", dataType: "string", allowed: []string{"NOT_IMPLEMENTED"}},
+		{name: "CurrentRecordQualityMode", dataType: "string", allowed: []string{"NOT_IMPLEMENTED"}},
+		{name: "NumberOfTracks", dataType: "ui4"},
+		{name: "CurrentTrack", dataType: "ui4"},
+		{name: "CurrentTrackDuration", dataType: "string"},
+		{name: "CurrentMediaDuration", dataType: "string"},
+		{name: "CurrentTrackMetaData", dataType: "string"},
+		{name: "CurrentTrackURI", dataType: "string"},
+		{name: "AVTransportURI", dataType: "string"},
+		{name: "AVTransportURIMetaData", dataType: "string"},
+		{name: "NextAVTransportURI", dataType: "string"},
+		{name: "NextAVTransportURIMetaData", dataType: "string"},
+		{name: "RelativeTimePosition", dataType: "string"},
+		{name: "AbsoluteTimePosition", dataType: "string"},
+		{name: "RelativeCounterPosition", dataType: "i4"},
+		{name: "AbsoluteCounterPosition", dataType: "i4"},
+		{name: "CurrentTransportActions", dataType: "string"},
+		{name: "LastChange", dataType: "string", events: true},
+		{name: "A_ARG_TYPE_SeekMode", dataType: "string", allowed: []string{"REL_TIME", "ABS_TIME"}},
+		{name: "A_ARG_TYPE_SeekTarget", dataType: "string"},
+		{name: "A_ARG_TYPE_InstanceID", dataType: "ui4"},
+	},
+}
+
+var renderingControlService = &service{
+	name:        "RenderingControl",
+	serviceType: renderingControlType,
+	actions: []action{
+		{name: "ListPresets", in: []string{instanceID}, out: []string{"CurrentPresetNameList:PresetNameList"}},
+		{name: "SelectPreset", in: []string{instanceID, "PresetName:A_ARG_TYPE_PresetName"}},
+		{name: "GetVolume", in: []string{instanceID, "Channel:A_ARG_TYPE_Channel"}, out: []string{"CurrentVolume:Volume"}},
+		{name: "SetVolume", in: []string{instanceID, "Channel:A_ARG_TYPE_Channel", "DesiredVolume:Volume"}},
+		{name: "GetMute", in: []string{instanceID, "Channel:A_ARG_TYPE_Channel"}, out: []string{"CurrentMute:Mute"}},
+		{name: "SetMute", in: []string{instanceID, "Channel:A_ARG_TYPE_Channel", "DesiredMute:Mute"}},
+	},
+	vars: []stateVar{
+		{name: "PresetNameList", dataType: "string"},
+		{name: "Volume", dataType: "ui2"},
+		{name: "Mute", dataType: "boolean"},
+		{name: "LastChange", dataType: "string", events: true},
+		{name: "A_ARG_TYPE_Channel", dataType: "string", allowed: []string{"Master"}},
+		{name: "A_ARG_TYPE_PresetName", dataType: "string", allowed: []string{"FactoryDefaults"}},
+		{name: "A_ARG_TYPE_InstanceID", dataType: "ui4"},
+	},
+}
+
+var connectionManagerService = &service{
+	name:        "ConnectionManager",
+	serviceType: connectionManagerType,
+	actions: []action{
+		{name: "GetProtocolInfo", out: []string{"Source:SourceProtocolInfo", "Sink:SinkProtocolInfo"}},
+		{name: "GetCurrentConnectionIDs", out: []string{"ConnectionIDs:CurrentConnectionIDs"}},
+		{name: "GetCurrentConnectionInfo", in: []string{"ConnectionID:A_ARG_TYPE_ConnectionID"}, out: []string{
+			"RcsID:A_ARG_TYPE_RcsID", "AVTransportID:A_ARG_TYPE_AVTransportID", "ProtocolInfo:A_ARG_TYPE_ProtocolInfo",
+			"PeerConnectionManager:A_ARG_TYPE_ConnectionManager", "PeerConnectionID:A_ARG_TYPE_ConnectionID",
+			"Direction:A_ARG_TYPE_Direction", "Status:A_ARG_TYPE_ConnectionStatus"}},
+	},
+	vars: []stateVar{
+		{name: "SourceProtocolInfo", dataType: "string", events: true},
+		{name: "SinkProtocolInfo", dataType: "string", events: true},
+		{name: "CurrentConnectionIDs", dataType: "string", events: true},
+		{name: "A_ARG_TYPE_ConnectionStatus", dataType: "string", allowed: []string{"OK", "ContentFormatMismatch", "InsufficientBandwidth", "UnreliableChannel", "Unknown"}},
+		{name: "A_ARG_TYPE_ConnectionManager", dataType: "string"},
+		{name: "A_ARG_TYPE_Direction", dataType: "string", allowed: []string{"Input", "Output"}},
+		{name: "A_ARG_TYPE_ProtocolInfo", dataType: "string"},
+		{name: "A_ARG_TYPE_ConnectionID", dataType: "i4"},
+		{name: "A_ARG_TYPE_AVTransportID", dataType: "i4"},
+		{name: "A_ARG_TYPE_RcsID", dataType: "i4"},
+	},
+}
+
+var services = []*service{avTransportService, renderingControlService, connectionManagerService}
+
+// scpd 按服务表生成服务描述 XML
+func (s *service) scpd() []byte {
+	type argument struct {
+		Name      string `xml:"name"`
+		Direction string `xml:"direction"`
+		Related   string `xml:"relatedStateVariable"`
+	}
+	type xmlAction struct {
+		Name      string     `xml:"name"`
+		Arguments []argument `xml:"argumentList>argument,omitempty"`
+	}
+	type xmlVar struct {
+		SendEvents string   `xml:"sendEvents,attr"`
+		Name       string   `xml:"name"`
+		DataType   string   `xml:"dataType"`
+		Allowed    []string `xml:"allowedValueList>allowedValue,omitempty"`
+	}
+	type doc struct {
+		XMLName xml.Name    `xml:"urn:schemas-upnp-org:service-1-0 scpd"`
+		Major   int         `xml:"specVersion>major"`
+		Minor   int         `xml:"specVersion>minor"`
+		Actions []xmlAction `xml:"actionList>action"`
+		Vars    []xmlVar    `xml:"serviceStateTable>stateVariable"`
+	}
+
+	d := doc{Major: 1, Minor: 0}
+	for _, a := range s.actions {
+		xa := xmlAction{Name: a.name}
+		for dir, list := range [][]string{a.in, a.out} {
+			direction := "in"
+			if dir == 1 {
+				direction = "out"
+			}
+			for _, spec := range list {
+				name, related, _ := strings.Cut(spec, ":")
+				xa.Arguments = append(xa.Arguments, argument{Name: name, Direction: direction, Related: related})
+			}
+		}
+		d.Actions = append(d.Actions, xa)
+	}
+	for _, v := range s.vars {
+		events := "no"
+		if v.events {
+			events = "yes"
+		}
+		d.Vars = append(d.Vars, xmlVar{SendEvents: events, Name: v.name, DataType: v.dataType, Allowed: v.allowed})
+	}
+
+	data, _ := xml.MarshalIndent(d, "", "  ")
+	return append([]byte(xml.Header), data...)
+}
+
+// deviceDescription 设备描述 XML
+func deviceDescription(name, udn string) []byte {
+	type xmlService struct {
+		ServiceType string `xml:"serviceType"`
+		ServiceID   string `xml:"serviceId"`
+		SCPDURL     string `xml:"SCPDURL"`
+		ControlURL  string `xml:"controlURL"`
+		EventSubURL string `xml:"eventSubURL"`
+	}
+	type device struct {
+		DeviceType   string       `xml:"deviceType"`
+		FriendlyName string       `xml:"friendlyName"`
+		Manufacturer string       `xml:"manufacturer"`
+		ModelName    string       `xml:"modelName"`
+		UDN          string       `xml:"UDN"`
+		Services     []xmlService `xml:"serviceList>service"`
+	}
+	type doc struct {
+		XMLName xml.Name `xml:"urn:schemas-upnp-org:device-1-0 root"`
+		Major   int      `xml:"specVersion>major"`
+		Minor   int      `xml:"specVersion>minor"`
+		Device  device   `xml:"device"`
+	}
+
+	d := doc{Major: 1, Minor: 0, Device: device{
+		DeviceType:   deviceType,
+		FriendlyName: name,
+		Manufacturer: "xiaozhi-go",
+		ModelName:    "xiaozhi-go MediaRenderer",
+		UDN:          udn,
+	}}
+	for _, s := range services {
+		d.Device.Services = append(d.Device.Services, xmlService{
+			ServiceType: s.serviceType,
+			ServiceID:   "urn:upnp-org:serviceId:" + s.name,
+			SCPDURL:     s.scpdURL(),
+			ControlURL:  s.controlURL(),
+			EventSubURL: s.eventURL(),
+		})
+	}
+
+	data, _ := xml.MarshalIndent(d, "", "  ")
+	return append([]byte(xml.Header), data...)
+}
@@ -0,0 +1,141 @@
+package upnp
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// UPnP 错误码
+const (
+	errInvalidAction     = 401
+	errInvalidArgs       = 402
+	errActionFailed      = 501
+	errTransitionInvalid = 701 // 当前状态不允许该操作
+	errNoContents        = 702
+	errSeekNotSupported  = 710
+	errIllegalSeekTarget = 711
+	errUnsupportedFormat = 714
+	errInvalidInstanceID = 718
+)
+
+// upnpError 以 SOAP Fault 返回给控制点的错误
+type upnpError struct {
+	code int
+	desc string
+}
+
+func (e *upnpError) Error() string {
+	return fmt.Sprintf("UPnP error %d: %s", e.code, e.desc)
+}
+
+func newError(code int, format string, args ...interface{}) error {
+	return &upnpError{code: code, desc: fmt.Sprintf(format, args...)}
+}
+
+// args SOAP 请求或响应的参数，按顺序输出
+type args []arg
+
+type arg struct {
+	name, value string
+}
+
+// get 按名称取参数，不存在时返回空字符串
+func (a args) get(name string) string {
+	for _, v := range a {
+		if v.name == name {
+			return v.value
+		}
+	}
+	return ""
+}
+
+// readAction 解析 SOAP 请求，返回动作名和参数
+func readAction(r *http.Request) (string, args, error) {
+	// SOAPACTION: "urn:schemas-upnp-org:service:AVTransport:1#Play"
+	header := strings.Trim(r.Header.Get("SOAPACTION"), `"`)
+	_, name, _ := strings.Cut(header, "#")
+
+	dec := xml.NewDecoder(io.LimitReader(r.Body, maxRequestSize))
+	var params args
+	depth, actionDepth := 0, -1
+	var current string
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid SOAP request: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case actionDepth < 0 && depth == 3: // Envelope > Body > 动作
+				actionDepth = depth
+				if name == "" {
+					name = t.Name.Local
+				}
+			case actionDepth > 0 && depth == actionDepth+1:
+				current = t.Name.Local
+				text.Reset()
+			}
+		case xml.CharData:
+			if current != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if current != "" && depth == actionDepth+1 {
+				params = append(params, arg{current, text.String()})
+				current = ""
+			}
+			depth--
+		}
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("missing SOAP action")
+	}
+	return name, params, nil
+}
+
+// writeResponse 输出 SOAP 响应
+func writeResponse(w http.ResponseWriter, serviceType, action string, out args) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&b, `<u:%sResponse xmlns:u="%s">`, action, serviceType)
+	for _, a := range out {
+		fmt.Fprintf(&b, "<%s>%s</%s>", a.name, escape(a.value), a.name)
+	}
+	fmt.Fprintf(&b, `</u:%sResponse></s:Body></s:Envelope>`, action)
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.Header().Set("EXT", "")
+	io.WriteString(w, b.String())
+}
+
+// writeFault 输出 SOAP Fault
+func writeFault(w http.ResponseWriter, err error) {
+	code, desc := errActionFailed, err.Error()
+	if ue, ok := err.(*upnpError); ok {
+		code, desc = ue.code, ue.desc
+	}
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `%s<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`+
+		`<s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail>`+
+		`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode><errorDescription>%s</errorDescription></UPnPError>`+
+		`</detail></s:Fault></s:Body></s:Envelope>`, xml.Header, code, escape(desc))
+}
+
+// escape 转义 XML 文本
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
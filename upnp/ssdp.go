@@ -0,0 +1,217 @@
+package upnp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SSDP 参数
+const (
+	ssdpAddress  = "239.255.255.250:1900"
+	ssdpMaxAge   = 1800
+	ssdpMaxDelay = time.Second // M-SEARCH 响应的最大随机延迟
+)
+
+// ssdpServer 在组播地址上响应 M-SEARCH 搜索，并定期广播设备上线
+type ssdpServer struct {
+	udn    string
+	port   int
+	logger *slog.Logger
+	group  *net.UDPAddr
+	conn   *net.UDPConn
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newSSDPServer(udn string, port int, logger *slog.Logger) (*ssdpServer, error) {
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join SSDP multicast group: %w", err)
+	}
+	return &ssdpServer{
+		udn:    udn,
+		port:   port,
+		logger: logger,
+		group:  group,
+		conn:   conn,
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// start 开始响应搜索并广播上线
+func (s *ssdpServer) start() {
+	s.wg.Add(2)
+	go s.readLoop()
+	go s.advertiseLoop()
+}
+
+// close 广播下线并停止
+func (s *ssdpServer) close() {
+	close(s.done)
+	s.conn.Close()
+	s.wg.Wait()
+	s.broadcast("ssdp:byebye")
+}
+
+// targets 设备宣告的通知类型：根设备、设备 UUID、设备类型和各服务类型
+func (s *ssdpServer) targets() []string {
+	targets := []string{"upnp:rootdevice", s.udn, deviceType}
+	for _, svc := range services {
+		targets = append(targets, svc.serviceType)
+	}
+	return targets
+}
+
+// usn 通知类型对应的唯一服务名
+func (s *ssdpServer) usn(target string) string {
+	if target == s.udn {
+		return s.udn
+	}
+	return s.udn + "::" + target
+}
+
+// location 从 ip 可访问的设备描述地址
+func (s *ssdpServer) location(ip net.IP) string {
+	return fmt.Sprintf("http://%s/description.xml", net.JoinHostPort(ip.String(), strconv.Itoa(s.port)))
+}
+
+// readLoop 处理 M-SEARCH 请求
+func (s *ssdpServer) readLoop() {
+	defer s.wg.Done()
+	buf := make([]byte, 2048)
+	for {
+		n, remote, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				s.logger.Warn("SSDP read failed", "error", err)
+			}
+			return
+		}
+		req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buf[:n])))
+		if err != nil || req.Method != "M-SEARCH" || req.Header.Get("MAN") != `"ssdp:discover"` {
+			continue
+		}
+		var matched []string
+		st := req.Header.Get("ST")
+		for _, target := range s.targets() {
+			if st == "ssdp:all" || st == target {
+				matched = append(matched, target)
+			}
+		}
+		if len(matched) > 0 {
+			go s.respond(remote, matched, searchDelay(req.Header.Get("MX")))
+		}
+	}
+}
+
+// searchDelay 按 MX 随机延迟响应，避免同时回复
+func searchDelay(mx string) time.Duration {
+	max := ssdpMaxDelay
+	if n, err := strconv.Atoi(mx); err == nil && n >= 0 && time.Duration(n)*time.Second < max {
+		max = time.Duration(n) * time.Second
+	}
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// respond 单播回复搜索者
+func (s *ssdpServer) respond(remote *net.UDPAddr, targets []string, delay time.Duration) {
+	select {
+	case <-time.After(delay):
+	case <-s.done:
+		return
+	}
+	ip, err := localIP(remote)
+	if err != nil {
+		s.logger.Debug("SSDP no route to searcher", "remote", remote.String(), "error", err)
+		return
+	}
+	for _, target := range targets {
+		msg := fmt.Sprintf("HTTP/1.1 200 OK\r\n"+
+			"CACHE-CONTROL: max-age=%d\r\n"+
+			"DATE: %s\r\n"+
+			"EXT:\r\n"+
+			"LOCATION: %s\r\n"+
+			"SERVER: %s\r\n"+
+			"ST: %s\r\n"+
+			"USN: %s\r\n\r\n",
+			ssdpMaxAge, time.Now().UTC().Format(http.TimeFormat), s.location(ip), serverHeader, target, s.usn(target))
+		if _, err := s.conn.WriteToUDP([]byte(msg), remote); err != nil {
+			s.logger.Debug("SSDP response failed", "remote", remote.String(), "error", err)
+			return
+		}
+	}
+}
+
+// advertiseLoop 启动时和每半个 max-age 广播一次上线
+func (s *ssdpServer) advertiseLoop() {
+	defer s.wg.Done()
+	s.broadcast("ssdp:alive")
+	ticker := time.NewTicker(ssdpMaxAge * time.Second / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.broadcast("ssdp:alive")
+		}
+	}
+}
+
+// broadcast 向组播地址发送 NOTIFY
+func (s *ssdpServer) broadcast(nts string) {
+	conn, err := net.DialUDP("udp4", nil, s.group)
+	if err != nil {
+		s.logger.Debug("SSDP notify failed", "error", err)
+		return
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+
+	for _, target := range s.targets() {
+		msg := fmt.Sprintf("NOTIFY * HTTP/1.1\r\n"+
+			"HOST: %s\r\n"+
+			"NT: %s\r\n"+
+			"NTS: %s\r\n"+
+			"USN: %s\r\n", ssdpAddress, target, nts, s.usn(target))
+		if nts == "ssdp:alive" {
+			msg += fmt.Sprintf("CACHE-CONTROL: max-age=%d\r\nLOCATION: %s\r\nSERVER: %s\r\n",
+				ssdpMaxAge, s.location(ip), serverHeader)
+		}
+		if _, err := conn.Write([]byte(msg + "\r\n")); err != nil {
+			s.logger.Debug("SSDP notify failed", "nts", nts, "error", err)
+			return
+		}
+	}
+}
+
+// localIP 与 remote 通信时使用的本机地址
+func localIP(remote *net.UDPAddr) (net.IP, error) {
+	conn, err := net.DialUDP("udp4", nil, remote)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	if ip.IsUnspecified() {
+		return nil, fmt.Errorf("no local address")
+	}
+	return ip, nil
+}
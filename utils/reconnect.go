@@ -27,3 +27,7 @@ func (e *ExponentialBackoff) NextDelay() time.Duration {
 	}
 	return delay
 }
+
+func (e *ExponentialBackoff) Reset() {
+	e.currentDelay = 1 * time.Second
+}